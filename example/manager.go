@@ -22,6 +22,8 @@ import (
 type OoyalaServerConfig struct {
 	JenkinsURI string `toml:"jenkins_uri"`
 	SimpleBuilderHost string `toml:"simple_builder_host"`
+	// the manager API as the builder sees it, to call back when builds are done (empty to poll)
+	BuilderCallbackURL string `toml:"builder_callback_url"`
 }
 
 func main() {
@@ -35,11 +37,15 @@ func main() {
 	var bldr builder.Builder
 	if config.JenkinsURI != "" {
 		log.Printf("Initializing Jenkins Builder with URI: %s", config.JenkinsURI)
-		bldr = builder.NewJenkinsBuilder(config.JenkinsURI, "Jenkins Builder")
+		jenkinsBuilder := builder.NewJenkinsBuilder(config.JenkinsURI, "Jenkins Builder")
+		jenkinsBuilder.CallbackURL = config.BuilderCallbackURL
+		bldr = jenkinsBuilder
 	} else if config.SimpleBuilderHost != "" {
 		uri := "http://" + config.SimpleBuilderHost
 		log.Printf("Initializing Simple Builder with URI: %s", uri)
-		bldr = builder.NewSimpleBuilder(uri, 120*time.Second)
+		simpleBuilder := builder.NewSimpleBuilder(uri, 120*time.Second)
+		simpleBuilder.CallbackURL = config.BuilderCallbackURL
+		bldr = simpleBuilder
 	} else {
 		log.Printf("No builder configured")
 	}
@@ -15,15 +15,19 @@ import (
 	graph "atlantis/manager/api/graph"
 	"atlantis/manager/crypto"
 	"atlantis/manager/rpc"
+	. "atlantis/manager/rpc/types"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"github.com/cespare/go-apachelog"
 	"github.com/gorilla/mux"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -42,6 +46,10 @@ var (
 )
 
 func NotFound(w http.ResponseWriter, r *http.Request) {
+	if isV2(r) {
+		v2NotFound(w, r)
+		return
+	}
 	w.Header().Set("Content-Type", "text/html")
 	w.WriteHeader(http.StatusNotFound)
 	fmt.Fprint(w, notFoundHTML)
@@ -53,166 +61,218 @@ func serverError(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, serverErrorHTML)
 }
 
-func Init(listenAddr string) error {
-	gmux := mux.NewRouter() // Use gorilla mux for APIs to make things easier
-
-	gmux.NotFoundHandler = http.HandlerFunc(NotFound)
-	// APIs should go here
-	gmux.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, staticDir+"/img/favicon.ico")
-	})
-	gmux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		http.Redirect(w, r, staticDir+"/dashboard/#dashboard", 302)
-	})
+// apiRoute is an endpoint of the REST API. RPC is the RPC its handler calls, whose arg and reply describe the
+// endpoint in the OpenAPI document; it is empty for the few that do not call one.
+type apiRoute struct {
+	Method  string
+	Path    string
+	Handler http.HandlerFunc
+	RPC     string
+}
 
+var apiRoutes = []apiRoute{
 	// Login
-	gmux.HandleFunc("/login", Login).Methods("POST")
+	{"POST", "/login", Login, "Login"},
+	{"POST", "/logout", Logout, "Logout"},
 
 	// Task Management
-	gmux.HandleFunc("/tasks", ListTaskIDs).Methods("GET")
-	gmux.HandleFunc("/tasks/{ID}", GetTaskStatus).Methods("GET")
+	{"GET", "/tasks", ListTaskIDs, "ListTaskIDs"},
+	{"GET", "/tasks/queue", GetTaskQueue, "TaskQueue"},
+	{"GET", "/tasks/query", QueryTasks, "QueryTasks"},
+	{"GET", "/tasks/{ID}", GetTaskStatus, "Status"},
+	{"GET", "/tasks/{ID}/stream", StreamTask, "TaskLog"},
+
+	// Scheduled Jobs
+	{"GET", "/jobs", ListJobs, "ListJobs"},
+	{"POST", "/jobs", ScheduleJob, "ScheduleJob"},
+	{"DELETE", "/jobs/{ID}", DeleteJob, "DeleteJob"},
+	{"POST", "/jobs/{ID}/pause", PauseJob, "PauseJob"},
+	{"POST", "/jobs/{ID}/resume", ResumeJob, "PauseJob"},
+
+	// API Tokens
+	{"GET", "/tokens", ListTokens, "ListTokens"},
+	{"POST", "/tokens", CreateToken, "CreateToken"},
+	{"DELETE", "/tokens/{ID}", RevokeToken, "RevokeToken"},
+
+	// Policies
+	{"GET", "/policies", ListPolicies, "ListPolicies"},
+	{"POST", "/policies", AddPolicy, "AddPolicy"},
+	{"DELETE", "/policies/{ID}", RemovePolicy, "RemovePolicy"},
+	{"GET", "/can-i", CanI, "CanI"},
+
+	// Approvals
+	{"GET", "/approvals", ListApprovals, "ListApprovals"},
+	{"POST", "/approvals/{ID}/approve", ApproveTask, "ApproveTask"},
+	{"POST", "/approvals/{ID}/reject", RejectTask, "ApproveTask"},
+
+	// Audit Log
+	{"GET", "/audit", Audit, "Audit"},
 
 	// Manager Management
-	gmux.HandleFunc("/health", Health).Methods("GET")
-	gmux.HandleFunc("/usage", Usage).Methods("GET")
-	gmux.HandleFunc("/managers", ListManagers).Methods("GET")
-	gmux.HandleFunc("/managers/{Region}/{Host}", GetManager).Methods("GET")
-	gmux.HandleFunc("/managers/{Region}/{Host}", RegisterManager).Methods("PUT")
-	gmux.HandleFunc("/managers/{Region}/{Host}", UnregisterManager).Methods("DELETE")
-	gmux.HandleFunc("/managers/{Region}/{Host}/roles/{Role}", AddRole).Methods("PUT")
-	gmux.HandleFunc("/managers/{Region}/{Host}/roles/{Role}", RemoveRole).Methods("DELETE")
-	gmux.HandleFunc("/managers/{Region}/{Host}/roles/{Role}/{Type}", AddRoleType).Methods("PUT")
-	gmux.HandleFunc("/managers/{Region}/{Host}/roles/{Role}/{Type}", RemoveRoleType).Methods("DELETE")
-	gmux.HandleFunc("/managers/self", GetSelf).Methods("GET")
+	{"GET", "/health", Health, "HealthCheck"},
+	{"GET", "/usage", Usage, "Usage"},
+	{"GET", "/cache", CacheStatsGet, "CacheStats"},
+	{"GET", "/ratelimits", RateLimits, "RateLimits"},
+	{"GET", "/managers", ListManagers, "ListManagers"},
+	{"GET", "/managers/{Region}/{Host}", GetManager, "GetManager"},
+	{"PUT", "/managers/{Region}/{Host}", RegisterManager, "RegisterManager"},
+	{"DELETE", "/managers/{Region}/{Host}", UnregisterManager, "UnregisterManager"},
+	{"PUT", "/managers/{Region}/{Host}/roles/{Role}", AddRole, "AddRole"},
+	{"DELETE", "/managers/{Region}/{Host}/roles/{Role}", RemoveRole, "RemoveRole"},
+	{"PUT", "/managers/{Region}/{Host}/roles/{Role}/{Type}", AddRoleType, "AddRole"},
+	{"DELETE", "/managers/{Region}/{Host}/roles/{Role}/{Type}", RemoveRoleType, "RemoveRole"},
+	{"GET", "/managers/self", GetSelf, "GetSelf"},
 
 	// Supervisor Management
-	gmux.HandleFunc("/supervisors", ListSupervisors).Methods("GET")
-	gmux.HandleFunc("/supervisors/{Host}", RegisterSupervisor).Methods("PUT")
-	gmux.HandleFunc("/supervisors/{Host}", UnregisterSupervisor).Methods("DELETE")
+	{"GET", "/supervisors", ListSupervisors, "ListSupervisors"},
+	{"PUT", "/supervisors/{Host}", RegisterSupervisor, "RegisterSupervisor"},
+	{"DELETE", "/supervisors/{Host}", UnregisterSupervisor, "UnregisterSupervisor"},
 
 	// Router Management
-	gmux.HandleFunc("/routers", ListRouters).Methods("GET")
-	gmux.HandleFunc("/routers/{Zone}/{Host}", GetRouter).Methods("GET")
-	gmux.HandleFunc("/routers/{Zone}/{Host}", RegisterRouter).Methods("PUT")
-	gmux.HandleFunc("/routers/{Zone}/{Host}", UnregisterRouter).Methods("DELETE")
+	{"GET", "/routers", ListRouters, "ListRouters"},
+	{"GET", "/routers/{Zone}/{Host}", GetRouter, "GetRouter"},
+	{"PUT", "/routers/{Zone}/{Host}", RegisterRouter, "RegisterRouter"},
+	{"DELETE", "/routers/{Zone}/{Host}", UnregisterRouter, "UnregisterRouter"},
 
 	// App Management
-	gmux.HandleFunc("/apps", ListRegisteredApps).Methods("GET")
-	gmux.HandleFunc("/apps/{App}", GetApp).Methods("GET")
-	gmux.HandleFunc("/apps/{App}", RegisterApp).Methods("PUT")
-	gmux.HandleFunc("/apps/{App}", UpdateApp).Methods("POST")
-	gmux.HandleFunc("/apps/{App}", UnregisterApp).Methods("DELETE")
-	gmux.HandleFunc("/apps/{App}/env/{Env}", AddDependerEnvData).Methods("PUT")
-	gmux.HandleFunc("/apps/{App}/env/{Env}", GetDependerEnvData).Methods("GET")
-	gmux.HandleFunc("/apps/{App}/env/{Env}", RemoveDependerEnvData).Methods("DELETE")
-	gmux.HandleFunc("/apps/{App}/depender/{Depender}", AddDependerAppData).Methods("PUT")
-	gmux.HandleFunc("/apps/{App}/depender/{Depender}", GetDependerAppData).Methods("GET")
-	gmux.HandleFunc("/apps/{App}/depender/{Depender}", RemoveDependerAppData).Methods("DELETE")
-	gmux.HandleFunc("/apps/{App}/depender/{Depender}/request", RequestAppDependency).Methods("POST")
-	gmux.HandleFunc("/apps/{App}/depender/{Depender}/env/{Env}", AddDependerEnvDataForDependerApp).Methods("PUT")
-	gmux.HandleFunc("/apps/{App}/depender/{Depender}/env/{Env}", GetDependerEnvDataForDependerApp).Methods("GET")
-	gmux.HandleFunc("/apps/{App}/depender/{Depender}/env/{Env}", RemoveDependerEnvDataForDependerApp).Methods("DELETE")
+	{"GET", "/apps", ListRegisteredApps, "ListRegisteredApps"},
+	{"GET", "/apps/{App}", GetApp, "GetApp"},
+	{"PUT", "/apps/{App}", RegisterApp, "RegisterApp"},
+	{"POST", "/apps/{App}", UpdateApp, "UpdateApp"},
+	{"DELETE", "/apps/{App}", UnregisterApp, "UnregisterApp"},
+	{"PUT", "/apps/{App}/env/{Env}", AddDependerEnvData, "AddDependerEnvData"},
+	{"GET", "/apps/{App}/env/{Env}", GetDependerEnvData, "GetDependerEnvData"},
+	{"DELETE", "/apps/{App}/env/{Env}", RemoveDependerEnvData, "RemoveDependerEnvData"},
+	{"PUT", "/apps/{App}/depender/{Depender}", AddDependerAppData, "AddDependerAppData"},
+	{"GET", "/apps/{App}/depender/{Depender}", GetDependerAppData, "GetDependerAppData"},
+	{"DELETE", "/apps/{App}/depender/{Depender}", RemoveDependerAppData, "RemoveDependerAppData"},
+	{"POST", "/apps/{App}/depender/{Depender}/request", RequestAppDependency, "RequestAppDependency"},
+	{"PUT", "/apps/{App}/depender/{Depender}/env/{Env}", AddDependerEnvDataForDependerApp, "AddDependerEnvDataForDependerApp"},
+	{"GET", "/apps/{App}/depender/{Depender}/env/{Env}", GetDependerEnvDataForDependerApp, "GetDependerEnvDataForDependerApp"},
+	{"DELETE", "/apps/{App}/depender/{Depender}/env/{Env}", RemoveDependerEnvDataForDependerApp, "RemoveDependerEnvDataForDependerApp"},
 
 	// Container Health
-	gmux.HandleFunc("/healthz", ContainerHealthzGet).Methods("GET")
+	{"GET", "/healthz", ContainerHealthzGet, ""},
 
-	// Router Config Management
-	gmux.HandleFunc("/pools", ListPools).Methods("GET")
-	gmux.HandleFunc("/pools/{PoolName}", GetPool).Methods("GET")
-	gmux.HandleFunc("/pools/{PoolName}", UpdatePool).Methods("PUT")
-	gmux.HandleFunc("/pools/{PoolName}", DeletePool).Methods("DELETE")
-	gmux.HandleFunc("/rules", ListRules).Methods("GET")
-	gmux.HandleFunc("/rules/{RuleName}", GetRule).Methods("GET")
-	gmux.HandleFunc("/rules/{RuleName}", UpdateRule).Methods("PUT")
-	gmux.HandleFunc("/rules/{RuleName}", DeleteRule).Methods("DELETE")
-	gmux.HandleFunc("/tries", ListTries).Methods("GET")
-	gmux.HandleFunc("/tries/{TrieName}", GetTrie).Methods("GET")
-	gmux.HandleFunc("/tries/{TrieName}", UpdateTrie).Methods("PUT")
-	gmux.HandleFunc("/tries/{TrieName}", DeleteTrie).Methods("DELETE")
-	gmux.HandleFunc("/ports/apps/{App}/envs/{Env}", GetAppEnvPort).Methods("GET")
-	gmux.HandleFunc("/ports/apps", ListAppEnvsWithPort).Methods("GET")
-	gmux.HandleFunc("/ports/{Port}", GetPort).Methods("GET")
-	gmux.HandleFunc("/ports/{Port}", UpdatePort).Methods("PUT")
-	gmux.HandleFunc("/ports/{Port}", DeletePort).Methods("DELETE")
-	gmux.HandleFunc("/ports", ListPorts).Methods("GET")
+	// Builder Callbacks
+	{"POST", "/builds/{ID}/complete", CompleteBuild, ""},
 
-	// Router Visualizations
-	gmux.HandleFunc("/visualize/router", graph.VisualizeIndex)
-	gmux.HandleFunc("/visualize/router/tries/{name}/json", graph.TrieJson)
-	gmux.HandleFunc("/visualize/router/tries/{name}/dot", graph.TrieDot)
-	gmux.HandleFunc("/visualize/router/tries/{name}/svg", graph.TrieSvg)
+	// Router Config Management
+	{"GET", "/pools", ListPools, "ListPools"},
+	{"GET", "/pools/{PoolName}", GetPool, "GetPool"},
+	{"PUT", "/pools/{PoolName}", UpdatePool, "UpdatePool"},
+	{"DELETE", "/pools/{PoolName}", DeletePool, "DeletePool"},
+	{"GET", "/rules", ListRules, "ListRules"},
+	{"GET", "/rules/{RuleName}", GetRule, "GetRule"},
+	{"PUT", "/rules/{RuleName}", UpdateRule, "UpdateRule"},
+	{"DELETE", "/rules/{RuleName}", DeleteRule, "DeleteRule"},
+	{"GET", "/tries", ListTries, "ListTries"},
+	{"GET", "/tries/{TrieName}", GetTrie, "GetTrie"},
+	{"PUT", "/tries/{TrieName}", UpdateTrie, "UpdateTrie"},
+	{"DELETE", "/tries/{TrieName}", DeleteTrie, "DeleteTrie"},
+	{"GET", "/ports/apps/{App}/envs/{Env}", GetAppEnvPort, "GetAppEnvPort"},
+	{"GET", "/ports/apps", ListAppEnvsWithPort, "ListAppEnvsWithPort"},
+	{"GET", "/ports/{Port}", GetPort, "GetPort"},
+	{"PUT", "/ports/{Port}", UpdatePort, "UpdatePort"},
+	{"DELETE", "/ports/{Port}", DeletePort, "DeletePort"},
+	{"GET", "/ports", ListPorts, "ListPorts"},
 
 	// Instance Management
-	gmux.HandleFunc("/instances/apps/{App}/shas/{Sha}/envs/{Env}/containers", ListContainers).Methods("GET")
-	gmux.HandleFunc("/instances/apps/{App}/shas/{Sha}/envs/{Env}/containers", Deploy).Methods("POST")
-	gmux.HandleFunc("/instances/apps/{App}/shas/{Sha}/envs/{Env}", Teardown).Methods("DELETE")
-	gmux.HandleFunc("/instances/apps/{App}/shas/{Sha}/envs", DeployListEnvs).Methods("GET")
-	gmux.HandleFunc("/instances/apps/{App}/shas/{Sha}", Teardown).Methods("DELETE")
-	gmux.HandleFunc("/instances/apps/{App}/shas", ListShas).Methods("GET")
-	gmux.HandleFunc("/instances/apps/{App}", Teardown).Methods("DELETE")
-	gmux.HandleFunc("/instances/apps", ListApps).Methods("GET")
-	gmux.HandleFunc("/instances/{ID}/deploy", DeployContainer).Methods("POST")
-	gmux.HandleFunc("/instances/{ID}/copy", CopyContainer).Methods("POST")
-	gmux.HandleFunc("/instances/{ID}/maint", ContainerMaintenance).Methods("POST")
-	gmux.HandleFunc("/instances/{ID}", ContainerIDGet).Methods("GET")
-	gmux.HandleFunc("/instances/{ID}", TeardownContainerID).Methods("DELETE")
-	gmux.HandleFunc("/instances", ListContainers).Methods("GET")
-	gmux.HandleFunc("/instances", TeardownContainers).Methods("DELETE")
+	{"GET", "/instances/apps/{App}/shas/{Sha}/envs/{Env}/containers", ListContainers, "ListContainers"},
+	{"POST", "/instances/apps/{App}/shas/{Sha}/envs/{Env}/containers", Deploy, "Deploy"},
+	{"DELETE", "/instances/apps/{App}/shas/{Sha}/envs/{Env}", Teardown, "Teardown"},
+	{"GET", "/instances/apps/{App}/shas/{Sha}/envs", DeployListEnvs, "ListEnvs"},
+	{"DELETE", "/instances/apps/{App}/shas/{Sha}", Teardown, "Teardown"},
+	{"GET", "/instances/apps/{App}/shas", ListShas, "ListShas"},
+	{"DELETE", "/instances/apps/{App}", Teardown, "Teardown"},
+	{"GET", "/instances/apps", ListApps, "ListApps"},
+	{"POST", "/instances/{ID}/deploy", DeployContainer, "DeployContainer"},
+	{"POST", "/instances/{ID}/copy", CopyContainer, "CopyContainer"},
+	{"POST", "/instances/{ID}/maint", ContainerMaintenance, "ContainerMaintenance"},
+	{"GET", "/instances/{ID}", ContainerIDGet, "GetContainer"},
+	{"DELETE", "/instances/{ID}", TeardownContainerID, "Teardown"},
+	{"GET", "/instances", ListContainers, "ListContainers"},
+	{"DELETE", "/instances", TeardownContainers, "Teardown"},
+	{"GET", "/supervisors/{Supervisor}/containers", QueryContainers, "QueryContainers"},
+	{"GET", "/envs/{Env}/containers", QueryContainers, "QueryContainers"},
+	{"GET", "/teams/{Team}/containers", QueryContainers, "QueryContainers"},
+	{"GET", "/containers", QueryContainers, "QueryContainers"},
+	{"GET", "/containers/details", GetContainers, "GetContainers"},
+	{"POST", "/indexes", RebuildIndexes, "RebuildIndexes"},
+
+	// Lock Management
+	{"GET", "/locks", ListLocks, "ListLocks"},
+	{"DELETE", "/locks", ForceUnlock, "ForceUnlock"},
 
 	// LDAP Management
-	gmux.HandleFunc("/users/{User}", GetPermissions).Methods("GET")
-	gmux.HandleFunc("/teams/{Team}/apps", ListTeamApps).Methods("GET")
-	gmux.HandleFunc("/teams/{Team}/apps/{App}", AllowApp).Methods("PUT")
-	gmux.HandleFunc("/teams/{Team}/apps/{App}", DisallowApp).Methods("DELETE")
-	gmux.HandleFunc("/teams/{Team}/admins", ListTeamAdmins).Methods("GET")
-	gmux.HandleFunc("/teams/{Team}/members", ListTeamMembers).Methods("GET")
-	gmux.HandleFunc("/teams", ListTeams).Methods("GET")
+	{"GET", "/users/{User}", GetPermissions, "IsSuperUser"},
+	{"GET", "/teams/{Team}/apps", ListTeamApps, "ListTeamApps"},
+	{"PUT", "/teams/{Team}/apps/{App}", AllowApp, "AllowApp"},
+	{"DELETE", "/teams/{Team}/apps/{App}", DisallowApp, "DisallowApp"},
+	{"GET", "/teams/{Team}/admins", ListTeamAdmins, "ListTeamAdmins"},
+	{"GET", "/teams/{Team}/members", ListTeamMembers, "ListTeamMembers"},
+	{"GET", "/teams", ListTeams, "ListTeams"},
+	{"POST", "/teams", SyncTeamApps, "SyncTeamApps"},
 
 	// Environment Management
-	gmux.HandleFunc("/envs/{Env}/app/{App}/resolve/{DepNames}", ResolveDeps).Methods("GET")
-	gmux.HandleFunc("/envs/{Env}", UpdateEnv).Methods("PUT")
-	gmux.HandleFunc("/envs/{Env}", DeleteEnv).Methods("DELETE")
-	gmux.HandleFunc("/envs", ListEnvs).Methods("GET")
+	{"GET", "/envs/{Env}/app/{App}/resolve/{DepNames}", ResolveDeps, "ResolveDeps"},
+	{"PUT", "/envs/{Env}", UpdateEnv, "UpdateEnv"},
+	{"DELETE", "/envs/{Env}", DeleteEnv, "DeleteEnv"},
+	{"POST", "/envs/{Env}/protect", ProtectEnv, "ProtectEnv"},
+	{"DELETE", "/envs/{Env}/protect", UnprotectEnv, "ProtectEnv"},
+	{"GET", "/envs", ListEnvs, "ListEnvs"},
+
+	// IP Group Management
+	{"GET", "/ipgroups/{Name}", GetIPGroup, "GetIPGroup"},
+	{"PUT", "/ipgroups/{Name}", UpdateIPGroup, "UpdateIPGroup"},
+	{"DELETE", "/ipgroups/{Name}", DeleteIPGroup, "DeleteIPGroup"},
+	{"GET", "/ipgroups", ListIPGroups, "ListIPGroups"},
+}
+
+func Init(listenAddr string) error {
+	gmux := mux.NewRouter() // Use gorilla mux for APIs to make things easier
+
+	gmux.NotFoundHandler = http.HandlerFunc(NotFound)
+	// APIs go in apiRoutes and v2Routes so that they are in the OpenAPI document
+	gmux.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, staticDir+"/img/favicon.ico")
+	})
+	gmux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, staticDir+"/dashboard/#dashboard", 302)
+	})
+
+	for _, route := range apiRoutes {
+		gmux.HandleFunc(route.Path, route.Handler).Methods(route.Method)
+	}
+	gmux.HandleFunc("/openapi.json", OpenAPIJSON).Methods("GET")
+
+	// Router Visualizations
+	gmux.HandleFunc("/visualize/router", graph.VisualizeIndex)
+	gmux.HandleFunc("/visualize/router/tries/{name}/json", graph.TrieJson)
+	gmux.HandleFunc("/visualize/router/tries/{name}/dot", graph.TrieDot)
+	gmux.HandleFunc("/visualize/router/tries/{name}/svg", graph.TrieSvg)
 
-	// IP Group Managerment
-	gmux.HandleFunc("/ipgroups/{Name}", GetIPGroup).Methods("GET")
-	gmux.HandleFunc("/ipgroups/{Name}", UpdateIPGroup).Methods("PUT")
-	gmux.HandleFunc("/ipgroups/{Name}", DeleteIPGroup).Methods("DELETE")
-	gmux.HandleFunc("/ipgroups", ListIPGroups).Methods("GET")
+	// v2 API
+	initV2(gmux)
 
 	// Static Assets
 	staticPath := "/" + staticDir + "/"
 	fileServer := http.StripPrefix(staticPath, http.FileServer(http.Dir("./"+staticDir)))
 	gmux.NewRoute().PathPrefix(staticPath).Handler(fileServer)
 
-	handler := apachelog.NewHandler(HandlerFunc(gmux), os.Stderr)
+	handler := apachelog.NewHandler(HandlerFunc(bearerSecret(clientCert(rateLimit(tokenScope(gmux))))), os.Stderr)
 	server = &http.Server{Addr: listenAddr, Handler: handler}
 	lAddr = listenAddr
 	return nil
 }
 
-func listenAndServeTLS(cert, key []byte) error {
+func listenAndServeTLS(config *tls.Config) error {
 	addr := server.Addr
 	if addr == "" {
 		log.Printf("Current Address: %s", addr)
 		panic("[API] Current Address is not HTTPS")
 	}
 
-	config := &tls.Config{}
-	if server.TLSConfig != nil {
-		config = server.TLSConfig
-	}
-	if config.NextProtos == nil {
-		config.NextProtos = []string{"http/1.1"}
-	}
-
-	var err error
-	config.Certificates = make([]tls.Certificate, 1)
-	config.Certificates[0], err = tls.X509KeyPair(cert, key)
-	if err != nil {
-		return err
-	}
-
 	conn, err := net.Listen("tcp", lAddr)
 	if err != nil {
 		return err
@@ -221,6 +281,84 @@ func listenAndServeTLS(cert, key []byte) error {
 	return server.Serve(tls.NewListener(conn, config))
 }
 
+// clientCert lets requests without credentials act as their client certificate, if its CN has a role. The v2 API
+// does this itself since it does not take credentials from the form.
+func clientCert(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && !isV2(r) && r.FormValue("Secret") == "" && r.FormValue("Password") == "" {
+			user, secret, ok, err := rpc.CertLogin(r.TLS)
+			if err != nil {
+				fmt.Fprintf(w, "%s", Output(nil, err))
+				return
+			}
+			if ok {
+				r.Form.Set("User", user)
+				r.Form.Set("Secret", secret)
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// REST requests do not map onto a single RPC, so API tokens that are limited to some RPCs can not use them.
+// bearerSecret lets requests pass their secret, an API token or a bearer token from the identity provider, in an
+// Authorization: Bearer header instead of the Secret form value.
+func bearerSecret(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if strings.HasPrefix(header, "Bearer ") && r.FormValue("Secret") == "" {
+			r.Form.Set("Secret", strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// rateLimit rejects requests whose source or user has run out of requests of their class. GETs read, logins are
+// logins and everything else mutates.
+func rateLimit(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := rpc.RateClassMutate
+		if r.URL.Path == "/login" || r.URL.Path == v2Prefix+"/login" {
+			class = rpc.RateClassLogin
+		} else if r.Method == "GET" || r.Method == "HEAD" {
+			class = rpc.RateClassRead
+		}
+		auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+		if isV2(r) {
+			auth = v2HeaderCredentials(r)
+		}
+		if err := rpc.LimitRequest(class, &auth); err != nil {
+			if isV2(r) {
+				writeV2Error(w, err)
+				return
+			}
+			if limitErr, ok := err.(*rpc.RateLimitError); ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.RetryAfter.Seconds()))))
+			}
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprintf(w, "%s", Output(nil, err))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// tokenScope keeps API tokens that are limited to some RPCs out of the REST API. v2 requests are a single RPC each,
+// so the v2 API checks them itself.
+func tokenScope(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isV2(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if err := rpc.AuthorizeTokenOperation(r.FormValue("Secret"), ""); err != nil {
+			fmt.Fprintf(w, "%s", Output(nil, err))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
 func Output(obj map[string]interface{}, err error) string {
 	var bytes []byte
 	if err != nil {
@@ -235,10 +373,41 @@ func Output(obj map[string]interface{}, err error) string {
 	return string(bytes)
 }
 
+// adds the per region replies of an aggregated call to obj
+func withRegions(obj map[string]interface{}, aggregate bool, regions, regionErrors interface{}) map[string]interface{} {
+	if aggregate {
+		obj["Regions"] = regions
+		obj["RegionErrors"] = regionErrors
+	}
+	return obj
+}
+
+// reads the paging, filtering and sorting of a list call from the form. Labels may be repeated or comma separated.
+func listOptions(r *http.Request) ListOptions {
+	limit, _ := strconv.Atoi(r.FormValue("Limit"))
+	labels := []string{}
+	for _, value := range r.Form["Labels"] {
+		for _, label := range strings.Split(value, ",") {
+			if label = strings.TrimSpace(label); label != "" {
+				labels = append(labels, label)
+			}
+		}
+	}
+	return ListOptions{limit, r.FormValue("Cursor"), r.FormValue("Prefix"), labels, r.FormValue("Sort")}
+}
+
+// adds the cursor of the next page of a list call to obj, if there is a next page
+func withNextCursor(obj map[string]interface{}, cursor string) map[string]interface{} {
+	if cursor != "" {
+		obj["NextCursor"] = cursor
+	}
+	return obj
+}
+
 func Listen() {
 	if server == nil {
 		panic("Not Initialized.")
 	}
 	log.Println("[API] Listening on", lAddr)
-	log.Fatal(listenAndServeTLS(crypto.SERVER_CERT, crypto.SERVER_KEY).Error())
+	log.Fatal(listenAndServeTLS(crypto.TLS.Config()).Error())
 }
@@ -26,7 +26,7 @@ import (
 func RequestAppDependency(w http.ResponseWriter, r *http.Request) {
 	var err error
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	envsString := r.FormValue("Envs")
 	envs := strings.Split(envsString, ",")
 	arg := ManagerRequestAppDependencyArg{
@@ -47,7 +47,7 @@ func RequestAppDependency(w http.ResponseWriter, r *http.Request) {
 func AddDependerAppData(w http.ResponseWriter, r *http.Request) {
 	var err error
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	depEnvData := map[string]*DependerEnvData{}
 	if r.FormValue("DependerEnvData") != "" {
 		err = json.Unmarshal([]byte(r.FormValue("DependerEnvData")), &depEnvData)
@@ -71,7 +71,7 @@ func AddDependerAppData(w http.ResponseWriter, r *http.Request) {
 
 func RemoveDependerAppData(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerRemoveDependerAppDataArg{
 		ManagerAuthArg: auth,
 		App:            vars["App"],
@@ -84,7 +84,7 @@ func RemoveDependerAppData(w http.ResponseWriter, r *http.Request) {
 
 func GetDependerAppData(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerGetDependerAppDataArg{
 		ManagerAuthArg: auth,
 		App:            vars["App"],
@@ -105,7 +105,7 @@ func GetDependerAppData(w http.ResponseWriter, r *http.Request) {
 func AddDependerEnvData(w http.ResponseWriter, r *http.Request) {
 	var err error
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	data := map[string]interface{}{}
 	if r.FormValue("Data") != "" {
 		err = json.Unmarshal([]byte(r.FormValue("Data")), &data)
@@ -156,7 +156,7 @@ func AddDependerEnvData(w http.ResponseWriter, r *http.Request) {
 
 func RemoveDependerEnvData(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerRemoveDependerEnvDataArg{
 		ManagerAuthArg: auth,
 		App:            vars["App"],
@@ -169,7 +169,7 @@ func RemoveDependerEnvData(w http.ResponseWriter, r *http.Request) {
 
 func GetDependerEnvData(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerGetDependerEnvDataArg{
 		ManagerAuthArg: auth,
 		App:            vars["App"],
@@ -190,7 +190,7 @@ func GetDependerEnvData(w http.ResponseWriter, r *http.Request) {
 func AddDependerEnvDataForDependerApp(w http.ResponseWriter, r *http.Request) {
 	var err error
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	data := map[string]interface{}{}
 	if r.FormValue("Data") != "" {
 		err = json.Unmarshal([]byte(r.FormValue("Data")), &data)
@@ -242,7 +242,7 @@ func AddDependerEnvDataForDependerApp(w http.ResponseWriter, r *http.Request) {
 
 func RemoveDependerEnvDataForDependerApp(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerRemoveDependerEnvDataForDependerAppArg{
 		ManagerAuthArg: auth,
 		App:            vars["App"],
@@ -256,7 +256,7 @@ func RemoveDependerEnvDataForDependerApp(w http.ResponseWriter, r *http.Request)
 
 func GetDependerEnvDataForDependerApp(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerGetDependerEnvDataForDependerAppArg{
 		ManagerAuthArg: auth,
 		App:            vars["App"],
@@ -0,0 +1,42 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package api
+
+import (
+	. "atlantis/manager/rpc/types"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+func ListApprovals(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	var reply ManagerListApprovalsReply
+	err := manager.ListApprovals(ManagerListApprovalsArg{auth}, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status, "Approvals": reply.Approvals}, err))
+}
+
+func approveTask(w http.ResponseWriter, r *http.Request, reject bool) {
+	vars := mux.Vars(r)
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	var reply ManagerApproveTaskReply
+	err := manager.ApproveTask(ManagerApproveTaskArg{auth, vars["ID"], reject}, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status, "Approval": reply.Approval}, err))
+}
+
+func ApproveTask(w http.ResponseWriter, r *http.Request) {
+	approveTask(w, r, false)
+}
+
+func RejectTask(w http.ResponseWriter, r *http.Request) {
+	approveTask(w, r, true)
+}
@@ -0,0 +1,45 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package api
+
+import (
+	. "atlantis/manager/rpc/types"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func Audit(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	arg := ManagerAuditArg{
+		ManagerAuthArg: auth,
+		AuditUser:      r.FormValue("AuditUser"),
+		Action:         r.FormValue("Action"),
+		App:            r.FormValue("App"),
+		Env:            r.FormValue("Env"),
+	}
+	for name, field := range map[string]*time.Time{"Since": &arg.Since, "Until": &arg.Until} {
+		if value := r.FormValue(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				fmt.Fprintf(w, "{\"error\": \"Invalid %s: %s\"}", name, err.Error())
+				return
+			}
+			*field = parsed
+		}
+	}
+	arg.Limit, _ = strconv.Atoi(r.FormValue("Limit"))
+	var reply ManagerAuditReply
+	err := manager.Audit(arg, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status, "Entries": reply.Entries}, err))
+}
@@ -0,0 +1,36 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package api
+
+import (
+	"atlantis/builder/api/types"
+	. "atlantis/common"
+	"atlantis/manager/builder"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+// CompleteBuild is called back by builders when a build is done, with the Token from the callback URL they were
+// given and the build as the body.
+func CompleteBuild(w http.ResponseWriter, r *http.Request) {
+	var build types.Build
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&build); err != nil {
+			fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": StatusError}, err))
+			return
+		}
+	}
+	err := builder.Complete(mux.Vars(r)["ID"], r.FormValue("Token"), &build)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": StatusOk}, err))
+}
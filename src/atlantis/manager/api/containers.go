@@ -16,15 +16,18 @@ import (
 	"fmt"
 	"github.com/gorilla/mux"
 	"net/http"
+	"strconv"
 )
 
 func ContainerIDGet(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
-	cArg := ManagerGetContainerArg{auth, vars["ID"]}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	aggregate, _ := strconv.ParseBool(r.FormValue("Aggregate"))
+	cArg := ManagerGetContainerArg{auth, vars["ID"], aggregate}
 	var reply ManagerGetContainerReply
 	err := manager.GetContainer(cArg, &reply)
-	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Container": reply.Container, "Status": reply.Status}, err))
+	fmt.Fprintf(w, "%s", Output(withRegions(map[string]interface{}{"Container": reply.Container,
+		"Status": reply.Status}, aggregate, reply.Regions, reply.RegionErrors), err))
 }
 
 func ContainerHealthzGet(w http.ResponseWriter, r *http.Request) {
@@ -46,36 +49,81 @@ func ContainerHealthzGet(w http.ResponseWriter, r *http.Request) {
 }
 
 func ListApps(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
-	arg := ManagerListAppsArg{auth}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	aggregate, _ := strconv.ParseBool(r.FormValue("Aggregate"))
+	arg := ManagerListAppsArg{auth, aggregate, listOptions(r)}
 	var reply ManagerListAppsReply
 	err := manager.ListApps(arg, &reply)
-	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Apps": reply.Apps, "Status": reply.Status}, err))
+	fmt.Fprintf(w, "%s", Output(withNextCursor(withRegions(map[string]interface{}{"Apps": reply.Apps,
+		"Status": reply.Status}, aggregate, reply.Regions, reply.RegionErrors), reply.NextCursor), err))
 }
 
 func ListShas(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
-	arg := ManagerListShasArg{auth, vars["App"]}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	aggregate, _ := strconv.ParseBool(r.FormValue("Aggregate"))
+	arg := ManagerListShasArg{auth, vars["App"], aggregate, listOptions(r)}
 	var reply ManagerListShasReply
 	err := manager.ListShas(arg, &reply)
-	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Shas": reply.Shas, "Status": reply.Status}, err))
+	fmt.Fprintf(w, "%s", Output(withNextCursor(withRegions(map[string]interface{}{"Shas": reply.Shas,
+		"Status": reply.Status}, aggregate, reply.Regions, reply.RegionErrors), reply.NextCursor), err))
 }
 
 func DeployListEnvs(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
-	arg := ManagerListEnvsArg{auth, vars["App"], vars["Sha"]}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	aggregate, _ := strconv.ParseBool(r.FormValue("Aggregate"))
+	arg := ManagerListEnvsArg{auth, vars["App"], vars["Sha"], aggregate}
 	var reply ManagerListEnvsReply
 	err := manager.ListEnvs(arg, &reply)
-	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Envs": reply.Envs, "Status": reply.Status}, err))
+	fmt.Fprintf(w, "%s", Output(withRegions(map[string]interface{}{"Envs": reply.Envs, "Status": reply.Status},
+		aggregate, reply.Regions, reply.RegionErrors), err))
+}
+
+// Filters come from the path when the route has them (e.g. /supervisors/{Supervisor}/containers) and from the
+// form otherwise, so any combination can be queried.
+func QueryContainers(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	filter := func(name string) string {
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return r.FormValue(name)
+	}
+	arg := ManagerQueryContainersArg{auth, filter("Supervisor"), filter("Env"), filter("Team")}
+	var reply ManagerQueryContainersReply
+	err := manager.QueryContainers(arg, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"ContainerIDs": reply.ContainerIDs, "Status": reply.Status}, err))
+}
+
+// Filters and Live come from the form, e.g. /containers/details?Env=prod&Zone=us-east-1a&Live=true
+func GetContainers(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	live, _ := strconv.ParseBool(r.FormValue("Live"))
+	arg := ManagerGetContainersArg{auth, r.FormValue("App"), r.FormValue("Sha"), r.FormValue("Env"),
+		r.FormValue("Host"), r.FormValue("Zone"), live, listOptions(r)}
+	var reply ManagerGetContainersReply
+	err := manager.GetContainers(arg, &reply)
+	fmt.Fprintf(w, "%s", Output(withNextCursor(map[string]interface{}{"Containers": reply.Containers,
+		"Status": reply.Status}, reply.NextCursor), err))
+}
+
+func RebuildIndexes(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	arg := ManagerRebuildIndexesArg{auth}
+	var reply ManagerRebuildIndexesReply
+	err := manager.RebuildIndexes(arg, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Indexed": reply.Indexed, "Status": reply.Status}, err))
 }
 
 func ListContainers(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
-	cArg := ManagerListContainersArg{auth, vars["App"], vars["Sha"], vars["Env"]}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	aggregate, _ := strconv.ParseBool(r.FormValue("Aggregate"))
+	cArg := ManagerListContainersArg{auth, vars["App"], vars["Sha"], vars["Env"], aggregate, listOptions(r)}
 	var reply ManagerListContainersReply
 	err := manager.ListContainers(cArg, &reply)
-	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"ContainerIDs": reply.ContainerIDs, "Status": reply.Status}, err))
+	fmt.Fprintf(w, "%s", Output(withNextCursor(withRegions(map[string]interface{}{"ContainerIDs": reply.ContainerIDs,
+		"Status": reply.Status}, aggregate, reply.Regions, reply.RegionErrors), reply.NextCursor), err))
 }
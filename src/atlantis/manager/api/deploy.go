@@ -23,7 +23,7 @@ import (
 
 func ResolveDeps(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerResolveDepsArg{
 		ManagerAuthArg: auth,
 		App:            vars["App"],
@@ -37,7 +37,7 @@ func ResolveDeps(w http.ResponseWriter, r *http.Request) {
 
 func Deploy(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	cpushares, err := strconv.ParseUint(r.FormValue("CPUShares"), 10, 0)
 	if err != nil {
 		fmt.Fprintf(w, "{\"error\": \"%s\"}", err.Error())
@@ -64,6 +64,7 @@ func Deploy(w http.ResponseWriter, r *http.Request) {
 		skipBld = false
 	}
 	manifest := r.FormValue("Manifest")
+	hotfix, _ := strconv.ParseBool(r.FormValue("Hotfix"))
 	
 	dArg := ManagerDeployArg{
 		ManagerAuthArg: auth,
@@ -76,6 +77,8 @@ func Deploy(w http.ResponseWriter, r *http.Request) {
 		Dev:            bool(dev),
 		SkipBuild:      bool(skipBld),
 		Manifest:       manifest,
+		LockTimeout:    r.FormValue("LockTimeout"),
+		Hotfix:         hotfix,
 	}
 	var reply AsyncReply
 	err = manager.Deploy(dArg, &reply)
@@ -84,7 +87,7 @@ func Deploy(w http.ResponseWriter, r *http.Request) {
 
 func DeployContainer(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	instances, err := strconv.ParseUint(r.FormValue("Instances"), 10, 0)
 	if err != nil {
 		fmt.Fprintf(w, "{\"error\": \"%s\"}", err.Error())
@@ -99,7 +102,7 @@ func DeployContainer(w http.ResponseWriter, r *http.Request) {
 
 func CopyContainer(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	ccArg := ManagerCopyContainerArg{
 		ManagerAuthArg: auth,
 		ContainerID:    vars["ID"],
@@ -112,8 +115,9 @@ func CopyContainer(w http.ResponseWriter, r *http.Request) {
 
 func Teardown(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
-	arg := ManagerTeardownArg{auth, vars["App"], vars["Sha"], vars["Env"], "", false}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	hotfix, _ := strconv.ParseBool(r.FormValue("Hotfix"))
+	arg := ManagerTeardownArg{auth, vars["App"], vars["Sha"], vars["Env"], "", false, r.FormValue("LockTimeout"), hotfix}
 	var reply AsyncReply
 	err := manager.Teardown(arg, &reply)
 	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"ID": reply.ID}, err))
@@ -121,21 +125,24 @@ func Teardown(w http.ResponseWriter, r *http.Request) {
 
 func TeardownContainerID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
-	cArg := ManagerTeardownArg{auth, "", "", "", vars["ID"], false}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	hotfix, _ := strconv.ParseBool(r.FormValue("Hotfix"))
+	cArg := ManagerTeardownArg{auth, "", "", "", vars["ID"], false, r.FormValue("LockTimeout"), hotfix}
 	var reply AsyncReply
 	err := manager.Teardown(cArg, &reply)
 	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"ID": reply.ID}, err))
 }
 
 func TeardownContainers(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	all, err := strconv.ParseBool(r.FormValue("All"))
 	if err != nil {
 		fmt.Fprintf(w, "{\"error\": \"%s\"}", err.Error())
 		return
 	}
-	tArg := ManagerTeardownArg{auth, r.FormValue("App"), r.FormValue("Sha"), r.FormValue("Env"), r.FormValue("ContainerID"), all}
+	hotfix, _ := strconv.ParseBool(r.FormValue("Hotfix"))
+	tArg := ManagerTeardownArg{auth, r.FormValue("App"), r.FormValue("Sha"), r.FormValue("Env"), r.FormValue("ContainerID"), all,
+		r.FormValue("LockTimeout"), hotfix}
 	var reply AsyncReply
 	err = manager.Teardown(tArg, &reply)
 	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"ID": reply.ID}, err))
@@ -16,28 +16,47 @@ import (
 	"fmt"
 	"github.com/gorilla/mux"
 	"net/http"
+	"strconv"
 )
 
 func ListEnvs(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
-	arg := ManagerListEnvsArg{auth, "", ""}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	aggregate, _ := strconv.ParseBool(r.FormValue("Aggregate"))
+	arg := ManagerListEnvsArg{auth, "", "", aggregate}
 	var reply ManagerListEnvsReply
 	err := manager.ListEnvs(arg, &reply)
-	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Envs": reply.Envs, "Status": reply.Status}, err))
+	fmt.Fprintf(w, "%s", Output(withRegions(map[string]interface{}{"Envs": reply.Envs, "Status": reply.Status},
+		aggregate, reply.Regions, reply.RegionErrors), err))
 }
 
 func UpdateEnv(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	dArg := ManagerEnvArg{auth, vars["Env"]}
 	var reply ManagerEnvReply
 	err := manager.UpdateEnv(dArg, &reply)
 	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status}, err))
 }
 
+func protectEnv(w http.ResponseWriter, r *http.Request, protected bool) {
+	vars := mux.Vars(r)
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	var reply ManagerProtectEnvReply
+	err := manager.ProtectEnv(ManagerProtectEnvArg{auth, vars["Env"], protected}, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status}, err))
+}
+
+func ProtectEnv(w http.ResponseWriter, r *http.Request) {
+	protectEnv(w, r, true)
+}
+
+func UnprotectEnv(w http.ResponseWriter, r *http.Request) {
+	protectEnv(w, r, false)
+}
+
 func DeleteEnv(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	dArg := ManagerEnvArg{auth, vars["Env"]}
 	var reply ManagerEnvReply
 	err := manager.DeleteEnv(dArg, &reply)
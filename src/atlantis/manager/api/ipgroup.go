@@ -22,7 +22,7 @@ import (
 func UpdateIPGroup(w http.ResponseWriter, r *http.Request) {
 	var err error
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	ipsString := r.FormValue("IPs")
 	ips := strings.Split(ipsString, ",")
 	arg := ManagerUpdateIPGroupArg{
@@ -38,7 +38,7 @@ func UpdateIPGroup(w http.ResponseWriter, r *http.Request) {
 func DeleteIPGroup(w http.ResponseWriter, r *http.Request) {
 	var err error
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerDeleteIPGroupArg{
 		ManagerAuthArg: auth,
 		Name:           vars["Name"],
@@ -51,7 +51,7 @@ func DeleteIPGroup(w http.ResponseWriter, r *http.Request) {
 func GetIPGroup(w http.ResponseWriter, r *http.Request) {
 	var err error
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerGetIPGroupArg{
 		ManagerAuthArg: auth,
 		Name:           vars["Name"],
@@ -63,7 +63,7 @@ func GetIPGroup(w http.ResponseWriter, r *http.Request) {
 
 func ListIPGroups(w http.ResponseWriter, r *http.Request) {
 	var err error
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerListIPGroupsArg{auth}
 	var reply ManagerListIPGroupsReply
 	err = manager.ListIPGroups(arg, &reply)
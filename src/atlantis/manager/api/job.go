@@ -0,0 +1,117 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package api
+
+import (
+	. "atlantis/manager/rpc/types"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// optional numbers and flags default to 0 and false
+func formUint(r *http.Request, name string) uint {
+	value, _ := strconv.ParseUint(r.FormValue(name), 10, 0)
+	return uint(value)
+}
+
+func formBool(r *http.Request, name string) bool {
+	value, _ := strconv.ParseBool(r.FormValue(name))
+	return value
+}
+
+// comma separated lists default to empty
+func formList(r *http.Request, name string) []string {
+	if value := r.FormValue(name); value != "" {
+		return strings.Split(value, ",")
+	}
+	return []string{}
+}
+
+func ListJobs(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	var reply ManagerListJobsReply
+	err := manager.ListJobs(ManagerListJobsArg{auth}, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status, "Jobs": reply.Jobs}, err))
+}
+
+// ScheduleJob takes the same form values as the request it schedules (e.g. App, Sha and Env for a Deploy) along
+// with Name, and At or Cron.
+func ScheduleJob(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	arg := ManagerScheduleJobArg{
+		ManagerAuthArg: auth,
+		Name:           r.FormValue("Name"),
+		At:             r.FormValue("At"),
+		Cron:           r.FormValue("Cron"),
+	}
+	switch arg.Name {
+	case "Deploy":
+		arg.Deploy = &ManagerDeployArg{
+			App:         r.FormValue("App"),
+			Sha:         r.FormValue("Sha"),
+			Env:         r.FormValue("Env"),
+			Instances:   formUint(r, "Instances"),
+			CPUShares:   formUint(r, "CPUShares"),
+			MemoryLimit: formUint(r, "MemoryLimit"),
+			Dev:         formBool(r, "Dev"),
+			SkipBuild:   formBool(r, "SkipBuild"),
+			Manifest:    r.FormValue("Manifest"),
+			LockTimeout: r.FormValue("LockTimeout"),
+			Hotfix:      formBool(r, "Hotfix"),
+		}
+	case "Teardown":
+		arg.Teardown = &ManagerTeardownArg{
+			App:         r.FormValue("App"),
+			Sha:         r.FormValue("Sha"),
+			Env:         r.FormValue("Env"),
+			ContainerID: r.FormValue("ContainerID"),
+			All:         formBool(r, "All"),
+			LockTimeout: r.FormValue("LockTimeout"),
+			Hotfix:      formBool(r, "Hotfix"),
+		}
+	case "DeployContainer":
+		arg.DeployContainer = &ManagerDeployContainerArg{
+			ContainerID: r.FormValue("ContainerID"),
+			Instances:   formUint(r, "Instances"),
+		}
+	}
+	var reply ManagerScheduleJobReply
+	err := manager.ScheduleJob(arg, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status, "Job": reply.Job}, err))
+}
+
+func pauseJob(w http.ResponseWriter, r *http.Request, paused bool) {
+	vars := mux.Vars(r)
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	var reply ManagerPauseJobReply
+	err := manager.PauseJob(ManagerPauseJobArg{auth, vars["ID"], paused}, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status, "Job": reply.Job}, err))
+}
+
+func PauseJob(w http.ResponseWriter, r *http.Request) {
+	pauseJob(w, r, true)
+}
+
+func ResumeJob(w http.ResponseWriter, r *http.Request) {
+	pauseJob(w, r, false)
+}
+
+func DeleteJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	var reply ManagerDeleteJobReply
+	err := manager.DeleteJob(ManagerDeleteJobArg{auth, vars["ID"]}, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status}, err))
+}
@@ -22,7 +22,7 @@ import (
 
 func ListTeamApps(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerListTeamAppsArg{auth, vars["Team"]}
 	var reply ManagerListTeamAppsReply
 	err := manager.ListTeamApps(arg, &reply)
@@ -31,7 +31,7 @@ func ListTeamApps(w http.ResponseWriter, r *http.Request) {
 
 func AllowApp(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerAppArg{auth, vars["App"], vars["Team"]}
 	var reply ManagerAppReply
 	err := manager.AllowApp(arg, &reply)
@@ -40,15 +40,24 @@ func AllowApp(w http.ResponseWriter, r *http.Request) {
 
 func DisallowApp(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerAppArg{auth, vars["App"], vars["Team"]}
 	var reply ManagerAppReply
 	err := manager.DisallowApp(arg, &reply)
 	fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
 }
 
+func SyncTeamApps(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	arg := ManagerSyncTeamAppsArg{auth}
+	var reply ManagerSyncTeamAppsReply
+	err := manager.SyncTeamApps(arg, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Added": reply.Added, "Removed": reply.Removed,
+		"Status": reply.Status}, err))
+}
+
 func ListTeams(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerListTeamsArg{auth}
 	var reply ManagerListTeamsReply
 	err := manager.ListTeams(arg, &reply)
@@ -62,7 +71,7 @@ func ListTeamAdmins(w http.ResponseWriter, r *http.Request) {
 
 func ListTeamMembers(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerListTeamMembersArg{auth, vars["Team"]}
 	var reply ManagerListTeamMembersReply
 	err := manager.ListTeamMembers(arg, &reply)
@@ -71,7 +80,7 @@ func ListTeamMembers(w http.ResponseWriter, r *http.Request) {
 
 func GetPermissions(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{vars["User"], "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{vars["User"], "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerSuperUserArg{auth}
 	var reply ManagerSuperUserReply
 	err := manager.IsSuperUser(arg, &reply)
@@ -23,3 +23,10 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	err := manager.Login(arg, &reply)
 	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"User": r.FormValue("User"), "Secret": reply.Secret}, err))
 }
+
+func Logout(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	var reply ManagerLogoutReply
+	err := manager.Logout(ManagerLogoutArg{auth}, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status}, err))
+}
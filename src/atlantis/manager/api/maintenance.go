@@ -21,7 +21,7 @@ import (
 
 func ContainerMaintenance(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	maintenance, err := strconv.ParseBool(r.FormValue("Maintenance"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -0,0 +1,346 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package api
+
+import (
+	. "atlantis/common"
+	. "atlantis/manager/constant"
+	. "atlantis/manager/rpc/types"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+var (
+	pathVarRegexp = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+	authArgType   = reflect.TypeOf(ManagerAuthArg{})
+	asyncType     = reflect.TypeOf(AsyncReply{})
+	v2ErrorSchema = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"Error": map[string]interface{}{"$ref": "#/components/schemas/V2Error"},
+		},
+	}
+)
+
+// openAPIBuilder makes an OpenAPI document. Named structs go into components/schemas as they are first used.
+type openAPIBuilder struct {
+	schemas map[string]interface{}
+	names   map[reflect.Type]string
+	ids     map[string]int
+}
+
+// OpenAPI returns an OpenAPI 3 document of the REST API, made from apiRoutes, v2Routes and the args and replies of
+// the RPCs behind them.
+func OpenAPI() map[string]interface{} {
+	b := &openAPIBuilder{schemas: map[string]interface{}{}, names: map[reflect.Type]string{}, ids: map[string]int{}}
+	b.schema(reflect.TypeOf(V2Error{}))
+	paths := map[string]map[string]interface{}{}
+	add := func(method, path string, op map[string]interface{}) {
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path][strings.ToLower(method)] = op
+	}
+	for _, route := range apiRoutes {
+		add(route.Method, route.Path, b.v1Operation(route))
+	}
+	for _, route := range v2Routes {
+		add(route.Method, v2Prefix+route.Path, b.v2Operation(route))
+	}
+	add("GET", "/openapi.json", map[string]interface{}{
+		"operationId": "OpenAPI",
+		"summary":     "This document",
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{"description": "The OpenAPI document of the API"},
+		},
+	})
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Atlantis Manager",
+			"version": ManagerAPIVersion,
+			"description": "v1 endpoints take their parameters and the User and Secret to authenticate with as form " +
+				"values and always answer 200, with an Error if they failed. v2 endpoints under /v2 take JSON " +
+				"bodies, authenticate with the Authorization header and answer with HTTP status codes.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]interface{}{
+				"basic":  map[string]interface{}{"type": "http", "scheme": "basic"},
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// OpenAPIJSON serves the OpenAPI document of the API.
+func OpenAPIJSON(w http.ResponseWriter, r *http.Request) {
+	data, err := json.Marshal(OpenAPI())
+	if err != nil {
+		serverError(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// operationID returns a unique id for an operation of rpc. Routes that call the same RPC are numbered.
+func (b *openAPIBuilder) operationID(prefix, rpc string) string {
+	if rpc == "" {
+		rpc = "Other"
+	}
+	id := prefix + rpc
+	b.ids[id]++
+	if b.ids[id] > 1 {
+		id += fmt.Sprint(b.ids[id])
+	}
+	return id
+}
+
+// rpcTypes returns the arg and reply types of the RPC named rpc, or nil if there is no such RPC
+func rpcTypes(rpc string) (reflect.Type, reflect.Type) {
+	method := reflect.ValueOf(manager).MethodByName(rpc)
+	if !method.IsValid() {
+		return nil, nil
+	}
+	return method.Type().In(0), method.Type().In(1).Elem()
+}
+
+func pathVars(path string) []string {
+	vars := []string{}
+	for _, match := range pathVarRegexp.FindAllStringSubmatch(path, -1) {
+		vars = append(vars, match[1])
+	}
+	return vars
+}
+
+func parameter(name, in string, schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"name": name, "in": in, "required": in == "path", "schema": schema}
+}
+
+// queryParameters returns the fields of arg that can be passed as query parameters, less those in skip
+func (b *openAPIBuilder) queryParameters(arg reflect.Type, skip []string) []interface{} {
+	params := []interface{}{}
+	if arg == nil || arg.Kind() != reflect.Struct || arg == authArgType {
+		return params
+	}
+	for _, field := range fields(arg) {
+		if !contains(skip, field.Name) && isParameter(field.Type) {
+			params = append(params, parameter(field.Name, "query", b.schema(field.Type)))
+		}
+	}
+	return params
+}
+
+// isParameter returns true if values of t fit in a query parameter
+func isParameter(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	}
+	return t == timeType
+}
+
+func (b *openAPIBuilder) v1Operation(route apiRoute) map[string]interface{} {
+	arg, reply := rpcTypes(route.RPC)
+	vars := pathVars(route.Path)
+	params := []interface{}{}
+	for _, name := range vars {
+		params = append(params, parameter(name, "path", map[string]interface{}{"type": "string"}))
+	}
+	if arg != nil && (arg == authArgType || hasAuthArg(arg)) {
+		params = append(params, parameter("User", "query", map[string]interface{}{"type": "string"}),
+			parameter("Secret", "query", map[string]interface{}{"type": "string"}))
+	}
+	params = append(params, b.queryParameters(arg, vars)...)
+	response := map[string]interface{}{"type": "object"}
+	if reply != nil {
+		response = map[string]interface{}{"allOf": []interface{}{b.schema(reply), map[string]interface{}{
+			"type": "object", "properties": map[string]interface{}{"Error": map[string]interface{}{"type": "string"}},
+		}}}
+	}
+	return map[string]interface{}{
+		"operationId": b.operationID("", route.RPC),
+		"summary":     route.RPC,
+		"tags":        []string{"v1"},
+		"parameters":  params,
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "The fields of the reply of " + route.RPC + " that the endpoint returns, or Error",
+				"content":     jsonContent(response),
+			},
+		},
+	}
+}
+
+func (b *openAPIBuilder) v2Operation(route v2Route) map[string]interface{} {
+	arg, reply := rpcTypes(route.RPC)
+	vars := pathVars(route.Path)
+	params := []interface{}{}
+	for _, name := range vars {
+		schema := map[string]interface{}{"type": "string"}
+		if field := v2Field(reflect.New(arg), name); field.IsValid() {
+			schema = b.schema(field.Type())
+		}
+		params = append(params, parameter(name, "path", schema))
+	}
+	op := map[string]interface{}{
+		"operationId": b.operationID("V2", route.RPC),
+		"summary":     route.RPC,
+		"tags":        []string{"v2"},
+		"security":    []interface{}{map[string]interface{}{"basic": []string{}}, map[string]interface{}{"bearer": []string{}}},
+	}
+	skip := append(append([]string{}, vars...), "User", "Password", "Secret", "Source")
+	for name := range route.Set {
+		skip = append(skip, name)
+	}
+	if route.Method == "POST" || route.Method == "PUT" {
+		body := arg
+		if route.Body != "" {
+			body = v2Field(reflect.New(arg), route.Body).Type()
+		}
+		if body.Kind() == reflect.Struct && body != authArgType {
+			op["requestBody"] = map[string]interface{}{"content": jsonContent(b.schema(body))}
+		}
+	} else {
+		params = append(params, b.queryParameters(arg, skip)...)
+	}
+	op["parameters"] = params
+	responses := map[string]interface{}{
+		"default": map[string]interface{}{"description": "The error", "content": jsonContent(v2ErrorSchema)},
+	}
+	if reply == asyncType {
+		responses["202"] = map[string]interface{}{
+			"description": "The task was started; follow it at Location",
+			"headers": map[string]interface{}{
+				"Location": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			},
+			"content": jsonContent(b.schema(reply)),
+		}
+	} else {
+		responses["200"] = map[string]interface{}{"description": "The reply", "content": jsonContent(b.schema(reply))}
+	}
+	op["responses"] = responses
+	return op
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+func hasAuthArg(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	field, ok := t.FieldByName("ManagerAuthArg")
+	return ok && field.Anonymous
+}
+
+// fields returns the fields of struct t as they are in its json, with embedded structs flattened. Credentials are
+// left out since they never go in a request body.
+func fields(t reflect.Type) []reflect.StructField {
+	list := []reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		switch {
+		case field.PkgPath != "" || field.Tag.Get("json") == "-" || field.Type == authArgType:
+		case field.Anonymous && field.Type.Kind() == reflect.Struct:
+			list = append(list, fields(field.Type)...)
+		default:
+			if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" {
+				field.Name = name
+			}
+			list = append(list, field)
+		}
+	}
+	return list
+}
+
+// schema returns the schema of t, or a reference to it for named structs
+func (b *openAPIBuilder) schema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return b.schema(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		name, ok := b.names[t]
+		if !ok {
+			name = b.schemaName(t)
+			b.names[t] = name
+			b.schemas[name] = map[string]interface{}{} // in case t refers to itself
+			b.schemas[name] = b.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// schemaName names t in components/schemas. Types of the same name from different packages get the package too.
+func (b *openAPIBuilder) schemaName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := b.schemas[name]; taken {
+		pkg := t.PkgPath()
+		name = strings.Title(pkg[strings.LastIndex(pkg, "/")+1:]) + name
+	}
+	return name
+}
+
+func (b *openAPIBuilder) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for _, field := range fields(t) {
+		properties[field.Name] = b.schema(field.Type)
+	}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if t.Kind() == reflect.Struct && len(properties) == 0 {
+		delete(schema, "properties")
+	}
+	return schema
+}
+
+func contains(list []string, str string) bool {
+	for _, elem := range list {
+		if elem == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	. "github.com/adjust/gocheck"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+)
+
+type OpenAPISuite struct{}
+
+var _ = Suite(&OpenAPISuite{})
+
+// routes that are not part of the API and so are not in the spec
+var undocumentedRoutes = map[string]bool{
+	"/":                                   true,
+	"/favicon.ico":                        true,
+	"/visualize/router":                   true,
+	"/visualize/router/tries/{name}/json": true,
+	"/visualize/router/tries/{name}/dot":  true,
+	"/visualize/router/tries/{name}/svg":  true,
+}
+
+func specOperation(doc map[string]interface{}, method, path string) map[string]interface{} {
+	ops, _ := doc["paths"].(map[string]map[string]interface{})[path]
+	op, _ := ops[strings.ToLower(method)].(map[string]interface{})
+	return op
+}
+
+func (s *OpenAPISuite) TestEveryRouteIsInTheSpec(c *C) {
+	doc := OpenAPI()
+	for _, route := range apiRoutes {
+		c.Assert(specOperation(doc, route.Method, route.Path), NotNil, Commentf("%s %s", route.Method, route.Path))
+	}
+	for _, route := range v2Routes {
+		c.Assert(specOperation(doc, route.Method, v2Prefix+route.Path), NotNil,
+			Commentf("%s %s", route.Method, v2Prefix+route.Path))
+	}
+}
+
+// Routes registered straight on the router, not through apiRoutes or v2Routes, have to be in the spec too.
+func (s *OpenAPISuite) TestEveryHandleFuncIsInTheSpec(c *C) {
+	doc := OpenAPI()
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", nil, 0)
+	c.Assert(err, IsNil)
+	found := 0
+	for _, file := range pkgs["api"].Files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || (sel.Sel.Name != "HandleFunc" && sel.Sel.Name != "Handle") {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			path, _ := strconv.Unquote(lit.Value)
+			found++
+			if undocumentedRoutes[path] {
+				return true
+			}
+			ops, _ := doc["paths"].(map[string]map[string]interface{})[path]
+			c.Assert(ops, NotNil, Commentf("%s is registered at %s but is not in the spec", path,
+				fset.Position(call.Pos())))
+			return true
+		})
+	}
+	c.Assert(found > 0, Equals, true)
+}
+
+func (s *OpenAPISuite) TestOperations(c *C) {
+	doc := OpenAPI()
+	c.Assert(doc["openapi"], Equals, "3.0.3")
+	ids := map[string]bool{}
+	for path, ops := range doc["paths"].(map[string]map[string]interface{}) {
+		for method, op := range ops {
+			id := op.(map[string]interface{})["operationId"].(string)
+			c.Assert(ids[id], Equals, false, Commentf("%s %s reuses %s", method, path, id))
+			ids[id] = true
+		}
+	}
+
+	for _, route := range v2Routes {
+		responses := specOperation(doc, route.Method, v2Prefix+route.Path)["responses"].(map[string]interface{})
+		switch route.RPC {
+		case "Deploy":
+			c.Assert(responses["202"], NotNil)
+			c.Assert(responses["200"], IsNil)
+		case "ListApps":
+			c.Assert(responses["200"], NotNil)
+			c.Assert(responses["202"], IsNil)
+		}
+	}
+}
+
+func (s *OpenAPISuite) TestSchemasAreResolved(c *C) {
+	doc := OpenAPI()
+	data, err := json.Marshal(doc)
+	c.Assert(err, IsNil)
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	c.Assert(schemas["V2Error"], NotNil)
+	for _, ref := range strings.Split(string(data), `"$ref":"#/components/schemas/`)[1:] {
+		name := ref[:strings.Index(ref, `"`)]
+		c.Assert(schemas[name], NotNil, Commentf("no schema %s", name))
+	}
+	// credentials go in the Authorization header, never in a body
+	deploy := schemas["ManagerDeployArg"].(map[string]interface{})["properties"].(map[string]interface{})
+	c.Assert(deploy["App"], NotNil)
+	c.Assert(deploy["Secret"], IsNil)
+	c.Assert(deploy["Password"], IsNil)
+}
+
+func (s *OpenAPISuite) TestServe(c *C) {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/openapi.json", nil)
+	OpenAPIJSON(w, r)
+	c.Assert(w.Code, Equals, http.StatusOK)
+	c.Assert(w.Header().Get("Content-Type"), Equals, "application/json")
+	var doc map[string]interface{}
+	c.Assert(json.Unmarshal(w.Body.Bytes(), &doc), IsNil)
+	c.Assert(doc["paths"], NotNil)
+}
@@ -0,0 +1,59 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package api
+
+import (
+	. "atlantis/manager/rpc/types"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+func ListPolicies(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	var reply ManagerListPoliciesReply
+	err := manager.ListPolicies(ManagerListPoliciesArg{auth}, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status, "Policies": reply.Policies}, err))
+}
+
+// AddPolicy takes Actions as a comma separated list. Team and PolicyUser say who the policy grants.
+func AddPolicy(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	arg := ManagerAddPolicyArg{
+		ManagerAuthArg: auth,
+		Team:           r.FormValue("Team"),
+		User:           r.FormValue("PolicyUser"),
+		App:            r.FormValue("App"),
+		Env:            r.FormValue("Env"),
+		Actions:        formList(r, "Actions"),
+	}
+	var reply ManagerAddPolicyReply
+	err := manager.AddPolicy(arg, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status, "Policy": reply.Policy}, err))
+}
+
+func RemovePolicy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	var reply ManagerRemovePolicyReply
+	err := manager.RemovePolicy(ManagerRemovePolicyArg{auth, vars["ID"]}, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status}, err))
+}
+
+func CanI(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	arg := ManagerCanIArg{auth, r.FormValue("Action"), r.FormValue("App"), r.FormValue("Env")}
+	var reply ManagerCanIReply
+	err := manager.CanI(arg, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status, "Allowed": reply.Allowed,
+		"Reason": reply.Reason}, err))
+}
@@ -21,7 +21,7 @@ import (
 )
 
 func ListRouters(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -35,7 +35,7 @@ func ListRouters(w http.ResponseWriter, r *http.Request) {
 
 func RegisterRouter(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -55,7 +55,7 @@ func RegisterRouter(w http.ResponseWriter, r *http.Request) {
 
 func UnregisterRouter(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -74,7 +74,7 @@ func UnregisterRouter(w http.ResponseWriter, r *http.Request) {
 
 func GetRouter(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -92,24 +92,26 @@ func GetRouter(w http.ResponseWriter, r *http.Request) {
 }
 
 func ListRegisteredApps(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	authorizedOnly, _ := strconv.ParseBool(r.FormValue("AuthorizedOnly"))
 	if authorizedOnly {
-		arg := ManagerListRegisteredAppsArg{auth}
+		arg := ManagerListRegisteredAppsArg{auth, listOptions(r)}
 		var reply ManagerListRegisteredAppsReply
 		err := manager.ListAuthorizedRegisteredApps(arg, &reply)
-		fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Apps": reply.Apps, "Status": reply.Status}, err))
+		fmt.Fprintf(w, "%s", Output(withNextCursor(map[string]interface{}{"Apps": reply.Apps,
+			"Status": reply.Status}, reply.NextCursor), err))
 	} else {
-		arg := ManagerListRegisteredAppsArg{auth}
+		arg := ManagerListRegisteredAppsArg{auth, listOptions(r)}
 		var reply ManagerListRegisteredAppsReply
 		err := manager.ListRegisteredApps(arg, &reply)
-		fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Apps": reply.Apps, "Status": reply.Status}, err))
+		fmt.Fprintf(w, "%s", Output(withNextCursor(map[string]interface{}{"Apps": reply.Apps,
+			"Status": reply.Status}, reply.NextCursor), err))
 	}
 }
 
 func RegisterApp(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	nonAtlantis, _ := strconv.ParseBool(r.FormValue("NonAtlantis"))
 	internal, _ := strconv.ParseBool(r.FormValue("Internal"))
 	arg := ManagerRegisterAppArg{
@@ -120,6 +122,7 @@ func RegisterApp(w http.ResponseWriter, r *http.Request) {
 		Repo:           r.FormValue("Repo"),
 		Root:           r.FormValue("Root"),
 		Email:          r.FormValue("Email"),
+		Team:           r.FormValue("Team"),
 	}
 	var reply ManagerRegisterAppReply
 	err := manager.RegisterApp(arg, &reply)
@@ -128,7 +131,7 @@ func RegisterApp(w http.ResponseWriter, r *http.Request) {
 
 func UpdateApp(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	nonAtlantis, _ := strconv.ParseBool(r.FormValue("NonAtlantis"))
 	internal, _ := strconv.ParseBool(r.FormValue("Internal"))
 	arg := ManagerRegisterAppArg{
@@ -147,7 +150,7 @@ func UpdateApp(w http.ResponseWriter, r *http.Request) {
 
 func UnregisterApp(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerRegisterAppArg{ManagerAuthArg: auth, Name: vars["App"]}
 	var reply ManagerRegisterAppReply
 	err := manager.UnregisterApp(arg, &reply)
@@ -156,7 +159,7 @@ func UnregisterApp(w http.ResponseWriter, r *http.Request) {
 
 func GetApp(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerGetAppArg{ManagerAuthArg: auth, Name: vars["App"]}
 	var reply ManagerGetAppReply
 	err := manager.GetApp(arg, &reply)
@@ -164,7 +167,7 @@ func GetApp(w http.ResponseWriter, r *http.Request) {
 }
 
 func ListSupervisors(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerListSupervisorsArg{auth}
 	var reply ManagerListSupervisorsReply
 	err := manager.ListSupervisors(arg, &reply)
@@ -173,7 +176,7 @@ func ListSupervisors(w http.ResponseWriter, r *http.Request) {
 
 func RegisterSupervisor(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerRegisterSupervisorArg{auth, vars["Host"]}
 	var reply AsyncReply
 	err := manager.RegisterSupervisor(arg, &reply)
@@ -182,7 +185,7 @@ func RegisterSupervisor(w http.ResponseWriter, r *http.Request) {
 
 func UnregisterSupervisor(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerRegisterSupervisorArg{auth, vars["Host"]}
 	var reply AsyncReply
 	err := manager.UnregisterSupervisor(arg, &reply)
@@ -190,7 +193,7 @@ func UnregisterSupervisor(w http.ResponseWriter, r *http.Request) {
 }
 
 func ListManagers(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerListManagersArg{auth}
 	var reply ManagerListManagersReply
 	err := manager.ListManagers(arg, &reply)
@@ -199,7 +202,7 @@ func ListManagers(w http.ResponseWriter, r *http.Request) {
 
 func RegisterManager(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerRegisterManagerArg{
 		ManagerAuthArg: auth,
 		Host:           vars["Host"],
@@ -214,7 +217,7 @@ func RegisterManager(w http.ResponseWriter, r *http.Request) {
 
 func UnregisterManager(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerRegisterManagerArg{ManagerAuthArg: auth, Host: vars["Host"], Region: vars["Region"]}
 	var reply AsyncReply
 	err := manager.UnregisterManager(arg, &reply)
@@ -223,7 +226,7 @@ func UnregisterManager(w http.ResponseWriter, r *http.Request) {
 
 func GetManager(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerGetManagerArg{
 		ManagerAuthArg: auth,
 		Region:         vars["Region"],
@@ -235,7 +238,7 @@ func GetManager(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetSelf(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerGetSelfArg{ManagerAuthArg: auth}
 	var reply ManagerGetManagerReply
 	err := manager.GetSelf(arg, &reply)
@@ -244,7 +247,7 @@ func GetSelf(w http.ResponseWriter, r *http.Request) {
 
 func AddRole(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerRoleArg{
 		ManagerAuthArg: auth,
 		Host:           vars["Host"],
@@ -258,7 +261,7 @@ func AddRole(w http.ResponseWriter, r *http.Request) {
 
 func RemoveRole(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerRoleArg{
 		ManagerAuthArg: auth,
 		Host:           vars["Host"],
@@ -272,7 +275,7 @@ func RemoveRole(w http.ResponseWriter, r *http.Request) {
 
 func AddRoleType(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerRoleArg{
 		ManagerAuthArg: auth,
 		Host:           vars["Host"],
@@ -287,7 +290,7 @@ func AddRoleType(w http.ResponseWriter, r *http.Request) {
 
 func RemoveRoleType(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	arg := ManagerRoleArg{
 		ManagerAuthArg: auth,
 		Host:           vars["Host"],
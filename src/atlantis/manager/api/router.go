@@ -24,7 +24,7 @@ import (
 
 func GetAppEnvPort(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	pArg := ManagerGetAppEnvPortArg{
 		ManagerAuthArg: auth,
 		App:            vars["App"],
@@ -36,7 +36,7 @@ func GetAppEnvPort(w http.ResponseWriter, r *http.Request) {
 }
 
 func ListAppEnvsWithPort(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -53,7 +53,7 @@ func ListAppEnvsWithPort(w http.ResponseWriter, r *http.Request) {
 
 func UpdatePort(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -79,7 +79,7 @@ func UpdatePort(w http.ResponseWriter, r *http.Request) {
 
 func DeletePort(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -102,7 +102,7 @@ func DeletePort(w http.ResponseWriter, r *http.Request) {
 
 func GetPort(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -124,7 +124,7 @@ func GetPort(w http.ResponseWriter, r *http.Request) {
 }
 
 func ListPorts(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -138,7 +138,7 @@ func ListPorts(w http.ResponseWriter, r *http.Request) {
 
 func GetPool(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -152,7 +152,7 @@ func GetPool(w http.ResponseWriter, r *http.Request) {
 
 func UpdatePool(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -173,7 +173,7 @@ func UpdatePool(w http.ResponseWriter, r *http.Request) {
 
 func DeletePool(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -186,21 +186,22 @@ func DeletePool(w http.ResponseWriter, r *http.Request) {
 }
 
 func ListPools(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
 		return
 	}
-	arg := ManagerListPoolsArg{auth, internal}
+	arg := ManagerListPoolsArg{auth, internal, listOptions(r)}
 	var reply ManagerListPoolsReply
 	err = manager.ListPools(arg, &reply)
-	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Pools": reply.Pools, "Status": reply.Status}, err))
+	fmt.Fprintf(w, "%s", Output(withNextCursor(map[string]interface{}{"Pools": reply.Pools, "Status": reply.Status},
+		reply.NextCursor), err))
 }
 
 func GetRule(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -214,7 +215,7 @@ func GetRule(w http.ResponseWriter, r *http.Request) {
 
 func UpdateRule(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -229,7 +230,7 @@ func UpdateRule(w http.ResponseWriter, r *http.Request) {
 
 func DeleteRule(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -242,21 +243,22 @@ func DeleteRule(w http.ResponseWriter, r *http.Request) {
 }
 
 func ListRules(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
 		return
 	}
-	arg := ManagerListRulesArg{auth, internal}
+	arg := ManagerListRulesArg{auth, internal, listOptions(r)}
 	var reply ManagerListRulesReply
 	err = manager.ListRules(arg, &reply)
-	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Rules": reply.Rules, "Status": reply.Status}, err))
+	fmt.Fprintf(w, "%s", Output(withNextCursor(map[string]interface{}{"Rules": reply.Rules, "Status": reply.Status},
+		reply.NextCursor), err))
 }
 
 func GetTrie(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -270,7 +272,7 @@ func GetTrie(w http.ResponseWriter, r *http.Request) {
 
 func UpdateTrie(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -286,7 +288,7 @@ func UpdateTrie(w http.ResponseWriter, r *http.Request) {
 
 func DeleteTrie(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -299,7 +301,7 @@ func DeleteTrie(w http.ResponseWriter, r *http.Request) {
 }
 
 func ListTries(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	internal, err := strconv.ParseBool(r.FormValue("Internal"))
 	if err != nil {
 		fmt.Fprintf(w, "%s", Output(map[string]interface{}{}, err))
@@ -15,12 +15,32 @@ import (
 	. "atlantis/manager/rpc/types"
 	"fmt"
 	"net/http"
+	"strconv"
 )
 
 func Usage(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
-	arg := ManagerUsageArg{ManagerAuthArg: auth}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	aggregate, _ := strconv.ParseBool(r.FormValue("Aggregate"))
+	arg := ManagerUsageArg{ManagerAuthArg: auth, Aggregate: aggregate}
 	var reply ManagerUsageReply
 	err := manager.Usage(arg, &reply)
-	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Usage": reply.Usage}, err))
+	output := map[string]interface{}{"Status": reply.Status, "Usage": reply.Usage}
+	fmt.Fprintf(w, "%s", Output(withRegions(output, aggregate, reply.Regions, reply.RegionErrors), err))
+}
+
+func CacheStatsGet(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	arg := ManagerCacheStatsArg{ManagerAuthArg: auth}
+	var reply ManagerCacheStatsReply
+	err := manager.CacheStats(arg, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status, "Stats": reply.Stats}, err))
+}
+
+func RateLimits(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	arg := ManagerRateLimitsArg{ManagerAuthArg: auth}
+	var reply ManagerRateLimitsReply
+	err := manager.RateLimits(arg, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status, "Limits": reply.Limits,
+		"Throttled": reply.Throttled}, err))
 }
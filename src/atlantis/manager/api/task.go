@@ -14,9 +14,13 @@ package api
 import (
 	. "atlantis/common"
 	. "atlantis/manager/rpc/types"
+	"encoding/json"
 	"fmt"
 	"github.com/gorilla/mux"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func GetTaskStatus(w http.ResponseWriter, r *http.Request) {
@@ -68,9 +72,112 @@ func GetTaskStatus(w http.ResponseWriter, r *http.Request) {
 }
 
 func ListTaskIDs(w http.ResponseWriter, r *http.Request) {
-	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret")}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
 	var ids []string
 	err := manager.ListTaskIDs(auth, &ids)
 	output := map[string]interface{}{"IDs": ids}
 	fmt.Fprintf(w, "%s", Output(output, err))
 }
+
+// QueryTasks takes the filters of ManagerQueryTasksArg as form values. Names is comma separated and times are
+// RFC3339.
+func QueryTasks(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	arg := ManagerQueryTasksArg{
+		ManagerAuthArg: auth,
+		TaskUser:       r.FormValue("TaskUser"),
+		Names:          []string{},
+		App:            r.FormValue("App"),
+		Env:            r.FormValue("Env"),
+		Status:         r.FormValue("Status"),
+	}
+	if names := r.FormValue("Names"); names != "" {
+		arg.Names = strings.Split(names, ",")
+	}
+	for name, field := range map[string]*time.Time{
+		"StartedAfter":   &arg.StartedAfter,
+		"StartedBefore":  &arg.StartedBefore,
+		"FinishedAfter":  &arg.FinishedAfter,
+		"FinishedBefore": &arg.FinishedBefore,
+	} {
+		if value := r.FormValue(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				fmt.Fprintf(w, "{\"error\": \"Invalid %s: %s\"}", name, err.Error())
+				return
+			}
+			*field = parsed
+		}
+	}
+	arg.Offset, _ = strconv.Atoi(r.FormValue("Offset"))
+	arg.Limit, _ = strconv.Atoi(r.FormValue("Limit"))
+	var reply ManagerQueryTasksReply
+	err := manager.QueryTasks(arg, &reply)
+	output := map[string]interface{}{"Status": reply.Status, "Tasks": reply.Tasks, "Total": reply.Total}
+	fmt.Fprintf(w, "%s", Output(output, err))
+}
+
+func GetTaskQueue(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	var reply ManagerTaskQueueReply
+	err := manager.TaskQueue(ManagerTaskQueueArg{auth}, &reply)
+	output := map[string]interface{}{
+		"Status":   reply.Status,
+		"Running":  reply.Running,
+		"Waiting":  reply.Waiting,
+		"Limits":   reply.Limits,
+		"AppLimit": reply.AppLimit,
+	}
+	fmt.Fprintf(w, "%s", Output(output, err))
+}
+
+// StreamTask pushes each line a task logs as it happens and a final event once the task is done. Events are
+// Server-Sent Events if the client accepts text/event-stream and JSON lines otherwise.
+func StreamTask(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/x-json-stream")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	send := func(event map[string]interface{}) {
+		data, _ := json.Marshal(event)
+		if sse {
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		} else {
+			fmt.Fprintf(w, "%s\n", data)
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+	var gone <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		gone = notifier.CloseNotify()
+	}
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	arg := ManagerTaskLogArg{ManagerAuthArg: auth, ID: vars["ID"]}
+	for {
+		var reply ManagerTaskLogReply
+		if err := manager.TaskLog(arg, &reply); err != nil {
+			send(map[string]interface{}{"Error": err.Error(), "Done": true})
+			return
+		}
+		for _, line := range reply.Lines {
+			send(map[string]interface{}{"Log": line})
+		}
+		arg.Since = reply.Next
+		if reply.Done {
+			send(map[string]interface{}{"Status": reply.Status, "Warnings": reply.Warnings, "Error": reply.Error,
+				"Done": true})
+			return
+		}
+		select {
+		case <-gone:
+			return
+		default:
+		}
+	}
+}
@@ -0,0 +1,53 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package api
+
+import (
+	. "atlantis/manager/rpc/types"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+func ListTokens(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	var reply ManagerListTokensReply
+	err := manager.ListTokens(ManagerListTokensArg{auth}, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status, "Tokens": reply.Tokens}, err))
+}
+
+// CreateToken takes Apps, Envs and Operations as comma separated lists. The reply is the only time Secret is shown.
+func CreateToken(w http.ResponseWriter, r *http.Request) {
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	arg := ManagerCreateTokenArg{
+		ManagerAuthArg: auth,
+		Name:           r.FormValue("Name"),
+		ServiceAccount: r.FormValue("ServiceAccount"),
+		Role:           r.FormValue("Role"),
+		Apps:           formList(r, "Apps"),
+		Envs:           formList(r, "Envs"),
+		Operations:     formList(r, "Operations"),
+		TTL:            r.FormValue("TTL"),
+	}
+	var reply ManagerCreateTokenReply
+	err := manager.CreateToken(arg, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status, "Token": reply.Token,
+		"Secret": reply.Secret}, err))
+}
+
+func RevokeToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	auth := ManagerAuthArg{r.FormValue("User"), "", r.FormValue("Secret"), r.RemoteAddr}
+	var reply ManagerRevokeTokenReply
+	err := manager.RevokeToken(ManagerRevokeTokenArg{auth, vars["ID"]}, &reply)
+	fmt.Fprintf(w, "%s", Output(map[string]interface{}{"Status": reply.Status}, err))
+}
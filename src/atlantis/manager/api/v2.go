@@ -0,0 +1,350 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package api
+
+import (
+	. "atlantis/common"
+	"atlantis/manager/rpc"
+	. "atlantis/manager/rpc/types"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	gozk "github.com/scalingdata/gozk"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const v2Prefix = "/v2"
+
+// v2Route maps an endpoint of the v2 API onto an RPC. The arg of the RPC is read from the JSON body of the request,
+// then from its query parameters and path variables, which are named after the fields of the arg they set. Nested
+// fields are named with dots, e.g. {Pool.Name}.
+type v2Route struct {
+	Method string
+	Path   string // relative to /v2
+	RPC    string
+	Body   string            // if set, the body is this field of the arg rather than the whole arg
+	Set    map[string]string // fields the route always sets, e.g. Reject for /approvals/{ID}/reject
+}
+
+// V2Error is what a failed v2 request returns, as {"Error": {...}}
+type V2Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *V2Error) Error() string {
+	return e.Message
+}
+
+func newV2Error(status int, message string) *V2Error {
+	return &V2Error{status, strings.Replace(http.StatusText(status), " ", "", -1), message}
+}
+
+// v2ErrorStatuses maps the kinds of RPC errors onto HTTP statuses
+var v2ErrorStatuses = map[rpc.ErrorKind]int{
+	rpc.ErrUnauthenticated: http.StatusUnauthorized,
+	rpc.ErrForbidden:       http.StatusForbidden,
+	rpc.ErrNotFound:        http.StatusNotFound,
+	rpc.ErrConflict:        http.StatusConflict,
+	rpc.ErrInvalid:         http.StatusBadRequest,
+	rpc.ErrUnsupported:     http.StatusNotImplemented,
+}
+
+// Errors of tasks that were waited for only keep their message, so those are told by what they say. The first
+// kind that has a phrase in the message wins; anything else is an internal error.
+var v2ErrorKinds = []struct {
+	status  int
+	phrases []string
+}{
+	{http.StatusUnauthorized, []string{"invalid credentials", "session expired", "invalid or revoked api token",
+		"jwt", "bearer token"}},
+	{http.StatusForbidden, []string{"not authorized", "not a super user", "not a team admin", "permission denied",
+		"do not have permission", "may not call", "is limited to rpcs", "is not allowed", "only superusers",
+		"cannot approve your own", "belongs to"}},
+	{http.StatusNotFound, []string{"does not exist", "not found", "no such", "unknown id", "is not registered",
+		"no pending approval"}},
+	{http.StatusConflict, []string{"already", "isn't done", "locked"}},
+	{http.StatusNotImplemented, []string{"no longer supported"}},
+	{http.StatusBadRequest, []string{"please specify", "empty", "invalid", "unknown", "must be", "may not change",
+		"would never run"}},
+}
+
+// v2ErrorFor returns the error object for err
+func v2ErrorFor(err error) *V2Error {
+	switch err := err.(type) {
+	case *V2Error:
+		return err
+	case *rpc.RateLimitError:
+		return newV2Error(http.StatusTooManyRequests, err.Error())
+	case *rpc.Error:
+		if status, ok := v2ErrorStatuses[err.Kind]; ok {
+			return newV2Error(status, err.Error())
+		}
+	}
+	if gozk.IsError(err, gozk.ZNONODE) {
+		return newV2Error(http.StatusNotFound, err.Error())
+	}
+	message := strings.ToLower(err.Error())
+	for _, kind := range v2ErrorKinds {
+		for _, phrase := range kind.phrases {
+			if strings.Contains(message, phrase) {
+				return newV2Error(kind.status, err.Error())
+			}
+		}
+	}
+	return newV2Error(http.StatusInternalServerError, err.Error())
+}
+
+func writeV2JSON(w http.ResponseWriter, status int, obj interface{}) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		status = http.StatusInternalServerError
+		data, _ = json.Marshal(map[string]interface{}{"Error": newV2Error(status, err.Error())})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func writeV2Error(w http.ResponseWriter, err error) {
+	v2Err := v2ErrorFor(err)
+	if limitErr, ok := err.(*rpc.RateLimitError); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.RetryAfter.Seconds()))))
+	}
+	writeV2JSON(w, v2Err.Status, map[string]interface{}{"Error": v2Err})
+}
+
+func isV2(r *http.Request) bool {
+	return r.URL.Path == v2Prefix || strings.HasPrefix(r.URL.Path, v2Prefix+"/")
+}
+
+// v2HeaderCredentials reads the credentials of a v2 request from its Authorization header: Basic for a user and
+// password, Bearer for a session secret, API token or identity provider token. A session secret only works with
+// the user it belongs to, which goes in the X-Atlantis-User header.
+func v2HeaderCredentials(r *http.Request) ManagerAuthArg {
+	auth := ManagerAuthArg{Source: r.RemoteAddr}
+	header := r.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(header, "Basic "):
+		if data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header[len("Basic "):])); err == nil {
+			if parts := strings.SplitN(string(data), ":", 2); len(parts) == 2 {
+				auth.User, auth.Password = parts[0], parts[1]
+			}
+		}
+	case strings.HasPrefix(header, "Bearer "):
+		auth.User = r.Header.Get("X-Atlantis-User")
+		auth.Secret = strings.TrimSpace(header[len("Bearer "):])
+	}
+	return auth
+}
+
+// v2Credentials returns who is making a v2 request. Requests without an Authorization header act as their client
+// certificate, if its CN has a role.
+func v2Credentials(r *http.Request) (ManagerAuthArg, error) {
+	auth := v2HeaderCredentials(r)
+	if r.Header.Get("Authorization") == "" && r.TLS != nil {
+		user, secret, ok, err := rpc.CertLogin(r.TLS)
+		if err != nil {
+			return auth, err
+		}
+		if ok {
+			auth.User, auth.Secret = user, secret
+		}
+	}
+	return auth, nil
+}
+
+// v2Field returns the field of v named name, allocating the structs it is in along the way. It returns an invalid
+// value if there is no such exported field.
+func v2Field(v reflect.Value, name string) reflect.Value {
+	for _, part := range strings.Split(name, ".") {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}
+		}
+		if field, ok := v.Type().FieldByName(part); !ok || field.PkgPath != "" {
+			return reflect.Value{}
+		}
+		v = v.FieldByName(part)
+	}
+	return v
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// setV2Field sets the field of v named name to value, which is a path variable or query parameter
+func setV2Field(v reflect.Value, name, value string) error {
+	field := v2Field(v, name)
+	if !field.IsValid() || !field.CanSet() {
+		return newV2Error(http.StatusBadRequest, "Unknown parameter "+name)
+	}
+	var err error
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(value); err == nil {
+			field.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(value, 10, field.Type().Bits()); err == nil {
+			field.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		if u, err = strconv.ParseUint(value, 10, field.Type().Bits()); err == nil {
+			field.SetUint(u)
+		}
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return newV2Error(http.StatusBadRequest, name+" can only be set in the request body")
+		}
+		field.Set(reflect.ValueOf(strings.Split(value, ",")).Convert(field.Type()))
+	case reflect.Struct:
+		if field.Type() != timeType {
+			return newV2Error(http.StatusBadRequest, name+" can only be set in the request body")
+		}
+		var t time.Time
+		if t, err = time.Parse(time.RFC3339, value); err == nil {
+			field.Set(reflect.ValueOf(t))
+		}
+	default:
+		return newV2Error(http.StatusBadRequest, name+" can only be set in the request body")
+	}
+	if err != nil {
+		return newV2Error(http.StatusBadRequest, fmt.Sprintf("Invalid %s %q", name, value))
+	}
+	return nil
+}
+
+// setV2Auth makes arg act as auth. Logins take the user and password of the Authorization header if there is one.
+func setV2Auth(arg reflect.Value, auth ManagerAuthArg) {
+	switch arg := arg.Interface().(type) {
+	case *ManagerAuthArg:
+		*arg = auth
+		return
+	case *ManagerLoginArg:
+		if auth.User != "" {
+			arg.User, arg.Pass, arg.Secret = auth.User, auth.Password, auth.Secret
+		}
+		return
+	}
+	if arg.Elem().Kind() != reflect.Struct {
+		return
+	}
+	if field, ok := arg.Elem().Type().FieldByName("ManagerAuthArg"); ok && field.Anonymous {
+		arg.Elem().FieldByIndex(field.Index).Set(reflect.ValueOf(auth))
+	}
+}
+
+// decodeV2Arg fills arg, a pointer to the arg of the RPC of route, from r
+func decodeV2Arg(r *http.Request, route v2Route, arg reflect.Value) error {
+	query := r.URL.Query()
+	for _, key := range []string{"User", "Password", "Secret"} {
+		if _, ok := query[key]; ok {
+			return newV2Error(http.StatusBadRequest, "Credentials go in the Authorization header, not the URL")
+		}
+	}
+	if r.Body != nil && r.ContentLength != 0 && r.Method != "GET" {
+		if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
+			return newV2Error(http.StatusUnsupportedMediaType, "Request bodies must be application/json")
+		}
+		target := arg
+		if route.Body != "" {
+			target = v2Field(arg, route.Body).Addr()
+		}
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(target.Interface()); err != nil {
+			return newV2Error(http.StatusBadRequest, "Invalid request body: "+err.Error())
+		}
+	}
+	if arg.Elem().Kind() == reflect.String {
+		// Status and the *Result RPCs just take a task id
+		arg.Elem().SetString(mux.Vars(r)["ID"])
+	} else {
+		for key, values := range query {
+			if err := setV2Field(arg, key, strings.Join(values, ",")); err != nil {
+				return err
+			}
+		}
+		for key, value := range mux.Vars(r) {
+			if err := setV2Field(arg, key, value); err != nil {
+				return err
+			}
+		}
+		for key, value := range route.Set {
+			if err := setV2Field(arg, key, value); err != nil {
+				return err
+			}
+		}
+	}
+	auth, err := v2Credentials(r)
+	if err != nil {
+		return err
+	}
+	setV2Auth(arg, auth)
+	// unlike REST requests in general, a v2 request is a single RPC, so tokens limited to some RPCs can use it
+	return rpc.AuthorizeTokenOperation(auth.Secret, route.RPC)
+}
+
+// v2Handler calls the RPC of route. Async RPCs answer 202 Accepted with the task to follow in Location.
+func v2Handler(route v2Route) http.HandlerFunc {
+	method := reflect.ValueOf(manager).MethodByName(route.RPC)
+	if !method.IsValid() {
+		panic("[API] v2 route " + route.Path + " is for unknown RPC " + route.RPC)
+	}
+	argType, replyType := method.Type().In(0), method.Type().In(1).Elem()
+	return func(w http.ResponseWriter, r *http.Request) {
+		arg := reflect.New(argType)
+		if err := decodeV2Arg(r, route, arg); err != nil {
+			writeV2Error(w, err)
+			return
+		}
+		reply := reflect.New(replyType)
+		if err, _ := method.Call([]reflect.Value{arg.Elem(), reply})[0].Interface().(error); err != nil {
+			writeV2Error(w, err)
+			return
+		}
+		status := http.StatusOK
+		if async, ok := reply.Interface().(*AsyncReply); ok {
+			w.Header().Set("Location", v2Prefix+"/tasks/"+async.ID)
+			status = http.StatusAccepted
+		}
+		writeV2JSON(w, status, reply.Interface())
+	}
+}
+
+func initV2(gmux *mux.Router) {
+	for _, route := range v2Routes {
+		gmux.HandleFunc(v2Prefix+route.Path, v2Handler(route)).Methods(route.Method)
+	}
+}
+
+// v2NotFound is NotFound for the v2 API
+func v2NotFound(w http.ResponseWriter, r *http.Request) {
+	writeV2Error(w, newV2Error(http.StatusNotFound, "No such endpoint "+r.Method+" "+r.URL.Path))
+}
@@ -0,0 +1,196 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package api
+
+// v2Routes are the endpoints of the v2 API, one for every RPC and a few more where a flag reads better as a path.
+// Routes are matched in order, so fixed paths come before variables in the same place.
+var v2Routes = []v2Route{
+	// Login
+	{"POST", "/login", "Login", "", nil},
+	{"POST", "/logout", "Logout", "", nil},
+
+	// Task Management
+	{"GET", "/tasks", "ListTaskIDs", "", nil},
+	{"GET", "/tasks/queue", "TaskQueue", "", nil},
+	{"GET", "/tasks/query", "QueryTasks", "", nil},
+	{"GET", "/tasks/{ID}", "Status", "", nil},
+	{"GET", "/tasks/{ID}/log", "TaskLog", "", nil},
+	{"GET", "/tasks/{ID}/results/deploy", "DeployResult", "", nil},
+	{"GET", "/tasks/{ID}/results/copy-container", "CopyContainerResult", "", nil},
+	{"GET", "/tasks/{ID}/results/teardown", "TeardownResult", "", nil},
+	{"GET", "/tasks/{ID}/results/register-router", "RegisterRouterResult", "", nil},
+	{"GET", "/tasks/{ID}/results/unregister-router", "UnregisterRouterResult", "", nil},
+	{"GET", "/tasks/{ID}/results/register-supervisor", "RegisterSupervisorResult", "", nil},
+	{"GET", "/tasks/{ID}/results/unregister-supervisor", "UnregisterSupervisorResult", "", nil},
+	{"GET", "/tasks/{ID}/results/register-manager", "RegisterManagerResult", "", nil},
+	{"GET", "/tasks/{ID}/results/unregister-manager", "UnregisterManagerResult", "", nil},
+
+	// Scheduled Jobs
+	{"GET", "/jobs", "ListJobs", "", nil},
+	{"POST", "/jobs", "ScheduleJob", "", nil},
+	{"DELETE", "/jobs/{ID}", "DeleteJob", "", nil},
+	{"POST", "/jobs/{ID}/pause", "PauseJob", "", map[string]string{"Paused": "true"}},
+	{"POST", "/jobs/{ID}/resume", "PauseJob", "", map[string]string{"Paused": "false"}},
+
+	// API Tokens
+	{"GET", "/tokens", "ListTokens", "", nil},
+	{"POST", "/tokens", "CreateToken", "", nil},
+	{"DELETE", "/tokens/{ID}", "RevokeToken", "", nil},
+
+	// Policies
+	{"GET", "/policies", "ListPolicies", "", nil},
+	{"POST", "/policies", "AddPolicy", "", nil},
+	{"DELETE", "/policies/{ID}", "RemovePolicy", "", nil},
+	{"GET", "/can-i", "CanI", "", nil},
+
+	// Approvals
+	{"GET", "/approvals", "ListApprovals", "", nil},
+	{"POST", "/approvals/{ID}/approve", "ApproveTask", "", nil},
+	{"POST", "/approvals/{ID}/reject", "ApproveTask", "", map[string]string{"Reject": "true"}},
+
+	// Audit Log
+	{"GET", "/audit", "Audit", "", nil},
+
+	// Manager Management
+	{"GET", "/health", "HealthCheck", "", nil},
+	{"GET", "/idle", "Idle", "", nil},
+	{"GET", "/version", "Version", "", nil},
+	{"GET", "/usage", "Usage", "", nil},
+	{"GET", "/cache", "CacheStats", "", nil},
+	{"GET", "/ratelimits", "RateLimits", "", nil},
+	{"GET", "/managers", "ListManagers", "", nil},
+	{"GET", "/managers/self", "GetSelf", "", nil},
+	{"GET", "/managers/{Region}/{Host}", "GetManager", "", nil},
+	{"PUT", "/managers/{Region}/{Host}", "RegisterManager", "", nil},
+	{"DELETE", "/managers/{Region}/{Host}", "UnregisterManager", "", nil},
+	{"GET", "/managers/{Region}/{Host}/roles/{Role}", "HasRole", "", nil},
+	{"PUT", "/managers/{Region}/{Host}/roles/{Role}", "AddRole", "", nil},
+	{"DELETE", "/managers/{Region}/{Host}/roles/{Role}", "RemoveRole", "", nil},
+	{"GET", "/managers/{Region}/{Host}/roles/{Role}/{Type}", "HasRole", "", nil},
+	{"PUT", "/managers/{Region}/{Host}/roles/{Role}/{Type}", "AddRole", "", nil},
+	{"DELETE", "/managers/{Region}/{Host}/roles/{Role}/{Type}", "RemoveRole", "", nil},
+
+	// Supervisor Management
+	{"GET", "/supervisors", "ListSupervisors", "", nil},
+	{"PUT", "/supervisors/{Host}", "RegisterSupervisor", "", nil},
+	{"DELETE", "/supervisors/{Host}", "UnregisterSupervisor", "", nil},
+
+	// Router Management
+	{"GET", "/routers", "ListRouters", "", nil},
+	{"GET", "/routers/{Zone}/{Host}", "GetRouter", "", nil},
+	{"PUT", "/routers/{Zone}/{Host}", "RegisterRouter", "", nil},
+	{"DELETE", "/routers/{Zone}/{Host}", "UnregisterRouter", "", nil},
+
+	// App Management
+	{"GET", "/apps", "ListRegisteredApps", "", nil},
+	{"GET", "/authorized-apps", "ListAuthorizedRegisteredApps", "", nil},
+	{"GET", "/apps/{Name}", "GetApp", "", nil},
+	{"PUT", "/apps/{Name}", "RegisterApp", "", nil},
+	{"POST", "/apps/{Name}", "UpdateApp", "", nil},
+	{"DELETE", "/apps/{Name}", "UnregisterApp", "", nil},
+	{"PUT", "/apps/{App}/env/{DependerEnvData.Name}", "AddDependerEnvData", "DependerEnvData", nil},
+	{"GET", "/apps/{App}/env/{Env}", "GetDependerEnvData", "", nil},
+	{"DELETE", "/apps/{App}/env/{Env}", "RemoveDependerEnvData", "", nil},
+	{"PUT", "/apps/{App}/depender/{DependerAppData.Name}", "AddDependerAppData", "DependerAppData", nil},
+	{"GET", "/apps/{App}/depender/{Depender}", "GetDependerAppData", "", nil},
+	{"DELETE", "/apps/{App}/depender/{Depender}", "RemoveDependerAppData", "", nil},
+	{"POST", "/apps/{Dependency}/depender/{App}/request", "RequestAppDependency", "", nil},
+	{"PUT", "/apps/{App}/depender/{Depender}/env/{DependerEnvData.Name}", "AddDependerEnvDataForDependerApp",
+		"DependerEnvData", nil},
+	{"GET", "/apps/{App}/depender/{Depender}/env/{Env}", "GetDependerEnvDataForDependerApp", "", nil},
+	{"DELETE", "/apps/{App}/depender/{Depender}/env/{Env}", "RemoveDependerEnvDataForDependerApp", "", nil},
+
+	// Router Config Management
+	{"GET", "/pools", "ListPools", "", nil},
+	{"GET", "/pools/{Name}", "GetPool", "", nil},
+	{"PUT", "/pools/{Pool.Name}", "UpdatePool", "Pool", nil},
+	{"DELETE", "/pools/{Name}", "DeletePool", "", nil},
+	{"GET", "/rules", "ListRules", "", nil},
+	{"GET", "/rules/{Name}", "GetRule", "", nil},
+	{"PUT", "/rules/{Rule.Name}", "UpdateRule", "Rule", nil},
+	{"DELETE", "/rules/{Name}", "DeleteRule", "", nil},
+	{"GET", "/tries", "ListTries", "", nil},
+	{"GET", "/tries/{Name}", "GetTrie", "", nil},
+	{"PUT", "/tries/{Trie.Name}", "UpdateTrie", "Trie", nil},
+	{"DELETE", "/tries/{Name}", "DeleteTrie", "", nil},
+	{"GET", "/ports", "ListPorts", "", nil},
+	{"GET", "/ports/apps", "ListAppEnvsWithPort", "", nil},
+	{"GET", "/ports/apps/{App}/envs/{Env}", "GetAppEnvPort", "", nil},
+	{"GET", "/ports/{Port}", "GetPort", "", nil},
+	{"PUT", "/ports/{Port.Port}", "UpdatePort", "Port", nil},
+	{"DELETE", "/ports/{Port}", "DeletePort", "", nil},
+
+	// Instance Management
+	{"GET", "/instances", "ListContainers", "", nil},
+	{"DELETE", "/instances", "Teardown", "", nil},
+	{"GET", "/instances/apps", "ListApps", "", nil},
+	{"DELETE", "/instances/apps/{App}", "Teardown", "", nil},
+	{"GET", "/instances/apps/{App}/shas", "ListShas", "", nil},
+	{"DELETE", "/instances/apps/{App}/shas/{Sha}", "Teardown", "", nil},
+	{"GET", "/instances/apps/{App}/shas/{Sha}/envs", "ListEnvs", "", nil},
+	{"DELETE", "/instances/apps/{App}/shas/{Sha}/envs/{Env}", "Teardown", "", nil},
+	{"GET", "/instances/apps/{App}/shas/{Sha}/envs/{Env}/containers", "ListContainers", "", nil},
+	{"POST", "/instances/apps/{App}/shas/{Sha}/envs/{Env}/containers", "Deploy", "", nil},
+	{"GET", "/instances/{ContainerID}", "GetContainer", "", nil},
+	{"DELETE", "/instances/{ContainerID}", "Teardown", "", nil},
+	{"POST", "/instances/{ContainerID}/deploy", "DeployContainer", "", nil},
+	{"POST", "/instances/{ContainerID}/copy", "CopyContainer", "", nil},
+	{"POST", "/instances/{ContainerID}/maint", "ContainerMaintenance", "", nil},
+	{"PUT", "/instances/{ContainerID}/ssh", "AuthorizeSSH", "", nil},
+	{"DELETE", "/instances/{ContainerID}/ssh", "DeauthorizeSSH", "", nil},
+	{"GET", "/containers", "QueryContainers", "", nil},
+	{"GET", "/containers/details", "GetContainers", "", nil},
+	{"GET", "/supervisors/{Supervisor}/containers", "QueryContainers", "", nil},
+	{"GET", "/envs/{Env}/containers", "QueryContainers", "", nil},
+	{"GET", "/teams/{Team}/containers", "QueryContainers", "", nil},
+	{"POST", "/indexes", "RebuildIndexes", "", nil},
+
+	// Lock Management
+	{"GET", "/locks", "ListLocks", "", nil},
+	{"DELETE", "/locks", "ForceUnlock", "", nil},
+
+	// LDAP Management
+	{"GET", "/is-superuser", "IsSuperUser", "", nil},
+	{"GET", "/users/{User}/apps", "ListAllowedApps", "", nil},
+	{"GET", "/users/{User}/apps/{App}", "IsAppAllowed", "", nil},
+	{"GET", "/teams", "ListTeams", "", nil},
+	{"POST", "/teams/sync", "SyncTeamApps", "", nil},
+	{"PUT", "/teams/{Team}", "CreateTeam", "", nil},
+	{"DELETE", "/teams/{Team}", "DeleteTeam", "", nil},
+	{"GET", "/teams/{Team}/is-admin", "IsTeamAdmin", "", nil},
+	{"GET", "/teams/{Team}/apps", "ListTeamApps", "", nil},
+	{"PUT", "/teams/{Team}/apps/{App}", "AllowApp", "", nil},
+	{"DELETE", "/teams/{Team}/apps/{App}", "DisallowApp", "", nil},
+	{"GET", "/teams/{Team}/admins", "ListTeamAdmins", "", nil},
+	{"PUT", "/teams/{Team}/admins/{User}", "AddTeamAdmin", "", nil},
+	{"DELETE", "/teams/{Team}/admins/{User}", "RemoveTeamAdmin", "", nil},
+	{"GET", "/teams/{Team}/members", "ListTeamMembers", "", nil},
+	{"PUT", "/teams/{Team}/members/{User}", "AddTeamMember", "", nil},
+	{"DELETE", "/teams/{Team}/members/{User}", "RemoveTeamMember", "", nil},
+	{"GET", "/teams/{Team}/emails", "ListTeamEmails", "", nil},
+	{"PUT", "/teams/{Team}/emails/{Email}", "AddTeamEmail", "", nil},
+	{"DELETE", "/teams/{Team}/emails/{Email}", "RemoveTeamEmail", "", nil},
+
+	// Environment Management
+	{"GET", "/envs", "ListEnvs", "", nil},
+	{"GET", "/envs/{Env}/app/{App}/resolve/{DepNames}", "ResolveDeps", "", nil},
+	{"PUT", "/envs/{Name}", "UpdateEnv", "", nil},
+	{"DELETE", "/envs/{Name}", "DeleteEnv", "", nil},
+	{"POST", "/envs/{Name}/protect", "ProtectEnv", "", map[string]string{"Protected": "true"}},
+	{"DELETE", "/envs/{Name}/protect", "ProtectEnv", "", map[string]string{"Protected": "false"}},
+
+	// IP Group Management
+	{"GET", "/ipgroups", "ListIPGroups", "", nil},
+	{"GET", "/ipgroups/{Name}", "GetIPGroup", "", nil},
+	{"PUT", "/ipgroups/{Name}", "UpdateIPGroup", "", nil},
+	{"DELETE", "/ipgroups/{Name}", "DeleteIPGroup", "", nil},
+}
@@ -0,0 +1,120 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package auth
+
+import (
+	"atlantis/manager/ldap"
+	"time"
+)
+
+// An AuthProvider checks who someone is. It knows nothing of sessions; Login keeps those for every provider.
+type AuthProvider interface {
+	// Authenticate checks the password of user and returns the teams user is in.
+	Authenticate(user, password string) (teams []string, err error)
+}
+
+// A BearerProvider also accepts tokens issued by someone else, e.g. an identity provider, in place of a secret.
+type BearerProvider interface {
+	AuthProvider
+	// IsBearer returns true if secret looks like one of its tokens.
+	IsBearer(secret string) bool
+	// Verify checks token and returns who it was issued to, the teams they are in and when it expires.
+	Verify(token string) (user string, teams []string, expires time.Time, err error)
+}
+
+// ErrInvalidCredentials is what Login returns when the password or secret it is passed is wrong or has expired.
+var ErrInvalidCredentials = ldap.ErrInvalidCredentials
+
+// A BearerError says why a bearer token was turned away.
+type BearerError struct {
+	Reason string
+}
+
+func (e *BearerError) Error() string {
+	return e.Reason
+}
+
+// IsInvalidCredentials returns true if err says that the credentials passed to Login were not good, rather than
+// that they could not be checked.
+func IsInvalidCredentials(err error) bool {
+	_, isBearer := err.(*BearerError)
+	return err == ErrInvalidCredentials || isBearer
+}
+
+// Provider is the AuthProvider that Login uses. The server picks it from its config.
+var Provider AuthProvider = LDAPProvider{}
+
+// skipLogin returns true if everyone is let in, which is the case for LDAP without a server to ask.
+func skipLogin() bool {
+	_, isLDAP := Provider.(LDAPProvider)
+	return isLDAP && ldap.LoginSkipped()
+}
+
+// Login checks the credentials of user and returns who they are and the secret of their session. A secret of a
+// session that is still going is good enough; otherwise Provider checks the password and a new session starts.
+// Bearer tokens need no session of their own, so the one Login returns ends when the token expires.
+func Login(user, password, secret string) (string, string, error) {
+	if skipLogin() {
+		return user, "dummysecret", nil // just let everything pass
+	}
+	if bearer, ok := Provider.(BearerProvider); ok && bearer.IsBearer(secret) {
+		return loginBearer(bearer, user, secret)
+	}
+	if ldap.ResumeSession(user, secret) {
+		return user, secret, nil
+	}
+	if password == "" {
+		return "", "", ErrInvalidCredentials
+	}
+	teams, err := Provider.Authenticate(user, password)
+	if err != nil {
+		return "", "", err
+	}
+	secret, err = ldap.NewSession(user, password, teams)
+	return user, secret, err
+}
+
+// loginBearer checks token once and then keeps who it is for in a session until it expires, so that everything
+// that looks up teams by session works for bearer tokens too.
+func loginBearer(bearer BearerProvider, user, token string) (string, string, error) {
+	if user != "" {
+		if session := ldap.Sessions.Get(user, token); session != nil {
+			return user, token, nil
+		}
+	}
+	claimed, teams, expires, err := bearer.Verify(token)
+	if err != nil {
+		return "", "", &BearerError{err.Error()}
+	}
+	if user != "" && user != claimed {
+		return "", "", &BearerError{"The bearer token was issued to " + claimed + ", not " + user}
+	}
+	if ldap.Sessions.Get(claimed, token) != nil {
+		return claimed, token, nil
+	}
+	if max := time.Now().Add(ldap.SessionTTL); expires.After(max) {
+		expires = max
+	}
+	session := &ldap.Session{User: claimed, Team: teams, Expires: expires}
+	if err := ldap.Sessions.Put(token, session); err != nil {
+		return "", "", err
+	}
+	return claimed, token, nil
+}
+
+// Logout ends the session of user with secret on every manager that shares it.
+func Logout(user, secret string) error {
+	if skipLogin() {
+		return nil
+	}
+	return ldap.Sessions.Delete(user, secret)
+}
@@ -0,0 +1,184 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package auth
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileProvider checks passwords against an htpasswd file, for small installs and development. Each line is
+// user:hash[:team,team...] where hash is made by htpasswd -m (apr1) or -s (SHA). The teams are optional; put users in the superuser group
+// to make them superusers. Lines starting with # are ignored. The file is read again whenever it changes.
+type FileProvider struct {
+	sync.Mutex
+	path    string
+	modTime time.Time
+	users   map[string]fileUser
+}
+
+type fileUser struct {
+	hash  string
+	teams []string
+}
+
+func NewFileProvider(path string) (*FileProvider, error) {
+	p := &FileProvider{path: path}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// load reads the file if it changed since it was last read
+func (p *FileProvider) load() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+	if p.users != nil && info.ModTime().Equal(p.modTime) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+	p.users = parseHtpasswd(string(data))
+	p.modTime = info.ModTime()
+	return nil
+}
+
+func parseHtpasswd(data string) map[string]fileUser {
+	users := map[string]fileUser{}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) < 2 {
+			log.Printf("Warning: skipping malformed htpasswd line for %s", fields[0])
+			continue
+		}
+		user := fileUser{hash: fields[1], teams: []string{}}
+		if len(fields) == 3 && fields[2] != "" {
+			user.teams = strings.Split(fields[2], ",")
+		}
+		users[fields[0]] = user
+	}
+	return users
+}
+
+func (p *FileProvider) Authenticate(user, password string) ([]string, error) {
+	p.Lock()
+	defer p.Unlock()
+	if err := p.load(); err != nil {
+		// keep going with what was read before rather than lock everyone out
+		log.Printf("Warning: could not read htpasswd file %s: %s", p.path, err)
+	}
+	if fu, ok := p.users[user]; ok && checkPassword(fu.hash, password) {
+		return fu.teams, nil
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// checkPassword returns true if password matches an htpasswd hash. bcrypt and crypt(3) hashes are not supported.
+func checkPassword(hash, password string) bool {
+	var computed string
+	switch {
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		computed = "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+	case strings.HasPrefix(hash, "$apr1$"):
+		computed = md5Crypt(password, hashSalt(hash, "$apr1$"), "$apr1$")
+	case strings.HasPrefix(hash, "$1$"):
+		computed = md5Crypt(password, hashSalt(hash, "$1$"), "$1$")
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(hash)) == 1
+}
+
+func hashSalt(hash, magic string) string {
+	salt := strings.TrimPrefix(hash, magic)
+	if i := strings.Index(salt, "$"); i >= 0 {
+		salt = salt[:i]
+	}
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	return salt
+}
+
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// md5Crypt is the MD5 based crypt of FreeBSD, which Apache calls apr1 when magic is $apr1$.
+func md5Crypt(password, salt, magic string) string {
+	pw, s := []byte(password), []byte(salt)
+	alt := md5.Sum(append(append(append([]byte{}, pw...), s...), pw...))
+	ctx := append(append(append([]byte{}, pw...), magic...), s...)
+	for i := len(pw); i > 0; i -= 16 {
+		if i > 16 {
+			ctx = append(ctx, alt[:]...)
+		} else {
+			ctx = append(ctx, alt[:i]...)
+		}
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			ctx = append(ctx, 0)
+		} else {
+			ctx = append(ctx, pw[0])
+		}
+	}
+	final := md5.Sum(ctx)
+	for i := 0; i < 1000; i++ {
+		round := []byte{}
+		if i&1 != 0 {
+			round = append(round, pw...)
+		} else {
+			round = append(round, final[:]...)
+		}
+		if i%3 != 0 {
+			round = append(round, s...)
+		}
+		if i%7 != 0 {
+			round = append(round, pw...)
+		}
+		if i&1 != 0 {
+			round = append(round, final[:]...)
+		} else {
+			round = append(round, pw...)
+		}
+		final = md5.Sum(round)
+	}
+	out := []byte(magic + salt + "$")
+	encode := func(v uint, n int) {
+		for ; n > 0; n-- {
+			out = append(out, cryptAlphabet[v&0x3f])
+			v >>= 6
+		}
+	}
+	for _, g := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		encode(uint(final[g[0]])<<16|uint(final[g[1]])<<8|uint(final[g[2]]), 4)
+	}
+	encode(uint(final[11]), 2)
+	return string(out)
+}
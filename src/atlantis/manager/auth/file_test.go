@@ -0,0 +1,68 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package auth
+
+import (
+	. "github.com/adjust/gocheck"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestAuth(t *testing.T) { TestingT(t) }
+
+type FileSuite struct{}
+
+var _ = Suite(&FileSuite{})
+
+func (s *FileSuite) TestCheckPassword(c *C) {
+	c.Assert(checkPassword("{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", "secret"), Equals, true)
+	c.Assert(checkPassword("{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", "wrong"), Equals, false)
+	c.Assert(checkPassword("$apr1$abcdefgh$h9FWgUz3n9YxylKLlR5SQ/", "secret"), Equals, true)
+	c.Assert(checkPassword("$apr1$abcdefgh$h9FWgUz3n9YxylKLlR5SQ/", "wrong"), Equals, false)
+	c.Assert(checkPassword("$1$abcdefgh$cHJi5PXp/ki/ktXzqlk6I1", "secret"), Equals, true)
+	c.Assert(checkPassword("$2y$05$notsupported", "secret"), Equals, false)
+	c.Assert(checkPassword("secret", "secret"), Equals, false)
+}
+
+func (s *FileSuite) TestFileProvider(c *C) {
+	file := path.Join(c.MkDir(), "htpasswd")
+	c.Assert(ioutil.WriteFile(file, []byte("# users\nalice:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=:ops,dev\n"+
+		"bob:$apr1$abcdefgh$h9FWgUz3n9YxylKLlR5SQ/\nmalformed\n"), 0600), IsNil)
+	p, err := NewFileProvider(file)
+	c.Assert(err, IsNil)
+	teams, err := p.Authenticate("alice", "secret")
+	c.Assert(err, IsNil)
+	c.Assert(teams, DeepEquals, []string{"ops", "dev"})
+	teams, err = p.Authenticate("bob", "secret")
+	c.Assert(err, IsNil)
+	c.Assert(teams, DeepEquals, []string{})
+	_, err = p.Authenticate("alice", "wrong")
+	c.Assert(err, Not(IsNil))
+	_, err = p.Authenticate("malformed", "")
+	c.Assert(err, Not(IsNil))
+
+	// changes are picked up without a restart
+	c.Assert(ioutil.WriteFile(file, []byte("bob:$1$abcdefgh$cHJi5PXp/ki/ktXzqlk6I1:ops\n"), 0600), IsNil)
+	later := time.Now().Add(time.Minute)
+	c.Assert(os.Chtimes(file, later, later), IsNil)
+	_, err = p.Authenticate("alice", "secret")
+	c.Assert(err, Not(IsNil))
+	teams, err = p.Authenticate("bob", "secret")
+	c.Assert(err, IsNil)
+	c.Assert(teams, DeepEquals, []string{"ops"})
+
+	_, err = NewFileProvider(path.Join(c.MkDir(), "missing"))
+	c.Assert(err, Not(IsNil))
+}
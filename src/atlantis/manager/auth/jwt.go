@@ -0,0 +1,358 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package auth
+
+import (
+	"atlantis/manager/ldap"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jwtLeeway           = time.Minute // for clocks that are a little off
+	jwksRefreshInterval = time.Minute // the least time between fetching keys for an unknown key id
+	jwksFetchTimeout    = 10 * time.Second
+)
+
+var jwtHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+var jwtCurves = map[string]elliptic.Curve{"ES256": elliptic.P256(), "ES384": elliptic.P384(), "ES512": elliptic.P521()}
+
+// JWTProvider accepts OIDC/JWT bearer tokens signed with one of the keys of a JWKS file or URL. It takes the user
+// and teams from the claims of the token; a token with the superuser claim set to true is for a superuser.
+type JWTProvider struct {
+	sync.RWMutex
+	jwks           string // a file or an http(s) url
+	Issuer         string // if set, tokens must be issued by it
+	Audience       string // if set, tokens must be meant for it
+	UserClaim      string
+	TeamsClaim     string
+	SuperUserClaim string
+	keys           map[string]crypto.PublicKey // key id -> key
+	loaded         time.Time
+}
+
+func NewJWTProvider(jwks, issuer, audience, userClaim, teamsClaim, superUserClaim string) (*JWTProvider, error) {
+	if jwks == "" {
+		return nil, errors.New("Please specify a JWKS file or URL to verify bearer tokens with")
+	}
+	p := &JWTProvider{
+		jwks:           jwks,
+		Issuer:         issuer,
+		Audience:       audience,
+		UserClaim:      userClaim,
+		TeamsClaim:     teamsClaim,
+		SuperUserClaim: superUserClaim,
+	}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *JWTProvider) load() error {
+	var data []byte
+	var err error
+	if strings.HasPrefix(p.jwks, "http://") || strings.HasPrefix(p.jwks, "https://") {
+		client := &http.Client{Timeout: jwksFetchTimeout}
+		var resp *http.Response
+		if resp, err = client.Get(p.jwks); err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return errors.New("Could not fetch JWKS from " + p.jwks + ": " + resp.Status)
+		}
+		data, err = ioutil.ReadAll(resp.Body)
+	} else {
+		data, err = ioutil.ReadFile(p.jwks)
+	}
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	p.Lock()
+	p.keys = keys
+	p.loaded = time.Now()
+	p.Unlock()
+	return nil
+}
+
+// key returns the key with id kid, fetching the keys again if it is unknown since keys get rotated
+func (p *JWTProvider) key(kid string) (crypto.PublicKey, error) {
+	p.RLock()
+	key := p.lookup(kid)
+	stale := time.Since(p.loaded) > jwksRefreshInterval
+	p.RUnlock()
+	if key == nil && stale {
+		if err := p.load(); err != nil {
+			return nil, err
+		}
+		p.RLock()
+		key = p.lookup(kid)
+		p.RUnlock()
+	}
+	if key == nil {
+		return nil, errors.New("Unknown JWT key " + kid)
+	}
+	return key, nil
+}
+
+// lookup returns the key with id kid. A token without a key id can only be checked if there is just one key.
+func (p *JWTProvider) lookup(kid string) crypto.PublicKey {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+type jwk struct {
+	Kty string
+	Kid string
+	Use string
+	Crv string
+	N   string
+	E   string
+	X   string
+	Y   string
+}
+
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct{ Keys []jwk }
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, err := decodeBigInt(k.N)
+			if err != nil {
+				return nil, err
+			}
+			e, err := decodeBigInt(k.E)
+			if err != nil {
+				return nil, err
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, err := decodeBigInt(k.X)
+			if err != nil {
+				return nil, err
+			}
+			y, err := decodeBigInt(k.Y)
+			if err != nil {
+				return nil, err
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("The JWKS has no RSA or EC signing keys")
+	}
+	return keys, nil
+}
+
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := decodeSegment(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// verifySignature checks that sig is the signature of signed with key using alg
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	hash, ok := jwtHashes[alg]
+	if !ok {
+		return errors.New("Unsupported JWT algorithm " + alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") || rsa.VerifyPKCS1v15(key, hash, digest, sig) != nil {
+			return errors.New("Invalid JWT signature")
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if jwtCurves[alg] != key.Curve || len(sig) != 2*size {
+			return errors.New("Invalid JWT signature")
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("Invalid JWT signature")
+		}
+	default:
+		return errors.New("Unsupported JWT key")
+	}
+	return nil
+}
+
+// claimList reads a claim that is either a list of strings or a string of names separated by commas or spaces
+func claimList(claim interface{}) []string {
+	list := []string{}
+	switch claim := claim.(type) {
+	case string:
+		list = strings.Fields(strings.Replace(claim, ",", " ", -1))
+	case []interface{}:
+		for _, elem := range claim {
+			if name, ok := elem.(string); ok {
+				list = append(list, name)
+			}
+		}
+	}
+	return list
+}
+
+func claimTrue(claim interface{}) bool {
+	return claim == true || claim == "true"
+}
+
+// checkClaims checks that claims are valid at now and returns when they expire
+func (p *JWTProvider) checkClaims(claims map[string]interface{}, now time.Time) (time.Time, error) {
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return time.Time{}, errors.New("The JWT does not expire")
+	}
+	expires := time.Unix(int64(exp), 0)
+	if now.After(expires.Add(jwtLeeway)) {
+		return time.Time{}, errors.New("The JWT has expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return time.Time{}, errors.New("The JWT is not valid yet")
+	}
+	if p.Issuer != "" && claims["iss"] != p.Issuer {
+		return time.Time{}, errors.New("The JWT was not issued by " + p.Issuer)
+	}
+	if p.Audience != "" && !contains(claimList(claims["aud"]), p.Audience) {
+		return time.Time{}, errors.New("The JWT is not meant for " + p.Audience)
+	}
+	return expires, nil
+}
+
+func (p *JWTProvider) IsBearer(secret string) bool {
+	return strings.HasPrefix(secret, "eyJ") && strings.Count(secret, ".") == 2
+}
+
+func (p *JWTProvider) Verify(token string) (string, []string, time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", nil, time.Time{}, errors.New("Malformed JWT")
+	}
+	var header struct {
+		Alg string
+		Kid string
+	}
+	data, err := decodeSegment(parts[0])
+	if err == nil {
+		err = json.Unmarshal(data, &header)
+	}
+	if err != nil {
+		return "", nil, time.Time{}, errors.New("Malformed JWT header")
+	}
+	key, err := p.key(header.Kid)
+	if err != nil {
+		return "", nil, time.Time{}, err
+	}
+	sig, err := decodeSegment(parts[2])
+	if err != nil {
+		return "", nil, time.Time{}, errors.New("Malformed JWT signature")
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return "", nil, time.Time{}, err
+	}
+	claims := map[string]interface{}{}
+	data, err = decodeSegment(parts[1])
+	if err == nil {
+		err = json.Unmarshal(data, &claims)
+	}
+	if err != nil {
+		return "", nil, time.Time{}, errors.New("Malformed JWT claims")
+	}
+	expires, err := p.checkClaims(claims, time.Now())
+	if err != nil {
+		return "", nil, time.Time{}, err
+	}
+	user, _ := claims[p.UserClaim].(string)
+	if user == "" {
+		return "", nil, time.Time{}, errors.New("The JWT has no " + p.UserClaim + " claim")
+	}
+	teams := claimList(claims[p.TeamsClaim])
+	if p.SuperUserClaim != "" && claimTrue(claims[p.SuperUserClaim]) && ldap.SuperUserGroup != "" &&
+		!contains(teams, ldap.SuperUserGroup) {
+		teams = append(teams, ldap.SuperUserGroup)
+	}
+	return user, teams, expires, nil
+}
+
+// Authenticate takes a bearer token in place of a password, so that people can log in with one once instead of
+// passing it on every request.
+func (p *JWTProvider) Authenticate(user, password string) ([]string, error) {
+	claimed, teams, _, err := p.Verify(password)
+	if err != nil {
+		return nil, &BearerError{err.Error()}
+	}
+	if claimed != user {
+		return nil, &BearerError{"The bearer token was issued to " + claimed + ", not " + user}
+	}
+	return teams, nil
+}
+
+func contains(list []string, str string) bool {
+	for _, elem := range list {
+		if elem == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package auth
+
+import (
+	"atlantis/manager/ldap"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	. "github.com/adjust/gocheck"
+	"io/ioutil"
+	"math/big"
+	"path"
+	"strings"
+	"time"
+)
+
+type JWTSuite struct {
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+	p      *JWTProvider
+}
+
+var _ = Suite(&JWTSuite{})
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func paddedBytes(n *big.Int, size int) []byte {
+	data := n.Bytes()
+	return append(make([]byte, size-len(data)), data...)
+}
+
+func (s *JWTSuite) SetUpSuite(c *C) {
+	var err error
+	s.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	s.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encodeSegment(s.rsaKey.N.Bytes()),
+			"e": encodeSegment(big.NewInt(int64(s.rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encodeSegment(paddedBytes(s.ecKey.X, 32)),
+			"y": encodeSegment(paddedBytes(s.ecKey.Y, 32))},
+		{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+	}})
+	file := path.Join(c.MkDir(), "jwks.json")
+	c.Assert(ioutil.WriteFile(file, jwks, 0600), IsNil)
+	s.p, err = NewJWTProvider(file, "https://idp", "atlantis", "sub", "groups", "admin")
+	c.Assert(err, IsNil)
+}
+
+func (s *JWTSuite) sign(c *C, alg, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := encodeSegment(header) + "." + encodeSegment(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	var err error
+	if alg == "RS256" {
+		sig, err = rsa.SignPKCS1v15(rand.Reader, s.rsaKey, crypto.SHA256, digest[:])
+		c.Assert(err, IsNil)
+	} else {
+		r, ss, err := ecdsa.Sign(rand.Reader, s.ecKey, digest[:])
+		c.Assert(err, IsNil)
+		sig = append(paddedBytes(r, 32), paddedBytes(ss, 32)...)
+	}
+	return signed + "." + encodeSegment(sig)
+}
+
+func (s *JWTSuite) claims(extra map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{"sub": "alice", "iss": "https://idp", "aud": []string{"atlantis", "other"},
+		"exp": time.Now().Add(time.Hour).Unix(), "groups": []string{"ops", "dev"}}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	return claims
+}
+
+func (s *JWTSuite) TestVerify(c *C) {
+	for _, alg := range []string{"RS256", "ES256"} {
+		kid := map[string]string{"RS256": "rsa", "ES256": "ec"}[alg]
+		token := s.sign(c, alg, kid, s.claims(nil))
+		c.Assert(s.p.IsBearer(token), Equals, true)
+		user, teams, expires, err := s.p.Verify(token)
+		c.Assert(err, IsNil)
+		c.Assert(user, Equals, "alice")
+		c.Assert(teams, DeepEquals, []string{"ops", "dev"})
+		c.Assert(expires.After(time.Now()), Equals, true)
+		_, err = s.p.Authenticate("alice", token)
+		c.Assert(err, IsNil)
+		_, err = s.p.Authenticate("bob", token)
+		c.Assert(err, Not(IsNil))
+	}
+	c.Assert(s.p.IsBearer("notajwt"), Equals, false)
+}
+
+func (s *JWTSuite) TestVerifyRejects(c *C) {
+	token := s.sign(c, "RS256", "rsa", s.claims(nil))
+	// the signature does not match the claims
+	forged := s.sign(c, "RS256", "rsa", s.claims(map[string]interface{}{"sub": "mallory"}))
+	_, _, _, err := s.p.Verify(token[:strings.LastIndex(token, ".")] + forged[strings.LastIndex(forged, "."):])
+	c.Assert(err, Not(IsNil))
+	// a key of the wrong type for the algorithm
+	_, _, _, err = s.p.Verify(s.sign(c, "ES256", "rsa", s.claims(nil)))
+	c.Assert(err, Not(IsNil))
+	_, _, _, err = s.p.Verify(s.sign(c, "RS256", "unknown", s.claims(nil)))
+	c.Assert(err, Not(IsNil))
+	for _, extra := range []map[string]interface{}{
+		{"exp": time.Now().Add(-time.Hour).Unix()},
+		{"exp": nil},
+		{"nbf": time.Now().Add(time.Hour).Unix()},
+		{"iss": "https://elsewhere"},
+		{"aud": "other"},
+		{"sub": ""},
+	} {
+		_, _, _, err = s.p.Verify(s.sign(c, "RS256", "rsa", s.claims(extra)))
+		c.Assert(err, Not(IsNil), Commentf("%v", extra))
+	}
+}
+
+func (s *JWTSuite) TestVerifySuperUser(c *C) {
+	defer func(group string) { ldap.SuperUserGroup = group }(ldap.SuperUserGroup)
+	ldap.SuperUserGroup = "admins"
+	_, teams, _, err := s.p.Verify(s.sign(c, "ES256", "ec", s.claims(map[string]interface{}{"admin": true,
+		"groups": "ops dev"})))
+	c.Assert(err, IsNil)
+	c.Assert(teams, DeepEquals, []string{"ops", "dev", "admins"})
+	_, teams, _, err = s.p.Verify(s.sign(c, "ES256", "ec", s.claims(map[string]interface{}{"admin": false})))
+	c.Assert(err, IsNil)
+	c.Assert(teams, DeepEquals, []string{"ops", "dev"})
+}
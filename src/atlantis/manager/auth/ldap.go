@@ -0,0 +1,24 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package auth
+
+import (
+	"atlantis/manager/ldap"
+)
+
+// LDAPProvider checks passwords by binding to the LDAP server the ldap package is set up with, and takes teams
+// from the groups the user is a member of.
+type LDAPProvider struct{}
+
+func (p LDAPProvider) Authenticate(user, password string) ([]string, error) {
+	return ldap.Authenticate(user, password)
+}
@@ -0,0 +1,146 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package builder
+
+import (
+	"atlantis/builder/api/types"
+	. "atlantis/common"
+	"encoding/json"
+	. "github.com/adjust/gocheck"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBuilder(t *testing.T) { TestingT(t) }
+
+type BuilderSuite struct{}
+
+var _ = Suite(&BuilderSuite{})
+
+func (s *BuilderSuite) TestComplete(c *C) {
+	token, done, err := addWaiter()
+	c.Assert(err, IsNil)
+	defer removeWaiter(token)
+	c.Assert(Complete("b1", "", &types.Build{}), Equals, ErrUnknownBuild)
+	c.Assert(Complete("b1", "wrong", &types.Build{}), Equals, ErrUnknownBuild)
+	setWaiterID(token, "b1")
+	c.Assert(Complete("b2", token, &types.Build{}), Equals, ErrUnknownBuild)
+	c.Assert(Complete("b1", token, &types.Build{Status: types.StatusDone}), IsNil)
+	// calling back again does not block
+	c.Assert(Complete("b1", token, &types.Build{Status: types.StatusDone}), IsNil)
+	build := <-done
+	c.Assert(build.ID, Equals, "b1")
+	c.Assert(build.Status, Equals, types.StatusDone)
+
+	removeWaiter(token)
+	c.Assert(Complete("b1", token, &types.Build{}), Equals, ErrUnknownBuild)
+}
+
+// fakeBuilder is a builder that is done with every build once it has been asked about it pollsUntilDone times,
+// or once it is done is called.
+type fakeBuilder struct {
+	sync.Mutex
+	pollsUntilDone int
+	polls          int
+	finished       bool
+	request        buildRequest
+}
+
+func (f *fakeBuilder) done() {
+	f.Lock()
+	f.finished = true
+	f.Unlock()
+}
+
+func (f *fakeBuilder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	status := types.StatusBuilding
+	switch r.URL.Path {
+	case "/build":
+		json.NewDecoder(r.Body).Decode(&f.request)
+	case "/build/b1":
+		f.polls++
+		if f.finished || (f.pollsUntilDone > 0 && f.polls >= f.pollsUntilDone) {
+			status = types.StatusDone
+		}
+	case "/build/b1/manifest":
+		w.Write([]byte("manifest"))
+		return
+	}
+	json.NewEncoder(w).Encode(&types.Build{ID: "b1", Status: status})
+}
+
+func readManifest(c *C, b *SimpleBuilder) {
+	body, err := b.Build(&Task{}, "repo", "root", "sha")
+	c.Assert(err, IsNil)
+	manifest, err := ioutil.ReadAll(body)
+	body.Close()
+	c.Assert(err, IsNil)
+	c.Assert(string(manifest), Equals, "manifest")
+}
+
+func (s *BuilderSuite) TestSimpleBuilderPolls(c *C) {
+	fake := &fakeBuilder{pollsUntilDone: 4}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	b := &SimpleBuilder{URL: server.URL, BuildTimeout: time.Minute, PollInterval: time.Millisecond,
+		MaxPollInterval: 4 * time.Millisecond}
+	readManifest(c, b)
+	c.Assert(fake.polls, Equals, 4)
+	c.Assert(fake.request.Callback, Equals, "")
+	c.Assert(fake.request.Sha, Equals, "sha")
+}
+
+func (s *BuilderSuite) TestSimpleBuilderTimeout(c *C) {
+	server := httptest.NewServer(&fakeBuilder{})
+	defer server.Close()
+	b := &SimpleBuilder{URL: server.URL, BuildTimeout: 20 * time.Millisecond, PollInterval: time.Millisecond,
+		MaxPollInterval: time.Millisecond}
+	_, err := b.Build(&Task{}, "repo", "root", "sha")
+	c.Assert(err, ErrorMatches, "Build Timeout.")
+}
+
+func (s *BuilderSuite) TestSimpleBuilderCallback(c *C) {
+	fake := &fakeBuilder{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	// the builder would not be asked again for an hour if it did not call back
+	b := &SimpleBuilder{URL: server.URL, BuildTimeout: time.Minute, CallbackURL: "https://manager:443/",
+		PollInterval: time.Hour, MaxPollInterval: time.Hour}
+	go func() {
+		for {
+			fake.Lock()
+			callback := fake.request.Callback
+			fake.Unlock()
+			if callback != "" {
+				query, _ := url.ParseQuery(callback[strings.Index(callback, "?")+1:])
+				fake.done()
+				for Complete("b1", query.Get("Token"), &types.Build{}) == ErrUnknownBuild {
+					time.Sleep(time.Millisecond)
+				}
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	readManifest(c, b)
+	c.Assert(fake.polls, Equals, 1)
+	c.Assert(strings.HasPrefix(fake.request.Callback, "https://manager:443/builds/{id}/complete?Token="), Equals,
+		true)
+}
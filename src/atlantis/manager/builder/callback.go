@@ -0,0 +1,90 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package builder
+
+import (
+	"atlantis/builder/api/types"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Builders that are given a callback URL POST the build to it when they finish, at /builds/{id}/complete on the
+// manager API, instead of being asked over and over whether they are done. Every build gets its own token in the
+// callback URL so that only the builder that was asked for it can complete it.
+
+// CallbackIDPlaceholder is replaced with the ID of the build by the builder before it calls back.
+const CallbackIDPlaceholder = "{id}"
+
+var ErrUnknownBuild = errors.New("No deploy is waiting for this build")
+
+type waiter struct {
+	id   string // empty until the builder says which build it started
+	done chan *types.Build
+}
+
+var (
+	waitersLock sync.Mutex
+	waiters     = map[string]*waiter{}
+)
+
+// addWaiter returns the token to put in the callback URL and where the build arrives when it is complete.
+func addWaiter() (string, chan *types.Build, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(buf)
+	done := make(chan *types.Build, 1)
+	waitersLock.Lock()
+	waiters[token] = &waiter{done: done}
+	waitersLock.Unlock()
+	return token, done, nil
+}
+
+// setWaiterID makes the waiter for token only take completions of build id.
+func setWaiterID(token, id string) {
+	waitersLock.Lock()
+	defer waitersLock.Unlock()
+	if w := waiters[token]; w != nil {
+		w.id = id
+	}
+}
+
+func removeWaiter(token string) {
+	waitersLock.Lock()
+	delete(waiters, token)
+	waitersLock.Unlock()
+}
+
+func callbackURL(base, token string) string {
+	return strings.TrimRight(base, "/") + "/builds/" + CallbackIDPlaceholder + "/complete?Token=" + token
+}
+
+// Complete hands build id to the deploy that is waiting for it. Builders may call back more than once, only the
+// first completion is kept.
+func Complete(id, token string, build *types.Build) error {
+	waitersLock.Lock()
+	defer waitersLock.Unlock()
+	w := waiters[token]
+	if token == "" || w == nil || (w.id != "" && w.id != id) {
+		return ErrUnknownBuild
+	}
+	build.ID = id
+	select {
+	case w.done <- build:
+	default:
+	}
+	return nil
+}
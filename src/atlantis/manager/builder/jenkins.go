@@ -12,25 +12,39 @@
 package builder
 
 import (
+	"atlantis/builder/api/types"
 	. "atlantis/common"
 	"errors"
 	"github.com/ooyala/go-jenkins-cli"
 	"io"
+	"net/url"
+	"time"
 )
 
+const DefaultJenkinsBuildTimeout = time.Hour
+
+// JenkinsBuilder waits for the Jenkins job to finish. If CallbackURL, the base URL of the manager API, is set the
+// job is passed it as manager_callback and has to POST the build to it when it is done, with its build number in
+// place of CallbackIDPlaceholder and a Status of done or error, instead.
 type JenkinsBuilder struct {
-	URL string
-	Job string
+	URL          string
+	Job          string
+	CallbackURL  string
+	BuildTimeout time.Duration
 }
 
 func NewJenkinsBuilder(url, job string) *JenkinsBuilder {
-	return &JenkinsBuilder{URL: url, Job: job}
+	return &JenkinsBuilder{URL: url, Job: job, BuildTimeout: DefaultJenkinsBuildTimeout}
 }
 
 func (b *JenkinsBuilder) Build(t *Task, repo, root, sha string) (io.ReadCloser, error) {
 	jenkins.JENKINS_SERVER = b.URL
 	t.LogStatus("Triggering Jenkins Build")
-	info, err := jenkins.DoBuild(b.Job, "app_repo="+repo+"&app_root="+root+"&app_commit="+sha, true)
+	params := "app_repo=" + repo + "&app_root=" + root + "&app_commit=" + sha
+	if b.CallbackURL != "" {
+		return b.buildWithCallback(t, params)
+	}
+	info, err := jenkins.DoBuild(b.Job, params, true)
 	if err != nil {
 		return nil, errors.New("Jenkins Error: " + err.Error())
 	}
@@ -39,3 +53,27 @@ func (b *JenkinsBuilder) Build(t *Task, repo, root, sha string) (io.ReadCloser,
 	}
 	return jenkins.GetArtifactReader(b.Job, info.ID, ManifestFile)
 }
+
+func (b *JenkinsBuilder) buildWithCallback(t *Task, params string) (io.ReadCloser, error) {
+	token, done, err := addWaiter()
+	if err != nil {
+		return nil, err
+	}
+	defer removeWaiter(token)
+	params += "&manager_callback=" + url.QueryEscape(callbackURL(b.CallbackURL, token))
+	if _, err := jenkins.DoBuild(b.Job, params, false); err != nil {
+		return nil, errors.New("Jenkins Error: " + err.Error())
+	}
+	t.LogStatus("Waiting for Jenkins Build")
+	timeout := time.NewTimer(b.BuildTimeout)
+	defer timeout.Stop()
+	select {
+	case build := <-done:
+		if build.Status != types.StatusDone {
+			return nil, errors.New("Jenkins Build " + build.ID + " " + build.Status + ": " + build.Error)
+		}
+		return jenkins.GetArtifactReader(b.Job, build.ID, ManifestFile)
+	case <-timeout.C:
+		return nil, errors.New("Build Timeout.")
+	}
+}
@@ -24,13 +24,32 @@ import (
 	"time"
 )
 
+const (
+	DefaultPollInterval    = time.Second
+	DefaultMaxPollInterval = 30 * time.Second
+)
+
+// SimpleBuilder asks the builder whether the build is done, at first every PollInterval and then less and less
+// often up to every MaxPollInterval. If CallbackURL, the base URL of the manager API, is set the builder is also
+// asked to call back when it is done and the manager only asks every MaxPollInterval in case the callback is lost.
 type SimpleBuilder struct {
-	URL          string
-	BuildTimeout time.Duration
+	URL             string
+	BuildTimeout    time.Duration
+	CallbackURL     string
+	PollInterval    time.Duration
+	MaxPollInterval time.Duration
 }
 
 func NewSimpleBuilder(url string, timeout time.Duration) *SimpleBuilder {
-	return &SimpleBuilder{URL: url, BuildTimeout: timeout}
+	return &SimpleBuilder{URL: url, BuildTimeout: timeout, PollInterval: DefaultPollInterval,
+		MaxPollInterval: DefaultMaxPollInterval}
+}
+
+// buildRequest is the build to start and where to call back, with the ID of the build in place of
+// CallbackIDPlaceholder, when it is done.
+type buildRequest struct {
+	types.Build
+	Callback string `json:",omitempty"`
 }
 
 func decodeBuildResp(resp *http.Response) (*types.Build, error) {
@@ -54,7 +73,18 @@ func decodeBuildResp(resp *http.Response) (*types.Build, error) {
 
 func (b *SimpleBuilder) Build(t *Task, repo, root, sha string) (io.ReadCloser, error) {
 	t.LogStatus("Triggering Simple Build")
-	jsonBytes, err := json.Marshal(&types.Build{URL: repo, RelPath: root, Sha: sha})
+	request := buildRequest{Build: types.Build{URL: repo, RelPath: root, Sha: sha}}
+	var token string
+	var done chan *types.Build
+	var err error
+	if b.CallbackURL != "" {
+		if token, done, err = addWaiter(); err != nil {
+			return nil, err
+		}
+		defer removeWaiter(token)
+		request.Callback = callbackURL(b.CallbackURL, token)
+	}
+	jsonBytes, err := json.Marshal(&request)
 	if err != nil {
 		return nil, err
 	}
@@ -66,20 +96,11 @@ func (b *SimpleBuilder) Build(t *Task, repo, root, sha string) (io.ReadCloser, e
 	if err != nil {
 		return nil, err
 	}
-	begin := time.Now()
-	end := begin.Add(b.BuildTimeout)
-	for build.Status != types.StatusDone && build.Status != types.StatusError {
-		if time.Now().After(end) {
-			// timeout
-			return nil, errors.New("Build Timeout.")
-		}
-		resp, err := http.Get(b.URL + "/build/" + build.ID)
-		if err != nil {
-			return nil, errors.New("Builder Error: " + err.Error())
-		}
-		if build, err = decodeBuildResp(resp); err != nil {
-			return nil, err
-		}
+	if done != nil {
+		setWaiterID(token, build.ID)
+	}
+	if build, err = b.waitForBuild(t, build, done); err != nil {
+		return nil, err
 	}
 	if build.Status == types.StatusError {
 		return nil, errors.New(fmt.Sprintf("%v", build.Error))
@@ -92,3 +113,41 @@ func (b *SimpleBuilder) Build(t *Task, repo, root, sha string) (io.ReadCloser, e
 	}
 	return resp.Body, nil
 }
+
+// waitForBuild asks the builder about build until it is done or has failed, for up to BuildTimeout. It asks right
+// away when the builder calls back on done.
+func (b *SimpleBuilder) waitForBuild(t *Task, build *types.Build, done chan *types.Build) (*types.Build, error) {
+	maxInterval := b.MaxPollInterval
+	if maxInterval <= 0 {
+		maxInterval = DefaultMaxPollInterval
+	}
+	interval := b.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	if done != nil {
+		t.LogStatus("Waiting for Build %s", build.ID)
+		interval = maxInterval
+	}
+	timeout := time.NewTimer(b.BuildTimeout)
+	defer timeout.Stop()
+	for build.Status != types.StatusDone && build.Status != types.StatusError {
+		select {
+		case <-timeout.C:
+			return nil, errors.New("Build Timeout.")
+		case <-done:
+		case <-time.After(interval):
+			if interval *= 2; interval > maxInterval {
+				interval = maxInterval
+			}
+		}
+		resp, err := http.Get(b.URL + "/build/" + build.ID)
+		if err != nil {
+			return nil, errors.New("Builder Error: " + err.Error())
+		}
+		if build, err = decodeBuildResp(resp); err != nil {
+			return nil, err
+		}
+	}
+	return build, nil
+}
@@ -0,0 +1,90 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	. "atlantis/manager/rpc/types"
+	"time"
+)
+
+func OutputApproval(approval *TaskApproval) {
+	if approval == nil {
+		return
+	}
+	Log("-> %s: %s requested by %s", approval.ID, approval.Description, approval.User)
+	Log("->   env:     %s", approval.Env)
+	Log("->   state:   %s", approval.State)
+	if approval.Approver != "" {
+		Log("->   by:      %s", approval.Approver)
+	}
+	if approval.State == "pending" {
+		Log("->   expires: %s", approval.Expires.Format(time.RFC3339))
+	}
+}
+
+type ListApprovalsCommand struct {
+}
+
+func (c *ListApprovalsCommand) Execute(args []string) error {
+	if err := Init(); err != nil {
+		return OutputError(err)
+	}
+	Log("List Approvals...")
+	arg := ManagerListApprovalsArg{dummyAuthArg}
+	var reply ManagerListApprovalsReply
+	if err := rpcClient.CallAuthed("ListApprovals", &arg, &reply); err != nil {
+		return OutputError(err)
+	}
+	ids := make([]string, len(reply.Approvals))
+	for i, approval := range reply.Approvals {
+		OutputApproval(approval)
+		ids[i] = approval.ID
+	}
+	return Output(map[string]interface{}{"status": reply.Status, "approvals": reply.Approvals}, ids, nil)
+}
+
+func approveTask(id string, reject bool) error {
+	if err := Init(); err != nil {
+		return OutputError(err)
+	}
+	if reject {
+		Log("Reject Task...")
+	} else {
+		Log("Approve Task...")
+	}
+	arg := ManagerApproveTaskArg{dummyAuthArg, id, reject}
+	var reply ManagerApproveTaskReply
+	if err := rpcClient.CallAuthed("ApproveTask", &arg, &reply); err != nil {
+		return OutputError(err)
+	}
+	Log("-> Status: %s", reply.Status)
+	OutputApproval(reply.Approval)
+	return Output(map[string]interface{}{"status": reply.Status, "approval": reply.Approval}, reply.Status, nil)
+}
+
+type ApproveTaskCommand struct {
+	ID string `short:"i" long:"id" description:"the task waiting for approval"`
+}
+
+func (c *ApproveTaskCommand) Execute(args []string) error {
+	ExtractArgs([]*string{&c.ID}, args)
+	return approveTask(c.ID, false)
+}
+
+type RejectTaskCommand struct {
+	ID string `short:"i" long:"id" description:"the task waiting for approval"`
+}
+
+func (c *RejectTaskCommand) Execute(args []string) error {
+	ExtractArgs([]*string{&c.ID}, args)
+	return approveTask(c.ID, true)
+}
@@ -0,0 +1,71 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	. "atlantis/manager/rpc/types"
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type AuditCommand struct {
+	User   string `short:"u" long:"user" description:"only changes made by this user"`
+	Action string `short:"t" long:"action" description:"only this kind of change, e.g. Teardown"`
+	App    string `short:"a" long:"app" description:"only changes to this app (required unless you are a superuser)"`
+	Env    string `short:"e" long:"env" description:"only changes in this environment"`
+	Since  string `long:"since" description:"only changes since this time (RFC3339) or this long ago (e.g. 24h)"`
+	Until  string `long:"until" description:"only changes until this time (RFC3339) or this long ago"`
+	Limit  int    `long:"limit" description:"show at most this many changes (default 100)"`
+}
+
+func (c *AuditCommand) Execute(args []string) error {
+	if err := Init(); err != nil {
+		return OutputError(err)
+	}
+	arg := ManagerAuditArg{
+		ManagerAuthArg: dummyAuthArg,
+		AuditUser:      c.User,
+		Action:         c.Action,
+		App:            c.App,
+		Env:            c.Env,
+		Limit:          c.Limit,
+	}
+	var err error
+	if arg.Since, err = parseTaskTime(c.Since); err != nil {
+		return OutputError(err)
+	}
+	if arg.Until, err = parseTaskTime(c.Until); err != nil {
+		return OutputError(err)
+	}
+	Log("Audit...")
+	var reply ManagerAuditReply
+	if err := rpcClient.CallAuthed("Audit", &arg, &reply); err != nil {
+		return OutputError(err)
+	}
+	var table bytes.Buffer
+	w := tabwriter.NewWriter(&table, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tUSER\tSOURCE\tACTION\tAPP\tENV\tOUTCOME\tERROR")
+	ids := make([]string, len(reply.Entries))
+	for i, entry := range reply.Entries {
+		ids[i] = entry.ID
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.Time.Format(time.RFC3339), entry.User,
+			entry.Source, entry.Action, entry.App, entry.Env, entry.Outcome, entry.Error)
+	}
+	w.Flush()
+	for _, line := range strings.Split(strings.TrimRight(table.String(), "\n"), "\n") {
+		Log("%s", line)
+	}
+	return Output(map[string]interface{}{"status": reply.Status, "entries": reply.Entries}, ids, nil)
+}
@@ -174,7 +174,11 @@ type ClientOpts struct {
 var clientOpts = &ClientOpts{}
 var cfg = []atlantis.RPCServerOpts{}
 var rpcClient = &client.ManagerRPCClient{*client.NewManagerRPCClientWithConfig(cfg), "", map[string]string{}}
-var dummyAuthArg = rpcTypes.ManagerAuthArg{"", "", ""}
+var dummyAuthArg = rpcTypes.ManagerAuthArg{"", "", "", ""}
+var listOptionsType = reflect.TypeOf(rpcTypes.ListOptions{})
+
+// how many items list commands ask for at a time, unless --page-size says otherwise
+const listPageSize = 500
 
 type commandWrapper struct {
 	Command interface{}
@@ -202,9 +206,12 @@ func New() *ManagerClient {
 
 	// Manager Management
 	o.AddCommand("login", "login to the system", "", &LoginCommand{})
+	o.AddCommand("logout", "end your session on every manager", "", &LogoutCommand{})
 	o.AddCommand("version", "check manager client and server versions", "", &VersionCommand{})
 	o.AddCommand("health", "check manager health", "", &HealthCommand{})
 	o.AddCommand("usage", "check manager usage stats", "", &UsageCommand{})
+	o.AddCommand("cache-stats", "check manager datamodel cache hit rates", "", &CacheStatsCommand{})
+	o.AddCommand("rate-limits", "show the rate limits and who is being throttled", "", &RateLimitsCommand{})
 	o.AddCommand("idle", "check if manager is idle", "", &IdleCommand{})
 	o.AddCommand("register-manager", "[async] register an manager", "", &RegisterManagerCommand{})
 	o.AddCommand("unregister-manager", "[async] unregister an manager", "", &UnregisterManagerCommand{})
@@ -252,11 +259,18 @@ func New() *ManagerClient {
 	o.AddCommand("create-env", "create a environment", "", &UpdateEnvCommand{}) // alias to update
 	o.AddCommand("update-env", "update a environment", "", &UpdateEnvCommand{})
 	o.AddCommand("delete-env", "delete a environment", "", &DeleteEnvCommand{})
+	o.AddCommand("protect-env", "[superuser only] require a second person to approve deploys and teardowns in an environment", "", &ProtectEnvCommand{})
+	o.AddCommand("unprotect-env", "[superuser only] stop requiring approval in an environment", "", &UnprotectEnvCommand{})
 	o.AddCommand("list-envs", "list evironments (available or deployed)", "",
 		&ListEnvsCommand{})
 
 	// Container Management
 	o.AddCommand("list-containers", "list deployed containers", "", &ListContainersCommand{})
+	o.AddCommand("query-containers", "list containers by supervisor, environment and/or team", "",
+		&QueryContainersCommand{})
+	o.AddCommand("get-containers", "get the details of every container that matches the filters", "",
+		&GetContainersCommand{})
+	o.AddCommand("rebuild-indexes", "rebuild the container indexes (superuser only)", "", &RebuildIndexesCommand{})
 	o.AddCommand("list-shas", "list deployed shas", "", &ListShasCommand{})
 	o.AddCommand("list-apps", "list deployed apps", "", &ListAppsCommand{})
 	o.AddCommand("deploy", "[async] deploy something", "", &DeployCommand{})
@@ -264,6 +278,8 @@ func New() *ManagerClient {
 	o.AddCommand("copy-container", "[async] deploy by copying a single container to a specific host", "", &CopyContainerCommand{})
 	o.AddCommand("teardown", "[async] teardown something", "", &TeardownCommand{})
 	o.AddCommand("get-container", "get a container", "", &GetContainerCommand{})
+	o.AddCommand("list-locks", "list deploy and teardown locks", "", &ListLocksCommand{})
+	o.AddCommand("force-unlock", "forcibly release a deploy or teardown lock (superuser only)", "", &ForceUnlockCommand{})
 
 	// Router Config Management
 	o.AddCommand("create-pool", "create a router pool", "", &UpdatePoolCommand{}) // alias to update
@@ -296,6 +312,7 @@ func New() *ManagerClient {
 	o.AddCommand("list-team-apps", "list all team appss", "", &ListTeamAppsCommand{})
 	o.AddCommand("allow-app", "allow an app for deploy by a team", "", &AllowTeamAppCommand{})
 	o.AddCommand("disallow-app", "disallow an app for deploy by a team", "", &DisallowTeamAppCommand{})
+	o.AddCommand("sync-team-apps", "reconcile team apps with LDAP now", "", &SyncTeamAppsCommand{})
 	o.AddCommand("is-app-allowed", "check if an app is allowed for deploy by a user", "", &IsAppAllowedCommand{})
 	o.AddCommand("list-allowed-apps", "list all allowed apps for a user", "", &ListAllowedAppsCommand{})
 
@@ -308,9 +325,31 @@ func New() *ManagerClient {
 
 	// Task Management
 	o.AddCommand("list-task-ids", "list all the task ids of async commands", "", &ListTaskIDsCommand{})
+	o.AddCommand("list-tasks", "search async commands by user, type, app, env, status and time", "",
+		&ListTasksCommand{})
 	o.AddCommand("status", "get the status of an async command", "", &StatusCommand{})
 	o.AddCommand("result", "get the result of an async command", "", &ResultCommand{})
 	o.AddCommand("wait", "get the wait of an async command", "", &WaitCommand{})
+	o.AddCommand("schedule-deploy", "deploy later, once or on a cron schedule", "", &ScheduleDeployCommand{})
+	o.AddCommand("schedule-teardown", "teardown later, once or on a cron schedule", "", &ScheduleTeardownCommand{})
+	o.AddCommand("schedule-deploy-container", "replicate a container later, once or on a cron schedule", "",
+		&ScheduleDeployContainerCommand{})
+	o.AddCommand("list-jobs", "list scheduled jobs", "", &ListJobsCommand{})
+	o.AddCommand("pause-job", "stop running a scheduled job until it is resumed", "", &PauseJobCommand{})
+	o.AddCommand("resume-job", "resume a paused scheduled job", "", &ResumeJobCommand{})
+	o.AddCommand("delete-job", "delete a scheduled job", "", &DeleteJobCommand{})
+	o.AddCommand("create-token", "create an API token for CI and other automation", "", &CreateTokenCommand{})
+	o.AddCommand("list-tokens", "list API tokens", "", &ListTokensCommand{})
+	o.AddCommand("revoke-token", "revoke an API token", "", &RevokeTokenCommand{})
+	o.AddCommand("add-policy", "[superuser only] limit an action in matching apps and envs to a team or user", "", &AddPolicyCommand{})
+	o.AddCommand("remove-policy", "[superuser only] remove a policy", "", &RemovePolicyCommand{})
+	o.AddCommand("list-policies", "list the policies that limit who may do what in which envs", "", &ListPoliciesCommand{})
+	o.AddCommand("can-i", "ask whether you may do an action on an app in an env, and why", "", &CanICommand{})
+	o.AddCommand("list-approvals", "list deploys and teardowns of protected envs waiting for approval", "", &ListApprovalsCommand{})
+	o.AddCommand("approve", "approve someone else's deploy or teardown of a protected env", "", &ApproveTaskCommand{})
+	o.AddCommand("reject", "reject someone else's deploy or teardown of a protected env", "", &RejectTaskCommand{})
+	o.AddCommand("audit", "show who changed what, from where, and when", "", &AuditCommand{})
+	o.AddCommand("task-queue", "list the async commands that are running or waiting to run", "", &TaskQueueCommand{})
 	o.AddCommand("deploy-result", "get the result of an async deploy", "", &DeployResultCommand{})
 	o.AddCommand("teardown-result", "get the result of an async teardown", "", &TeardownResultCommand{})
 
@@ -325,6 +364,10 @@ func (o *ManagerClient) Run() {
 // Used to initialize a before a command is run. This is the first thing that should happen in all Executes.
 func Init() error {
 	overlayConfig()
+	if token := os.Getenv(TokenEnvVar); token != "" {
+		UseToken(token)
+		return nil
+	}
 	return AutoLoginDefault()
 }
 
@@ -351,12 +394,15 @@ func genericExtractArgs(command reflect.Value, args []string) []string {
 	return args
 }
 
-// Copy arguments from the CLI Command struct to the RPCarg struct
+// Copy arguments from the CLI Command struct to the RPCarg struct. The ListOptions of list calls are copied field
+// by field.
 func genericCopyArgs(command reflect.Value, arg reflect.Value) {
 	for f := 0; f < arg.NumField(); f++ {
 		name := arg.Type().Field(f).Name
 		if v := command.FieldByName(name); v.IsValid() {
 			arg.Field(f).Set(v)
+		} else if arg.Field(f).Type() == listOptionsType {
+			genericCopyArgs(command, arg.Field(f))
 		}
 	}
 }
@@ -424,7 +470,7 @@ func genericLogData(name string, v reflect.Value, indent string, skip int) {
 }
 
 func executeFlags(rv reflect.Value) (message, rpc, field, name, fileName, fileField string, fileData interface{},
-	noauth, async, wait bool) {
+	noauth, async, wait, follow bool) {
 	// Use flags from the Properties field, if it exists.
 	if properties, ok := rv.Type().FieldByName("Properties"); ok {
 		message = properties.Tag.Get("message")
@@ -461,12 +507,24 @@ func executeFlags(rv reflect.Value) (message, rpc, field, name, fileName, fileFi
 		name = strings.ToLower(field)
 	}
 
+	// Aggregated commands print what each region returned.
+	if aggregateField := rv.FieldByName("Aggregate"); aggregateField.Kind() == reflect.Bool && aggregateField.Bool() {
+		field = "Regions"
+		name = "regions"
+	}
+
 	// Async commands are weird; we get back an ID to wait on, and there's always an boolean --wait flag.
 	if waitField := rv.FieldByName("Wait"); waitField.Kind() == reflect.Bool {
 		async = true
 		wait = waitField.Bool()
 	}
 
+	// Following an async command implies waiting on it; we just print its log as it goes.
+	if followField := rv.FieldByName("Follow"); followField.Kind() == reflect.Bool && followField.Bool() {
+		wait = true
+		follow = true
+	}
+
 	// If we need to read file data, get that set up
 	if fileDataField := rv.FieldByName("FileData"); fileDataField.IsValid() {
 		if fileNamev := rv.FieldByName("FromFile"); fileNamev.IsValid() {
@@ -494,7 +552,7 @@ func copyType(rv reflect.Value, name string) (reflect.Value, error) {
 func genericResult(command interface{}, args []string) (map[string]string, map[string]interface{}, string, map[string]map[string]interface{}, error) {
 	rv := reflect.ValueOf(command).Elem()
 	// Extract all the configuration flags from the Command struct
-	message, rpc, field, name, fileName, fileField, fileData, noauth, async, wait := executeFlags(rv)
+	message, rpc, field, name, fileName, fileField, fileData, noauth, async, wait, follow := executeFlags(rv)
 
 	// Some command require auth, some don't
 	if noauth {
@@ -521,6 +579,12 @@ func genericResult(command interface{}, args []string) (map[string]string, map[s
 	genericExtractArgs(rv, args)
 	genericCopyArgs(rv, argv.Elem())
 
+	// Ask for lists in pages so that no one reply is huge. Aggregated lists print what each region has instead.
+	if limit := argv.Elem().FieldByName("Limit"); argv.Elem().FieldByName("ListOptions").IsValid() &&
+		limit.Int() == 0 && field != "Regions" {
+		limit.SetInt(listPageSize)
+	}
+
 	// Read in file data if necessary
 	if fileData != nil {
 		file, err := os.Open(fileName)
@@ -571,13 +635,20 @@ func genericResult(command interface{}, args []string) (map[string]string, map[s
 			}
 		}
 
+		// List calls come back a page at a time; fetch the rest of the pages too.
+		if !noauth && !async {
+			if err := genericPages(rpc, argv, region, replyv, field); err != nil {
+				return nil, nil, "", nil, OutputError(err)
+			}
+		}
+
 		// If we're waiting on an async command, update the reply when it comes back.
 		if wait {
 			if idv := replyv.Elem().FieldByName("ID"); idv.IsValid() {
 				Log("-> ID: %v", replyv.Elem().FieldByName("ID"))
 				replyv = reflect.New(rv.FieldByName("Reply").Type())
 				reply = replyv.Interface()
-				if err := genericWait(command, rpc, idv.String(), reply); err != nil {
+				if err := genericWait(command, rpc, idv.String(), reply, follow); err != nil {
 					return nil, nil, "", nil, OutputError(err)
 				}
 			} else {
@@ -618,7 +689,40 @@ func genericResult(command interface{}, args []string) (map[string]string, map[s
 	return statuses, replies, name, datas, nil
 }
 
-func genericWait(command interface{}, rpc, id string, reply interface{}) error {
+// genericPages fetches the pages after the first one of a list call and adds their items to reply, so that paging
+// is invisible on the command line.
+func genericPages(rpc string, argv reflect.Value, region int, replyv reflect.Value, field string) error {
+	cursor := argv.Elem().FieldByName("Cursor")
+	next := replyv.Elem().FieldByName("NextCursor")
+	list := replyv.Elem().FieldByName(field)
+	if !cursor.IsValid() || !next.IsValid() || !list.IsValid() || list.Kind() != reflect.Slice {
+		return nil
+	}
+	defer cursor.SetString("")
+	for next.String() != "" {
+		cursor.SetString(next.String())
+		pagev := reflect.New(replyv.Elem().Type())
+		if err := rpcClient.CallAuthedMulti(rpc, argv.Interface().(client.AuthedArg), region,
+			pagev.Interface()); err != nil {
+			return err
+		}
+		list.Set(reflect.AppendSlice(list, pagev.Elem().FieldByName(field)))
+		next.SetString(pagev.Elem().FieldByName("NextCursor").String())
+	}
+	return nil
+}
+
+func genericWait(command interface{}, rpc, id string, reply interface{}, follow bool) error {
+	if follow {
+		if err := followTask(id); err != nil {
+			return OutputError(err)
+		}
+		if err := rpcClient.Call(rpc+"Result", id, reply); err != nil {
+			return OutputError(err)
+		}
+		return nil
+	}
+
 	Log("Waiting...")
 	var statusReply atlantis.TaskStatus
 	var currentStatus string
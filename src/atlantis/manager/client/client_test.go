@@ -122,7 +122,7 @@ func TestRegisterApp(t *testing.T) {
 	checkCommand(t, registerCommand, "", &ManagerRegisterAppReply{"OK"})
 
 	log.Print("== Listing apps and checking existence ==")
-	checkCommand(t, &ListRegisteredAppsCommand{}, "", &ManagerListRegisteredAppsReply{[]string{testName}, "OK"})
+	checkCommand(t, &ListRegisteredAppsCommand{}, "", &ManagerListRegisteredAppsReply{Apps: []string{testName}, Status: "OK"})
 
 	log.Print("== Unregistering dummy app ==")
 	checkCommand(t, &UnregisterAppCommand{}, testName, &ManagerRegisterAppReply{"OK"})
@@ -165,11 +165,11 @@ func checkUrl(t *testing.T, url, name, expected string) bool {
 func TestFullDeploy(t *testing.T) {
 	testName := "e2e-test-" + time.Now().Format("2006-01-02T15-04-05-0700")
 
-	checkCommand(t, &ListAppsCommand{}, "", &ManagerListAppsReply{[]string{"hello-go"}, "OK"})
+	checkCommand(t, &ListAppsCommand{}, "", &ManagerListAppsReply{Apps: []string{"hello-go"}, Status: "OK"})
 
 	log.Print("== Creating environment ==")
 	checkCommand(t, &UpdateEnvCommand{}, testName, &ManagerEnvReply{"OK"})
-	checkCommand(t, &ListEnvsCommand{}, "", &ManagerListEnvsReply{[]string{testName}, "OK"})
+	checkCommand(t, &ListEnvsCommand{}, "", &ManagerListEnvsReply{Envs: []string{testName}, Status: "OK"})
 
 	log.Print("== Setting cmk dependency ==")
 	envFile := writeDepFile(t, testName, `{ "contact_group": "edanaher-test" }`)
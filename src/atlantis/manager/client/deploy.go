@@ -25,7 +25,10 @@ type DeployCommand struct {
 	Dev         bool   `long:"dev" description:"only deploy 1 instance in 1 AZ"`
 	SkipBuild   bool   `long:"skip-build" description:"assume similar container (app/sha/env) already deployed; skip build image step"`
 	Manifest    string `long:"manifest" description:"pass manifest in json format; use together with skip-build"`
+	LockTimeout string `long:"lock-timeout" description:"wait this long (e.g. 5m) for a conflicting deploy or teardown instead of failing"`
+	Hotfix      bool   `long:"hotfix" description:"go ahead of other queued deploys and teardowns (needs a hotfix policy)"`
 	Wait        bool   `long:"wait" description:"wait until the deploy is done before exiting"`
+	Follow      bool   `long:"follow" description:"print the deploy's log as it happens and wait until it is done"`
 	Properties  string `field:"Containers"`
 	Arg         ManagerDeployArg
 	Reply       ManagerDeployReply
@@ -35,6 +38,7 @@ type DeployContainerCommand struct {
 	ContainerID string `short:"c" long:"container" description:"the id of the container to replicate"`
 	Instances   uint   `short:"i" long:"instances" default:"1" description:"the number of instances to deploy in each AZ"`
 	Wait        bool   `long:"wait" description:"wait until the deploy is done before exiting"`
+	Follow      bool   `long:"follow" description:"print the deploy's log as it happens and wait until it is done"`
 	Properties  string `field:"Containers"`
 	Arg         ManagerDeployArg
 	Reply       ManagerDeployReply
@@ -85,7 +89,10 @@ type TeardownCommand struct {
 	Env         string `short:"e" long:"env" description:"the environment to teardown"`
 	ContainerID string `short:"c" long:"container" description:"the container to teardown"`
 	All         bool   `long:"all" description:"teardown all containers in every supervisor"`
+	LockTimeout string `long:"lock-timeout" description:"wait this long (e.g. 5m) for a conflicting deploy or teardown instead of failing"`
+	Hotfix      bool   `long:"hotfix" description:"go ahead of other queued deploys and teardowns (needs a hotfix policy)"`
 	Wait        bool   `long:"wait" description:"wait until the teardown is done before exiting"`
+	Follow      bool   `long:"follow" description:"print the teardown's log as it happens and wait until it is done"`
 	Properties  string `field:"ContainerIDs" name:"containers"`
 	Arg         ManagerTeardownArg
 	Reply       ManagerTeardownReply
@@ -121,33 +128,90 @@ func (c *TeardownResultCommand) Execute(args []string) error {
 
 type GetContainerCommand struct {
 	ContainerID string `short:"c" long:"container" description:"the container to get"`
+	Aggregate   bool   `long:"aggregate" description:"look for the container in every region"`
 	Arg         ManagerGetContainerArg
 	Reply       ManagerGetContainerReply
 }
 
-type ListContainersCommand struct {
-	App        string `short:"a" long:"app" description:"the app to list"`
-	Sha        string `short:"s" long:"sha" description:"the sha to list"`
-	Env        string `short:"e" long:"env" description:"the environment to list"`
+type QueryContainersCommand struct {
+	Supervisor string `short:"H" long:"host" description:"only containers on this supervisor"`
+	Env        string `short:"e" long:"env" description:"only containers in this environment"`
+	Team       string `short:"t" long:"team" description:"only containers of apps owned by this team"`
 	Properties string `field:"ContainerIDs" name:"containers"`
+	Arg        ManagerQueryContainersArg
+	Reply      ManagerQueryContainersReply
+}
+
+type GetContainersCommand struct {
+	App    string   `short:"a" long:"app" description:"only containers of this app"`
+	Sha    string   `short:"s" long:"sha" description:"only containers of this sha"`
+	Env    string   `short:"e" long:"env" description:"only containers in this environment"`
+	Host   string   `short:"H" long:"host" description:"only containers on this supervisor"`
+	Zone   string   `short:"z" long:"zone" description:"only containers in this zone"`
+	Live   bool     `long:"live" description:"also ask the supervisors what they are running"`
+	Prefix string   `long:"prefix" description:"only containers whose id starts with this"`
+	Labels []string `long:"label" description:"only containers with this label, as key=value (repeatable)"`
+	Sort   string   `long:"sort" description:"asc (the default) or desc"`
+	Limit  int      `long:"page-size" description:"how many to ask for at a time"`
+	Arg    ManagerGetContainersArg
+	Reply  ManagerGetContainersReply
+}
+
+type RebuildIndexesCommand struct {
+	Properties string `field:"Indexed"`
+	Arg        ManagerRebuildIndexesArg
+	Reply      ManagerRebuildIndexesReply
+}
+
+type ListContainersCommand struct {
+	App        string   `short:"a" long:"app" description:"the app to list"`
+	Sha        string   `short:"s" long:"sha" description:"the sha to list"`
+	Env        string   `short:"e" long:"env" description:"the environment to list"`
+	Aggregate  bool     `long:"aggregate" description:"ask every region, not just this one"`
+	Prefix     string   `long:"prefix" description:"only list names that start with this"`
+	Labels     []string `long:"label" description:"only list those with this label, as key=value (repeatable)"`
+	Sort       string   `long:"sort" description:"asc (the default) or desc"`
+	Limit      int      `long:"page-size" description:"how many to ask for at a time"`
+	Properties string   `field:"ContainerIDs" name:"containers"`
 	Arg        ManagerListContainersArg
 	Reply      ManagerListContainersReply
 }
 
 type ListEnvsCommand struct {
-	App   string `short:"a" long:"app" description:"the app to list (empty for all available envs)"`
-	Sha   string `short:"s" long:"sha" description:"the sha to list (empty for all available envs)"`
-	Arg   ManagerListEnvsArg
-	Reply ManagerListEnvsReply
+	App       string `short:"a" long:"app" description:"the app to list (empty for all available envs)"`
+	Sha       string `short:"s" long:"sha" description:"the sha to list (empty for all available envs)"`
+	Aggregate bool   `long:"aggregate" description:"ask every region, not just this one"`
+	Arg       ManagerListEnvsArg
+	Reply     ManagerListEnvsReply
 }
 
 type ListShasCommand struct {
-	App   string `short:"a" long:"app" description:"the app to list"`
-	Arg   ManagerListShasArg
-	Reply ManagerListShasReply
+	App       string `short:"a" long:"app" description:"the app to list"`
+	Aggregate bool   `long:"aggregate" description:"ask every region, not just this one"`
+	Prefix    string `long:"prefix" description:"only list names that start with this"`
+	Sort      string `long:"sort" description:"asc (the default) or desc"`
+	Limit     int    `long:"page-size" description:"how many to ask for at a time"`
+	Arg       ManagerListShasArg
+	Reply     ManagerListShasReply
 }
 
 type ListAppsCommand struct {
-	Arg   ManagerListAppsArg
-	Reply ManagerListAppsReply
+	Aggregate bool   `long:"aggregate" description:"ask every region, not just this one"`
+	Prefix    string `long:"prefix" description:"only list names that start with this"`
+	Sort      string `long:"sort" description:"asc (the default) or desc"`
+	Limit     int    `long:"page-size" description:"how many to ask for at a time"`
+	Arg       ManagerListAppsArg
+	Reply     ManagerListAppsReply
+}
+
+type ListLocksCommand struct {
+	Arg   ManagerListLocksArg
+	Reply ManagerListLocksReply
+}
+
+type ForceUnlockCommand struct {
+	Path       string `short:"p" long:"path" description:"the locked path to release, e.g. /app/sha/env"`
+	Properties string `field:"Lock"`
+	Arg        ManagerForceUnlockArg
+	Reply      ManagerForceUnlockReply
 }
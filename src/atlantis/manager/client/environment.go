@@ -59,3 +59,39 @@ type DeleteEnvCommand struct {
 	Arg   ManagerEnvArg
 	Reply ManagerEnvReply
 }
+
+func protectEnv(name string, protected bool) error {
+	if err := Init(); err != nil {
+		return OutputError(err)
+	}
+	if protected {
+		Log("Protect Env...")
+	} else {
+		Log("Unprotect Env...")
+	}
+	arg := ManagerProtectEnvArg{dummyAuthArg, name, protected}
+	var reply ManagerProtectEnvReply
+	if err := rpcClient.CallAuthed("ProtectEnv", &arg, &reply); err != nil {
+		return OutputError(err)
+	}
+	Log("-> Status: %s", reply.Status)
+	return Output(map[string]interface{}{"status": reply.Status}, reply.Status, nil)
+}
+
+type ProtectEnvCommand struct {
+	Name string `short:"n" long:"name" description:"the name of the environment"`
+}
+
+func (c *ProtectEnvCommand) Execute(args []string) error {
+	ExtractArgs([]*string{&c.Name}, args)
+	return protectEnv(c.Name, true)
+}
+
+type UnprotectEnvCommand struct {
+	Name string `short:"n" long:"name" description:"the name of the environment"`
+}
+
+func (c *UnprotectEnvCommand) Execute(args []string) error {
+	ExtractArgs([]*string{&c.Name}, args)
+	return protectEnv(c.Name, false)
+}
@@ -0,0 +1,207 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	. "atlantis/manager/rpc/types"
+	"time"
+)
+
+func OutputJob(job *ScheduledJob) {
+	if job == nil {
+		return
+	}
+	Log("-> %s: %s by %s", job.ID, job.Name, job.User)
+	if job.TokenID != "" {
+		Log("->   token:    %s", job.TokenID)
+	}
+	if job.Cron != "" {
+		Log("->   cron:     %s", job.Cron)
+	} else {
+		Log("->   at:       %s", job.At.Format(time.RFC3339))
+	}
+	if job.Paused {
+		Log("->   paused")
+	} else if !job.NextRun.IsZero() {
+		Log("->   next run: %s", job.NextRun.Format(time.RFC3339))
+	}
+	if job.LastTaskID != "" {
+		Log("->   last run: %s (task %s)", job.LastRun.Format(time.RFC3339), job.LastTaskID)
+	}
+	if job.LastError != "" {
+		Log("->   last error: %s", job.LastError)
+	}
+}
+
+func scheduleJob(arg ManagerScheduleJobArg) error {
+	if err := Init(); err != nil {
+		return OutputError(err)
+	}
+	Log("Schedule %s...", arg.Name)
+	var reply ManagerScheduleJobReply
+	if err := rpcClient.CallAuthed("ScheduleJob", &arg, &reply); err != nil {
+		return OutputError(err)
+	}
+	Log("-> Status: %s", reply.Status)
+	OutputJob(reply.Job)
+	return Output(map[string]interface{}{"status": reply.Status, "job": reply.Job}, reply.Job.ID, nil)
+}
+
+type ScheduleDeployCommand struct {
+	App         string `short:"a" long:"app" description:"the app to deploy"`
+	Sha         string `short:"s" long:"sha" description:"the sha to deploy"`
+	Env         string `short:"e" long:"env" description:"the environment to deploy"`
+	Instances   uint   `short:"i" long:"instances" default:"1" description:"the number of instances to deploy in each AZ"`
+	CPUShares   uint   `short:"c" long:"cpu-shares" default:"0" description:"the number of CPU shares per instance"`
+	MemoryLimit uint   `short:"m" long:"memory-limit" default:"0" description:"the MBytes of memory per instance"`
+	Dev         bool   `long:"dev" description:"only deploy 1 instance in 1 AZ"`
+	SkipBuild   bool   `long:"skip-build" description:"assume similar container (app/sha/env) already deployed; skip build image step"`
+	LockTimeout string `long:"lock-timeout" description:"wait this long (e.g. 5m) for a conflicting deploy or teardown instead of failing"`
+	Hotfix      bool   `long:"hotfix" description:"go ahead of other queued deploys and teardowns (needs a hotfix policy)"`
+	At          string `long:"at" description:"deploy once at this time, e.g. 2014-06-01T02:00:00-07:00"`
+	Cron        string `long:"cron" description:"deploy on this cron schedule, e.g. \"0 2 * * *\""`
+}
+
+func (c *ScheduleDeployCommand) Execute(args []string) error {
+	ExtractArgs([]*string{&c.App, &c.Sha, &c.Env}, args)
+	return scheduleJob(ManagerScheduleJobArg{
+		ManagerAuthArg: dummyAuthArg,
+		Name:           "Deploy",
+		Deploy: &ManagerDeployArg{
+			App:         c.App,
+			Sha:         c.Sha,
+			Env:         c.Env,
+			Instances:   c.Instances,
+			CPUShares:   c.CPUShares,
+			MemoryLimit: c.MemoryLimit,
+			Dev:         c.Dev,
+			SkipBuild:   c.SkipBuild,
+			LockTimeout: c.LockTimeout,
+			Hotfix:      c.Hotfix,
+		},
+		At:   c.At,
+		Cron: c.Cron,
+	})
+}
+
+type ScheduleTeardownCommand struct {
+	App         string `short:"a" long:"app" description:"the app to teardown"`
+	Sha         string `short:"s" long:"sha" description:"the sha to teardown"`
+	Env         string `short:"e" long:"env" description:"the environment to teardown"`
+	ContainerID string `short:"c" long:"container" description:"the container to teardown"`
+	All         bool   `long:"all" description:"teardown all containers in every supervisor"`
+	LockTimeout string `long:"lock-timeout" description:"wait this long (e.g. 5m) for a conflicting deploy or teardown instead of failing"`
+	Hotfix      bool   `long:"hotfix" description:"go ahead of other queued deploys and teardowns (needs a hotfix policy)"`
+	At          string `long:"at" description:"teardown once at this time, e.g. 2014-06-01T02:00:00-07:00"`
+	Cron        string `long:"cron" description:"teardown on this cron schedule, e.g. \"0 2 * * *\""`
+}
+
+func (c *ScheduleTeardownCommand) Execute(args []string) error {
+	ExtractArgs([]*string{&c.App, &c.Sha, &c.Env}, args)
+	return scheduleJob(ManagerScheduleJobArg{
+		ManagerAuthArg: dummyAuthArg,
+		Name:           "Teardown",
+		Teardown: &ManagerTeardownArg{
+			App:         c.App,
+			Sha:         c.Sha,
+			Env:         c.Env,
+			ContainerID: c.ContainerID,
+			All:         c.All,
+			LockTimeout: c.LockTimeout,
+			Hotfix:      c.Hotfix,
+		},
+		At:   c.At,
+		Cron: c.Cron,
+	})
+}
+
+type ScheduleDeployContainerCommand struct {
+	ContainerID string `short:"c" long:"container" description:"the id of the container to replicate"`
+	Instances   uint   `short:"i" long:"instances" default:"1" description:"the number of instances to deploy in each AZ"`
+	At          string `long:"at" description:"deploy once at this time, e.g. 2014-06-01T02:00:00-07:00"`
+	Cron        string `long:"cron" description:"deploy on this cron schedule, e.g. \"0 2 * * *\""`
+}
+
+func (c *ScheduleDeployContainerCommand) Execute(args []string) error {
+	ExtractArgs([]*string{&c.ContainerID}, args)
+	return scheduleJob(ManagerScheduleJobArg{
+		ManagerAuthArg:  dummyAuthArg,
+		Name:            "DeployContainer",
+		DeployContainer: &ManagerDeployContainerArg{ContainerID: c.ContainerID, Instances: c.Instances},
+		At:              c.At,
+		Cron:            c.Cron,
+	})
+}
+
+type ListJobsCommand struct {
+}
+
+func (c *ListJobsCommand) Execute(args []string) error {
+	if err := Init(); err != nil {
+		return OutputError(err)
+	}
+	Log("List Jobs...")
+	arg := ManagerListJobsArg{dummyAuthArg}
+	var reply ManagerListJobsReply
+	if err := rpcClient.CallAuthed("ListJobs", &arg, &reply); err != nil {
+		return OutputError(err)
+	}
+	ids := make([]string, len(reply.Jobs))
+	for i, job := range reply.Jobs {
+		OutputJob(job)
+		ids[i] = job.ID
+	}
+	return Output(map[string]interface{}{"status": reply.Status, "jobs": reply.Jobs}, ids, nil)
+}
+
+func pauseJob(id string, paused bool) error {
+	if err := Init(); err != nil {
+		return OutputError(err)
+	}
+	if paused {
+		Log("Pause Job...")
+	} else {
+		Log("Resume Job...")
+	}
+	arg := ManagerPauseJobArg{dummyAuthArg, id, paused}
+	var reply ManagerPauseJobReply
+	if err := rpcClient.CallAuthed("PauseJob", &arg, &reply); err != nil {
+		return OutputError(err)
+	}
+	Log("-> Status: %s", reply.Status)
+	OutputJob(reply.Job)
+	return Output(map[string]interface{}{"status": reply.Status, "job": reply.Job}, reply.Status, nil)
+}
+
+type PauseJobCommand struct {
+	ID string `short:"i" long:"id" description:"the job to pause"`
+}
+
+func (c *PauseJobCommand) Execute(args []string) error {
+	ExtractArgs([]*string{&c.ID}, args)
+	return pauseJob(c.ID, true)
+}
+
+type ResumeJobCommand struct {
+	ID string `short:"i" long:"id" description:"the job to resume"`
+}
+
+func (c *ResumeJobCommand) Execute(args []string) error {
+	ExtractArgs([]*string{&c.ID}, args)
+	return pauseJob(c.ID, false)
+}
+
+type DeleteJobCommand struct {
+	ID    string `short:"i" long:"id" description:"the job to delete"`
+	Arg   ManagerDeleteJobArg
+	Reply ManagerDeleteJobReply
+}
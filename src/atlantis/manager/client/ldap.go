@@ -52,6 +52,36 @@ func (c *LoginCommand) Execute(args []string) error {
 	return OutputEmpty()
 }
 
+type LogoutCommand struct {
+}
+
+// Ends the session on the managers and forgets its secret here
+func (c *LogoutCommand) Execute(args []string) error {
+	overlayConfig()
+	Log("Logging out over RPC")
+	user, secrets, err := GetSecrets()
+	if err != nil {
+		return OutputError(err)
+	}
+	rpcClient.User = user
+	rpcClient.Secrets = secrets
+	for r := range cfg {
+		hostName := rpcClient.Opts[r].RPCHostAndPort()
+		if secrets[hostName] == "" {
+			continue
+		}
+		arg := ManagerLogoutArg{dummyAuthArg}
+		var reply ManagerLogoutReply
+		if err := rpcClient.CallAuthedMulti("Logout", &arg, r, &reply); err != nil {
+			Log("-> %s: %s", hostName, err.Error())
+		}
+		if err := SaveSecret(user, "", hostName); err != nil {
+			return OutputError(err)
+		}
+	}
+	return OutputEmpty()
+}
+
 // ----------------------------------------------------------------------------------------------------------
 // User and Application Authorization
 // ----------------------------------------------------------------------------------------------------------
@@ -75,7 +105,7 @@ func (c *CreateTeamCommand) Execute(args []string) error {
 		return err
 	}
 	if c.Team != "" {
-		auth := ManagerAuthArg{user, "", secret}
+		auth := ManagerAuthArg{user, "", secret, ""}
 		arg := ManagerTeamArg{auth, c.Team}
 		var reply ManagerTeamReply
 		if err := rpcClient.Call("CreateTeam", arg, &reply); err != nil {
@@ -112,7 +142,7 @@ func (c *DeleteTeamCommand) Execute(args []string) error {
 	if c.Team == "" {
 		return Output(map[string]interface{}{}, nil, errors.New("Missing Team Argument"))
 	}
-	auth := ManagerAuthArg{user, "", secret}
+	auth := ManagerAuthArg{user, "", secret, ""}
 	arg := ManagerTeamArg{auth, c.Team}
 	var reply ManagerTeamReply
 	if err := rpcClient.Call("DeleteTeam", arg, &reply); err != nil {
@@ -155,6 +185,24 @@ type ListTeamAppsCommand struct {
   Properties string `field:"TeamApps" name:"apps"`
 }
 
+type SyncTeamAppsCommand struct {
+}
+
+func (c *SyncTeamAppsCommand) Execute(args []string) error {
+	if err := Init(); err != nil {
+		return OutputError(err)
+	}
+	Log("Sync Team Apps...")
+	arg := ManagerSyncTeamAppsArg{ManagerAuthArg: dummyAuthArg}
+	var reply ManagerSyncTeamAppsReply
+	if err := rpcClient.CallAuthed("SyncTeamApps", &arg, &reply); err != nil {
+		return OutputError(err)
+	}
+	Log("-> added: %v", reply.Added)
+	Log("-> removed: %v", reply.Removed)
+	return Output(map[string]interface{}{"added": reply.Added, "removed": reply.Removed}, nil, nil)
+}
+
 type AddTeamMemberCommand struct {
 	User string `short:"u" long:"user" description:"the name of the user"`
 	Team string `short:"t" long:"team" description:"the name of the team"`
@@ -200,7 +248,7 @@ func ModifyTeamMember(action, team, user string) error {
 	if user == "" && team == "" {
 		return errors.New("Missing User/Team Arguments")
 	}
-	auth := ManagerAuthArg{cuser, "", secret}
+	auth := ManagerAuthArg{cuser, "", secret, ""}
 	arg := ManagerTeamMemberArg{auth, team, user}
 	var reply ManagerTeamMemberReply
 	if err := rpcClient.Call(action, arg, &reply); err != nil {
@@ -253,7 +301,7 @@ func ModifyTeamAdmin(action, user, team string) error {
 	if user == "" && team == "" {
 		return errors.New("Missing User/Team Arguments")
 	}
-	auth := ManagerAuthArg{cuser, "", secret}
+	auth := ManagerAuthArg{cuser, "", secret, ""}
 	arg := ManagerModifyTeamAdminArg{auth, team, user}
 	var reply ManagerAppReply
 	if err := rpcClient.Call(action, arg, &reply); err != nil {
@@ -288,14 +336,11 @@ func (c *DisallowTeamAppCommand) Execute(args []string) error {
 	return OutputEmpty()
 }
 
+// team apps live in zookeeper so this works without LDAP operations
 func ModifyAllowedTeamApp(action, team, app string) error {
 	if err := Init(); err != nil {
 		return err
 	}
-	if !ldapOperationsEnabled {
-		Log("LDAP Operations are not enabled.")
-		return OutputEmpty()
-	}
 	user, secret, err := GetSecret()
 	if err != nil {
 		return err
@@ -303,7 +348,7 @@ func ModifyAllowedTeamApp(action, team, app string) error {
 	if app == "" && team == "" {
 		return errors.New("Missing App/Team Arguments")
 	}
-	auth := ManagerAuthArg{user, "", secret}
+	auth := ManagerAuthArg{user, "", secret, ""}
 	arg := ManagerAppArg{auth, app, team}
 	var reply ManagerAppReply
 	if err := rpcClient.Call(action, arg, &reply); err != nil {
@@ -356,7 +401,7 @@ func ModifyTeamEmail(action, email, team string) error {
 	if email == "" && team == "" {
 		return errors.New("Missing Email/Team Arguments")
 	}
-	auth := ManagerAuthArg{user, "", secret}
+	auth := ManagerAuthArg{user, "", secret, ""}
 	arg := ManagerEmailArg{auth, team, email}
 	var reply ManagerEmailReply
 	if err := rpcClient.Call(action, arg, &reply); err != nil {
@@ -382,7 +427,7 @@ func (c *IsAppAllowedCommand) Execute(args []string) error {
 	if err != nil {
 		return err
 	}
-	auth := ManagerAuthArg{user, "", secret}
+	auth := ManagerAuthArg{user, "", secret, ""}
 	arg := ManagerIsAppAllowedArg{ManagerAuthArg: auth, User: c.User, App: c.App}
 	var reply ManagerIsAppAllowedReply
 	if err = rpcClient.Call("IsAppAllowed", arg, &reply); err == nil {
@@ -407,7 +452,7 @@ func (c *ListAllowedAppsCommand) Execute(args []string) error {
 	if err != nil {
 		return err
 	}
-	auth := ManagerAuthArg{user, "", secret}
+	auth := ManagerAuthArg{user, "", secret, ""}
 	arg := ManagerListAllowedAppsArg{ManagerAuthArg: auth, User: c.User}
 	var reply ManagerListAllowedAppsReply
 	if err = rpcClient.Call("ListAllowedApps", arg, &reply); err == nil {
@@ -556,7 +601,7 @@ func IsTeamAdmin(team string) error {
 	if team == "" {
 		return errors.New("No team specified")
 	}
-	auth := ManagerAuthArg{user, "", secret}
+	auth := ManagerAuthArg{user, "", secret, ""}
 	arg := ManagerTeamArg{auth, team}
 	var reply ManagerTeamAdminReply
 
@@ -577,7 +622,7 @@ func IsSuperUser() error {
 	if user == "" {
 		return errors.New("Not in SuperUsers Group")
 	}
-	auth := ManagerAuthArg{user, "", secret}
+	auth := ManagerAuthArg{user, "", secret, ""}
 	arg := ManagerSuperUserArg{auth}
 	var reply ManagerSuperUserReply
 	if err := rpcClient.Call("IsSuperUser", arg, &reply); err != nil {
@@ -0,0 +1,104 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	. "atlantis/manager/rpc/types"
+	"strings"
+)
+
+func OutputPolicy(policy *PolicyRule) {
+	if policy == nil {
+		return
+	}
+	who := "user " + policy.User
+	if policy.Team != "" {
+		who = "team " + policy.Team
+	}
+	Log("-> %s: %s may %s %s in %s (added by %s)", policy.ID, who, strings.Join(policy.Actions, ", "), policy.App,
+		policy.Env, policy.CreatedBy)
+}
+
+type AddPolicyCommand struct {
+	Team    string   `short:"t" long:"team" description:"grant the members of this team"`
+	User    string   `short:"u" long:"user" description:"grant this user"`
+	App     string   `short:"a" long:"app" default:"*" description:"the apps the policy covers, e.g. web-*"`
+	Env     string   `short:"e" long:"env" default:"*" description:"the envs the policy covers, e.g. prod*"`
+	Actions []string `short:"x" long:"action" description:"deploy, teardown, ssh, maintenance, read or hotfix (repeatable)"`
+}
+
+func (c *AddPolicyCommand) Execute(args []string) error {
+	if err := Init(); err != nil {
+		return OutputError(err)
+	}
+	Log("Add Policy...")
+	arg := ManagerAddPolicyArg{dummyAuthArg, c.Team, c.User, c.App, c.Env, c.Actions}
+	var reply ManagerAddPolicyReply
+	if err := rpcClient.CallAuthed("AddPolicy", &arg, &reply); err != nil {
+		return OutputError(err)
+	}
+	Log("-> Status: %s", reply.Status)
+	OutputPolicy(reply.Policy)
+	return Output(map[string]interface{}{"status": reply.Status, "policy": reply.Policy}, reply.Policy.ID, nil)
+}
+
+type RemovePolicyCommand struct {
+	ID    string `short:"i" long:"id" description:"the policy to remove"`
+	Arg   ManagerRemovePolicyArg
+	Reply ManagerRemovePolicyReply
+}
+
+type ListPoliciesCommand struct {
+}
+
+func (c *ListPoliciesCommand) Execute(args []string) error {
+	if err := Init(); err != nil {
+		return OutputError(err)
+	}
+	Log("List Policies...")
+	arg := ManagerListPoliciesArg{dummyAuthArg}
+	var reply ManagerListPoliciesReply
+	if err := rpcClient.CallAuthed("ListPolicies", &arg, &reply); err != nil {
+		return OutputError(err)
+	}
+	ids := make([]string, len(reply.Policies))
+	for i, policy := range reply.Policies {
+		OutputPolicy(policy)
+		ids[i] = policy.ID
+	}
+	return Output(map[string]interface{}{"status": reply.Status, "policies": reply.Policies}, ids, nil)
+}
+
+type CanICommand struct {
+	Action string `short:"x" long:"action" description:"deploy, teardown, ssh, maintenance, read or hotfix"`
+	App    string `short:"a" long:"app" description:"the app"`
+	Env    string `short:"e" long:"env" description:"the environment"`
+}
+
+func (c *CanICommand) Execute(args []string) error {
+	ExtractArgs([]*string{&c.Action, &c.App, &c.Env}, args)
+	if err := Init(); err != nil {
+		return OutputError(err)
+	}
+	arg := ManagerCanIArg{dummyAuthArg, c.Action, c.App, c.Env}
+	var reply ManagerCanIReply
+	if err := rpcClient.CallAuthed("CanI", &arg, &reply); err != nil {
+		return OutputError(err)
+	}
+	if reply.Allowed {
+		Log("yes: %s", reply.Reason)
+	} else {
+		Log("no: %s", reply.Reason)
+	}
+	return Output(map[string]interface{}{"status": reply.Status, "allowed": reply.Allowed, "reason": reply.Reason},
+		reply.Allowed, nil)
+}
@@ -103,6 +103,7 @@ type RegisterAppCommand struct {
 	Repo        string `short:"g" long:"git" description:"the app's git repository"`
 	Root        string `short:"r" long:"root" description:"the app's root within the repo"`
 	Email       string `short:"e" long:"email" description"the email of the app's owner"`
+	Team        string `short:"t" long:"team" description:"the team that owns the app"`
 	Arg         ManagerRegisterAppArg
 	Reply       ManagerRegisterAppReply
 }
@@ -154,13 +155,21 @@ type GetAppCommand struct {
 }
 
 type ListRegisteredAppsCommand struct {
-	Arg   ManagerListRegisteredAppsArg
-	Reply ManagerListRegisteredAppsReply
+	Prefix string   `long:"prefix" description:"only list names that start with this"`
+	Labels []string `long:"label" description:"only list those with this label, as key=value (repeatable)"`
+	Sort   string   `long:"sort" description:"asc (the default) or desc"`
+	Limit  int      `long:"page-size" description:"how many to ask for at a time"`
+	Arg    ManagerListRegisteredAppsArg
+	Reply  ManagerListRegisteredAppsReply
 }
 
 type ListAuthorizedRegisteredAppsCommand struct {
-	Arg   ManagerListRegisteredAppsArg
-	Reply ManagerListRegisteredAppsReply
+	Prefix string   `long:"prefix" description:"only list names that start with this"`
+	Labels []string `long:"label" description:"only list those with this label, as key=value (repeatable)"`
+	Sort   string   `long:"sort" description:"asc (the default) or desc"`
+	Limit  int      `long:"page-size" description:"how many to ask for at a time"`
+	Arg    ManagerListRegisteredAppsArg
+	Reply  ManagerListRegisteredAppsReply
 }
 
 type HealthCommand struct {
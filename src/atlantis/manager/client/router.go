@@ -63,7 +63,11 @@ type GetPoolCommand struct {
 }
 
 type ListPoolsCommand struct {
-	Internal bool `short:"i" long:"internal" description:"true if internal"`
+	Internal bool     `short:"i" long:"internal" description:"true if internal"`
+	Prefix   string   `long:"prefix" description:"only list names that start with this"`
+	Labels   []string `long:"label" description:"only list those with this label, as key=value (repeatable)"`
+	Sort     string   `long:"sort" description:"asc (the default) or desc"`
+	Limit    int      `long:"page-size" description:"how many to ask for at a time"`
 	Arg      ManagerListPoolsArg
 	Reply    ManagerListPoolsReply
 }
@@ -111,7 +115,11 @@ type GetRuleCommand struct {
 }
 
 type ListRulesCommand struct {
-	Internal bool `short:"i" long:"internal" description:"true if internal"`
+	Internal bool     `short:"i" long:"internal" description:"true if internal"`
+	Prefix   string   `long:"prefix" description:"only list names that start with this"`
+	Labels   []string `long:"label" description:"only list those with this label, as key=value (repeatable)"`
+	Sort     string   `long:"sort" description:"asc (the default) or desc"`
+	Limit    int      `long:"page-size" description:"how many to ask for at a time"`
 	Arg      ManagerListRulesArg
 	Reply    ManagerListRulesReply
 }
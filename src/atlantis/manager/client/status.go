@@ -16,6 +16,17 @@ import (
 )
 
 type UsageCommand struct {
-	Arg   ManagerUsageArg
-	Reply ManagerUsageReply
+	Aggregate bool `long:"aggregate" description:"ask every region, not just this one"`
+	Arg       ManagerUsageArg
+	Reply     ManagerUsageReply
+}
+
+type CacheStatsCommand struct {
+	Arg   ManagerCacheStatsArg
+	Reply ManagerCacheStatsReply
+}
+
+type RateLimitsCommand struct {
+	Arg   ManagerRateLimitsArg
+	Reply ManagerRateLimitsReply
 }
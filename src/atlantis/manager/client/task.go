@@ -13,7 +13,12 @@ package client
 
 import (
 	. "atlantis/common"
+	. "atlantis/manager/rpc/types"
+	"bytes"
 	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
 	"time"
 )
 
@@ -81,7 +86,8 @@ func (c *ResultCommand) Execute(args []string) error {
 }
 
 type WaitCommand struct {
-	ID string `short:"i" long:"id" description:"the task ID to wait on"`
+	ID     string `short:"i" long:"id" description:"the task ID to wait on"`
+	Follow bool   `long:"follow" description:"print the task's log as it happens"`
 }
 
 func (c *WaitCommand) Execute(args []string) error {
@@ -90,6 +96,12 @@ func (c *WaitCommand) Execute(args []string) error {
 		return OutputError(err)
 	}
 	args = ExtractArgs([]*string{&c.ID}, args)
+	if c.Follow {
+		if err := followTask(c.ID); err != nil {
+			return OutputError(err)
+		}
+		return (&ResultCommand{c.ID}).Execute(args)
+	}
 	Log("Waiting...")
 	arg := c.ID
 	var statusReply TaskStatus
@@ -110,6 +122,34 @@ func (c *WaitCommand) Execute(args []string) error {
 	return (&ResultCommand{c.ID}).Execute(args)
 }
 
+// followTask prints the task's log as the manager records it and returns once the task is done. If the task
+// failed, its error is returned so that the exit status reflects it.
+func followTask(id string) error {
+	Log("Following...")
+	arg := ManagerTaskLogArg{ManagerAuthArg: dummyAuthArg, ID: id}
+	var reply ManagerTaskLogReply
+	for {
+		reply = ManagerTaskLogReply{}
+		if err := rpcClient.CallAuthed("TaskLog", &arg, &reply); err != nil {
+			return err
+		}
+		for _, line := range reply.Lines {
+			Log(line)
+		}
+		arg.Since = reply.Next
+		if reply.Done {
+			break
+		}
+	}
+	for _, warning := range reply.Warnings {
+		Log("Warning: %s", warning)
+	}
+	if reply.Error != "" {
+		return errors.New(reply.Error)
+	}
+	return nil
+}
+
 type ListTaskIDsCommand struct {
 }
 
@@ -124,3 +164,111 @@ func (c *ListTaskIDsCommand) Execute(args []string) error {
 	}
 	return Output(map[string]interface{}{"ids": ids}, ids, nil)
 }
+
+type TaskQueueCommand struct {
+}
+
+func (c *TaskQueueCommand) Execute(args []string) error {
+	if err := Init(); err != nil {
+		return OutputError(err)
+	}
+	Log("Task Queue...")
+	arg := ManagerTaskQueueArg{dummyAuthArg}
+	var reply ManagerTaskQueueReply
+	if err := rpcClient.CallAuthed("TaskQueue", &arg, &reply); err != nil {
+		return OutputError(err)
+	}
+	Log("-> Running:")
+	for _, qt := range reply.Running {
+		Log("->   %s %s %s [%s] since %s", qt.ID, qt.Name, qt.App, qt.User, qt.Queued.Format(time.RFC3339))
+	}
+	Log("-> Waiting:")
+	for _, qt := range reply.Waiting {
+		priority := ""
+		if qt.Priority {
+			priority = " (priority)"
+		}
+		Log("->   %d. %s %s %s [%s]%s since %s", qt.Position, qt.ID, qt.Name, qt.App, qt.User, priority,
+			qt.Queued.Format(time.RFC3339))
+	}
+	ids := []string{}
+	for _, qt := range reply.Waiting {
+		ids = append(ids, qt.ID)
+	}
+	return Output(map[string]interface{}{"status": reply.Status, "running": reply.Running, "waiting": reply.Waiting,
+		"limits": reply.Limits, "appLimit": reply.AppLimit}, ids, nil)
+}
+
+type ListTasksCommand struct {
+	User           string   `short:"u" long:"user" description:"only tasks started by this user"`
+	Type           []string `short:"t" long:"type" description:"only tasks of this type, e.g. Deploy (may be repeated)"`
+	App            string   `short:"a" long:"app" description:"only tasks for this app"`
+	Env            string   `short:"e" long:"env" description:"only tasks in this environment"`
+	Status         string   `short:"s" long:"status" description:"only tasks that are running, done, error or in this exact status"`
+	StartedAfter   string   `long:"started-after" description:"only tasks started after this time (RFC3339) or this long ago (e.g. 24h)"`
+	StartedBefore  string   `long:"started-before" description:"only tasks started before this time (RFC3339) or this long ago"`
+	FinishedAfter  string   `long:"finished-after" description:"only tasks finished after this time (RFC3339) or this long ago"`
+	FinishedBefore string   `long:"finished-before" description:"only tasks finished before this time (RFC3339) or this long ago"`
+	Offset         int      `long:"offset" description:"skip this many tasks"`
+	Limit          int      `long:"limit" description:"show at most this many tasks (default 50)"`
+}
+
+// parseTaskTime reads either an RFC3339 time or a duration before now. Empty is the zero time.
+func parseTaskTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if ago, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-ago), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func (c *ListTasksCommand) Execute(args []string) error {
+	if err := Init(); err != nil {
+		return OutputError(err)
+	}
+	arg := ManagerQueryTasksArg{
+		ManagerAuthArg: dummyAuthArg,
+		TaskUser:       c.User,
+		Names:          c.Type,
+		App:            c.App,
+		Env:            c.Env,
+		Status:         c.Status,
+		Offset:         c.Offset,
+		Limit:          c.Limit,
+	}
+	values := []string{c.StartedAfter, c.StartedBefore, c.FinishedAfter, c.FinishedBefore}
+	fields := []*time.Time{&arg.StartedAfter, &arg.StartedBefore, &arg.FinishedAfter, &arg.FinishedBefore}
+	for i, value := range values {
+		parsed, err := parseTaskTime(value)
+		if err != nil {
+			return OutputError(err)
+		}
+		*fields[i] = parsed
+	}
+	Log("List Tasks...")
+	var reply ManagerQueryTasksReply
+	if err := rpcClient.CallAuthed("QueryTasks", &arg, &reply); err != nil {
+		return OutputError(err)
+	}
+	var table bytes.Buffer
+	w := tabwriter.NewWriter(&table, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTYPE\tUSER\tSTATUS\tSTARTED\tFINISHED\tDESCRIPTION")
+	ids := make([]string, len(reply.Tasks))
+	for i, task := range reply.Tasks {
+		ids[i] = task.ID
+		finished := "-"
+		if task.Done {
+			finished = task.EndTime.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", task.ID, task.Name, task.User, task.Status,
+			task.StartTime.Format(time.RFC3339), finished, task.Description)
+	}
+	w.Flush()
+	for _, line := range strings.Split(strings.TrimRight(table.String(), "\n"), "\n") {
+		Log("%s", line)
+	}
+	Log("-> showing %d of %d tasks", len(reply.Tasks), reply.Total)
+	return Output(map[string]interface{}{"tasks": reply.Tasks, "total": reply.Total}, ids, nil)
+}
@@ -0,0 +1,125 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	. "atlantis/manager/rpc/types"
+	"strings"
+	"time"
+)
+
+// TokenEnvVar holds an API token to use instead of logging in, e.g. in CI.
+const TokenEnvVar = "ATLANTIS_TOKEN"
+
+// UseToken makes every call to every region use token, either an API token or a bearer token from the identity
+// provider the manager trusts. The manager works out who the token acts as.
+func UseToken(token string) {
+	rpcClient.User = ""
+	for r := range cfg {
+		rpcClient.Secrets[rpcClient.Opts[r].RPCHostAndPort()] = token
+	}
+}
+
+func orAll(list []string) string {
+	if len(list) == 0 {
+		return "all"
+	}
+	return strings.Join(list, ", ")
+}
+
+func OutputToken(token *APIToken) {
+	if token == nil {
+		return
+	}
+	if token.ServiceAccount {
+		Log("-> %s: %s for service account %s (by %s)", token.ID, token.Name, token.User, token.Owner)
+	} else {
+		Log("-> %s: %s for %s", token.ID, token.Name, token.User)
+	}
+	if token.Role != "" {
+		Log("->   role:       %s", token.Role)
+	}
+	Log("->   apps:       %s", orAll(token.Apps))
+	Log("->   envs:       %s", orAll(token.Envs))
+	Log("->   operations: %s", orAll(token.Operations))
+	Log("->   created:    %s", token.Created.Format(time.RFC3339))
+	if token.Revoked {
+		Log("->   revoked")
+	} else if !token.Expires.IsZero() {
+		Log("->   expires:    %s", token.Expires.Format(time.RFC3339))
+	}
+}
+
+type CreateTokenCommand struct {
+	Name           string   `short:"n" long:"name" description:"what the token is for"`
+	ServiceAccount string   `short:"s" long:"service-account" description:"[superuser only] create the token for this service account instead of yourself"`
+	Role           string   `short:"r" long:"role" description:"the team the service account is in, e.g. the superuser group"`
+	Apps           []string `short:"a" long:"app" description:"limit the token to this app (repeatable); superusers may leave it out for all apps"`
+	Envs           []string `short:"e" long:"env" description:"limit the token to this environment (repeatable)"`
+	Operations     []string `short:"o" long:"op" description:"limit the token to this RPC, e.g. Deploy (repeatable)"`
+	TTL            string   `short:"t" long:"ttl" description:"expire the token after this long, e.g. 2160h"`
+}
+
+func (c *CreateTokenCommand) Execute(args []string) error {
+	ExtractArgs([]*string{&c.Name}, args)
+	if err := Init(); err != nil {
+		return OutputError(err)
+	}
+	Log("Create Token...")
+	arg := ManagerCreateTokenArg{
+		ManagerAuthArg: dummyAuthArg,
+		Name:           c.Name,
+		ServiceAccount: c.ServiceAccount,
+		Role:           c.Role,
+		Apps:           c.Apps,
+		Envs:           c.Envs,
+		Operations:     c.Operations,
+		TTL:            c.TTL,
+	}
+	var reply ManagerCreateTokenReply
+	if err := rpcClient.CallAuthed("CreateToken", &arg, &reply); err != nil {
+		return OutputError(err)
+	}
+	Log("-> Status: %s", reply.Status)
+	OutputToken(reply.Token)
+	Log("-> Secret: %s", reply.Secret)
+	Log("-> this is the only time the secret is shown; set %s to it to use the token", TokenEnvVar)
+	return Output(map[string]interface{}{"status": reply.Status, "token": reply.Token, "secret": reply.Secret},
+		reply.Secret, nil)
+}
+
+type ListTokensCommand struct {
+}
+
+func (c *ListTokensCommand) Execute(args []string) error {
+	if err := Init(); err != nil {
+		return OutputError(err)
+	}
+	Log("List Tokens...")
+	arg := ManagerListTokensArg{dummyAuthArg}
+	var reply ManagerListTokensReply
+	if err := rpcClient.CallAuthed("ListTokens", &arg, &reply); err != nil {
+		return OutputError(err)
+	}
+	ids := make([]string, len(reply.Tokens))
+	for i, token := range reply.Tokens {
+		OutputToken(token)
+		ids[i] = token.ID
+	}
+	return Output(map[string]interface{}{"status": reply.Status, "tokens": reply.Tokens}, ids, nil)
+}
+
+type RevokeTokenCommand struct {
+	ID    string `short:"i" long:"id" description:"the token to revoke"`
+	Arg   ManagerRevokeTokenArg
+	Reply ManagerRevokeTokenReply
+}
@@ -40,4 +40,25 @@ const (
 	DefaultSuperUserOnlyCheckInterval = "5s"
 	DefaultMinRouterPort              = uint16(49152)
 	DefaultMaxRouterPort              = uint16(65535)
+	DefaultTeamAppsSyncInterval       = "10m"
+	DefaultTaskRetention              = "168h"
+	DefaultTaskLimits                 = "Deploy:10,DeployContainer:10,CopyContainer:10,Teardown:10"
+	DefaultAppTaskLimit               = uint(2)
+	DefaultSchedulerInterval          = "30s"
+	DefaultSessionStore               = "zookeeper"
+	DefaultApprovalTimeout            = "1h"
+	DefaultAuditSink                  = "zookeeper"
+	DefaultAuditFile                  = "/var/log/atlantis/manager/audit.log"
+	DefaultAuditRetention             = "0"
+	DefaultAuditQueryLimit            = 100
+	MaxAuditQueryLimit                = 1000
+	DefaultAuthProvider               = "ldap"
+	DefaultJWTUserClaim               = "sub"
+	DefaultJWTTeamsClaim              = "groups"
+	DefaultLDAPPoolSize               = uint(10)
+	DefaultLDAPCacheTTL               = "5m"
+	DefaultTLSClientAuth              = "none"
+	DefaultRateLimits                 = "read:50/200,mutate:10/50,login:1/10"
+	DefaultTaskQueryLimit             = 50
+	MaxTaskQueryLimit                 = 500
 )
@@ -42,3 +42,19 @@ func DecryptDependerEnvData(data *types.DependerEnvData) error {
 	}
 	return nil
 }
+
+func EncryptCredentials(auth *types.ManagerAuthArg) (string, error) {
+	jsonBytes, err := json.Marshal(auth)
+	if err != nil {
+		return "", err
+	}
+	return string(crypto.Encrypt(jsonBytes)), nil
+}
+
+func DecryptCredentials(data string) (*types.ManagerAuthArg, error) {
+	auth := &types.ManagerAuthArg{}
+	if err := json.Unmarshal(crypto.Decrypt([]byte(data)), auth); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
@@ -0,0 +1,113 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package crypto
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"log"
+	"sync"
+)
+
+// TLSStore holds the certificate the manager serves and the CAs it trusts for client certificates. Reload reads
+// them from disk again; connections that are already up keep what they were set up with and new handshakes get
+// the new files.
+type TLSStore struct {
+	sync.RWMutex
+	CertFile   string
+	KeyFile    string
+	CAFile     string
+	ClientAuth tls.ClientAuthType
+	cert       *tls.Certificate
+	clientCAs  *x509.CertPool
+}
+
+// TLS is what the RPC and API listeners are configured from.
+var TLS = &TLSStore{}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":    tls.NoClientCert,
+	"request": tls.VerifyClientCertIfGiven,
+	"require": tls.RequireAndVerifyClientCert,
+}
+
+// InitTLS sets where the certificate, key and CA bundle are and whether clients must present a certificate
+// (none, request or require). Without a cert and key the compiled in certificate is served.
+func InitTLS(certFile, keyFile, caFile, clientAuth string) error {
+	authType, ok := clientAuthTypes[clientAuth]
+	if !ok {
+		return errors.New("Invalid TLS client auth " + clientAuth + ", use none, request or require")
+	}
+	if (certFile == "") != (keyFile == "") {
+		return errors.New("Please specify both a TLS cert and key, or neither")
+	}
+	if authType != tls.NoClientCert && caFile == "" {
+		return errors.New("Please specify a CA bundle to verify client certificates with")
+	}
+	TLS = &TLSStore{CertFile: certFile, KeyFile: keyFile, CAFile: caFile, ClientAuth: authType}
+	return TLS.Reload()
+}
+
+// Reload reads the certificate, key and CA bundle again. Nothing changes if any of them can not be read.
+func (s *TLSStore) Reload() error {
+	var cert tls.Certificate
+	var err error
+	if s.CertFile == "" {
+		cert, err = tls.X509KeyPair(SERVER_CERT, SERVER_KEY)
+	} else {
+		cert, err = tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+	}
+	if err != nil {
+		return err
+	}
+	var pool *x509.CertPool
+	if s.CAFile != "" {
+		data, err := ioutil.ReadFile(s.CAFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return errors.New("No certificates in CA bundle " + s.CAFile)
+		}
+	}
+	s.Lock()
+	s.cert = &cert
+	s.clientCAs = pool
+	s.Unlock()
+	if s.CertFile != "" {
+		log.Printf("[TLS] loaded %s", s.CertFile)
+	}
+	return nil
+}
+
+// Config returns a server config that looks up the current certificate and CAs on every handshake.
+func (s *TLSStore) Config() *tls.Config {
+	return &tls.Config{
+		NextProtos: []string{"http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			s.RLock()
+			defer s.RUnlock()
+			if s.cert == nil {
+				return nil, errors.New("TLS is not initialized")
+			}
+			return &tls.Config{
+				NextProtos:   []string{"http/1.1"},
+				Certificates: []tls.Certificate{*s.cert},
+				ClientCAs:    s.clientCAs,
+				ClientAuth:   s.ClientAuth,
+			}, nil
+		},
+	}
+}
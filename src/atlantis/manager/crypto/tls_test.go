@@ -0,0 +1,95 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	. "github.com/adjust/gocheck"
+	"io/ioutil"
+	"math/big"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestCrypto(t *testing.T) { TestingT(t) }
+
+type TLSSuite struct{}
+
+var _ = Suite(&TLSSuite{})
+
+// writeCert writes a self signed certificate for cn and its key to dir and returns their paths
+func writeCert(c *C, dir, cn string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: cn},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour), IsCA: true,
+		BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+	certFile, keyFile := path.Join(dir, "cert.pem"), path.Join(dir, "key.pem")
+	c.Assert(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600), IsNil)
+	c.Assert(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600),
+		IsNil)
+	return certFile, keyFile
+}
+
+func servedCN(c *C, store *TLSStore) string {
+	config, err := store.Config().GetConfigForClient(&tls.ClientHelloInfo{})
+	c.Assert(err, IsNil)
+	cert, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	c.Assert(err, IsNil)
+	return cert.Subject.CommonName
+}
+
+func (s *TLSSuite) TestInitTLS(c *C) {
+	defer func(store *TLSStore) { TLS = store }(TLS)
+	dir := c.MkDir()
+	certFile, keyFile := writeCert(c, dir, "manager-1")
+	c.Assert(InitTLS(certFile, "", "", "none"), Not(IsNil))
+	c.Assert(InitTLS(certFile, keyFile, "", "sometimes"), Not(IsNil))
+	c.Assert(InitTLS(certFile, keyFile, "", "require"), Not(IsNil))
+	c.Assert(InitTLS(certFile, keyFile, path.Join(dir, "missing.pem"), "require"), Not(IsNil))
+	c.Assert(InitTLS(certFile, keyFile, keyFile, "require"), Not(IsNil))
+
+	c.Assert(InitTLS(certFile, keyFile, certFile, "require"), IsNil)
+	config, err := TLS.Config().GetConfigForClient(&tls.ClientHelloInfo{})
+	c.Assert(err, IsNil)
+	c.Assert(config.ClientAuth, Equals, tls.RequireAndVerifyClientCert)
+	c.Assert(config.ClientCAs, Not(IsNil))
+	c.Assert(servedCN(c, TLS), Equals, "manager-1")
+}
+
+func (s *TLSSuite) TestReload(c *C) {
+	dir := c.MkDir()
+	certFile, keyFile := writeCert(c, dir, "manager-1")
+	store := &TLSStore{CertFile: certFile, KeyFile: keyFile}
+	c.Assert(store.Reload(), IsNil)
+	c.Assert(servedCN(c, store), Equals, "manager-1")
+
+	writeCert(c, dir, "manager-2")
+	c.Assert(store.Reload(), IsNil)
+	c.Assert(servedCN(c, store), Equals, "manager-2")
+
+	// a broken file leaves the last good certificate in place
+	c.Assert(ioutil.WriteFile(keyFile, []byte("garbage"), 0600), IsNil)
+	c.Assert(store.Reload(), Not(IsNil))
+	c.Assert(servedCN(c, store), Equals, "manager-2")
+}
@@ -76,7 +76,7 @@ func (za *ZkApp) Delete() error {
 			return err
 		}
 	}
-	return Zk.RecursiveDelete(za.path())
+	return recursiveDelete(za.path())
 }
 
 func (za *ZkApp) path() string {
@@ -0,0 +1,68 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	"atlantis/manager/rpc/types"
+	"encoding/json"
+	gozk "github.com/scalingdata/gozk"
+	"log"
+)
+
+// ZkApproval is a deploy or teardown of a protected env waiting for a second person. It is keyed by the id of the
+// task that waits on it and is removed once that task stops waiting.
+type ZkApproval struct {
+	types.TaskApproval
+}
+
+func GetApproval(id string) (za *ZkApproval, err error) {
+	za = &ZkApproval{}
+	err = getJson(helper.GetBaseApprovalPath(id), za)
+	return
+}
+
+// WatchApproval returns approval id and a channel that fires the next time the approval changes
+func WatchApproval(id string) (*ZkApproval, <-chan gozk.Event, error) {
+	data, _, watch, err := Zk.Conn.GetW(helper.GetBaseApprovalPath(id))
+	if err != nil {
+		return nil, nil, err
+	}
+	za := &ZkApproval{}
+	if err := json.Unmarshal([]byte(data), za); err != nil {
+		return nil, nil, err
+	}
+	return za, watch, nil
+}
+
+func (za *ZkApproval) Save() error {
+	return setJson(za.path(), za)
+}
+
+func (za *ZkApproval) Delete() error {
+	return recursiveDelete(za.path())
+}
+
+func (za *ZkApproval) path() string {
+	return helper.GetBaseApprovalPath(za.ID)
+}
+
+func ListApprovals() (ids []string, err error) {
+	ids, _, err = Zk.Children(helper.GetBaseApprovalPath())
+	if err != nil {
+		log.Printf("Error getting list of approvals. Error: %s.", err.Error())
+	}
+	if ids == nil {
+		ids = []string{}
+	}
+	return
+}
@@ -0,0 +1,53 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	"atlantis/manager/rpc/types"
+	. "github.com/adjust/gocheck"
+	"time"
+)
+
+func (s *DatamodelSuite) TestApprovals(c *C) {
+	Zk.RecursiveDelete(helper.GetBaseApprovalPath())
+	CreateApprovalPath()
+	requested := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	approval := &ZkApproval{types.TaskApproval{ID: "task1", Name: "Deploy", User: "alice", App: app, Env: env,
+		State: "pending", Requested: requested, Expires: requested.Add(time.Hour)}}
+	c.Assert(approval.Save(), IsNil)
+	c.Assert((&ZkApproval{types.TaskApproval{ID: "task2", Name: "Teardown", State: "pending"}}).Save(), IsNil)
+
+	ids, err := ListApprovals()
+	c.Assert(err, IsNil)
+	c.Assert(sorted(ids...), DeepEquals, []string{"task1", "task2"})
+	za, err := GetApproval("task1")
+	c.Assert(err, IsNil)
+	c.Assert(za, DeepEquals, approval)
+
+	za, watch, err := WatchApproval("task1")
+	c.Assert(err, IsNil)
+	za.State = "approved"
+	za.Approver = "bob"
+	c.Assert(za.Save(), IsNil)
+	<-watch
+	za, err = GetApproval("task1")
+	c.Assert(err, IsNil)
+	c.Assert(za.Approver, Equals, "bob")
+
+	c.Assert(za.Delete(), IsNil)
+	_, err = GetApproval("task1")
+	c.Assert(err, Not(IsNil))
+	ids, err = ListApprovals()
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []string{"task2"})
+}
@@ -0,0 +1,170 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	"atlantis/manager/rpc/types"
+	"encoding/json"
+	"errors"
+	gozk "github.com/scalingdata/gozk"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The audit log is kept by the day the entries were recorded in, as sequential nodes at
+// /audit/<yyyy-mm-dd>/entry-<n>, so that reading a few days never means listing the whole log. The id of an entry is
+// <yyyy-mm-dd>-entry-<n>, which sorts in the order the entries were added.
+const (
+	auditEntryPrefix = "entry-"
+	auditDay         = "2006-01-02"
+)
+
+func auditEntryID(day, node string) string {
+	return day + "-" + node
+}
+
+func auditEntryPath(id string) (string, error) {
+	if strings.HasPrefix(id, auditEntryPrefix) {
+		return helper.GetBaseAuditPath(id), nil // from before the log was kept by day
+	}
+	if len(id) <= len(auditDay)+1 || id[len(auditDay)] != '-' {
+		return "", errors.New("Malformed audit entry id " + id)
+	}
+	return helper.GetBaseAuditPath(id[:len(auditDay)], id[len(auditDay)+1:]), nil
+}
+
+// AppendAuditEntry adds entry to the audit log and sets its ID. Entries are sequential nodes that can be read but not
+// changed, so their ids sort in the order they were added.
+func AppendAuditEntry(entry *types.AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	day := entry.Time.UTC().Format(auditDay)
+	if _, err := Zk.Touch(helper.GetBaseAuditPath(day)); err != nil {
+		return err
+	}
+	created, err := Zk.Conn.Create(helper.GetBaseAuditPath(day, auditEntryPrefix), string(data), gozk.SEQUENCE,
+		gozk.WorldACL(gozk.PERM_READ))
+	if err != nil {
+		return err
+	}
+	entry.ID = auditEntryID(day, path.Base(created))
+	return nil
+}
+
+// GetAuditEntry reads an entry straight from zookeeper; there are too many of them to cache.
+func GetAuditEntry(id string) (*types.AuditEntry, error) {
+	entryPath, err := auditEntryPath(id)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := Zk.Conn.Get(entryPath)
+	if err != nil {
+		return nil, err
+	}
+	entry := &types.AuditEntry{}
+	if err := json.Unmarshal([]byte(data), entry); err != nil {
+		return nil, err
+	}
+	entry.ID = id
+	return entry, nil
+}
+
+// ListAuditDays returns the days from after to before that have entries, oldest first. A zero time leaves that end
+// open.
+func ListAuditDays(after, before time.Time) ([]string, error) {
+	nodes, _, err := Zk.Children(helper.GetBaseAuditPath())
+	if err != nil {
+		log.Printf("Error getting list of audit days. Error: %s.", err.Error())
+		return []string{}, err
+	}
+	days := []string{}
+	for _, day := range nodes {
+		if strings.HasPrefix(day, auditEntryPrefix) ||
+			(!after.IsZero() && day < after.UTC().Format(auditDay)) ||
+			(!before.IsZero() && day > before.UTC().Format(auditDay)) {
+			continue
+		}
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+// ListAuditEntries returns the ids of the entries recorded on day, oldest first
+func ListAuditEntries(day string) (ids []string, err error) {
+	nodes, _, err := Zk.Children(helper.GetBaseAuditPath(day))
+	if err != nil {
+		log.Printf("Error getting list of audit entries of %s. Error: %s.", day, err.Error())
+	}
+	ids = []string{}
+	for _, node := range nodes {
+		ids = append(ids, auditEntryID(day, node))
+	}
+	sort.Strings(ids)
+	return
+}
+
+// MoveAuditEntries moves the entries from before the log was kept by day into their days. Returns the number of
+// entries moved.
+func MoveAuditEntries() (int, error) {
+	nodes, _, err := Zk.Children(helper.GetBaseAuditPath())
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(nodes)
+	count := 0
+	for _, node := range nodes {
+		if !strings.HasPrefix(node, auditEntryPrefix) {
+			continue
+		}
+		entry, err := GetAuditEntry(node)
+		if gozk.IsError(err, gozk.ZNONODE) {
+			continue // another manager moved it
+		} else if err != nil {
+			return count, err
+		}
+		if err := AppendAuditEntry(entry); err != nil {
+			return count, err
+		}
+		if err := Zk.Delete(helper.GetBaseAuditPath(node), -1); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// PruneAuditDays deletes the days that ended more than maxAge ago along with their entries. Returns the number of
+// days deleted.
+func PruneAuditDays(maxAge time.Duration) (int, error) {
+	days, err := ListAuditDays(time.Time{}, time.Now().Add(-maxAge).AddDate(0, 0, -1))
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, day := range days {
+		if err := Zk.RecursiveDelete(helper.GetBaseAuditPath(day)); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
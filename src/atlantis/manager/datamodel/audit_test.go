@@ -0,0 +1,86 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	"atlantis/manager/rpc/types"
+	"encoding/json"
+	. "github.com/adjust/gocheck"
+	gozk "github.com/scalingdata/gozk"
+	"time"
+)
+
+func (s *DatamodelSuite) TestAuditEntries(c *C) {
+	Zk.RecursiveDelete(helper.GetBaseAuditPath())
+	CreateAuditPath()
+	now := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	first := &types.AuditEntry{Time: now, User: "alice", Source: "10.0.0.1:5000", Action: "Teardown", App: app,
+		Env: env, Request: `{"App":"app"}`, Outcome: "ok"}
+	c.Assert(AppendAuditEntry(first), IsNil)
+	second := &types.AuditEntry{Time: now.Add(time.Minute), User: "bob", Action: "Deploy", Outcome: "denied",
+		Error: "Permission Denied"}
+	c.Assert(AppendAuditEntry(second), IsNil)
+	c.Assert(first.ID, Not(Equals), "")
+	c.Assert(first.ID < second.ID, Equals, true)
+
+	ids, err := ListAuditEntries("2014-06-01")
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []string{first.ID, second.ID})
+	entry, err := GetAuditEntry(first.ID)
+	c.Assert(err, IsNil)
+	c.Assert(entry, DeepEquals, first)
+}
+
+func (s *DatamodelSuite) TestAuditDays(c *C) {
+	Zk.RecursiveDelete(helper.GetBaseAuditPath())
+	CreateAuditPath()
+	now := time.Now().UTC()
+	old := &types.AuditEntry{Time: now.AddDate(0, 0, -10), User: "alice", Action: "Teardown"}
+	c.Assert(AppendAuditEntry(old), IsNil)
+	recent := &types.AuditEntry{Time: now, User: "bob", Action: "Deploy"}
+	c.Assert(AppendAuditEntry(recent), IsNil)
+	c.Assert(old.ID < recent.ID, Equals, true)
+
+	days, err := ListAuditDays(time.Time{}, time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(days, DeepEquals, []string{old.Time.Format(auditDay), now.Format(auditDay)})
+	days, err = ListAuditDays(now.AddDate(0, 0, -1), time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(days, DeepEquals, []string{now.Format(auditDay)})
+	days, err = ListAuditDays(time.Time{}, now.AddDate(0, 0, -1))
+	c.Assert(err, IsNil)
+	c.Assert(days, DeepEquals, []string{old.Time.Format(auditDay)})
+
+	// entries from before the log was kept by day are moved into their days
+	legacy := &types.AuditEntry{Time: now.AddDate(0, 0, -10), User: "carol", Action: "Deploy"}
+	data, _ := json.Marshal(legacy)
+	_, err = Zk.Conn.Create(helper.GetBaseAuditPath(auditEntryPrefix), string(data), gozk.SEQUENCE,
+		gozk.WorldACL(gozk.PERM_ALL))
+	c.Assert(err, IsNil)
+	count, err := MoveAuditEntries()
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 1)
+	ids, err := ListAuditEntries(old.Time.Format(auditDay))
+	c.Assert(err, IsNil)
+	c.Assert(ids, HasLen, 2)
+	entry, err := GetAuditEntry(ids[1])
+	c.Assert(err, IsNil)
+	c.Assert(entry.User, Equals, "carol")
+
+	count, err = PruneAuditDays(7 * 24 * time.Hour)
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 1)
+	days, err = ListAuditDays(time.Time{}, time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(days, DeepEquals, []string{now.Format(auditDay)})
+}
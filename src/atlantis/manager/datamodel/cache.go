@@ -0,0 +1,221 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	"atlantis/manager/rpc/types"
+	"log"
+	"strings"
+	"sync"
+)
+
+const (
+	CacheInstances   = "instances"
+	CacheApps        = "apps"
+	CacheEnvs        = "envs"
+	CacheSupervisors = "supervisors"
+	CacheTeams       = "teams"
+	CachePolicies    = "policies"
+)
+
+// The node cache keeps the raw data of hot nodes (instances, apps, envs, supervisors, team apps and policies) in
+// memory. An
+// entry is filled by a read that also sets a zookeeper watch on the node. When the watch fires the entry is dropped
+// and the next read goes back to zookeeper. Writes made through this package drop the entry right away so a manager
+// always reads its own writes.
+type nodeCache struct {
+	sync.RWMutex
+	enabled bool
+	data    map[string]string
+	filling map[string]uint64 // path -> token of the read currently filling it
+	token   uint64
+	stats   map[string]*types.CacheStats
+}
+
+var cache = newNodeCache()
+
+func newNodeCache() *nodeCache {
+	c := &nodeCache{enabled: true}
+	c.reset()
+	return c
+}
+
+func (c *nodeCache) reset() {
+	c.data = map[string]string{}
+	c.filling = map[string]uint64{}
+	c.stats = map[string]*types.CacheStats{}
+	for _, kind := range []string{CacheInstances, CacheApps, CacheEnvs, CacheSupervisors, CacheTeams,
+		CachePolicies} {
+		c.stats[kind] = &types.CacheStats{}
+	}
+}
+
+// returns the kind of cached node at path or "" if the node is not cached
+func cacheKind(path string) string {
+	switch {
+	case strings.HasPrefix(path, helper.GetBaseInstanceDataPath()+"/"):
+		return CacheInstances
+	case strings.HasPrefix(path, helper.GetBaseAppPath()+"/"):
+		return CacheApps
+	case strings.HasPrefix(path, helper.GetBaseEnvPath()+"/"):
+		return CacheEnvs
+	case strings.HasPrefix(path, helper.GetBaseSupervisorPath()+"/"):
+		return CacheSupervisors
+	case strings.HasPrefix(path, helper.GetBaseTeamappsPath()+"/"):
+		return CacheTeams
+	case strings.HasPrefix(path, helper.GetBasePolicyPath()+"/"):
+		return CachePolicies
+	}
+	return ""
+}
+
+func (c *nodeCache) get(path string) (string, error) {
+	kind := cacheKind(path)
+	c.Lock()
+	if !c.enabled || kind == "" || Zk.Conn == nil {
+		c.Unlock()
+		data, _, err := Zk.Get(path)
+		return data, err
+	}
+	if data, ok := c.data[path]; ok {
+		c.stats[kind].Hits++
+		c.Unlock()
+		return data, nil
+	}
+	c.stats[kind].Misses++
+	c.token++
+	token := c.token
+	c.filling[path] = token
+	c.Unlock()
+
+	data, _, watch, err := Zk.Conn.GetW(path)
+	if err != nil {
+		c.Lock()
+		if c.filling[path] == token {
+			delete(c.filling, path)
+		}
+		c.Unlock()
+		return data, err
+	}
+	c.Lock()
+	// only store if nothing invalidated the node while we were reading it
+	if c.filling[path] == token {
+		delete(c.filling, path)
+		c.data[path] = data
+	}
+	c.Unlock()
+	go func() {
+		// any event (change, delete, session loss, closed channel) means our copy can no longer be trusted
+		<-watch
+		c.invalidate(path)
+	}()
+	return data, nil
+}
+
+// drops path and everything below it
+func (c *nodeCache) invalidate(path string) {
+	c.Lock()
+	defer c.Unlock()
+	kind := cacheKind(path)
+	if kind == "" {
+		return
+	}
+	prefix := path + "/"
+	for p, _ := range c.data {
+		if p == path || strings.HasPrefix(p, prefix) {
+			delete(c.data, p)
+			c.stats[kind].Invalidations++
+		}
+	}
+	for p, _ := range c.filling {
+		if p == path || strings.HasPrefix(p, prefix) {
+			delete(c.filling, p)
+		}
+	}
+}
+
+func (c *nodeCache) Stats() map[string]*types.CacheStats {
+	c.RLock()
+	defer c.RUnlock()
+	entries := map[string]int{}
+	for p, _ := range c.data {
+		entries[cacheKind(p)]++
+	}
+	stats := map[string]*types.CacheStats{}
+	for kind, s := range c.stats {
+		stat := *s
+		stat.Entries = entries[kind]
+		if total := stat.Hits + stat.Misses; total > 0 {
+			stat.HitRate = float64(stat.Hits) / float64(total)
+		}
+		stats[kind] = &stat
+	}
+	return stats
+}
+
+// Returns hit/miss counters for each kind of cached node
+func GetCacheStats() map[string]*types.CacheStats {
+	return cache.Stats()
+}
+
+// Turns the cache on or off. Turning it off drops everything it holds.
+func SetCacheEnabled(enabled bool) {
+	cache.Lock()
+	defer cache.Unlock()
+	cache.enabled = enabled
+	if !enabled {
+		cache.reset()
+	}
+}
+
+// Drops every cached node and resets the counters
+func ResetCache() {
+	cache.Lock()
+	defer cache.Unlock()
+	cache.reset()
+}
+
+// Loads every instance, app, env, supervisor, team and policy into the cache so that the first listing after startup does not
+// have to go to zookeeper once per node.
+func WarmCache() {
+	bases := []string{
+		helper.GetBaseInstanceDataPath(),
+		helper.GetBaseAppPath(),
+		helper.GetBaseEnvPath(),
+		helper.GetBaseSupervisorPath(),
+		helper.GetBaseTeamappsPath(),
+		helper.GetBasePolicyPath(),
+	}
+	count := 0
+	for _, base := range bases {
+		children, _, err := Zk.Children(base)
+		if err != nil {
+			log.Printf("Error warming cache for %s. Error: %s.", base, err.Error())
+			continue
+		}
+		for _, child := range children {
+			if _, err := cache.get(helper.JoinWithBase(base, child)); err == nil {
+				count++
+			}
+		}
+	}
+	log.Printf("Warmed cache with %d nodes", count)
+}
+
+// Deletes path recursively and drops it from the cache
+func recursiveDelete(path string) error {
+	cache.invalidate(path)
+	err := Zk.RecursiveDelete(path)
+	cache.invalidate(path)
+	return err
+}
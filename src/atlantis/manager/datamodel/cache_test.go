@@ -0,0 +1,88 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	"atlantis/manager/rpc/types"
+	. "github.com/adjust/gocheck"
+	"time"
+)
+
+func (s *DatamodelSuite) TestCacheInstance(c *C) {
+	Zk.RecursiveDelete(helper.GetBaseInstancePath())
+	inst, err := CreateInstance(app, sha, env, host)
+	c.Assert(err, IsNil)
+	ResetCache()
+	_, err = GetInstance(inst.ID)
+	c.Assert(err, IsNil)
+	_, err = GetInstance(inst.ID)
+	c.Assert(err, IsNil)
+	stats := GetCacheStats()[CacheInstances]
+	c.Assert(stats.Misses, Equals, uint64(1))
+	c.Assert(stats.Hits, Equals, uint64(1))
+	c.Assert(stats.Entries, Equals, 1)
+	c.Assert(stats.HitRate, Equals, 0.5)
+
+	// our own writes are visible right away
+	c.Assert(inst.SetPort(uint16(1337)), IsNil)
+	getInst, err := GetInstance(inst.ID)
+	c.Assert(err, IsNil)
+	c.Assert(getInst.Port, Equals, uint16(1337))
+
+	// writes from elsewhere are picked up by the watch
+	_, err = Zk.TouchAndSet(helper.GetBaseInstanceDataPath(inst.ID), `{"ID":"`+inst.ID+`","Port":1338}`)
+	c.Assert(err, IsNil)
+	for i := 0; i < 50 && GetCacheStats()[CacheInstances].Entries > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	getInst, err = GetInstance(inst.ID)
+	c.Assert(err, IsNil)
+	c.Assert(getInst.Port, Equals, uint16(1338))
+
+	_, err = inst.Delete()
+	c.Assert(err, IsNil)
+	_, err = GetInstance(inst.ID)
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *DatamodelSuite) TestCacheDisabled(c *C) {
+	Zk.RecursiveDelete(helper.GetBaseEnvPath())
+	c.Assert(Env(env).Save(), IsNil)
+	SetCacheEnabled(false)
+	defer SetCacheEnabled(true)
+	_, err := GetEnv(env)
+	c.Assert(err, IsNil)
+	_, err = GetEnv(env)
+	c.Assert(err, IsNil)
+	stats := GetCacheStats()[CacheEnvs]
+	c.Assert(stats.Hits, Equals, uint64(0))
+	c.Assert(stats.Misses, Equals, uint64(0))
+}
+
+func (s *DatamodelSuite) TestCachePolicy(c *C) {
+	Zk.RecursiveDelete(helper.GetBasePolicyPath())
+	CreatePolicyPath()
+	policy := &ZkPolicy{types.PolicyRule{ID: "prod", Team: "sre", App: "*", Env: "prod", Actions: []string{"read"}}}
+	c.Assert(policy.Save(), IsNil)
+	ResetCache()
+	_, err := GetPolicy("prod")
+	c.Assert(err, IsNil)
+	_, err = GetPolicy("prod")
+	c.Assert(err, IsNil)
+	stats := GetCacheStats()[CachePolicies]
+	c.Assert(stats.Misses, Equals, uint64(1))
+	c.Assert(stats.Hits, Equals, uint64(1))
+	c.Assert(policy.Delete(), IsNil)
+	_, err = GetPolicy("prod")
+	c.Assert(err, Not(IsNil))
+}
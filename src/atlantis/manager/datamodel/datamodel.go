@@ -42,10 +42,40 @@ func CreateRouterPaths() {
 
 func CreateLockPaths() {
 	Zk.Touch(helper.GetBaseLockPath("deploy"))
+	Zk.Touch(helper.GetBaseLockPath("leases"))
 	Zk.Touch(helper.GetBaseLockPath("router_ports_internal"))
 	Zk.Touch(helper.GetBaseLockPath("router_ports_external"))
 }
 
+func CreateTaskPath() {
+	Zk.Touch(helper.GetBaseTaskPath())
+	Zk.Touch(helper.GetBaseTaskIndexPath())
+}
+
+func CreateJobPath() {
+	Zk.Touch(helper.GetBaseJobPath())
+}
+
+func CreateAuditPath() {
+	Zk.Touch(helper.GetBaseAuditPath())
+}
+
+func CreateApprovalPath() {
+	Zk.Touch(helper.GetBaseApprovalPath())
+}
+
+func CreatePolicyPath() {
+	Zk.Touch(helper.GetBasePolicyPath())
+}
+
+func CreateSessionPath() {
+	Zk.Touch(helper.GetBaseSessionPath())
+}
+
+func CreateTokenPath() {
+	Zk.Touch(helper.GetBaseTokenPath())
+}
+
 func CreateAppPath() {
 	Zk.Touch(helper.GetBaseAppPath())
 }
@@ -55,6 +85,12 @@ func CreateInstancePaths() {
 	Zk.Touch(helper.GetBaseInstanceDataPath())
 }
 
+func CreateIndexPaths() {
+	for _, kind := range IndexKinds {
+		Zk.Touch(helper.GetBaseIndexPath(kind))
+	}
+}
+
 func CreateSupervisorPath() {
 	Zk.Touch(helper.GetBaseSupervisorPath())
 }
@@ -71,7 +107,15 @@ func CreatePaths() {
 	CreateRouterPortsPaths()
 	CreateRouterPaths()
 	CreateLockPaths()
+	CreateTaskPath()
+	CreateJobPath()
+	CreateTokenPath()
+	CreateSessionPath()
+	CreatePolicyPath()
+	CreateApprovalPath()
+	CreateAuditPath()
 	CreateInstancePaths()
+	CreateIndexPaths()
 	CreateAppPath()
 	CreateSupervisorPath()
 	CreateManagerPath()
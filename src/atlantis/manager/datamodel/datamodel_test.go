@@ -47,6 +47,11 @@ func (s *DatamodelSuite) SetUpSuite(c *C) {
 	Zk = zkTestServer.Zk
 }
 
+func (s *DatamodelSuite) SetUpTest(c *C) {
+	// tests wipe zookeeper directly, don't let one test see what another cached
+	ResetCache()
+}
+
 func (s *DatamodelSuite) TearDownSuite(c *C) {
 	err := zkTestServer.Destroy()
 	c.Assert(err, IsNil)
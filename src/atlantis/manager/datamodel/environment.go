@@ -16,17 +16,18 @@ import (
 )
 
 type ZkEnv struct {
-	Name string
+	Name      string
+	Protected bool `json:",omitempty"` // deploys and teardowns need a second person to approve them
 }
 
 func GetEnv(name string) (*ZkEnv, error) {
-	e := &ZkEnv{name}
+	e := &ZkEnv{Name: name}
 	err := e.Get()
 	return e, err
 }
 
 func Env(name string) *ZkEnv {
-	return &ZkEnv{name}
+	return &ZkEnv{Name: name}
 }
 
 func (e *ZkEnv) Save() error {
@@ -40,7 +41,7 @@ func (e *ZkEnv) Delete() error {
 	if err := ReclaimRouterPortsForEnv(false, e.Name); err != nil {
 		return err
 	}
-	return Zk.RecursiveDelete(e.path())
+	return recursiveDelete(e.path())
 }
 
 func (e *ZkEnv) Get() error {
@@ -0,0 +1,226 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	gozk "github.com/scalingdata/gozk"
+	"log"
+	"sort"
+)
+
+// Instances are indexed by supervisor, env and owning team so that questions like "what runs on host X" don't
+// have to walk all of /instances. Each index entry is an empty node at /index/<kind>/<key>/<container id>.
+const (
+	IndexSupervisor = "supervisor"
+	IndexEnv        = "env"
+	IndexTeam       = "team"
+)
+
+var IndexKinds = []string{IndexSupervisor, IndexEnv, IndexTeam}
+
+func (zi *ZkInstance) indexKeys() map[string][]string {
+	keys := map[string][]string{}
+	if zi.Host != "" {
+		keys[IndexSupervisor] = []string{zi.Host}
+	}
+	if zi.Env != "" {
+		keys[IndexEnv] = []string{zi.Env}
+	}
+	if zi.App != "" {
+		teams, err := ListTeamsForApp(zi.App)
+		if err != nil {
+			log.Printf("Warning: could not get teams for app %s: %s", zi.App, err)
+		}
+		keys[IndexTeam] = teams
+	}
+	return keys
+}
+
+func (zi *ZkInstance) addToIndexes() error {
+	for kind, keys := range zi.indexKeys() {
+		for _, key := range keys {
+			if _, err := Zk.Touch(helper.GetBaseIndexPath(kind, key, zi.ID)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (zi *ZkInstance) removeFromIndexes() {
+	for kind, keys := range zi.indexKeys() {
+		for _, key := range keys {
+			removeFromIndex(kind, key, zi.ID)
+		}
+	}
+}
+
+// removeFromAllIndexes removes id from every key it is indexed under. It is for instances whose data can't be read
+// to tell what their keys are, so it walks the whole index.
+func removeFromAllIndexes(id string) error {
+	for _, kind := range IndexKinds {
+		keys, err := ListIndexKeys(kind)
+		if err != nil && !gozk.IsError(err, gozk.ZNONODE) {
+			return err
+		}
+		for _, key := range keys {
+			if stat, err := Zk.Exists(helper.GetBaseIndexPath(kind, key, id)); err == nil && stat != nil {
+				removeFromIndex(kind, key, id)
+			}
+		}
+	}
+	return nil
+}
+
+func removeFromIndex(kind, key, id string) {
+	Zk.RecursiveDelete(helper.GetBaseIndexPath(kind, key, id))
+	// clean up the key if it was the last one
+	if ids, _, err := Zk.Children(helper.GetBaseIndexPath(kind, key)); err == nil && len(ids) == 0 {
+		Zk.Delete(helper.GetBaseIndexPath(kind, key), -1)
+	}
+}
+
+// Adds all instances of app to team's index
+func IndexTeamApp(team, app string) error {
+	ids, err := listAppInstances(app)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := Zk.Touch(helper.GetBaseIndexPath(IndexTeam, team, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Removes all instances of app from team's index
+func UnindexTeamApp(team, app string) error {
+	ids, err := listAppInstances(app)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		removeFromIndex(IndexTeam, team, id)
+	}
+	return nil
+}
+
+func listAppInstances(app string) ([]string, error) {
+	ids := []string{}
+	shas, _, err := Zk.VisibleChildren(helper.GetBaseInstancePath(app))
+	if gozk.IsError(err, gozk.ZNONODE) {
+		// no instances of this app
+		return ids, nil
+	} else if err != nil {
+		return ids, err
+	}
+	for _, sha := range shas {
+		envs, _, err := Zk.VisibleChildren(helper.GetBaseInstancePath(app, sha))
+		if err != nil {
+			return ids, err
+		}
+		for _, env := range envs {
+			instances, _, err := Zk.VisibleChildren(helper.GetBaseInstancePath(app, sha, env))
+			if err != nil {
+				return ids, err
+			}
+			ids = append(ids, instances...)
+		}
+	}
+	return ids, nil
+}
+
+func ListIndexKeys(kind string) (keys []string, err error) {
+	keys, _, err = Zk.Children(helper.GetBaseIndexPath(kind))
+	if err != nil {
+		log.Printf("Error getting list of %s index keys. Error: %s.", kind, err.Error())
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	return
+}
+
+func ListIndexedInstances(kind, key string) (instances []string, err error) {
+	instances, _, err = Zk.Children(helper.GetBaseIndexPath(kind, key))
+	if gozk.IsError(err, gozk.ZNONODE) {
+		// a missing key just means nothing is indexed under it
+		return []string{}, nil
+	} else if err != nil {
+		log.Printf("Error getting list of instances indexed under %s %s. Error: %s.", kind, key, err.Error())
+		return []string{}, err
+	}
+	if instances == nil {
+		instances = []string{}
+	}
+	return
+}
+
+// QueryInstances returns the ids of instances matching every non-empty filter. With no filters it returns every
+// instance.
+func QueryInstances(supervisor, env, team string) ([]string, error) {
+	filters := map[string]string{IndexSupervisor: supervisor, IndexEnv: env, IndexTeam: team}
+	var result map[string]bool
+	for _, kind := range IndexKinds {
+		if filters[kind] == "" {
+			continue
+		}
+		ids, err := ListIndexedInstances(kind, filters[kind])
+		if err != nil {
+			return []string{}, err
+		}
+		matched := map[string]bool{}
+		for _, id := range ids {
+			if result == nil || result[id] {
+				matched[id] = true
+			}
+		}
+		result = matched
+	}
+	if result == nil {
+		return ListAllInstances()
+	}
+	instances := make([]string, 0, len(result))
+	for id, _ := range result {
+		instances = append(instances, id)
+	}
+	sort.Strings(instances)
+	return instances, nil
+}
+
+// RebuildIndexes throws away all indexes and recreates them from instance data. Returns the number of instances
+// indexed.
+func RebuildIndexes() (int, error) {
+	ids, err := ListAllInstances()
+	if err != nil {
+		return 0, err
+	}
+	if err := Zk.RecursiveDelete(helper.GetBaseIndexPath()); err != nil {
+		return 0, err
+	}
+	CreateIndexPaths()
+	count := 0
+	for _, id := range ids {
+		zi, err := GetInstance(id)
+		if err != nil {
+			log.Printf("Warning: skipping %s while rebuilding indexes: %s", id, err)
+			continue
+		}
+		if err := zi.addToIndexes(); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,112 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	. "github.com/adjust/gocheck"
+	"sort"
+)
+
+func (s *DatamodelSuite) TestIndexes(c *C) {
+	Zk.RecursiveDelete(helper.GetBaseInstancePath())
+	Zk.RecursiveDelete(helper.GetBaseInstanceDataPath())
+	Zk.RecursiveDelete(helper.GetBaseIndexPath())
+	Zk.RecursiveDelete(helper.GetBaseTeamappsPath())
+	CreateInstancePaths()
+	CreateIndexPaths()
+	c.Assert(Teamapps("team1", []string{"app1"}).Save(), IsNil)
+
+	inst0, err := CreateInstance("app1", sha, "prod", "host0")
+	c.Assert(err, IsNil)
+	inst1, err := CreateInstance("app1", sha, "staging", "host1")
+	c.Assert(err, IsNil)
+	inst2, err := CreateInstance("app2", sha, "prod", "host1")
+	c.Assert(err, IsNil)
+
+	ids, err := QueryInstances("host1", "", "")
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, sorted(inst1.ID, inst2.ID))
+	ids, err = QueryInstances("", "prod", "")
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, sorted(inst0.ID, inst2.ID))
+	ids, err = QueryInstances("", "prod", "team1")
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []string{inst0.ID})
+	ids, err = QueryInstances("host0", "staging", "")
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []string{})
+	ids, err = QueryInstances("", "", "")
+	c.Assert(err, IsNil)
+	sort.Strings(ids)
+	c.Assert(ids, DeepEquals, sorted(inst0.ID, inst1.ID, inst2.ID))
+
+	// team ownership changes are reflected
+	ta := GetTeamapps("team2")
+	c.Assert(ta.AddApp("app2"), IsNil)
+	ids, err = QueryInstances("", "", "team2")
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []string{inst2.ID})
+	c.Assert(ta.DeleteApp("app2"), IsNil)
+	ids, err = QueryInstances("", "", "team2")
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []string{})
+
+	// deleted instances are removed
+	_, err = inst1.Delete()
+	c.Assert(err, IsNil)
+	ids, err = QueryInstances("host1", "", "")
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []string{inst2.ID})
+	keys, err := ListIndexKeys(IndexEnv)
+	c.Assert(err, IsNil)
+	c.Assert(keys, DeepEquals, []string{"prod"})
+
+	// rebuilding gets back to the same state
+	Zk.RecursiveDelete(helper.GetBaseIndexPath())
+	count, err := RebuildIndexes()
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 2)
+	ids, err = QueryInstances("", "prod", "team1")
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []string{inst0.ID})
+	ids, err = QueryInstances("host1", "", "")
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []string{inst2.ID})
+}
+
+func (s *DatamodelSuite) TestIndexesUnreadableInstance(c *C) {
+	Zk.RecursiveDelete(helper.GetBaseInstancePath())
+	Zk.RecursiveDelete(helper.GetBaseInstanceDataPath())
+	Zk.RecursiveDelete(helper.GetBaseIndexPath())
+	CreateInstancePaths()
+	CreateIndexPaths()
+	inst, err := CreateInstance("app1", sha, "prod", "host0")
+	c.Assert(err, IsNil)
+	_, err = Zk.TouchAndSet(helper.GetBaseInstanceDataPath(inst.ID), "not json")
+	c.Assert(err, IsNil)
+	ResetCache()
+	// without its data there is no telling the host, so every index is searched for the instance
+	_, err = (&ZkInstance{ID: inst.ID, App: "app1", Sha: sha, Env: "prod"}).Delete()
+	c.Assert(err, IsNil)
+	ids, err := QueryInstances("host0", "", "")
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []string{})
+	keys, err := ListIndexKeys(IndexSupervisor)
+	c.Assert(err, IsNil)
+	c.Assert(keys, DeepEquals, []string{})
+}
+
+func sorted(strs ...string) []string {
+	sort.Strings(strs)
+	return strs
+}
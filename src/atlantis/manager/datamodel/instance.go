@@ -16,16 +16,20 @@ import (
 	"atlantis/supervisor/rpc/types"
 	"errors"
 	"log"
+	"time"
 )
 
 type ZkInstance struct {
-	ID       string
-	App      string
-	Sha      string
-	Env      string
-	Host     string
-	Port     uint16
-	Manifest *types.Manifest
+	ID          string
+	App         string
+	Sha         string
+	Env         string
+	Host        string
+	Zone        string `json:",omitempty"`
+	Port        uint16
+	Manifest    *types.Manifest
+	Maintenance bool      // as last set through the manager
+	Created     time.Time // zero for instances created before it was recorded
 }
 
 func InstanceExists(id string) bool {
@@ -53,7 +57,7 @@ func CreateInstance(app, sha, env, host string) (*ZkInstance, error) {
 	for InstanceExists(id) {
 		id = helper.CreateContainerID(app, sha, env)
 	}
-	zi := &ZkInstance{ID: id, App: app, Sha: sha, Env: env, Host: host, Port: 0}
+	zi := &ZkInstance{ID: id, App: app, Sha: sha, Env: env, Host: host, Port: 0, Created: time.Now()}
 	if _, err := Zk.Touch(zi.path()); err != nil {
 		Zk.RecursiveDelete(zi.path())
 		return zi, err
@@ -61,9 +65,12 @@ func CreateInstance(app, sha, env, host string) (*ZkInstance, error) {
 	if err := setJson(zi.dataPath(), zi); err != nil {
 		// clean up
 		Zk.RecursiveDelete(zi.path())
-		Zk.RecursiveDelete(zi.dataPath())
+		recursiveDelete(zi.dataPath())
 		return zi, err
 	}
+	if err := zi.addToIndexes(); err != nil {
+		log.Printf("Warning: could not index instance %s: %s", id, err)
+	}
 	return zi, nil
 }
 
@@ -74,9 +81,17 @@ func (zi *ZkInstance) Delete() (bool, error) { // true if this was the last inst
 		err2 error
 		list []string
 	)
-	// try to get the data (its ok if we can't)
+	// try to get the data (its ok if we can't, but then it takes a walk of the indexes to tell where it was)
 	dataErr := getJson(zi.dataPath(), zi)
-	err = Zk.RecursiveDelete(zi.dataPath())
+	if dataErr != nil {
+		log.Printf("Warning: could not read instance %s, removing it from every index: %s", zi.ID, dataErr)
+		if err := removeFromAllIndexes(zi.ID); err != nil {
+			log.Printf("Warning: could not remove instance %s from the indexes: %s", zi.ID, err)
+		}
+	} else {
+		zi.removeFromIndexes()
+	}
+	err = recursiveDelete(zi.dataPath())
 	err2 = Zk.RecursiveDelete(zi.path())
 	if err != nil {
 		return last, err
@@ -84,6 +99,10 @@ func (zi *ZkInstance) Delete() (bool, error) { // true if this was the last inst
 	if err2 != nil {
 		return last, err2
 	}
+	if zi.App == "" || zi.Sha == "" || zi.Env == "" {
+		// without the full app+sha+env the parent paths are unknown, leave them alone
+		return last, nil
+	}
 	// check if we can delete parent directories
 	if list, err = ListInstances(zi.App, zi.Sha, zi.Env); err != nil {
 		log.Printf("Warning: clean up fail during instance delete: %s", err)
@@ -94,7 +113,7 @@ func (zi *ZkInstance) Delete() (bool, error) { // true if this was the last inst
 	last = true
 	Zk.RecursiveDelete(helper.GetBaseInstancePath(zi.App, zi.Sha, zi.Env))
 	if dataErr != nil {
-		log.Printf("Warning: could not fetch data to clean up pool: %s", err)
+		log.Printf("Warning: could not fetch data to clean up pool: %s", dataErr)
 	}
 	if list, err = ListAppEnvs(zi.App, zi.Sha); err != nil {
 		log.Printf("Warning: clean up fail during instance delete: %s", err)
@@ -125,6 +144,16 @@ func (zi *ZkInstance) SetManifest(m *types.Manifest) error {
 	return setJson(zi.dataPath(), zi)
 }
 
+func (zi *ZkInstance) SetZone(zone string) error {
+	zi.Zone = zone
+	return setJson(zi.dataPath(), zi)
+}
+
+func (zi *ZkInstance) SetMaintenance(maint bool) error {
+	zi.Maintenance = maint
+	return setJson(zi.dataPath(), zi)
+}
+
 func (zi *ZkInstance) path() string {
 	return helper.GetBaseInstancePath(zi.App, zi.Sha, zi.Env, zi.ID)
 }
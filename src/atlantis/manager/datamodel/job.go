@@ -0,0 +1,67 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	"atlantis/manager/rpc/types"
+	gozk "github.com/scalingdata/gozk"
+	"log"
+)
+
+// ZkJob is a scheduled job along with the (encrypted) API token it runs with. The token never leaves the manager;
+// replies only carry the ScheduledJob.
+type ZkJob struct {
+	types.ScheduledJob
+	Credentials string
+}
+
+func GetJob(id string) (zj *ZkJob, err error) {
+	zj = &ZkJob{}
+	err = getJson(helper.GetBaseJobPath(id), zj)
+	return
+}
+
+func (zj *ZkJob) Save() error {
+	return setJson(zj.path(), zj)
+}
+
+func (zj *ZkJob) Delete() error {
+	return recursiveDelete(zj.path())
+}
+
+func (zj *ZkJob) path() string {
+	return helper.GetBaseJobPath(zj.ID)
+}
+
+func ListJobs() (ids []string, err error) {
+	ids, _, err = Zk.Children(helper.GetBaseJobPath())
+	if err != nil {
+		log.Printf("Error getting list of jobs. Error: %s.", err.Error())
+	}
+	if ids == nil {
+		ids = []string{}
+	}
+	return
+}
+
+// TakeSchedulerLead makes host the manager that runs scheduled jobs in this region unless another manager already
+// is. The lead is an ephemeral node, so it passes on when the leader goes away. Returns true if host leads.
+func TakeSchedulerLead(host string) bool {
+	path := helper.GetBaseLockPath("scheduler")
+	if _, err := Zk.Conn.Create(path, host, gozk.EPHEMERAL, gozk.WorldACL(gozk.PERM_ALL)); err == nil {
+		log.Printf("%s is now the scheduler leader", host)
+		return true
+	}
+	leader, _, err := Zk.Conn.Get(path)
+	return err == nil && leader == host
+}
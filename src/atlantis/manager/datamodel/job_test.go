@@ -0,0 +1,52 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	"atlantis/manager/rpc/types"
+	. "github.com/adjust/gocheck"
+)
+
+func (s *DatamodelSuite) TestJobs(c *C) {
+	Zk.RecursiveDelete(helper.GetBaseJobPath())
+	CreateJobPath()
+	job := &ZkJob{ScheduledJob: types.ScheduledJob{ID: "nightly", User: "me", Name: "Teardown", Cron: "0 2 * * *",
+		Teardown: &types.ManagerTeardownArg{App: "app", Env: "dev"}}, Credentials: "sealed"}
+	c.Assert(job.Save(), IsNil)
+	c.Assert((&ZkJob{ScheduledJob: types.ScheduledJob{ID: "once", Name: "Deploy"}}).Save(), IsNil)
+
+	ids, err := ListJobs()
+	c.Assert(err, IsNil)
+	c.Assert(sorted(ids...), DeepEquals, []string{"nightly", "once"})
+	zj, err := GetJob("nightly")
+	c.Assert(err, IsNil)
+	c.Assert(zj, DeepEquals, job)
+
+	c.Assert(zj.Delete(), IsNil)
+	_, err = GetJob("nightly")
+	c.Assert(err, Not(IsNil))
+	ids, err = ListJobs()
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []string{"once"})
+}
+
+func (s *DatamodelSuite) TestSchedulerLead(c *C) {
+	Zk.Delete(helper.GetBaseLockPath("scheduler"), -1)
+	c.Assert(TakeSchedulerLead(host), Equals, true)
+	c.Assert(TakeSchedulerLead(host), Equals, true)
+	c.Assert(TakeSchedulerLead("other-host"), Equals, false)
+	Zk.Delete(helper.GetBaseLockPath("scheduler"), -1)
+	c.Assert(TakeSchedulerLead("other-host"), Equals, true)
+	c.Assert(TakeSchedulerLead(host), Equals, false)
+	Zk.Delete(helper.GetBaseLockPath("scheduler"), -1)
+}
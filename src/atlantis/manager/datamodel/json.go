@@ -17,7 +17,7 @@ import (
 )
 
 func getJson(nodePath string, data interface{}) error {
-	raw_data, _, err := Zk.Get(nodePath)
+	raw_data, err := cache.get(nodePath)
 	if err != nil {
 		log.Printf("Error getting data from node %s. Error: %s.", nodePath, err.Error())
 		return err
@@ -40,5 +40,6 @@ func setJson(nodePath string, data interface{}) error {
 		return err
 	}
 	_, err = Zk.TouchAndSet(nodePath, string(bytes))
+	cache.invalidate(nodePath)
 	return err
 }
@@ -12,47 +12,102 @@
 package datamodel
 
 import (
+	. "atlantis/manager/constant"
 	"atlantis/manager/helper"
+	"atlantis/manager/rpc/types"
+	"encoding/json"
+	"errors"
 	"fmt"
 	zookeeper "github.com/ghao-ooyala/gozk-recipes"
+	gozk "github.com/scalingdata/gozk"
+	"log"
+	"net/url"
+	"sort"
 	"strings"
+	"time"
 )
 
 const allPath = "/"
 
+var lockRetryInterval = 1 * time.Second
+
 type LockConflictError string
 
 func (e LockConflictError) Error() string {
 	return "Lock Conflict with: " + string(e)
 }
 
-func getLockedPaths(path string) (map[string]string, error) {
-	var lockedPaths map[string]string
-	if err := getJson(path, &lockedPaths); err != nil {
+func deployLockPath() string {
+	return helper.GetBaseLockPath("deploy")
+}
+
+// Every deploy/teardown lock entry is backed by an ephemeral lease node owned by the session of the manager that
+// took the lock. If that manager dies its session goes away, the lease disappears and the entry is considered
+// expired.
+func leasePath(path string) string {
+	return helper.GetBaseLockPath("leases", url.QueryEscape(path))
+}
+
+func leaseAlive(path string) bool {
+	stat, err := Zk.Exists(leasePath(path))
+	return err == nil && stat != nil
+}
+
+func getLockedPaths(path string) (map[string]*types.LockInfo, error) {
+	var rawPaths map[string]json.RawMessage
+	if err := getJson(path, &rawPaths); err != nil {
 		return nil, err
 	}
-	if lockedPaths == nil {
-		lockedPaths = map[string]string{}
+	lockedPaths := map[string]*types.LockInfo{}
+	for p, raw := range rawPaths {
+		info := &types.LockInfo{}
+		if err := json.Unmarshal(raw, info); err != nil {
+			// entries written before leases were introduced only contain the task id
+			var id string
+			if err := json.Unmarshal(raw, &id); err != nil {
+				return nil, err
+			}
+			info.TaskID = id
+		}
+		info.Path = p
+		lockedPaths[p] = info
 	}
 	return lockedPaths, nil
 }
 
-type DeployLock struct {
-	id     string
-	path   string
-	locked bool
+// entries without an owner predate leases and can only be removed by ForceUnlock
+func expired(info *types.LockInfo) bool {
+	return info.Owner != "" && !leaseAlive(info.Path)
+}
+
+// drops expired entries, returns true if anything was dropped
+func pruneExpired(lockedPaths map[string]*types.LockInfo) bool {
+	pruned := false
+	for p, info := range lockedPaths {
+		if expired(info) {
+			log.Printf("Dropping expired lock on %s held by %s on %s", p, info.TaskID, info.Owner)
+			delete(lockedPaths, p)
+			pruned = true
+		}
+	}
+	return pruned
 }
 
-func NewDeployLock(id, app, sha, env string) *DeployLock {
-	return &DeployLock{id: id, path: fmt.Sprintf("/%s/%s/%s", app, sha, env)}
+// pathLock implements the deploy and teardown locks. Both live in the same lock file; they only differ in which
+// already locked paths they conflict with.
+type pathLock struct {
+	id        string
+	user      string
+	path      string
+	locked    bool
+	conflicts func(lockedPath string) bool
 }
 
-func (l *DeployLock) Lock() error {
+func (l *pathLock) Lock() error {
 	if l.locked {
 		return nil
 	}
-	// check if we can lock by checking if any thing in the lock file is a prefix to us
-	path := helper.GetBaseLockPath("deploy")
+	path := deployLockPath()
 	mutex := zookeeper.NewMutex(Zk.Conn, path)
 	if err := mutex.Lock(); err != nil {
 		return err
@@ -62,29 +117,61 @@ func (l *DeployLock) Lock() error {
 	if err != nil {
 		return err
 	}
-	if allID, ok := lockedPaths[allPath]; ok && allID != "" {
-		return LockConflictError(allID)
+	pruned := pruneExpired(lockedPaths)
+	conflict := ""
+	if all, ok := lockedPaths[allPath]; ok && all.TaskID != "" {
+		conflict = all.TaskID
+	}
+	for p, info := range lockedPaths {
+		if conflict == "" && l.conflicts(p) {
+			conflict = info.TaskID
+		}
 	}
-	for p, conflictID := range lockedPaths {
-		if strings.HasPrefix(l.path, p) {
-			return LockConflictError(conflictID)
+	if conflict != "" {
+		if pruned {
+			setJson(path, lockedPaths)
 		}
+		return LockConflictError(conflict)
+	}
+	// if no conflicts, take the lease and register our lock
+	lease := leasePath(l.path)
+	Zk.Delete(lease, -1) // left over from an interrupted unlock
+	if _, err := Zk.Conn.Create(lease, l.id, gozk.EPHEMERAL, gozk.WorldACL(gozk.PERM_ALL)); err != nil {
+		return err
+	}
+	lockedPaths[l.path] = &types.LockInfo{
+		Path:     l.path,
+		TaskID:   l.id,
+		Owner:    Host,
+		User:     l.user,
+		Acquired: time.Now(),
 	}
-	// if no conflicts, register our lock
-	lockedPaths[l.path] = l.id
 	if err := setJson(path, lockedPaths); err != nil {
+		Zk.Delete(lease, -1)
 		return err
 	}
 	l.locked = true
 	return nil
 }
 
-func (l *DeployLock) Unlock() error {
+// LockWait tries to take the lock until timeout passes. Only lock conflicts are retried.
+func (l *pathLock) LockWait(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := l.Lock()
+		if _, ok := err.(LockConflictError); !ok || !time.Now().Add(lockRetryInterval).Before(deadline) {
+			return err
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+func (l *pathLock) Unlock() error {
 	if !l.locked {
 		return nil
 	}
 	// remove ourselves from the lock file
-	path := helper.GetBaseLockPath("deploy")
+	path := deployLockPath()
 	mutex := zookeeper.NewMutex(Zk.Conn, path)
 	if err := mutex.Lock(); err != nil {
 		return err
@@ -94,7 +181,11 @@ func (l *DeployLock) Unlock() error {
 	if err != nil {
 		return err
 	}
-	delete(lockedPaths, l.path)
+	// only remove the entry if it is still ours; it may have been forcibly released and taken by someone else
+	if info, ok := lockedPaths[l.path]; ok && info.TaskID == l.id {
+		delete(lockedPaths, l.path)
+		Zk.Delete(leasePath(l.path), -1)
+	}
 	if err := setJson(path, lockedPaths); err != nil {
 		return err
 	}
@@ -102,73 +193,75 @@ func (l *DeployLock) Unlock() error {
 	return nil
 }
 
+type DeployLock struct {
+	pathLock
+}
+
+func NewDeployLock(id, user, app, sha, env string) *DeployLock {
+	l := &DeployLock{pathLock{id: id, user: user, path: fmt.Sprintf("/%s/%s/%s", app, sha, env)}}
+	// we conflict if anything in the lock file is a prefix to us
+	l.conflicts = func(p string) bool { return strings.HasPrefix(l.path, p) }
+	return l
+}
+
 type TeardownLock struct {
-	id     string
-	path   string
-	locked bool
+	pathLock
 }
 
-func NewTeardownLock(id string, args ...string) *TeardownLock {
+func NewTeardownLock(id, user string, args ...string) *TeardownLock {
 	path := allPath
 	if len(args) > 0 {
 		path = helper.JoinWithBase("/", args...)
 	}
-	return &TeardownLock{id: id, path: path}
+	l := &TeardownLock{pathLock{id: id, user: user, path: path}}
+	// we conflict if we are a prefix to anything in the lock file
+	l.conflicts = func(p string) bool { return strings.HasPrefix(p, l.path) }
+	return l
 }
 
-func (l *TeardownLock) Lock() error {
-	if l.locked {
-		return nil
-	}
-	// check if we can lock by checking if we are a prefix to anything in the lock file
-	path := helper.GetBaseLockPath("deploy")
-	mutex := zookeeper.NewMutex(Zk.Conn, path)
-	if err := mutex.Lock(); err != nil {
-		return err
-	}
-	defer mutex.Unlock()
-	lockedPaths, err := getLockedPaths(path)
+// ListLocks returns every deploy and teardown lock currently held, including expired ones that have not been
+// cleaned up yet.
+func ListLocks() ([]*types.LockInfo, error) {
+	lockedPaths, err := getLockedPaths(deployLockPath())
 	if err != nil {
-		return err
-	}
-	if allID, ok := lockedPaths[allPath]; ok && allID != "" {
-		return LockConflictError(allID)
+		return nil, err
 	}
-	for p, conflictID := range lockedPaths {
-		if strings.HasPrefix(p, l.path) {
-			return LockConflictError(conflictID)
-		}
+	paths := make([]string, 0, len(lockedPaths))
+	for p, _ := range lockedPaths {
+		paths = append(paths, p)
 	}
-	// if no conflicts, register our lock
-	lockedPaths[l.path] = l.id
-	if err := setJson(path, lockedPaths); err != nil {
-		return err
+	sort.Strings(paths)
+	locks := make([]*types.LockInfo, len(paths))
+	for i, p := range paths {
+		locks[i] = lockedPaths[p]
+		locks[i].Expired = expired(locks[i])
 	}
-	l.locked = true
-	return nil
+	return locks, nil
 }
 
-func (l *TeardownLock) Unlock() error {
-	if !l.locked {
-		return nil
-	}
-	// remove ourselves from the lock file
-	path := helper.GetBaseLockPath("deploy")
-	mutex := zookeeper.NewMutex(Zk.Conn, path)
+// ForceUnlock releases the lock on path no matter who holds it and returns what was released. The task holding
+// it is not stopped.
+func ForceUnlock(path string) (*types.LockInfo, error) {
+	lockPath := deployLockPath()
+	mutex := zookeeper.NewMutex(Zk.Conn, lockPath)
 	if err := mutex.Lock(); err != nil {
-		return err
+		return nil, err
 	}
 	defer mutex.Unlock()
-	lockedPaths, err := getLockedPaths(path)
+	lockedPaths, err := getLockedPaths(lockPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	delete(lockedPaths, l.path)
-	if err := setJson(path, lockedPaths); err != nil {
-		return err
+	info, ok := lockedPaths[path]
+	if !ok {
+		return nil, errors.New("No lock held on " + path)
 	}
-	l.locked = false
-	return nil
+	delete(lockedPaths, path)
+	if err := setJson(lockPath, lockedPaths); err != nil {
+		return nil, err
+	}
+	Zk.Delete(leasePath(path), -1)
+	return info, nil
 }
 
 func NewRouterPortsLock(internal bool) *RouterPortsLock {
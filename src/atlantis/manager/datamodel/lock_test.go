@@ -15,22 +15,24 @@ import (
 	"atlantis/manager/helper"
 	"fmt"
 	. "github.com/adjust/gocheck"
+	"time"
 )
 
 func (s *DatamodelSuite) TestDeployAndTeardownLocking(c *C) {
 	Zk.RecursiveDelete(helper.GetBaseLockPath("deploy"))
+	Zk.RecursiveDelete(helper.GetBaseLockPath("leases"))
 	CreateLockPaths()
 
 	// Fire off a bunch of deploys
-	dl0 := NewDeployLock("dl0", "app0", "sha0", "env0")
+	dl0 := NewDeployLock("dl0", "user", "app0", "sha0", "env0")
 	c.Assert(dl0.Lock(), IsNil)
-	dl1 := NewDeployLock("dl1", "app1", "sha1", "env1")
+	dl1 := NewDeployLock("dl1", "user", "app1", "sha1", "env1")
 	c.Assert(dl1.Lock(), IsNil)
-	dl2 := NewDeployLock("dl2", "app1", "sha2", "env1")
+	dl2 := NewDeployLock("dl2", "user", "app1", "sha2", "env1")
 	c.Assert(dl2.Lock(), IsNil)
-	dl3 := NewDeployLock("dl3", "app1", "sha1", "env2")
+	dl3 := NewDeployLock("dl3", "user", "app1", "sha1", "env2")
 	c.Assert(dl3.Lock(), IsNil)
-	dl4 := NewDeployLock("dl4", "app1", "sha1", "env2")
+	dl4 := NewDeployLock("dl4", "user", "app1", "sha1", "env2")
 	err := dl4.Lock()
 	c.Assert(err, Not(IsNil))
 	c.Assert(err, FitsTypeOf, LockConflictError("dl3"))
@@ -40,35 +42,93 @@ func (s *DatamodelSuite) TestDeployAndTeardownLocking(c *C) {
 	c.Assert(dl4.Lock(), IsNil)
 
 	// Try some Teardowns
-	tl0 := NewTeardownLock("tl0", "app2", "sha2", "env2")
+	tl0 := NewTeardownLock("tl0", "user", "app2", "sha2", "env2")
 	c.Assert(tl0.Lock(), IsNil)
-	tl1 := NewTeardownLock("tl1", "app0", "sha0", "env0")
+	tl1 := NewTeardownLock("tl1", "user", "app0", "sha0", "env0")
 	err = tl1.Lock()
 	c.Assert(err, Not(IsNil))
 	c.Assert(err, FitsTypeOf, LockConflictError("dl0"))
 	c.Assert(err, Equals, LockConflictError("dl0"))
-	tl2 := NewTeardownLock("tl2", "app0", "sha1")
+	tl2 := NewTeardownLock("tl2", "user", "app0", "sha1")
 	c.Assert(tl2.Lock(), IsNil)
-	tl3 := NewTeardownLock("tl3", "app3")
+	tl3 := NewTeardownLock("tl3", "user", "app3")
 	c.Assert(tl3.Lock(), IsNil)
-	tl4 := NewTeardownLock("tl4", "app0")
+	tl4 := NewTeardownLock("tl4", "user", "app0")
 	err = tl4.Lock()
 	c.Assert(err, Not(IsNil))
 	c.Assert(err, FitsTypeOf, LockConflictError("dl0"))
 	c.Assert(err, Equals, LockConflictError("dl0"))
-	tl5 := NewTeardownLock("tl5")
+	tl5 := NewTeardownLock("tl5", "user")
 	err = tl5.Lock()
 	c.Assert(err, Not(IsNil))
 	c.Assert(err, FitsTypeOf, LockConflictError("dl0"))
 
 	// Try a deploy while tearing down
-	dl5 := NewDeployLock("dl5", "app3", "sha3", "env3")
+	dl5 := NewDeployLock("dl5", "user", "app3", "sha3", "env3")
 	err = dl5.Lock()
 	c.Assert(err, Not(IsNil))
 	c.Assert(err, FitsTypeOf, LockConflictError("tl3"))
 	c.Assert(err, Equals, LockConflictError("tl3"))
 }
 
+func (s *DatamodelSuite) TestLockLeases(c *C) {
+	Zk.RecursiveDelete(helper.GetBaseLockPath("deploy"))
+	Zk.RecursiveDelete(helper.GetBaseLockPath("leases"))
+	CreateLockPaths()
+
+	dl0 := NewDeployLock("dl0", "user0", "app0", "sha0", "env0")
+	c.Assert(dl0.Lock(), IsNil)
+	locks, err := ListLocks()
+	c.Assert(err, IsNil)
+	c.Assert(len(locks), Equals, 1)
+	c.Assert(locks[0].Path, Equals, "/app0/sha0/env0")
+	c.Assert(locks[0].TaskID, Equals, "dl0")
+	c.Assert(locks[0].User, Equals, "user0")
+	c.Assert(locks[0].Expired, Equals, false)
+
+	// the owning manager going away drops its lease
+	c.Assert(Zk.Delete(leasePath("/app0/sha0/env0"), -1), IsNil)
+	locks, err = ListLocks()
+	c.Assert(err, IsNil)
+	c.Assert(locks[0].Expired, Equals, true)
+	dl1 := NewDeployLock("dl1", "user1", "app0", "sha0", "env0")
+	c.Assert(dl1.Lock(), IsNil)
+	// the old owner can't release someone else's lock
+	c.Assert(dl0.Unlock(), IsNil)
+	locks, err = ListLocks()
+	c.Assert(err, IsNil)
+	c.Assert(len(locks), Equals, 1)
+	c.Assert(locks[0].TaskID, Equals, "dl1")
+
+	// force unlock releases regardless of owner
+	_, err = ForceUnlock("/app1")
+	c.Assert(err, Not(IsNil))
+	info, err := ForceUnlock("/app0/sha0/env0")
+	c.Assert(err, IsNil)
+	c.Assert(info.TaskID, Equals, "dl1")
+	locks, err = ListLocks()
+	c.Assert(err, IsNil)
+	c.Assert(len(locks), Equals, 0)
+}
+
+func (s *DatamodelSuite) TestLockWait(c *C) {
+	Zk.RecursiveDelete(helper.GetBaseLockPath("deploy"))
+	Zk.RecursiveDelete(helper.GetBaseLockPath("leases"))
+	CreateLockPaths()
+	lockRetryInterval = 10 * time.Millisecond
+
+	tl0 := NewTeardownLock("tl0", "user", "app0")
+	c.Assert(tl0.Lock(), IsNil)
+	dl0 := NewDeployLock("dl0", "user", "app0", "sha0", "env0")
+	c.Assert(dl0.LockWait(50*time.Millisecond), Equals, LockConflictError("tl0"))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		tl0.Unlock()
+	}()
+	c.Assert(dl0.LockWait(5*time.Second), IsNil)
+	c.Assert(dl0.Unlock(), IsNil)
+}
+
 func (s *DatamodelSuite) TestLockPrint(c *C) {
 	e := LockConflictError("hello")
 	fmt.Sprintf("%s", e)
@@ -0,0 +1,51 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	"atlantis/manager/rpc/types"
+	"log"
+)
+
+type ZkPolicy struct {
+	types.PolicyRule
+}
+
+func GetPolicy(id string) (zp *ZkPolicy, err error) {
+	zp = &ZkPolicy{}
+	err = getJson(helper.GetBasePolicyPath(id), zp)
+	return
+}
+
+func (zp *ZkPolicy) Save() error {
+	return setJson(zp.path(), zp)
+}
+
+func (zp *ZkPolicy) Delete() error {
+	return recursiveDelete(zp.path())
+}
+
+func (zp *ZkPolicy) path() string {
+	return helper.GetBasePolicyPath(zp.ID)
+}
+
+func ListPolicies() (ids []string, err error) {
+	ids, _, err = Zk.Children(helper.GetBasePolicyPath())
+	if err != nil {
+		log.Printf("Error getting list of policies. Error: %s.", err.Error())
+	}
+	if ids == nil {
+		ids = []string{}
+	}
+	return
+}
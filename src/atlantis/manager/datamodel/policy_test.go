@@ -0,0 +1,41 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	"atlantis/manager/rpc/types"
+	. "github.com/adjust/gocheck"
+)
+
+func (s *DatamodelSuite) TestPolicies(c *C) {
+	Zk.RecursiveDelete(helper.GetBasePolicyPath())
+	CreatePolicyPath()
+	policy := &ZkPolicy{types.PolicyRule{ID: "prod", Team: "sre", App: "*", Env: "prod*",
+		Actions: []string{"deploy", "teardown"}, CreatedBy: "admin"}}
+	c.Assert(policy.Save(), IsNil)
+	c.Assert((&ZkPolicy{types.PolicyRule{ID: "ssh", User: "me", App: app, Env: env}}).Save(), IsNil)
+
+	ids, err := ListPolicies()
+	c.Assert(err, IsNil)
+	c.Assert(sorted(ids...), DeepEquals, []string{"prod", "ssh"})
+	zp, err := GetPolicy("prod")
+	c.Assert(err, IsNil)
+	c.Assert(zp, DeepEquals, policy)
+
+	c.Assert(zp.Delete(), IsNil)
+	_, err = GetPolicy("prod")
+	c.Assert(err, Not(IsNil))
+	ids, err = ListPolicies()
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []string{"ssh"})
+}
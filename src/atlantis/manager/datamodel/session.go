@@ -0,0 +1,56 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	"log"
+	"time"
+)
+
+// ZkSession is a login session shared by the managers of a region. It is stored under a key made from the user
+// and a hash of the secret, never under the secret itself.
+type ZkSession struct {
+	Key     string `json:"-"`
+	User    string
+	Team    []string
+	Expires time.Time
+}
+
+func GetSession(key string) (zs *ZkSession, err error) {
+	zs = &ZkSession{Key: key}
+	err = getJson(helper.GetBaseSessionPath(key), zs)
+	return
+}
+
+func (zs *ZkSession) Save() error {
+	return setJson(zs.path(), zs)
+}
+
+func (zs *ZkSession) Delete() error {
+	return recursiveDelete(zs.path())
+}
+
+func (zs *ZkSession) path() string {
+	return helper.GetBaseSessionPath(zs.Key)
+}
+
+func ListSessions() (keys []string, err error) {
+	keys, _, err = Zk.Children(helper.GetBaseSessionPath())
+	if err != nil {
+		log.Printf("Error getting list of sessions. Error: %s.", err.Error())
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	return
+}
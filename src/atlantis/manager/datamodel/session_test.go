@@ -0,0 +1,41 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	. "github.com/adjust/gocheck"
+	"time"
+)
+
+func (s *DatamodelSuite) TestSessions(c *C) {
+	Zk.RecursiveDelete(helper.GetBaseSessionPath())
+	CreateSessionPath()
+	expires := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	session := &ZkSession{Key: "abc", User: "me", Team: []string{"team"}, Expires: expires}
+	c.Assert(session.Save(), IsNil)
+	c.Assert((&ZkSession{Key: "def", User: "you"}).Save(), IsNil)
+
+	keys, err := ListSessions()
+	c.Assert(err, IsNil)
+	c.Assert(sorted(keys...), DeepEquals, []string{"abc", "def"})
+	zs, err := GetSession("abc")
+	c.Assert(err, IsNil)
+	c.Assert(zs, DeepEquals, session)
+
+	c.Assert(zs.Delete(), IsNil)
+	_, err = GetSession("abc")
+	c.Assert(err, Not(IsNil))
+	keys, err = ListSessions()
+	c.Assert(err, IsNil)
+	c.Assert(keys, DeepEquals, []string{"def"})
+}
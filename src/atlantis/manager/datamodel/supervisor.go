@@ -13,7 +13,6 @@ package datamodel
 
 import (
 	. "atlantis/common"
-	. "atlantis/manager/constant"
 	"atlantis/manager/helper"
 	"atlantis/manager/supervisor"
 	"errors"
@@ -70,7 +69,7 @@ func (h ZkSupervisor) Touch() error {
 
 // Delete the host node and all child container nodes of that host
 func (h ZkSupervisor) Delete() error {
-	return Zk.RecursiveDelete(h.path())
+	return recursiveDelete(h.path())
 }
 
 // Supervisor will tell us the port -> container mapping for a given host, and we will store this back in zk in
@@ -136,6 +135,7 @@ func (h ZkSupervisor) Info() (*SupervisorData, error) {
 func (h ZkSupervisor) deleteContainer(container string) (err error) {
 	nodePath := h.containerPath(container)
 	err = Zk.Delete(nodePath, -1)
+	cache.invalidate(nodePath)
 	if err != nil {
 		log.Printf("Error deleting node from zookeeper. Error: %s", err.Error())
 	}
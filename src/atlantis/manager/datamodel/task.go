@@ -0,0 +1,231 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	. "atlantis/common"
+	"atlantis/manager/helper"
+	"atlantis/manager/rpc/types"
+	"encoding/json"
+	"errors"
+	gozk "github.com/scalingdata/gozk"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ZkTask types.TaskRecord
+
+func GetTask(id string) (zt *ZkTask, err error) {
+	zt = &ZkTask{}
+	err = getJson(helper.GetBaseTaskPath(id), zt)
+	return
+}
+
+// WatchTask returns task id and a channel that fires the next time the task changes
+func WatchTask(id string) (*ZkTask, <-chan gozk.Event, error) {
+	data, _, watch, err := Zk.Conn.GetW(helper.GetBaseTaskPath(id))
+	if err != nil {
+		return nil, nil, err
+	}
+	zt := &ZkTask{}
+	if err := json.Unmarshal([]byte(data), zt); err != nil {
+		return nil, nil, err
+	}
+	return zt, watch, nil
+}
+
+func (zt *ZkTask) Save() error {
+	return setJson(zt.path(), zt)
+}
+
+func (zt *ZkTask) Delete() error {
+	if err := Zk.RecursiveDelete(zt.path()); err != nil {
+		return err
+	}
+	zt.removeFromIndex()
+	return nil
+}
+
+func (zt *ZkTask) path() string {
+	return helper.GetBaseTaskPath(zt.ID)
+}
+
+func ListTasks() (ids []string, err error) {
+	ids, _, err = Zk.Children(helper.GetBaseTaskPath())
+	if err != nil {
+		log.Printf("Error getting list of tasks. Error: %s.", err.Error())
+	}
+	if ids == nil {
+		ids = []string{}
+	}
+	return
+}
+
+// FailOrphanedTasks marks every unfinished task run by host as failed. It is meant to be called when the manager on
+// host starts, at which point nothing it was running before can still be running. Returns the ids of the tasks.
+func FailOrphanedTasks(host string) ([]string, error) {
+	ids, err := ListTasks()
+	if err != nil {
+		return []string{}, err
+	}
+	failed := []string{}
+	for _, id := range ids {
+		zt, err := GetTask(id)
+		if err != nil || zt.Done || zt.Manager != host {
+			continue
+		}
+		zt.Done = true
+		zt.Status = StatusError
+		zt.Error = "Manager " + host + " restarted while the task was running"
+		zt.EndTime = time.Now()
+		if err := zt.Save(); err != nil {
+			return failed, err
+		}
+		failed = append(failed, id)
+	}
+	return failed, nil
+}
+
+// PruneTasks deletes finished tasks that ended more than maxAge ago. Returns the number of tasks deleted.
+func PruneTasks(maxAge time.Duration) (int, error) {
+	ids, err := ListTasks()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, id := range ids {
+		zt, err := GetTask(id)
+		if err != nil || !zt.Done || time.Since(zt.EndTime) < maxAge {
+			continue
+		}
+		if err := zt.Delete(); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Tasks are indexed by the day they started in so that querying them does not mean reading every task. Each entry
+// is an empty node at /task_index/<yyyy-mm-dd>/<entry> whose name holds what tasks are looked up by: when they
+// started, their id, name, user, app and env.
+type TaskIndexEntry struct {
+	ID        string
+	Name      string
+	User      string
+	App       string
+	Env       string
+	StartTime time.Time
+}
+
+const taskIndexDay = "2006-01-02"
+
+// IndexEntry returns the entry of the task in the index
+func (zt *ZkTask) IndexEntry() *TaskIndexEntry {
+	return &TaskIndexEntry{zt.ID, zt.Name, zt.User, zt.App, zt.Env, zt.StartTime}
+}
+
+func (e *TaskIndexEntry) path() string {
+	fields := []string{strconv.FormatInt(e.StartTime.UnixNano(), 10), e.ID, e.Name, e.User, e.App, e.Env}
+	for i, field := range fields {
+		fields[i] = url.QueryEscape(field)
+	}
+	return helper.GetBaseTaskIndexPath(e.StartTime.UTC().Format(taskIndexDay), strings.Join(fields, ","))
+}
+
+func parseTaskIndexEntry(node string) (*TaskIndexEntry, error) {
+	fields := strings.Split(node, ",")
+	if len(fields) != 6 {
+		return nil, errors.New("Malformed task index entry " + node)
+	}
+	for i, field := range fields {
+		var err error
+		if fields[i], err = url.QueryUnescape(field); err != nil {
+			return nil, err
+		}
+	}
+	start, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &TaskIndexEntry{fields[1], fields[2], fields[3], fields[4], fields[5], time.Unix(0, start)}, nil
+}
+
+// AddToIndex makes the task show up in ListTaskIndex. The index only holds what never changes once a task has
+// started, so this is called once per task.
+func (zt *ZkTask) AddToIndex() error {
+	_, err := Zk.Touch(zt.IndexEntry().path())
+	return err
+}
+
+func (zt *ZkTask) removeFromIndex() {
+	path := zt.IndexEntry().path()
+	Zk.RecursiveDelete(path)
+	// clean up the day if it was the last one
+	day := path[:strings.LastIndex(path, "/")]
+	if entries, _, err := Zk.Children(day); err == nil && len(entries) == 0 {
+		Zk.Delete(day, -1)
+	}
+}
+
+// ListTaskIndex returns the index entries of the tasks that started on the days from after to before. A zero time
+// leaves that end open.
+func ListTaskIndex(after, before time.Time) ([]*TaskIndexEntry, error) {
+	days, _, err := Zk.Children(helper.GetBaseTaskIndexPath())
+	if err != nil {
+		log.Printf("Error getting list of task index days. Error: %s.", err.Error())
+		return []*TaskIndexEntry{}, err
+	}
+	entries := []*TaskIndexEntry{}
+	for _, day := range days {
+		if (!after.IsZero() && day < after.UTC().Format(taskIndexDay)) ||
+			(!before.IsZero() && day > before.UTC().Format(taskIndexDay)) {
+			continue
+		}
+		nodes, _, err := Zk.Children(helper.GetBaseTaskIndexPath(day))
+		if err != nil {
+			continue // pruned since we listed the days
+		}
+		for _, node := range nodes {
+			if entry, err := parseTaskIndexEntry(node); err == nil {
+				entries = append(entries, entry)
+			} else {
+				log.Printf("Warning: skipping task index entry %s: %s", node, err)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// IndexTasks adds every task to the index, which covers tasks from before there was one. Returns the number of tasks
+// indexed.
+func IndexTasks() (int, error) {
+	ids, err := ListTasks()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, id := range ids {
+		zt, err := GetTask(id)
+		if err != nil {
+			continue
+		}
+		if err := zt.AddToIndex(); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
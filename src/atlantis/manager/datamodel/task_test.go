@@ -0,0 +1,99 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	. "atlantis/common"
+	"atlantis/manager/helper"
+	. "github.com/adjust/gocheck"
+	"time"
+)
+
+func (s *DatamodelSuite) TestTasks(c *C) {
+	Zk.RecursiveDelete(helper.GetBaseTaskPath())
+	CreateTaskPath()
+	running := &ZkTask{ID: "running", Name: "Deploy", Manager: host, Status: "Deploying"}
+	c.Assert(running.Save(), IsNil)
+	other := &ZkTask{ID: "other", Name: "Deploy", Manager: "other-host", Status: "Deploying"}
+	c.Assert(other.Save(), IsNil)
+	old := &ZkTask{ID: "old", Name: "Teardown", Manager: host, Status: StatusDone, Done: true,
+		EndTime: time.Now().Add(-2 * time.Hour)}
+	c.Assert(old.Save(), IsNil)
+
+	ids, err := ListTasks()
+	c.Assert(err, IsNil)
+	c.Assert(sorted(ids...), DeepEquals, []string{"old", "other", "running"})
+
+	failed, err := FailOrphanedTasks(host)
+	c.Assert(err, IsNil)
+	c.Assert(failed, DeepEquals, []string{"running"})
+	zt, err := GetTask("running")
+	c.Assert(err, IsNil)
+	c.Assert(zt.Done, Equals, true)
+	c.Assert(zt.Status, Equals, StatusError)
+	c.Assert(zt.Error, Not(Equals), "")
+	zt, err = GetTask("other")
+	c.Assert(err, IsNil)
+	c.Assert(zt.Done, Equals, false)
+
+	count, err := PruneTasks(time.Hour)
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 1)
+	ids, err = ListTasks()
+	c.Assert(err, IsNil)
+	c.Assert(sorted(ids...), DeepEquals, []string{"other", "running"})
+}
+
+func (s *DatamodelSuite) TestTaskIndex(c *C) {
+	Zk.RecursiveDelete(helper.GetBaseTaskPath())
+	Zk.RecursiveDelete(helper.GetBaseTaskIndexPath())
+	CreateTaskPath()
+	day := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	first := &ZkTask{ID: "first", Name: "Deploy", User: "a,b/c", App: "app", Env: "prod", StartTime: day}
+	second := &ZkTask{ID: "second", Name: "Teardown", User: "bob", StartTime: day.Add(24 * time.Hour)}
+	for _, zt := range []*ZkTask{first, second} {
+		c.Assert(zt.Save(), IsNil)
+		c.Assert(zt.AddToIndex(), IsNil)
+	}
+	entries, err := ListTaskIndex(time.Time{}, time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	// only the days asked for are read
+	entries, err = ListTaskIndex(day.Add(12*time.Hour), time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].ID, Equals, "second")
+	entries, err = ListTaskIndex(time.Time{}, day)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	entry := *entries[0]
+	c.Assert(entry.StartTime.Equal(day), Equals, true)
+	entry.StartTime = day
+	c.Assert(entry, DeepEquals, TaskIndexEntry{ID: "first", Name: "Deploy", User: "a,b/c", App: "app", Env: "prod",
+		StartTime: day})
+
+	// deleting a task takes it out of the index, and the day with it once it is empty
+	c.Assert(first.Delete(), IsNil)
+	days, _, err := Zk.Children(helper.GetBaseTaskIndexPath())
+	c.Assert(err, IsNil)
+	c.Assert(days, DeepEquals, []string{"2014-06-02"})
+
+	// tasks from before the index are indexed at start up
+	Zk.RecursiveDelete(helper.GetBaseTaskIndexPath())
+	count, err := IndexTasks()
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 1)
+	entries, err = ListTaskIndex(time.Time{}, time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].ID, Equals, "second")
+}
@@ -17,13 +17,16 @@ import (
 	"log"
 )
 
+// Apps are the apps the team may deploy. Members is a copy of the team's LDAP membership kept up to date by the
+// team apps sync so that authorization does not have to go to LDAP.
 type ZkTeamapps struct {
-	Team string
-	Apps []string
+	Team    string
+	Apps    []string
+	Members []string `json:",omitempty"`
 }
 
 func GetTeamapps(team string) (*ZkTeamapps) {
-	e := &ZkTeamapps{team, []string{}}
+	e := &ZkTeamapps{team, []string{}, nil}
 	err := e.Get()
 	if err != nil {
 		log.Println("Warning:Cannot find team apps for " + team)
@@ -32,17 +35,35 @@ func GetTeamapps(team string) (*ZkTeamapps) {
 }
 
 func Teamapps(name string, apps []string) *ZkTeamapps {
-	return &ZkTeamapps{name, apps}
+	return &ZkTeamapps{name, apps, nil}
 }
 
 func (e *ZkTeamapps) Save() error {
 	return setJson(e.path(), e)
 }
 
-/*
 func (e *ZkTeamapps) Delete() error {
-	return Zk.RecursiveDelete(e.path())
-}*/
+	for _, app := range e.Apps {
+		if err := UnindexTeamApp(e.Team, app); err != nil {
+			log.Printf("Warning: could not unindex instances of %s for team %s: %s", app, e.Team, err)
+		}
+	}
+	return recursiveDelete(e.path())
+}
+
+func (e *ZkTeamapps) HasApp(app string) bool {
+	return contains(e.Apps, app)
+}
+
+func (e *ZkTeamapps) HasMember(user string) bool {
+	return contains(e.Members, user)
+}
+
+// Replaces the synced copy of the team's members
+func (e *ZkTeamapps) SetMembers(members []string) error {
+	e.Members = members
+	return setJson(e.path(), e)
+}
 
 func (e *ZkTeamapps) AddApp(app string) error {
 	for _, elements := range e.Apps {
@@ -51,17 +72,26 @@ func (e *ZkTeamapps) AddApp(app string) error {
 		}
 	}
 	e.Apps = append(e.Apps, app)
-	return setJson(e.path(), e)
+	if err := setJson(e.path(), e); err != nil {
+		return err
+	}
+	if err := IndexTeamApp(e.Team, app); err != nil {
+		log.Printf("Warning: could not index instances of %s for team %s: %s", app, e.Team, err)
+	}
+	return nil
 }
 
 func (e *ZkTeamapps) DeleteApp(app string) error {
 	for index, elements := range e.Apps {
 		if elements == app {
 			e.Apps = remove(e.Apps, index)
+			if err := UnindexTeamApp(e.Team, app); err != nil {
+				log.Printf("Warning: could not unindex instances of %s for team %s: %s", app, e.Team, err)
+			}
 			
 			//remove team from zk if it own no apps
-			if len(e.Apps) == 0 {
-				return Zk.RecursiveDelete(e.path())
+			if len(e.Apps) == 0 && len(e.Members) == 0 {
+				return recursiveDelete(e.path())
 			}
 			return setJson(e.path(), e)
 		}
@@ -75,6 +105,89 @@ func remove(slice []string, index int) []string {
     return append(slice[:index], slice[index+1:]...)
 }
 
+func contains(list []string, item string) bool {
+	for _, elem := range list {
+		if elem == item {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the teams that have apps or members in zookeeper
+func ListTeamapps() (teams []string, err error) {
+	teams, _, err = Zk.Children(helper.GetBaseTeamappsPath())
+	if err != nil {
+		log.Printf("Error getting list of team apps. Error: %s.", err.Error())
+	}
+	if teams == nil {
+		teams = []string{}
+	}
+	return
+}
+
+// Returns the teams whose team apps list contains app
+func ListTeamsForApp(app string) ([]string, error) {
+	teams, _, err := Zk.Children(helper.GetBaseTeamappsPath())
+	if err != nil {
+		return []string{}, err
+	}
+	owners := []string{}
+	for _, team := range teams {
+		ta := &ZkTeamapps{team, []string{}, nil}
+		if err := ta.Get(); err != nil {
+			continue
+		}
+		if ta.HasApp(app) {
+			owners = append(owners, team)
+		}
+	}
+	return owners, nil
+}
+
+// Returns true if user is a synced member of a team that may deploy app
+func IsTeamappMember(user, app string) bool {
+	teams, err := ListTeamsForApp(app)
+	if err != nil {
+		return false
+	}
+	for _, team := range teams {
+		if GetTeamapps(team).HasMember(user) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns every app that user may deploy according to the synced team members
+func ListTeamappsForMember(user string) []string {
+	teams, err := ListTeamapps()
+	if err != nil {
+		return []string{}
+	}
+	apps := []string{}
+	for _, team := range teams {
+		if ta := GetTeamapps(team); ta.HasMember(user) {
+			apps = append(apps, ta.Apps...)
+		}
+	}
+	return apps
+}
+
+// Removes app from every team that may deploy it. Used when the app is unregistered.
+func DeleteAppFromTeams(app string) error {
+	teams, err := ListTeamsForApp(app)
+	if err != nil {
+		return err
+	}
+	for _, team := range teams {
+		if err := GetTeamapps(team).DeleteApp(app); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (e *ZkTeamapps) Get() error {
 	return getJson(e.path(), e)
 }
@@ -0,0 +1,47 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	. "github.com/adjust/gocheck"
+)
+
+func (s *DatamodelSuite) TestTeamappMembers(c *C) {
+	Zk.RecursiveDelete(helper.GetBaseTeamappsPath())
+	c.Assert(Teamapps("team1", []string{"app1", "app2"}).Save(), IsNil)
+	c.Assert(Teamapps("team2", []string{"app2"}).Save(), IsNil)
+	c.Assert(IsTeamappMember("user1", "app1"), Equals, false)
+
+	c.Assert(GetTeamapps("team1").SetMembers([]string{"user1"}), IsNil)
+	c.Assert(IsTeamappMember("user1", "app1"), Equals, true)
+	c.Assert(IsTeamappMember("user1", "app3"), Equals, false)
+	c.Assert(IsTeamappMember("user2", "app1"), Equals, false)
+	c.Assert(sorted(ListTeamappsForMember("user1")...), DeepEquals, []string{"app1", "app2"})
+
+	// teams with synced members stick around without apps
+	ta := GetTeamapps("team1")
+	c.Assert(ta.DeleteApp("app1"), IsNil)
+	c.Assert(ta.DeleteApp("app2"), IsNil)
+	teams, err := ListTeamapps()
+	c.Assert(err, IsNil)
+	c.Assert(sorted(teams...), DeepEquals, []string{"team1", "team2"})
+
+	c.Assert(DeleteAppFromTeams("app2"), IsNil)
+	teams, err = ListTeamsForApp("app2")
+	c.Assert(err, IsNil)
+	c.Assert(teams, DeepEquals, []string{})
+	teams, err = ListTeamapps()
+	c.Assert(err, IsNil)
+	c.Assert(teams, DeepEquals, []string{"team1"})
+	c.Assert(GetTeamapps("team1").Delete(), IsNil)
+}
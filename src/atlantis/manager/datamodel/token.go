@@ -0,0 +1,82 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	"atlantis/manager/rpc/types"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ZkToken is an API token along with the hash of its secret. The secret itself is only ever shown to whoever
+// created the token.
+type ZkToken struct {
+	types.APIToken
+	Hash string
+}
+
+func HashTokenSecret(secret string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(secret)))
+}
+
+func GetToken(id string) (zt *ZkToken, err error) {
+	zt = &ZkToken{}
+	err = getJson(helper.GetBaseTokenPath(id), zt)
+	return
+}
+
+func (zt *ZkToken) Save() error {
+	return setJson(zt.path(), zt)
+}
+
+func (zt *ZkToken) Delete() error {
+	return recursiveDelete(zt.path())
+}
+
+func (zt *ZkToken) path() string {
+	return helper.GetBaseTokenPath(zt.ID)
+}
+
+// Valid returns true if secret is this token's secret and the token has not been revoked or expired.
+func (zt *ZkToken) Valid(secret string, now time.Time) bool {
+	if zt.Revoked || (!zt.Expires.IsZero() && now.After(zt.Expires)) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(zt.Hash), []byte(HashTokenSecret(secret))) == 1
+}
+
+func (zt *ZkToken) AllowsApp(app string) bool {
+	return len(zt.Apps) == 0 || contains(zt.Apps, app)
+}
+
+func (zt *ZkToken) AllowsEnv(env string) bool {
+	return len(zt.Envs) == 0 || contains(zt.Envs, env)
+}
+
+func (zt *ZkToken) AllowsOperation(operation string) bool {
+	return len(zt.Operations) == 0 || contains(zt.Operations, operation)
+}
+
+func ListTokens() (ids []string, err error) {
+	ids, _, err = Zk.Children(helper.GetBaseTokenPath())
+	if err != nil {
+		log.Printf("Error getting list of tokens. Error: %s.", err.Error())
+	}
+	if ids == nil {
+		ids = []string{}
+	}
+	return
+}
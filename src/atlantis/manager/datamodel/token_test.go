@@ -0,0 +1,58 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package datamodel
+
+import (
+	"atlantis/manager/helper"
+	"atlantis/manager/rpc/types"
+	. "github.com/adjust/gocheck"
+	"time"
+)
+
+func (s *DatamodelSuite) TestTokens(c *C) {
+	Zk.RecursiveDelete(helper.GetBaseTokenPath())
+	CreateTokenPath()
+	now := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	token := &ZkToken{APIToken: types.APIToken{ID: "ci", Name: "ci", User: "ci-bot", ServiceAccount: true,
+		Owner: "admin", Apps: []string{"app"}, Envs: []string{"staging"}, Operations: []string{"Deploy"},
+		Created: now, Expires: now.Add(time.Hour)}, Hash: HashTokenSecret("secret")}
+	c.Assert(token.Save(), IsNil)
+	c.Assert((&ZkToken{APIToken: types.APIToken{ID: "mine", User: "me", Owner: "me"}}).Save(), IsNil)
+
+	ids, err := ListTokens()
+	c.Assert(err, IsNil)
+	c.Assert(sorted(ids...), DeepEquals, []string{"ci", "mine"})
+	zt, err := GetToken("ci")
+	c.Assert(err, IsNil)
+	c.Assert(zt, DeepEquals, token)
+
+	c.Assert(zt.Valid("secret", now), Equals, true)
+	c.Assert(zt.Valid("wrong", now), Equals, false)
+	c.Assert(zt.Valid("secret", now.Add(2*time.Hour)), Equals, false)
+	c.Assert(zt.AllowsApp("app"), Equals, true)
+	c.Assert(zt.AllowsApp("other"), Equals, false)
+	c.Assert(zt.AllowsEnv("prod"), Equals, false)
+	c.Assert(zt.AllowsOperation("Deploy"), Equals, true)
+	c.Assert(zt.AllowsOperation("RegisterApp"), Equals, false)
+	zt.Revoked = true
+	c.Assert(zt.Valid("secret", now), Equals, false)
+
+	unscoped, err := GetToken("mine")
+	c.Assert(err, IsNil)
+	c.Assert(unscoped.AllowsApp("other"), Equals, true)
+	c.Assert(unscoped.AllowsOperation("RegisterApp"), Equals, true)
+
+	c.Assert(unscoped.Delete(), IsNil)
+	ids, err = ListTokens()
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []string{"ci"})
+}
@@ -62,6 +62,12 @@ func GetBaseInstanceDataPath(args ...string) string {
 	return JoinWithBase(base, args...)
 }
 
+// Helper function to get path to node in /index, e.g. GetBaseIndexPath("env", "prod", id)
+func GetBaseIndexPath(args ...string) string {
+	base := fmt.Sprintf("/atlantis/index/%s", Region)
+	return JoinWithBase(base, args...)
+}
+
 func GetBaseSupervisorPath(args ...string) string {
 	base := fmt.Sprintf("/atlantis/supervisors/%s", Region)
 	return JoinWithBase(base, args...)
@@ -140,6 +146,48 @@ func GetBaseEnvPath(args ...string) string {
 	return JoinWithBase(base, args...)
 }
 
+func GetBaseTaskPath(args ...string) string {
+	base := fmt.Sprintf("/atlantis/tasks/%s", Region)
+	return JoinWithBase(base, args...)
+}
+
+// tasks are indexed by the day they started, e.g. GetBaseTaskIndexPath("2014-06-01", entry)
+func GetBaseTaskIndexPath(args ...string) string {
+	base := fmt.Sprintf("/atlantis/task_index/%s", Region)
+	return JoinWithBase(base, args...)
+}
+
+func GetBaseJobPath(args ...string) string {
+	base := fmt.Sprintf("/atlantis/jobs/%s", Region)
+	return JoinWithBase(base, args...)
+}
+
+func GetBaseAuditPath(args ...string) string {
+	base := fmt.Sprintf("/atlantis/audit/%s", Region)
+	return JoinWithBase(base, args...)
+}
+
+func GetBaseApprovalPath(args ...string) string {
+	base := fmt.Sprintf("/atlantis/approvals/%s", Region)
+	return JoinWithBase(base, args...)
+}
+
+func GetBasePolicyPath(args ...string) string {
+	base := fmt.Sprintf("/atlantis/policies/%s", Region)
+	return JoinWithBase(base, args...)
+}
+
+func GetBaseSessionPath(args ...string) string {
+	base := fmt.Sprintf("/atlantis/sessions/%s", Region)
+	return JoinWithBase(base, args...)
+}
+
+// tokens are not per region so that CI can use one token everywhere
+func GetBaseTokenPath(args ...string) string {
+	base := "/atlantis/tokens"
+	return JoinWithBase(base, args...)
+}
+
 func GetBaseLockPath(args ...string) string {
 	base := fmt.Sprintf("/atlantis/lock/%s", Region)
 	return JoinWithBase(base, args...)
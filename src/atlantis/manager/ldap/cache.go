@@ -0,0 +1,98 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package ldap
+
+import (
+	"sync"
+	"time"
+)
+
+// CacheTTL is how long what LDAP said about the teams of a user is believed. The apps of teams are not cached
+// here, they are read from zookeeper through its own cache.
+var CacheTTL = 5 * time.Minute
+
+// A TTLCache keeps lists of names for a while. Writes made through the manager invalidate what they change so a
+// manager always sees its own writes; changes made in LDAP directly show up once the entry expires.
+type TTLCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	value   []string
+	expires time.Time
+}
+
+func NewTTLCache(ttl time.Duration) *TTLCache {
+	return &TTLCache{ttl: ttl, entries: map[string]cacheEntry{}}
+}
+
+// Get returns a copy of the entry for key and whether there was one that has not expired.
+func (c *TTLCache) Get(key string) ([]string, bool) {
+	c.Lock()
+	defer c.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return append([]string{}, entry.value...), true
+}
+
+func (c *TTLCache) Set(key string, value []string) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.Lock()
+	c.entries[key] = cacheEntry{append([]string{}, value...), time.Now().Add(c.ttl)}
+	c.Unlock()
+}
+
+func (c *TTLCache) Invalidate(keys ...string) {
+	c.Lock()
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+	c.Unlock()
+}
+
+func (c *TTLCache) Clear() {
+	c.Lock()
+	c.entries = map[string]cacheEntry{}
+	c.Unlock()
+}
+
+// UserTeams caches user -> the teams LDAP says user is in.
+var UserTeams = NewTTLCache(CacheTTL)
+
+// TeamsOf returns the teams of user, asking LDAP only if it was not asked recently.
+func TeamsOf(user string) ([]string, error) {
+	if teams, ok := UserTeams.Get(user); ok {
+		return teams, nil
+	}
+	conn, err := Pool.Get()
+	if err != nil {
+		return nil, err
+	}
+	teams, err := GetTeamList(conn, user)
+	if err != nil {
+		Pool.Discard(conn)
+		return nil, err
+	}
+	Pool.Put(conn)
+	UserTeams.Set(user, teams)
+	return teams, nil
+}
@@ -0,0 +1,61 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package ldap
+
+import (
+	. "github.com/adjust/gocheck"
+	"time"
+)
+
+type CacheSuite struct{}
+
+var _ = Suite(&CacheSuite{})
+
+func (s *CacheSuite) TestTTLCache(c *C) {
+	cache := NewTTLCache(time.Hour)
+	_, ok := cache.Get("me")
+	c.Assert(ok, Equals, false)
+	cache.Set("me", []string{"ops"})
+	teams, ok := cache.Get("me")
+	c.Assert(ok, Equals, true)
+	c.Assert(teams, DeepEquals, []string{"ops"})
+	// callers get their own copy
+	teams[0] = "changed"
+	teams, _ = cache.Get("me")
+	c.Assert(teams, DeepEquals, []string{"ops"})
+
+	cache.Set("you", []string{})
+	cache.Invalidate("me", "nobody")
+	_, ok = cache.Get("me")
+	c.Assert(ok, Equals, false)
+	_, ok = cache.Get("you")
+	c.Assert(ok, Equals, true)
+	cache.Clear()
+	_, ok = cache.Get("you")
+	c.Assert(ok, Equals, false)
+}
+
+func (s *CacheSuite) TestTTLCacheExpiry(c *C) {
+	cache := NewTTLCache(time.Hour)
+	cache.Set("me", []string{"ops"})
+	entry := cache.entries["me"]
+	entry.expires = time.Now().Add(-time.Second)
+	cache.entries["me"] = entry
+	_, ok := cache.Get("me")
+	c.Assert(ok, Equals, false)
+	c.Assert(cache.entries, HasLen, 0)
+
+	disabled := NewTTLCache(0)
+	disabled.Set("me", []string{"ops"})
+	_, ok = disabled.Get("me")
+	c.Assert(ok, Equals, false)
+}
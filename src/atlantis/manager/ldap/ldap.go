@@ -12,25 +12,21 @@
 package ldap
 
 import (
-	"atlantis/crypto"
 	"crypto/tls"
 	"errors"
 	"github.com/mavricknz/ldap"
 	"log"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 )
 
 var (
-	SessionDestroyChan   chan *Request
 	BaseDomain           string
 	LdapServer           string
 	LdapPort             uint16
 	TlsConfig            *tls.Config
 	skipLogin            bool
-	SessionMap           map[string]map[string]*Session // map user -> (map secret -> *Session)
 	AppClass             string
 	UsernameAttr         string
 	TeamAdminAttr        string
@@ -50,23 +46,6 @@ var (
 	TeamBlackList        []string
 )
 
-type Request struct {
-	User     string
-	Secret   string
-	LoggedIn bool
-	RespChan chan bool
-}
-
-type Session struct {
-
-	//TODO remove LDAPConn from session
-	//since we can no longer re-use ldap conn with jump cloud
- 
-	LDAPConn *ldap.LDAPConnection
-	Team     []string
-	Timer    *time.Timer
-}
-
 func Init(lserver string, lport uint16, baseDomain string) {
 	if lserver == "" {
 		// if we're being initialized empty, then don't try to log people in
@@ -79,101 +58,48 @@ func Init(lserver string, lport uint16, baseDomain string) {
 	LdapServer = lserver
 	LdapPort = lport
 	BaseDomain = baseDomain
-	SessionMap = make(map[string]map[string]*Session)
-	SessionDestroyChan = make(chan *Request)
-	go SessionExpiryRoutine()
-}
-
-func CreateSession(req *Request, lc *ldap.LDAPConnection, team []string) {
-	if SessionMap[req.User] == nil {
-		SessionMap[req.User] = map[string]*Session{req.Secret: &Session{LDAPConn: lc, Team: team,
-			Timer: time.AfterFunc(30*time.Minute, func() {
-				SessionDestroyChan <- req
-			})},
-		}
-	} else if SessionMap[req.User][req.Secret] == nil {
-		SessionMap[req.User][req.Secret] = &Session{LDAPConn: lc, Team: team, Timer: time.AfterFunc(30*time.Minute, func() {
-			SessionDestroyChan <- req
-		})}
-	} else {
-		SessionMap[req.User][req.Secret].Timer.Reset(30*time.Minute)
-	}
 }
 
-func LookupSession(req *Request) {
-	req.LoggedIn = false
-	if SessionMap[req.User] != nil && req.Secret != "" {
-		if SessionMap[req.User][req.Secret] != nil {
-			req.LoggedIn = true
-		}
-	}
+// LoginSkipped returns true if there is no LDAP server to log in to, in which case everyone is let in.
+func LoginSkipped() bool {
+	return skipLogin
 }
 
-func LookupConnection(user, secret string) *ldap.LDAPConnection {
-	if SessionMap[user] != nil && SessionMap[user][secret] != nil {
-		return SessionMap[user][secret].LDAPConn
-	}
-	return nil
+// InitPool sizes the connection pool and sets how long the caches of what LDAP says are kept.
+func InitPool(size int, cacheTTL time.Duration) {
+	Pool.Close()
+	PoolSize = size
+	Pool = NewConnPool(size, dialSearchConn)
+	CacheTTL = cacheTTL
+	UserTeams = NewTTLCache(cacheTTL)
 }
 
-func LookupTeam(user, secret string) []string {
-	if SessionMap[user] != nil && SessionMap[user][secret] != nil {
-		return SessionMap[user][secret].Team
+// Authenticate binds to LDAP as user to check their password and returns the teams they are in.
+func Authenticate(user, pass string) ([]string, error) {
+	conn, err := Pool.Get()
+	if err != nil {
+		return nil, err
 	}
-	return nil
-}
-
-
-func SessionExpiryRoutine() {
-	for {
-		select {
-		case req := <-SessionDestroyChan:
-			SessionMap[req.User][req.Secret].LDAPConn.Close()
-			delete(SessionMap[req.User], req.Secret)
-			break
-		}
+	err = LoginBind(user, pass, conn)
+	// the connection goes back to the pool bound as the search user, or not at all
+	if bindErr := LoginBind(SearchUserDn, SearchUserPwd, conn); bindErr != nil {
+		log.Println("Warning: ldap binding with dn search account failed; ", bindErr)
+		Pool.Discard(conn)
+	} else {
+		Pool.Put(conn)
 	}
-}
-
-func Login(user, pass, secret string) (string, error) {
-	if skipLogin {
-		return "dummysecret", nil // just let everything pass
+	if err != nil {
+		return nil, err
 	}
-	// Checking if we are already logged in
-	var LDAPConn *ldap.LDAPConnection
-	var err error
-	req := &Request{user, secret, false, make(chan bool)}
-	LookupSession(req)
-	teamList := []string{}
-	if !req.LoggedIn {
-		LDAPConn, err = CreateLdapConn(LdapServer, LdapPort, TlsConfig)
-		
-		if err != nil {
-			return "", err
-		}
-		err = LoginBind(user, pass, LDAPConn)
-		if err != nil {
-			return "", err
-		}
 
-		//bind with account with dn search enabled
-		err = LoginBind(SearchUserDn, SearchUserPwd, LDAPConn)
-		if err != nil {
-			log.Println("Warning: ldap binding with dn search account failed; ", err)
-		}
-
-		now := strconv.FormatInt(time.Now().Unix(), 10)
-		sec := string(crypto.Encrypt([]byte(pass + now)))
-		re := regexp.MustCompile("[^a-zA-Z0-9]")
-		sec = re.ReplaceAllString(sec, "")
-		req.Secret = sec
-		teamList, err = GetTeamList(LDAPConn, user)
-		if err != nil {
-			log.Println("Warning: get team list failed for user; ", err)
-		}
+	// logging in again is how people pick up a change to their teams
+	UserTeams.Invalidate(user)
+	teamList, err := TeamsOf(user)
+	if err != nil {
+		log.Println("Warning: get team list failed for user; ", err)
+		return []string{}, nil
 	}
-	CreateSession(req, LDAPConn, teamList)
-	return req.Secret, nil
+	return teamList, nil
 }
 
 func CreateLdapConn(server string, port uint16, tlsConf *tls.Config) (*ldap.LDAPConnection, error) {
@@ -214,6 +140,8 @@ func GetTeamList(LDAPConn *ldap.LDAPConnection , user string) ([]string, error){
 	return ret, nil
 }
 
+// ErrInvalidCredentials is what logging in with a wrong password or an ended session returns.
+var ErrInvalidCredentials = errors.New("Session Expired/Invalid Credentials")
 
 func LoginBind(user, pass string, lc *ldap.LDAPConnection) error {
 	var dnInfo string
@@ -221,7 +149,7 @@ func LoginBind(user, pass string, lc *ldap.LDAPConnection) error {
 	err := lc.Bind(dnInfo, pass)
 	if err != nil {
 		log.Println("ERROR : Login not Successful")
-		return errors.New("Session Expired/Invalid Credentials")
+		return ErrInvalidCredentials
 	}
 	return nil
 }
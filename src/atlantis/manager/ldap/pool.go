@@ -0,0 +1,143 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package ldap
+
+import (
+	"errors"
+	"github.com/mavricknz/ldap"
+	"sync"
+	"time"
+)
+
+// PoolSize is how many connections to LDAP a manager keeps open at most.
+var PoolSize = 10
+
+// ConnPool hands out connections to LDAP that are bound as the search user. Connections that were idle for longer
+// than checkAfter are checked with a search of the root DSE before they are handed out again; dead ones are
+// replaced. Get blocks while every connection is in use.
+type ConnPool struct {
+	sync.Mutex
+	slots      chan struct{}
+	idle       []*pooledConn
+	checkAfter time.Duration
+	dial       func() (*ldap.LDAPConnection, error)
+	ping       func(*ldap.LDAPConnection) error
+	close      func(*ldap.LDAPConnection)
+}
+
+type pooledConn struct {
+	conn     *ldap.LDAPConnection
+	lastUsed time.Time
+}
+
+// Pool is the pool every LDAP search goes through. Init replaces it once the server is known.
+var Pool = NewConnPool(PoolSize, dialSearchConn)
+
+func NewConnPool(size int, dial func() (*ldap.LDAPConnection, error)) *ConnPool {
+	if size < 1 {
+		size = 1
+	}
+	return &ConnPool{
+		slots:      make(chan struct{}, size),
+		idle:       []*pooledConn{},
+		checkAfter: time.Minute,
+		dial:       dial,
+		ping:       pingConn,
+		close:      func(conn *ldap.LDAPConnection) { conn.Close() },
+	}
+}
+
+// dialSearchConn opens a new connection and binds it as the search user
+func dialSearchConn() (*ldap.LDAPConnection, error) {
+	if LdapServer == "" {
+		return nil, errors.New("No LDAP server configured")
+	}
+	conn, err := CreateLdapConn(LdapServer, LdapPort, TlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := LoginBind(SearchUserDn, SearchUserPwd, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// pingConn reads the root DSE, which every server answers and which costs next to nothing
+func pingConn(conn *ldap.LDAPConnection) error {
+	_, err := conn.Search(ldap.NewSimpleSearchRequest("", 0, "(objectClass=*)", []string{"1.1"}))
+	return err
+}
+
+// Get returns a healthy connection. Every connection from Get must go back through Put or Discard.
+func (p *ConnPool) Get() (*ldap.LDAPConnection, error) {
+	p.slots <- struct{}{}
+	for {
+		p.Lock()
+		if len(p.idle) == 0 {
+			p.Unlock()
+			break
+		}
+		pc := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.Unlock()
+		if time.Since(pc.lastUsed) < p.checkAfter || p.ping(pc.conn) == nil {
+			return pc.conn, nil
+		}
+		p.close(pc.conn)
+	}
+	conn, err := p.dial()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Put gives back a connection that still works and is bound as the search user.
+func (p *ConnPool) Put(conn *ldap.LDAPConnection) {
+	p.Lock()
+	p.idle = append(p.idle, &pooledConn{conn, time.Now()})
+	p.Unlock()
+	<-p.slots
+}
+
+// Discard closes a connection that failed or is no longer bound as the search user.
+func (p *ConnPool) Discard(conn *ldap.LDAPConnection) {
+	p.close(conn)
+	<-p.slots
+}
+
+// Close closes the idle connections. Connections in use are closed when they are discarded.
+func (p *ConnPool) Close() {
+	p.Lock()
+	defer p.Unlock()
+	for _, pc := range p.idle {
+		p.close(pc.conn)
+	}
+	p.idle = []*pooledConn{}
+}
+
+// Search runs req on a pooled connection. A connection that fails a search is not trusted again.
+func Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	conn, err := Pool.Get()
+	if err != nil {
+		return nil, err
+	}
+	sr, err := conn.Search(req)
+	if err != nil {
+		Pool.Discard(conn)
+		return nil, err
+	}
+	Pool.Put(conn)
+	return sr, nil
+}
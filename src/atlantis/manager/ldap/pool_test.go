@@ -0,0 +1,111 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package ldap
+
+import (
+	"errors"
+	. "github.com/adjust/gocheck"
+	"github.com/mavricknz/ldap"
+	"time"
+)
+
+type PoolSuite struct{}
+
+var _ = Suite(&PoolSuite{})
+
+type fakeLdap struct {
+	dialed []*ldap.LDAPConnection
+	closed []*ldap.LDAPConnection
+	dead   map[*ldap.LDAPConnection]bool
+}
+
+func newFakePool(size int) (*ConnPool, *fakeLdap) {
+	fake := &fakeLdap{dead: map[*ldap.LDAPConnection]bool{}}
+	p := NewConnPool(size, func() (*ldap.LDAPConnection, error) {
+		conn := &ldap.LDAPConnection{}
+		fake.dialed = append(fake.dialed, conn)
+		return conn, nil
+	})
+	p.ping = func(conn *ldap.LDAPConnection) error {
+		if fake.dead[conn] {
+			return errors.New("dead")
+		}
+		return nil
+	}
+	p.close = func(conn *ldap.LDAPConnection) { fake.closed = append(fake.closed, conn) }
+	return p, fake
+}
+
+func (s *PoolSuite) TestReuse(c *C) {
+	p, fake := newFakePool(2)
+	conn, err := p.Get()
+	c.Assert(err, IsNil)
+	p.Put(conn)
+	again, err := p.Get()
+	c.Assert(err, IsNil)
+	c.Assert(again, Equals, conn)
+	c.Assert(fake.dialed, HasLen, 1)
+
+	p.Discard(again)
+	c.Assert(fake.closed, DeepEquals, []*ldap.LDAPConnection{conn})
+	_, err = p.Get()
+	c.Assert(err, IsNil)
+	c.Assert(fake.dialed, HasLen, 2)
+}
+
+func (s *PoolSuite) TestHealthCheck(c *C) {
+	p, fake := newFakePool(2)
+	conn, _ := p.Get()
+	p.Put(conn)
+	fake.dead[conn] = true
+	// recently used connections are not checked
+	again, _ := p.Get()
+	c.Assert(again, Equals, conn)
+	p.Put(again)
+
+	p.checkAfter = 0
+	fresh, err := p.Get()
+	c.Assert(err, IsNil)
+	c.Assert(fresh, Not(Equals), conn)
+	c.Assert(fake.closed, DeepEquals, []*ldap.LDAPConnection{conn})
+}
+
+func (s *PoolSuite) TestBounded(c *C) {
+	p, _ := newFakePool(1)
+	conn, _ := p.Get()
+	got := make(chan *ldap.LDAPConnection)
+	go func() {
+		next, _ := p.Get()
+		got <- next
+	}()
+	select {
+	case <-got:
+		c.Fatal("Get did not wait for a free connection")
+	case <-time.After(50 * time.Millisecond):
+	}
+	p.Put(conn)
+	select {
+	case next := <-got:
+		c.Assert(next, Equals, conn)
+	case <-time.After(time.Second):
+		c.Fatal("Get did not get the connection that was put back")
+	}
+}
+
+func (s *PoolSuite) TestDialError(c *C) {
+	p := NewConnPool(1, func() (*ldap.LDAPConnection, error) { return nil, errors.New("down") })
+	_, err := p.Get()
+	c.Assert(err, Not(IsNil))
+	// a failed dial does not use up the slot
+	_, err = p.Get()
+	c.Assert(err, ErrorMatches, "down")
+}
@@ -0,0 +1,200 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package ldap
+
+import (
+	"atlantis/crypto"
+	"atlantis/manager/datamodel"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SessionTTL is how long a session lasts after it was last used.
+var SessionTTL = 30 * time.Minute
+
+// Sessions keeps the sessions of logged in users. It is in memory until InitSessions says otherwise.
+var Sessions SessionStore = NewMemorySessionStore()
+
+type Session struct {
+	User    string
+	Team    []string
+	Expires time.Time
+}
+
+// A SessionStore keeps sessions by user and secret. Stores only keep a hash of the secret and must be safe to use
+// from several goroutines.
+type SessionStore interface {
+	// Get returns the session of user with secret, or nil if there is none or it has expired.
+	Get(user, secret string) *Session
+	Put(secret string, session *Session) error
+	Delete(user, secret string) error
+	// Expire drops the sessions that expired before now.
+	Expire(now time.Time)
+}
+
+// InitSessions picks where sessions are kept: "memory" keeps them in this manager, "zookeeper" shares them with
+// every manager in the region and keeps them across restarts.
+func InitSessions(store string) error {
+	switch store {
+	case "memory":
+		Sessions = NewMemorySessionStore()
+	case "zookeeper":
+		Sessions = ZkSessionStore{}
+	default:
+		return errors.New("Unknown session store " + store + ", use memory or zookeeper")
+	}
+	go SessionExpiryRoutine()
+	return nil
+}
+
+func sessionKey(user, secret string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(user+"\x00"+secret)))
+}
+
+type MemorySessionStore struct {
+	sync.Mutex
+	sessions map[string]Session // sessionKey(user, secret) -> session
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]Session{}}
+}
+
+func (s *MemorySessionStore) Get(user, secret string) *Session {
+	s.Lock()
+	defer s.Unlock()
+	session, ok := s.sessions[sessionKey(user, secret)]
+	if !ok || time.Now().After(session.Expires) {
+		return nil
+	}
+	return &session
+}
+
+func (s *MemorySessionStore) Put(secret string, session *Session) error {
+	s.Lock()
+	defer s.Unlock()
+	s.sessions[sessionKey(session.User, secret)] = *session
+	return nil
+}
+
+func (s *MemorySessionStore) Delete(user, secret string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.sessions, sessionKey(user, secret))
+	return nil
+}
+
+func (s *MemorySessionStore) Expire(now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	for key, session := range s.sessions {
+		if now.After(session.Expires) {
+			delete(s.sessions, key)
+		}
+	}
+}
+
+// ZkSessionStore keeps sessions in zookeeper so that every manager in the region knows them.
+type ZkSessionStore struct{}
+
+func (s ZkSessionStore) Get(user, secret string) *Session {
+	zs, err := datamodel.GetSession(sessionKey(user, secret))
+	if err != nil || zs.User != user || time.Now().After(zs.Expires) {
+		return nil
+	}
+	return &Session{User: zs.User, Team: zs.Team, Expires: zs.Expires}
+}
+
+func (s ZkSessionStore) Put(secret string, session *Session) error {
+	zs := &datamodel.ZkSession{Key: sessionKey(session.User, secret), User: session.User, Team: session.Team,
+		Expires: session.Expires}
+	return zs.Save()
+}
+
+func (s ZkSessionStore) Delete(user, secret string) error {
+	return (&datamodel.ZkSession{Key: sessionKey(user, secret)}).Delete()
+}
+
+// every manager expires sessions, so another may have deleted one already
+func (s ZkSessionStore) Expire(now time.Time) {
+	keys, err := datamodel.ListSessions()
+	if err != nil {
+		return
+	}
+	for _, key := range keys {
+		zs, err := datamodel.GetSession(key)
+		if err != nil || !now.After(zs.Expires) {
+			continue
+		}
+		if err := zs.Delete(); err != nil {
+			log.Printf("Warning: could not delete expired session of %s: %s", zs.User, err)
+		}
+	}
+}
+
+// touchSession pushes the expiry of a session that is being used back to SessionTTL from now. To keep writes down
+// it only does so once the session has been idle for a minute.
+func touchSession(secret string, session *Session) {
+	now := time.Now()
+	if session.Expires.Sub(now) > SessionTTL-time.Minute {
+		return
+	}
+	session.Expires = now.Add(SessionTTL)
+	if err := Sessions.Put(secret, session); err != nil {
+		log.Printf("Warning: could not extend session of %s: %s", session.User, err)
+	}
+}
+
+// ResumeSession returns true if user has a session with secret, and keeps the session from expiring.
+func ResumeSession(user, secret string) bool {
+	if secret == "" {
+		return false
+	}
+	session := Sessions.Get(user, secret)
+	if session == nil {
+		return false
+	}
+	touchSession(secret, session)
+	return true
+}
+
+// NewSession starts a session for user, who is in teams, and returns its secret.
+func NewSession(user, pass string, teams []string) (string, error) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	sec := string(crypto.Encrypt([]byte(pass + now)))
+	re := regexp.MustCompile("[^a-zA-Z0-9]")
+	sec = re.ReplaceAllString(sec, "")
+	if err := Sessions.Put(sec, &Session{User: user, Team: teams, Expires: time.Now().Add(SessionTTL)}); err != nil {
+		return "", err
+	}
+	return sec, nil
+}
+
+func LookupTeam(user, secret string) []string {
+	if session := Sessions.Get(user, secret); session != nil {
+		return session.Team
+	}
+	return nil
+}
+
+// SessionExpiryRoutine drops expired sessions once a minute.
+func SessionExpiryRoutine() {
+	for now := range time.Tick(time.Minute) {
+		Sessions.Expire(now)
+	}
+}
@@ -0,0 +1,54 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package ldap
+
+import (
+	. "github.com/adjust/gocheck"
+	"testing"
+	"time"
+)
+
+func TestLdap(t *testing.T) { TestingT(t) }
+
+type SessionSuite struct{}
+
+var _ = Suite(&SessionSuite{})
+
+func (s *SessionSuite) TestMemorySessionStore(c *C) {
+	store := NewMemorySessionStore()
+	now := time.Now()
+	c.Assert(store.Put("secret", &Session{User: "me", Team: []string{"team"}, Expires: now.Add(time.Hour)}), IsNil)
+	c.Assert(store.Put("old", &Session{User: "me", Expires: now.Add(-time.Minute)}), IsNil)
+
+	session := store.Get("me", "secret")
+	c.Assert(session, Not(IsNil))
+	c.Assert(session.Team, DeepEquals, []string{"team"})
+	c.Assert(store.Get("you", "secret"), IsNil)
+	c.Assert(store.Get("me", "wrong"), IsNil)
+	c.Assert(store.Get("me", "old"), IsNil)
+	for key := range store.sessions {
+		c.Assert(key, Not(Equals), "secret")
+	}
+
+	store.Expire(now)
+	c.Assert(store.sessions, HasLen, 1)
+	c.Assert(store.Delete("me", "secret"), IsNil)
+	c.Assert(store.Get("me", "secret"), IsNil)
+}
+
+func (s *SessionSuite) TestInitSessions(c *C) {
+	c.Assert(InitSessions("memory"), IsNil)
+	c.Assert(InitSessions("zookeeper"), IsNil)
+	c.Assert(Sessions, Equals, SessionStore(ZkSessionStore{}))
+	c.Assert(InitSessions("redis"), Not(IsNil))
+	Sessions = NewMemorySessionStore()
+}
@@ -12,6 +12,7 @@
 package manager
 
 import (
+	. "atlantis/common"
 	"atlantis/manager/datamodel"
 	"atlantis/manager/dns"
 	"atlantis/manager/helper"
@@ -22,6 +23,12 @@ import (
 
 var Port string
 
+// seconds to wait on another manager before giving up on it
+const PeerTimeout = 30
+
+// seconds to wait on the health check of another manager, which should be quick
+const PeerHealthTimeout = 5
+
 func Init(port string) {
 	Port = port
 }
@@ -151,3 +158,29 @@ func HealthCheck(host string) (*ManagerHealthCheckReply, error) {
 	var reply ManagerHealthCheckReply
 	return &reply, NewManagerRPCClient(host+":"+Port).Call("HealthCheck", args, &reply)
 }
+
+// CallPeer calls name on the manager running on host
+func CallPeer(host, name string, arg, reply interface{}) error {
+	return NewManagerRPCClient(host+":"+Port).CallWithTimeout(name, arg, reply, PeerTimeout)
+}
+
+// CheckPeer returns nil if the manager on host says it is OK within PeerHealthTimeout
+func CheckPeer(host string) error {
+	var reply ManagerHealthCheckReply
+	err := NewManagerRPCClient(host+":"+Port).CallWithTimeout("HealthCheck", ManagerHealthCheckArg{}, &reply,
+		PeerHealthTimeout)
+	if err == nil && reply.Status != StatusOk {
+		err = errors.New("Manager " + host + " is " + reply.Status)
+	}
+	return err
+}
+
+// PeerRegions returns the managers of every region other than skipRegion
+func PeerRegions(skipRegion string) (map[string][]string, error) {
+	managers, err := datamodel.ListManagers()
+	if err != nil {
+		return nil, err
+	}
+	delete(managers, skipRegion)
+	return managers, nil
+}
@@ -0,0 +1,327 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/common"
+	. "atlantis/manager/constant"
+	"atlantis/manager/manager"
+	. "atlantis/manager/rpc/types"
+	"errors"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Aggregated calls run the normal call in this region and the same call, with Aggregate turned off, against one
+// healthy manager in every other region. Replies are kept per region in the Regions field of the reply and
+// failures in RegionErrors so that one unreachable region does not hide the others.
+//
+// Paged lists are filtered in every region and paged once they are merged, so Regions has the whole filtered list
+// of each region.
+//
+// The caller is authenticated in this region. Sessions are only known in the region that handed them out and
+// passwords should go no further than they have to, so other regions are called with a short-lived API token that
+// acts as the caller instead (see peerCredentials).
+
+type regionResult struct {
+	region string
+	reply  interface{}
+	err    error
+}
+
+// how aggregate finds and talks to the managers of other regions
+var (
+	peerRegions = manager.PeerRegions
+	checkPeer   = manager.CheckPeer
+	callPeer    = manager.CallPeer
+)
+
+// how long the tokens other regions are called with are good for
+var peerTokenTTL = time.Minute
+
+// peerCredentials returns a copy of arg that other regions accept, and a func that cleans up after the call. The
+// caller of arg must already be authenticated. Unless they used an API token, which every region knows, the copy
+// carries a token that acts as them, may only make the call name and expires after peerTokenTTL.
+func peerCredentials(name string, arg interface{}) (interface{}, func(), error) {
+	done := func() {}
+	if arg == nil {
+		return arg, done, nil
+	}
+	peerArg := reflect.New(reflect.TypeOf(arg))
+	peerArg.Elem().Set(reflect.ValueOf(arg))
+	auth := requestAuthArg(peerArg.Interface())
+	if auth == nil || isToken(auth.Secret) {
+		return arg, done, nil
+	}
+	now := time.Now()
+	zt, secret, err := saveToken(APIToken{
+		ID:         CreateRandomID(tokenIDSize),
+		Name:       "aggregate " + name,
+		User:       auth.User,
+		Owner:      auth.User,
+		Operations: []string{name},
+		Created:    now,
+		Expires:    now.Add(peerTokenTTL),
+	})
+	if err != nil {
+		return nil, done, err
+	}
+	*auth = ManagerAuthArg{User: auth.User, Secret: secret, Source: auth.Source}
+	return peerArg.Elem().Interface(), func() {
+		if err := zt.Delete(); err != nil {
+			log.Printf("[RPC][%s] could not delete token %s: %s", name, zt.ID, err)
+		}
+	}, nil
+}
+
+// healthyPeer health checks every host at once and returns the first that is healthy, or "" if none are
+func healthyPeer(hosts []string) string {
+	healthy := make(chan string, len(hosts))
+	for _, host := range hosts {
+		go func(host string) {
+			if checkPeer(host) == nil {
+				healthy <- host
+			} else {
+				healthy <- ""
+			}
+		}(host)
+	}
+	for i := 0; i < len(hosts); i++ {
+		if host := <-healthy; host != "" {
+			return host
+		}
+	}
+	return ""
+}
+
+// Every region is called as soon as one of its managers is found to be healthy, so a region that is down only
+// holds up the call for as long as its health checks take.
+func aggregate(name string, arg interface{}, newReply func() interface{},
+	local func(reply interface{}) error) (map[string]interface{}, map[string]string) {
+	peers, err := peerRegions(Region)
+	if err != nil {
+		log.Printf("[RPC][%s] could not list managers in other regions: %s", name, err)
+		peers = map[string][]string{}
+	}
+	peerArg, done, peerErr := peerCredentials(name, arg)
+	defer done()
+	results := make(chan regionResult, len(peers)+1)
+	var wg sync.WaitGroup
+	wg.Add(len(peers) + 1)
+	go func() {
+		defer wg.Done()
+		reply := newReply()
+		results <- regionResult{Region, reply, local(reply)}
+	}()
+	for region, hosts := range peers {
+		go func(region string, hosts []string) {
+			defer wg.Done()
+			if peerErr != nil {
+				results <- regionResult{region, nil, errors.New("Could not make credentials for region " + region +
+					": " + peerErr.Error())}
+				return
+			}
+			host := healthyPeer(hosts)
+			if host == "" {
+				results <- regionResult{region, nil, errors.New("No healthy manager in region " + region)}
+				return
+			}
+			reply := newReply()
+			results <- regionResult{region, reply, callPeer(host, name, peerArg, reply)}
+		}(region, hosts)
+	}
+	wg.Wait()
+	close(results)
+	replies := map[string]interface{}{}
+	errs := map[string]string{}
+	for result := range results {
+		if result.err != nil {
+			errs[result.region] = result.err.Error()
+			continue
+		}
+		replies[result.region] = result.reply
+	}
+	return replies, errs
+}
+
+// merges lists from every region into one sorted list without duplicates
+func mergeLists(lists ...[]string) []string {
+	seen := map[string]bool{}
+	merged := []string{}
+	for _, list := range lists {
+		for _, item := range list {
+			if !seen[item] {
+				seen[item] = true
+				merged = append(merged, item)
+			}
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+func (m *ManagerRPC) aggregateGetContainer(arg ManagerGetContainerArg, reply *ManagerGetContainerReply) error {
+	arg.Aggregate = false
+	e := &GetContainerExecutor{arg, reply}
+	if err := e.Authorize(); err != nil {
+		return err
+	}
+	arg.ManagerAuthArg = e.arg.ManagerAuthArg // as authenticated
+	replies, errs := aggregate("GetContainer", arg, func() interface{} { return &ManagerGetContainerReply{} },
+		func(r interface{}) error { return m.GetContainer(arg, r.(*ManagerGetContainerReply)) })
+	reply.Regions = map[string]*ManagerGetContainerReply{}
+	for region, r := range replies {
+		reply.Regions[region] = r.(*ManagerGetContainerReply)
+		// container ids are unique so at most one region will have it
+		if reply.Container == nil {
+			reply.Container = reply.Regions[region].Container
+		}
+	}
+	reply.RegionErrors = errs
+	if reply.Container == nil {
+		reply.Status = StatusError
+		return errorOf(ErrNotFound, "Container "+arg.ContainerID+" not found in any region")
+	}
+	reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) aggregateListContainers(arg ManagerListContainersArg, reply *ManagerListContainersReply) error {
+	arg.Aggregate = false
+	opts := arg.ListOptions
+	arg.ListOptions = filterOnly(opts)
+	e := &ListContainersExecutor{arg, reply}
+	if err := e.Authorize(); err != nil {
+		return err
+	}
+	arg.ManagerAuthArg = e.arg.ManagerAuthArg // as authenticated
+	replies, errs := aggregate("ListContainers", arg, func() interface{} { return &ManagerListContainersReply{} },
+		func(r interface{}) error { return m.ListContainers(arg, r.(*ManagerListContainersReply)) })
+	reply.Regions = map[string]*ManagerListContainersReply{}
+	lists := [][]string{}
+	for region, r := range replies {
+		reply.Regions[region] = r.(*ManagerListContainersReply)
+		lists = append(lists, reply.Regions[region].ContainerIDs)
+	}
+	var err error
+	reply.ContainerIDs, reply.NextCursor, err = pageList(mergeLists(lists...), pageOnly(opts), nil)
+	reply.RegionErrors = errs
+	if err != nil {
+		reply.Status = StatusError
+		return err
+	}
+	reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) aggregateListEnvs(arg ManagerListEnvsArg, reply *ManagerListEnvsReply) error {
+	arg.Aggregate = false
+	e := &ListEnvsExecutor{arg, reply}
+	if err := e.Authorize(); err != nil {
+		return err
+	}
+	arg.ManagerAuthArg = e.arg.ManagerAuthArg // as authenticated
+	replies, errs := aggregate("ListEnvs", arg, func() interface{} { return &ManagerListEnvsReply{} },
+		func(r interface{}) error { return m.ListEnvs(arg, r.(*ManagerListEnvsReply)) })
+	reply.Regions = map[string]*ManagerListEnvsReply{}
+	lists := [][]string{}
+	for region, r := range replies {
+		reply.Regions[region] = r.(*ManagerListEnvsReply)
+		lists = append(lists, reply.Regions[region].Envs)
+	}
+	reply.Envs = mergeLists(lists...)
+	reply.RegionErrors = errs
+	reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) aggregateListShas(arg ManagerListShasArg, reply *ManagerListShasReply) error {
+	arg.Aggregate = false
+	opts := arg.ListOptions
+	arg.ListOptions = filterOnly(opts)
+	e := &ListShasExecutor{arg, reply}
+	if err := e.Authorize(); err != nil {
+		return err
+	}
+	arg.ManagerAuthArg = e.arg.ManagerAuthArg // as authenticated
+	replies, errs := aggregate("ListShas", arg, func() interface{} { return &ManagerListShasReply{} },
+		func(r interface{}) error { return m.ListShas(arg, r.(*ManagerListShasReply)) })
+	reply.Regions = map[string]*ManagerListShasReply{}
+	lists := [][]string{}
+	for region, r := range replies {
+		reply.Regions[region] = r.(*ManagerListShasReply)
+		lists = append(lists, reply.Regions[region].Shas)
+	}
+	var err error
+	reply.Shas, reply.NextCursor, err = pageList(mergeLists(lists...), pageOnly(opts), nil)
+	reply.RegionErrors = errs
+	if err != nil {
+		reply.Status = StatusError
+		return err
+	}
+	reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) aggregateListApps(arg ManagerListAppsArg, reply *ManagerListAppsReply) error {
+	arg.Aggregate = false
+	opts := arg.ListOptions
+	arg.ListOptions = filterOnly(opts)
+	e := &ListAppsExecutor{arg, reply}
+	if err := e.Authorize(); err != nil {
+		return err
+	}
+	arg.ManagerAuthArg = e.arg.ManagerAuthArg // as authenticated
+	replies, errs := aggregate("ListApps", arg, func() interface{} { return &ManagerListAppsReply{} },
+		func(r interface{}) error { return m.ListApps(arg, r.(*ManagerListAppsReply)) })
+	reply.Regions = map[string]*ManagerListAppsReply{}
+	lists := [][]string{}
+	for region, r := range replies {
+		reply.Regions[region] = r.(*ManagerListAppsReply)
+		lists = append(lists, reply.Regions[region].Apps)
+	}
+	var err error
+	reply.Apps, reply.NextCursor, err = pageList(mergeLists(lists...), pageOnly(opts), nil)
+	reply.RegionErrors = errs
+	if err != nil {
+		reply.Status = StatusError
+		return err
+	}
+	reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) aggregateUsage(arg ManagerUsageArg, reply *ManagerUsageReply) error {
+	arg.Aggregate = false
+	e := &UsageExecutor{arg, reply}
+	if err := e.Authorize(); err != nil {
+		return err
+	}
+	arg.ManagerAuthArg = e.arg.ManagerAuthArg // as authenticated
+	replies, errs := aggregate("Usage", arg, func() interface{} { return &ManagerUsageReply{} },
+		func(r interface{}) error { return m.Usage(arg, r.(*ManagerUsageReply)) })
+	reply.Regions = map[string]*ManagerUsageReply{}
+	reply.Usage = map[string]*SupervisorUsage{}
+	for region, r := range replies {
+		reply.Regions[region] = r.(*ManagerUsageReply)
+		// supervisor hosts are unique across regions
+		for host, usage := range reply.Regions[region].Usage {
+			reply.Usage[host] = usage
+		}
+	}
+	reply.RegionErrors = errs
+	reply.Status = StatusOk
+	return nil
+}
@@ -0,0 +1,86 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/manager/constant"
+	. "atlantis/manager/rpc/types"
+	"errors"
+	. "github.com/adjust/gocheck"
+	"sync"
+	"time"
+)
+
+type AggregateSuite struct{}
+
+var _ = Suite(&AggregateSuite{})
+
+func (s *AggregateSuite) TestMergeLists(c *C) {
+	c.Assert(mergeLists(), DeepEquals, []string{})
+	c.Assert(mergeLists([]string{"b", "a"}, nil, []string{"c", "a"}), DeepEquals, []string{"a", "b", "c"})
+}
+
+func (s *AggregateSuite) TestAggregateFailingRegion(c *C) {
+	defer func(regions func(string) (map[string][]string, error), check func(string) error,
+		call func(string, string, interface{}, interface{}) error) {
+		peerRegions, checkPeer, callPeer = regions, check, call
+	}(peerRegions, checkPeer, callPeer)
+	peerRegions = func(skip string) (map[string][]string, error) {
+		return map[string][]string{"down": {"down1", "down2"}, "up": {"slow", "up1"}}, nil
+	}
+	checkPeer = func(host string) error {
+		switch host {
+		case "up1":
+			return nil
+		case "slow":
+			time.Sleep(time.Second)
+			return nil
+		}
+		time.Sleep(300 * time.Millisecond)
+		return errors.New(host + " timed out")
+	}
+	var lock sync.Mutex
+	called := map[string]time.Duration{}
+	start := time.Now()
+	callPeer = func(host, name string, arg, reply interface{}) error {
+		lock.Lock()
+		called[host] = time.Since(start)
+		lock.Unlock()
+		*reply.(*[]string) = []string{host}
+		return nil
+	}
+	replies, errs := aggregate("ListApps", nil, func() interface{} { return &[]string{} },
+		func(reply interface{}) error {
+			*reply.(*[]string) = []string{"local"}
+			return nil
+		})
+	c.Assert(errs, DeepEquals, map[string]string{"down": "No healthy manager in region down"})
+	c.Assert(replies, HasLen, 2)
+	c.Assert(*replies[Region].(*[]string), DeepEquals, []string{"local"})
+	c.Assert(*replies["up"].(*[]string), DeepEquals, []string{"up1"})
+	// the healthy region is called as soon as its first healthy manager answers, without waiting on the others
+	c.Assert(called, HasLen, 1)
+	c.Assert(called["up1"] < 200*time.Millisecond, Equals, true)
+	c.Assert(time.Since(start) < 900*time.Millisecond, Equals, true)
+}
+
+func (s *AggregateSuite) TestPeerCredentialsToken(c *C) {
+	// every region knows API tokens, so they are passed on as they are
+	arg := ManagerListAppsArg{ManagerAuthArg: ManagerAuthArg{User: "ci", Secret: "atl_id_s3cr3t"}, Aggregate: true}
+	peerArg, done, err := peerCredentials("ListApps", arg)
+	c.Assert(err, IsNil)
+	done()
+	c.Assert(peerArg, DeepEquals, arg)
+	peerArg, _, err = peerCredentials("ListApps", nil)
+	c.Assert(err, IsNil)
+	c.Assert(peerArg, IsNil)
+}
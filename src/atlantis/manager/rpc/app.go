@@ -18,7 +18,6 @@ import (
 	. "atlantis/manager/rpc/types"
 	"atlantis/manager/smtp"
 	"bytes"
-	"errors"
 	"fmt"
 	"strings"
 	"text/template"
@@ -44,7 +43,7 @@ type RequestAppDependencyExecutor struct {
 }
 
 func (e *RequestAppDependencyExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *RequestAppDependencyExecutor) Result() interface{} {
@@ -62,20 +61,20 @@ func (e *RequestAppDependencyExecutor) Authorize() error {
 func (e *RequestAppDependencyExecutor) Execute(t *Task) error {
 	if e.arg.App == "" {
 		e.reply.Status = StatusError
-		return errors.New("Please specify an app")
+		return errorOf(ErrInvalid, "Please specify an app")
 	}
 	if e.arg.Dependency == "" {
 		e.reply.Status = StatusError
-		return errors.New("Please specify an app to depend on")
+		return errorOf(ErrInvalid, "Please specify an app to depend on")
 	}
 	if len(e.arg.Envs) == 0 {
 		e.reply.Status = StatusError
-		return errors.New("Please specify the envs your app needs the dependency in")
+		return errorOf(ErrInvalid, "Please specify the envs your app needs the dependency in")
 	}
 	for _, env := range e.arg.Envs {
 		if _, err := datamodel.GetEnv(env); err != nil {
 			e.reply.Status = StatusError
-			return errors.New("The env " + env + " does not exist")
+			return errorOf(ErrNotFound, "The env "+env+" does not exist")
 		}
 	}
 	// fetch apps
@@ -101,7 +100,7 @@ func (e *RequestAppDependencyExecutor) Execute(t *Task) error {
 		missingEnvs = e.arg.Envs
 	}
 	if len(missingEnvs) == 0 {
-		return errors.New(fmt.Sprintf("Your app already has access to the dependency %s in envs %v",
+		return errorOf(ErrConflict, fmt.Sprintf("Your app already has access to the dependency %s in envs %v",
 			e.arg.Dependency, e.arg.Envs))
 	}
 
@@ -155,7 +154,7 @@ type AddDependerAppDataExecutor struct {
 }
 
 func (e *AddDependerAppDataExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *AddDependerAppDataExecutor) Result() interface{} {
@@ -163,7 +162,7 @@ func (e *AddDependerAppDataExecutor) Result() interface{} {
 }
 
 func (e *AddDependerAppDataExecutor) Description() string {
-	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] [+] %s depender %+v", e.arg.App, e.arg.DependerAppData)
+	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] [+] %s depender %+v", e.arg.App, Redact(e.arg.DependerAppData))
 }
 
 func (e *AddDependerAppDataExecutor) Authorize() error {
@@ -172,18 +171,18 @@ func (e *AddDependerAppDataExecutor) Authorize() error {
 
 func (e *AddDependerAppDataExecutor) Execute(t *Task) error {
 	if e.arg.App == "" {
-		return errors.New("Please specify an app")
+		return errorOf(ErrInvalid, "Please specify an app")
 	}
 	if e.arg.DependerAppData == nil {
-		return errors.New("Please specify data for the depender app")
+		return errorOf(ErrInvalid, "Please specify data for the depender app")
 	} else if e.arg.DependerAppData.Name == "" {
-		return errors.New("Please specify name for the depender app")
+		return errorOf(ErrInvalid, "Please specify name for the depender app")
 	}
 	// verify SecurityGroups are valid
 	for _, envData := range e.arg.DependerAppData.DependerEnvData {
 		for ipGroup, _ := range envData.SecurityGroup {
 			if _, err := datamodel.GetIPGroup(ipGroup); err != nil {
-				return errors.New("Invalid IP Group in Security Group: " + ipGroup)
+				return errorOf(ErrInvalid, "Invalid IP Group in Security Group: "+ipGroup)
 			}
 		}
 	}
@@ -205,7 +204,7 @@ func (e *AddDependerAppDataExecutor) Execute(t *Task) error {
 }
 
 func (m *ManagerRPC) AddDependerAppData(arg ManagerAddDependerAppDataArg, reply *ManagerAddDependerAppDataReply) error {
-	return NewTask("AddDependerAppData", &AddDependerAppDataExecutor{arg, reply}).Run()
+	return NewAuditedTask("AddDependerAppData", &AddDependerAppDataExecutor{arg, reply}).Run()
 }
 
 type RemoveDependerAppDataExecutor struct {
@@ -214,7 +213,7 @@ type RemoveDependerAppDataExecutor struct {
 }
 
 func (e *RemoveDependerAppDataExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *RemoveDependerAppDataExecutor) Result() interface{} {
@@ -231,10 +230,10 @@ func (e *RemoveDependerAppDataExecutor) Authorize() error {
 
 func (e *RemoveDependerAppDataExecutor) Execute(t *Task) error {
 	if e.arg.App == "" {
-		return errors.New("Please specify an app")
+		return errorOf(ErrInvalid, "Please specify an app")
 	}
 	if e.arg.Depender == "" {
-		return errors.New("Please specify a depender app")
+		return errorOf(ErrInvalid, "Please specify a depender app")
 	}
 	zkApp, err := datamodel.GetApp(e.arg.App)
 	if err != nil {
@@ -253,7 +252,7 @@ func (e *RemoveDependerAppDataExecutor) Execute(t *Task) error {
 }
 
 func (m *ManagerRPC) RemoveDependerAppData(arg ManagerRemoveDependerAppDataArg, reply *ManagerRemoveDependerAppDataReply) error {
-	return NewTask("RemoveDependerAppData", &RemoveDependerAppDataExecutor{arg, reply}).Run()
+	return NewAuditedTask("RemoveDependerAppData", &RemoveDependerAppDataExecutor{arg, reply}).Run()
 }
 
 type GetDependerAppDataExecutor struct {
@@ -262,7 +261,7 @@ type GetDependerAppDataExecutor struct {
 }
 
 func (e *GetDependerAppDataExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *GetDependerAppDataExecutor) Result() interface{} {
@@ -279,10 +278,10 @@ func (e *GetDependerAppDataExecutor) Authorize() error {
 
 func (e *GetDependerAppDataExecutor) Execute(t *Task) error {
 	if e.arg.App == "" {
-		return errors.New("Please specify an app")
+		return errorOf(ErrInvalid, "Please specify an app")
 	}
 	if e.arg.Depender == "" {
-		return errors.New("Please specify a depender app")
+		return errorOf(ErrInvalid, "Please specify a depender app")
 	}
 	zkApp, err := datamodel.GetApp(e.arg.App)
 	if err != nil {
@@ -313,7 +312,7 @@ type AddDependerEnvDataExecutor struct {
 }
 
 func (e *AddDependerEnvDataExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *AddDependerEnvDataExecutor) Result() interface{} {
@@ -321,7 +320,7 @@ func (e *AddDependerEnvDataExecutor) Result() interface{} {
 }
 
 func (e *AddDependerEnvDataExecutor) Description() string {
-	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] [+] %s env %+v", e.arg.App, e.arg.DependerEnvData)
+	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] [+] %s env %+v", e.arg.App, Redact(e.arg.DependerEnvData))
 }
 
 func (e *AddDependerEnvDataExecutor) Authorize() error {
@@ -330,17 +329,17 @@ func (e *AddDependerEnvDataExecutor) Authorize() error {
 
 func (e *AddDependerEnvDataExecutor) Execute(t *Task) error {
 	if e.arg.App == "" {
-		return errors.New("Please specify an app")
+		return errorOf(ErrInvalid, "Please specify an app")
 	}
 	if e.arg.DependerEnvData == nil {
-		return errors.New("Please specify data for the env")
+		return errorOf(ErrInvalid, "Please specify data for the env")
 	} else if e.arg.DependerEnvData.Name == "" {
-		return errors.New("Please specify name for the env")
+		return errorOf(ErrInvalid, "Please specify name for the env")
 	}
 	// verify SecurityGroups are valid
 	for ipGroup, _ := range e.arg.DependerEnvData.SecurityGroup {
 		if _, err := datamodel.GetIPGroup(ipGroup); err != nil {
-			return errors.New("Invalid IP Group in Security Group: " + ipGroup)
+			return errorOf(ErrInvalid, "Invalid IP Group in Security Group: "+ipGroup)
 		}
 	}
 	zkApp, err := datamodel.GetApp(e.arg.App)
@@ -360,7 +359,7 @@ func (e *AddDependerEnvDataExecutor) Execute(t *Task) error {
 }
 
 func (m *ManagerRPC) AddDependerEnvData(arg ManagerAddDependerEnvDataArg, reply *ManagerAddDependerEnvDataReply) error {
-	return NewTask("AddDependerEnvData", &AddDependerEnvDataExecutor{arg, reply}).Run()
+	return NewAuditedTask("AddDependerEnvData", &AddDependerEnvDataExecutor{arg, reply}).Run()
 }
 
 type RemoveDependerEnvDataExecutor struct {
@@ -369,7 +368,7 @@ type RemoveDependerEnvDataExecutor struct {
 }
 
 func (e *RemoveDependerEnvDataExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *RemoveDependerEnvDataExecutor) Result() interface{} {
@@ -386,10 +385,10 @@ func (e *RemoveDependerEnvDataExecutor) Authorize() error {
 
 func (e *RemoveDependerEnvDataExecutor) Execute(t *Task) error {
 	if e.arg.App == "" {
-		return errors.New("Please specify an app")
+		return errorOf(ErrInvalid, "Please specify an app")
 	}
 	if e.arg.Env == "" {
-		return errors.New("Please specify an env")
+		return errorOf(ErrInvalid, "Please specify an env")
 	}
 	zkApp, err := datamodel.GetApp(e.arg.App)
 	if err != nil {
@@ -408,7 +407,7 @@ func (e *RemoveDependerEnvDataExecutor) Execute(t *Task) error {
 }
 
 func (m *ManagerRPC) RemoveDependerEnvData(arg ManagerRemoveDependerEnvDataArg, reply *ManagerRemoveDependerEnvDataReply) error {
-	return NewTask("RemoveDependerEnvData", &RemoveDependerEnvDataExecutor{arg, reply}).Run()
+	return NewAuditedTask("RemoveDependerEnvData", &RemoveDependerEnvDataExecutor{arg, reply}).Run()
 }
 
 type GetDependerEnvDataExecutor struct {
@@ -417,7 +416,7 @@ type GetDependerEnvDataExecutor struct {
 }
 
 func (e *GetDependerEnvDataExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *GetDependerEnvDataExecutor) Result() interface{} {
@@ -434,10 +433,10 @@ func (e *GetDependerEnvDataExecutor) Authorize() error {
 
 func (e *GetDependerEnvDataExecutor) Execute(t *Task) error {
 	if e.arg.App == "" {
-		return errors.New("Please specify an app")
+		return errorOf(ErrInvalid, "Please specify an app")
 	}
 	if e.arg.Env == "" {
-		return errors.New("Please specify an env")
+		return errorOf(ErrInvalid, "Please specify an env")
 	}
 	zkApp, err := datamodel.GetApp(e.arg.App)
 	if err != nil {
@@ -468,7 +467,7 @@ type AddDependerEnvDataForDependerAppExecutor struct {
 }
 
 func (e *AddDependerEnvDataForDependerAppExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *AddDependerEnvDataForDependerAppExecutor) Result() interface{} {
@@ -476,7 +475,7 @@ func (e *AddDependerEnvDataForDependerAppExecutor) Result() interface{} {
 }
 
 func (e *AddDependerEnvDataForDependerAppExecutor) Description() string {
-	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] [+] %s depender %s env %+v", e.arg.App, e.arg.Depender, e.arg.DependerEnvData)
+	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] [+] %s depender %s env %+v", e.arg.App, e.arg.Depender, Redact(e.arg.DependerEnvData))
 }
 
 func (e *AddDependerEnvDataForDependerAppExecutor) Authorize() error {
@@ -485,20 +484,20 @@ func (e *AddDependerEnvDataForDependerAppExecutor) Authorize() error {
 
 func (e *AddDependerEnvDataForDependerAppExecutor) Execute(t *Task) error {
 	if e.arg.App == "" {
-		return errors.New("Please specify an app")
+		return errorOf(ErrInvalid, "Please specify an app")
 	}
 	if e.arg.Depender == "" {
-		return errors.New("Please specify a depender app")
+		return errorOf(ErrInvalid, "Please specify a depender app")
 	}
 	if e.arg.DependerEnvData == nil {
-		return errors.New("Please specify data for the env")
+		return errorOf(ErrInvalid, "Please specify data for the env")
 	} else if e.arg.DependerEnvData.Name == "" {
-		return errors.New("Please specify name for the env")
+		return errorOf(ErrInvalid, "Please specify name for the env")
 	}
 	// verify SecurityGroups are valid
 	for ipGroup, _ := range e.arg.DependerEnvData.SecurityGroup {
 		if _, err := datamodel.GetIPGroup(ipGroup); err != nil {
-			return errors.New("Invalid IP Group in Security Group: " + ipGroup)
+			return errorOf(ErrInvalid, "Invalid IP Group in Security Group: "+ipGroup)
 		}
 	}
 	zkApp, err := datamodel.GetApp(e.arg.App)
@@ -519,7 +518,7 @@ func (e *AddDependerEnvDataForDependerAppExecutor) Execute(t *Task) error {
 
 func (m *ManagerRPC) AddDependerEnvDataForDependerApp(arg ManagerAddDependerEnvDataForDependerAppArg,
 	reply *ManagerAddDependerEnvDataForDependerAppReply) error {
-	return NewTask("AddDependerEnvDataForDependerApp", &AddDependerEnvDataForDependerAppExecutor{arg, reply}).Run()
+	return NewAuditedTask("AddDependerEnvDataForDependerApp", &AddDependerEnvDataForDependerAppExecutor{arg, reply}).Run()
 }
 
 type RemoveDependerEnvDataForDependerAppExecutor struct {
@@ -528,7 +527,7 @@ type RemoveDependerEnvDataForDependerAppExecutor struct {
 }
 
 func (e *RemoveDependerEnvDataForDependerAppExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *RemoveDependerEnvDataForDependerAppExecutor) Result() interface{} {
@@ -545,13 +544,13 @@ func (e *RemoveDependerEnvDataForDependerAppExecutor) Authorize() error {
 
 func (e *RemoveDependerEnvDataForDependerAppExecutor) Execute(t *Task) error {
 	if e.arg.App == "" {
-		return errors.New("Please specify an app")
+		return errorOf(ErrInvalid, "Please specify an app")
 	}
 	if e.arg.Depender == "" {
-		return errors.New("Please specify a depender app")
+		return errorOf(ErrInvalid, "Please specify a depender app")
 	}
 	if e.arg.Env == "" {
-		return errors.New("Please specify an env")
+		return errorOf(ErrInvalid, "Please specify an env")
 	}
 	zkApp, err := datamodel.GetApp(e.arg.App)
 	if err != nil {
@@ -571,7 +570,7 @@ func (e *RemoveDependerEnvDataForDependerAppExecutor) Execute(t *Task) error {
 
 func (m *ManagerRPC) RemoveDependerEnvDataForDependerApp(arg ManagerRemoveDependerEnvDataForDependerAppArg,
 	reply *ManagerRemoveDependerEnvDataForDependerAppReply) error {
-	return NewTask("RemoveDependerEnvDataForDependerApp", &RemoveDependerEnvDataForDependerAppExecutor{arg, reply}).Run()
+	return NewAuditedTask("RemoveDependerEnvDataForDependerApp", &RemoveDependerEnvDataForDependerAppExecutor{arg, reply}).Run()
 }
 
 type GetDependerEnvDataForDependerAppExecutor struct {
@@ -580,7 +579,7 @@ type GetDependerEnvDataForDependerAppExecutor struct {
 }
 
 func (e *GetDependerEnvDataForDependerAppExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *GetDependerEnvDataForDependerAppExecutor) Result() interface{} {
@@ -597,13 +596,13 @@ func (e *GetDependerEnvDataForDependerAppExecutor) Authorize() error {
 
 func (e *GetDependerEnvDataForDependerAppExecutor) Execute(t *Task) error {
 	if e.arg.App == "" {
-		return errors.New("Please specify an app")
+		return errorOf(ErrInvalid, "Please specify an app")
 	}
 	if e.arg.Depender == "" {
-		return errors.New("Please specify a depender app")
+		return errorOf(ErrInvalid, "Please specify a depender app")
 	}
 	if e.arg.Env == "" {
-		return errors.New("Please specify an env")
+		return errorOf(ErrInvalid, "Please specify an env")
 	}
 	zkApp, err := datamodel.GetApp(e.arg.App)
 	if err != nil {
@@ -0,0 +1,311 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/common"
+	. "atlantis/manager/constant"
+	"atlantis/manager/datamodel"
+	. "atlantis/manager/rpc/types"
+	"atlantis/manager/smtp"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"text/template"
+	"time"
+)
+
+const (
+	approvalPending  = "pending"
+	approvalApproved = "approved"
+	approvalRejected = "rejected"
+	approvalExpired  = "expired"
+)
+
+var (
+	approvalTimeout = time.Hour
+	// the tasks that need a second person to approve them when they change a protected env
+	approvalTasks = []string{"Deploy", "DeployContainer", "CopyContainer", "Teardown"}
+)
+
+// InitApprovals sets how long a deploy or teardown of a protected env waits to be approved before it fails
+func InitApprovals(timeout time.Duration) {
+	approvalTimeout = timeout
+}
+
+// approvalAction is the policy action an approver needs to be allowed to approve a task named name
+func approvalAction(name string) string {
+	if name == "Teardown" {
+		return "teardown"
+	}
+	return "deploy"
+}
+
+// protectedEnv returns the protected env that the task named name for app in env would change, or "" if it
+// changes none. A task without an env changes every env the app is in, or every env if it has no app either.
+func protectedEnv(name, app, env string) string {
+	if !contains(approvalTasks, name) {
+		return ""
+	}
+	envs := []string{env}
+	if env == "" && app != "" {
+		envs, _ = appEnvs(app, "")
+	} else if env == "" {
+		envs, _ = datamodel.ListEnvs()
+	}
+	for _, env := range envs {
+		if zkEnv, err := datamodel.GetEnv(env); err == nil && zkEnv.Protected {
+			return env
+		}
+	}
+	return ""
+}
+
+// tokenOwner returns who created the API token secret, or "" if secret is not a valid token
+func tokenOwner(secret string) string {
+	if !isToken(secret) {
+		return ""
+	}
+	if zt, err := lookupToken(secret); err == nil {
+		return zt.Owner
+	}
+	return ""
+}
+
+// recordApproval keeps the approval with the task record
+func recordApproval(t *Task, record *taskRecord, approval TaskApproval) {
+	record.Lock()
+	record.zt.Approval = &approval
+	record.Unlock()
+	record.update(t)
+	record.save()
+}
+
+// awaitApproval blocks the task of record, asked for with auth, until a second person approves it if it changes a
+// protected env. Returns an error if the task was rejected or no one approved it in time.
+func awaitApproval(t *Task, record *taskRecord, auth *ManagerAuthArg) error {
+	zt := record.zt // only the fields that never change are read
+	env := protectedEnv(zt.Name, zt.App, zt.Env)
+	if env == "" {
+		return nil
+	}
+	now := time.Now()
+	za := &datamodel.ZkApproval{TaskApproval{
+		ID:          zt.ID,
+		Name:        zt.Name,
+		Description: zt.Description,
+		User:        zt.User,
+		Owner:       tokenOwner(auth.Secret),
+		App:         zt.App,
+		Env:         env,
+		State:       approvalPending,
+		Requested:   now,
+		Expires:     now.Add(approvalTimeout),
+	}}
+	if err := za.Save(); err != nil {
+		return err
+	}
+	defer za.Delete()
+	t.LogStatus("Waiting for a second person to approve changing protected env %s", env)
+	recordApproval(t, record, za.TaskApproval)
+	if err := notifyApprovers(&za.TaskApproval); err != nil {
+		log.Printf("[RPC][Approval] could not notify approvers of task %s: %s", zt.ID, err)
+	}
+	for {
+		current, watch, err := datamodel.WatchApproval(zt.ID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case current.State == approvalApproved:
+			t.LogStatus("Approved by %s", current.Approver)
+			recordApproval(t, record, current.TaskApproval)
+			return nil
+		case current.State == approvalRejected:
+			recordApproval(t, record, current.TaskApproval)
+			return errors.New("Rejected by " + current.Approver)
+		case !time.Now().Before(current.Expires):
+			current.State = approvalExpired
+			current.Decided = time.Now()
+			recordApproval(t, record, current.TaskApproval)
+			return errors.New(fmt.Sprintf("No one approved changing protected env %s within %s", env,
+				approvalTimeout))
+		}
+		select {
+		case <-watch:
+		case <-time.After(current.Expires.Sub(time.Now())):
+		}
+	}
+}
+
+type ApprovalRequestTemplate struct {
+	ManagerCName string
+	TaskApproval
+}
+
+// notifyApprovers mails the app's team (and the configured cc) that a task is waiting for approval
+func notifyApprovers(approval *TaskApproval) error {
+	to := []string{}
+	if approval.App != "" {
+		if zkApp, err := datamodel.GetApp(approval.App); err == nil && zkApp.Email != "" {
+			to = append(to, zkApp.Email)
+		}
+	}
+	subject := fmt.Sprintf("[Atlantis] %s is waiting for approval to change %s", approval.Name, approval.Env)
+	tmpl := template.Must(template.New("approval_request").Parse(`
+{{.User}} wants to run the following {{.Name}} in the protected environment '{{.Env}}':
+
+{{.Description}}
+
+It will not run until someone else approves it, either with
+
+  atlantis-manager approve {{.ID}}
+
+or by POSTing to https://{{.ManagerCName}}/approvals/{{.ID}}/approve
+
+The request expires at {{.Expires}}.
+`))
+	myself, err := datamodel.GetManager(Region, Host)
+	if err != nil {
+		return err
+	}
+	buf := bytes.NewBuffer([]byte{})
+	tmpl.Execute(buf, ApprovalRequestTemplate{myself.ManagerCName, *approval})
+	return smtp.SendMail(to, subject, buf.String())
+}
+
+// listApprovals returns the approvals that are still pending, cleaning up the ones whose task is gone or done
+func listApprovals() []*TaskApproval {
+	ids, err := datamodel.ListApprovals()
+	if err != nil {
+		return []*TaskApproval{}
+	}
+	approvals := []*TaskApproval{}
+	for _, id := range ids {
+		za, err := datamodel.GetApproval(id)
+		if err != nil {
+			continue
+		}
+		if zt, err := datamodel.GetTask(id); err != nil || zt.Done {
+			za.Delete()
+			continue
+		}
+		if za.State == approvalPending && time.Now().Before(za.Expires) {
+			approvals = append(approvals, &za.TaskApproval)
+		}
+	}
+	return approvals
+}
+
+// ----------------------------------------------------------------------------------------------------------
+// Approve, List Approvals
+// ----------------------------------------------------------------------------------------------------------
+
+type ListApprovalsExecutor struct {
+	arg   ManagerListApprovalsArg
+	reply *ManagerListApprovalsReply
+}
+
+func (e *ListApprovalsExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *ListApprovalsExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *ListApprovalsExecutor) Description() string {
+	return "[" + e.arg.ManagerAuthArg.User + "]"
+}
+
+func (e *ListApprovalsExecutor) Authorize() error {
+	return SimpleAuthorize(&e.arg.ManagerAuthArg)
+}
+
+func (e *ListApprovalsExecutor) Execute(t *Task) error {
+	e.reply.Approvals = listApprovals()
+	e.reply.Status = StatusOk
+	return nil
+}
+
+type ApproveTaskExecutor struct {
+	arg   ManagerApproveTaskArg
+	reply *ManagerApproveTaskReply
+}
+
+func (e *ApproveTaskExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *ApproveTaskExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *ApproveTaskExecutor) Description() string {
+	if e.arg.Reject {
+		return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] reject %s", e.arg.ID)
+	}
+	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] approve %s", e.arg.ID)
+}
+
+// Authorize lets anyone but the requester (or the owner of the token they used) approve a task if they could have
+// run it themselves. API tokens can't approve anything, the point being that a second person looks at it.
+func (e *ApproveTaskExecutor) Authorize() error {
+	if isToken(e.arg.ManagerAuthArg.Secret) {
+		return errorOf(ErrForbidden, "API tokens cannot approve tasks")
+	}
+	if err := SimpleAuthorize(&e.arg.ManagerAuthArg); err != nil {
+		return err
+	}
+	za, err := datamodel.GetApproval(e.arg.ID)
+	if err != nil {
+		return errorOf(ErrNotFound, "No pending approval for task "+e.arg.ID)
+	}
+	if za.User == e.arg.ManagerAuthArg.User || (za.Owner != "" && za.Owner == e.arg.ManagerAuthArg.User) {
+		return errorOf(ErrForbidden, "You cannot approve your own task")
+	}
+	return AuthorizeAction(&e.arg.ManagerAuthArg, approvalAction(za.Name), za.App, za.Env)
+}
+
+func (e *ApproveTaskExecutor) Execute(t *Task) error {
+	if e.arg.ID == "" {
+		return errorOf(ErrInvalid, "Please specify a task id")
+	}
+	za, err := datamodel.GetApproval(e.arg.ID)
+	if err != nil {
+		return errorOf(ErrNotFound, "No pending approval for task "+e.arg.ID)
+	}
+	if za.State != approvalPending || !time.Now().Before(za.Expires) {
+		return errors.New(fmt.Sprintf("Task %s is no longer waiting for approval", e.arg.ID))
+	}
+	za.State = approvalApproved
+	if e.arg.Reject {
+		za.State = approvalRejected
+	}
+	za.Approver = e.arg.ManagerAuthArg.User
+	za.Decided = time.Now()
+	if err := za.Save(); err != nil {
+		return err
+	}
+	e.reply.Approval = &za.TaskApproval
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) ListApprovals(arg ManagerListApprovalsArg, reply *ManagerListApprovalsReply) error {
+	return NewTask("ListApprovals", &ListApprovalsExecutor{arg, reply}).Run()
+}
+
+func (m *ManagerRPC) ApproveTask(arg ManagerApproveTaskArg, reply *ManagerApproveTaskReply) error {
+	return NewAuditedTask("ApproveTask", &ApproveTaskExecutor{arg, reply}).Run()
+}
@@ -0,0 +1,362 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/common"
+	. "atlantis/manager/constant"
+	"atlantis/manager/datamodel"
+	. "atlantis/manager/rpc/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	auditOk     = "ok"
+	auditDenied = "denied"
+	auditError  = "error"
+	auditIDSize = 16
+)
+
+// AuditSink is where the audit log is kept. Entries are only ever appended.
+type AuditSink interface {
+	Append(entry *AuditEntry) error
+	// Scan calls fn with each entry from since to until, newest first, until fn returns false. A zero time leaves
+	// that end open. Sinks may pass entries from around the range too, so fn still has to check the time.
+	Scan(since, until time.Time, fn func(entry *AuditEntry) bool) error
+}
+
+var AuditLog AuditSink = ZkAuditSink{}
+
+// InitAudit picks where the audit log is kept: in zookeeper, shared by the region, or in a local file. Zookeeper
+// keeps the log by day and, unless retention is 0, deletes the days older than retention every hour; copy them
+// elsewhere first, e.g. with the audit RPC, if they must be kept for longer. The file sink is never pruned.
+func InitAudit(sink, file string, retention time.Duration) error {
+	switch sink {
+	case "zookeeper":
+		AuditLog = ZkAuditSink{}
+		if count, err := datamodel.MoveAuditEntries(); err != nil {
+			log.Printf("[RPC][Audit] could not move audit entries into their days: %s", err)
+		} else if count > 0 {
+			log.Printf("[RPC][Audit] moved %d audit entries into their days", count)
+		}
+		if retention > 0 {
+			go pruneAudit(retention)
+		}
+	case "file":
+		fileSink, err := NewFileAuditSink(file)
+		if err != nil {
+			return err
+		}
+		AuditLog = fileSink
+	default:
+		return errors.New("Unknown audit sink " + sink + ", use zookeeper or file")
+	}
+	return nil
+}
+
+func pruneAudit(retention time.Duration) {
+	for {
+		if count, err := datamodel.PruneAuditDays(retention); err != nil {
+			log.Printf("[RPC][Audit] could not prune the audit log: %s", err)
+		} else if count > 0 {
+			log.Printf("[RPC][Audit] pruned %d days of the audit log", count)
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+// ZkAuditSink keeps the audit log in zookeeper as sequential nodes, by day
+type ZkAuditSink struct{}
+
+func (s ZkAuditSink) Append(entry *AuditEntry) error {
+	return datamodel.AppendAuditEntry(entry)
+}
+
+// Scan only reads the days from since to until
+func (s ZkAuditSink) Scan(since, until time.Time, fn func(entry *AuditEntry) bool) error {
+	days, err := datamodel.ListAuditDays(since, until)
+	if err != nil {
+		return err
+	}
+	for i := len(days) - 1; i >= 0; i-- {
+		ids, err := datamodel.ListAuditEntries(days[i])
+		if err != nil {
+			continue // pruned since we listed the days
+		}
+		for j := len(ids) - 1; j >= 0; j-- {
+			entry, err := datamodel.GetAuditEntry(ids[j])
+			if err != nil {
+				continue
+			}
+			if !fn(entry) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// FileAuditSink keeps the audit log in a local file, one json entry per line
+type FileAuditSink struct {
+	sync.Mutex
+	path string
+}
+
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+	return &FileAuditSink{path: path}, nil
+}
+
+func (s *FileAuditSink) Append(entry *AuditEntry) error {
+	entry.ID = CreateRandomID(auditIDSize)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+func (s *FileAuditSink) Scan(since, until time.Time, fn func(entry *AuditEntry) bool) error {
+	s.Lock()
+	data, err := ioutil.ReadFile(s.path)
+	s.Unlock()
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		entry := &AuditEntry{}
+		if err := json.Unmarshal([]byte(lines[i]), entry); err != nil {
+			continue
+		}
+		if !fn(entry) {
+			break
+		}
+	}
+	return nil
+}
+
+// redactRequest returns request as json with the values of sensitive fields masked
+func redactRequest(request interface{}) string {
+	data, err := json.Marshal(Redact(request))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// executorAuth returns the credentials of the request that e runs
+func executorAuth(e TaskExecutor) ManagerAuthArg {
+	request := e.Request()
+	if request == nil {
+		return ManagerAuthArg{}
+	}
+	if reflect.TypeOf(request).Kind() != reflect.Ptr {
+		ptr := reflect.New(reflect.TypeOf(request))
+		ptr.Elem().Set(reflect.ValueOf(request))
+		request = ptr.Interface()
+	}
+	if auth := requestAuthArg(request); auth != nil {
+		return *auth
+	}
+	return ManagerAuthArg{}
+}
+
+// auditSubject returns the app and env that the task e runs is about, if any
+func auditSubject(e TaskExecutor) (app, env string) {
+	_, app, env, _ = taskSubject(e)
+	value := reflect.Indirect(reflect.ValueOf(e.Request()))
+	if value.Kind() != reflect.Struct {
+		return
+	}
+	if field := value.FieldByName("App"); app == "" && field.IsValid() && field.Kind() == reflect.String {
+		app = field.String()
+	}
+	if field := value.FieldByName("Env"); env == "" && field.IsValid() && field.Kind() == reflect.String {
+		env = field.String()
+	}
+	return
+}
+
+// auditEntry describes the outcome of the task named name that e runs
+func auditEntry(name string, e TaskExecutor, app, env string, err error, outcome string) *AuditEntry {
+	auth := executorAuth(e)
+	entry := &AuditEntry{
+		Time:    time.Now(),
+		User:    auth.User,
+		Source:  auth.Source,
+		Action:  name,
+		App:     app,
+		Env:     env,
+		Request: redactRequest(e.Request()),
+		Outcome: outcome,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+func recordAudit(entry *AuditEntry) {
+	if err := AuditLog.Append(entry); err != nil {
+		log.Printf("[RPC][Audit] could not record %s by %s: %s", entry.Action, entry.User, err)
+	}
+}
+
+// auditExecutor writes who ran the mutating task it runs, from where, and how it went to the audit log. That
+// includes requests that were not authorized.
+type auditExecutor struct {
+	TaskExecutor
+	name string
+}
+
+// NewAuditedTask creates a task that is written to the audit log. Use it for tasks that change something.
+func NewAuditedTask(name string, e TaskExecutor) *Task {
+	return NewTask(name, &auditExecutor{e, name})
+}
+
+func (e *auditExecutor) Authorize() error {
+	err := e.TaskExecutor.Authorize()
+	if err != nil {
+		app, env := auditSubject(e.TaskExecutor)
+		recordAudit(auditEntry(e.name, e.TaskExecutor, app, env, err, auditDenied))
+	}
+	return err
+}
+
+func (e *auditExecutor) Execute(t *Task) error {
+	// the subject may be gone afterwards, e.g. the container of a teardown
+	app, env := auditSubject(e.TaskExecutor)
+	err := e.TaskExecutor.Execute(t)
+	outcome := auditOk
+	if err != nil {
+		outcome = auditError
+	}
+	entry := auditEntry(e.name, e.TaskExecutor, app, env, err, outcome)
+	entry.TaskID = t.ID
+	recordAudit(entry)
+	return err
+}
+
+// auditMatches returns true if entry matches the filters in arg
+func auditMatches(arg *ManagerAuditArg, entry *AuditEntry) bool {
+	if arg.AuditUser != "" && entry.User != arg.AuditUser {
+		return false
+	}
+	if arg.Action != "" && entry.Action != arg.Action {
+		return false
+	}
+	if arg.App != "" && entry.App != arg.App {
+		return false
+	}
+	if arg.Env != "" && entry.Env != arg.Env {
+		return false
+	}
+	if !arg.Since.IsZero() && entry.Time.Before(arg.Since) {
+		return false
+	}
+	if !arg.Until.IsZero() && entry.Time.After(arg.Until) {
+		return false
+	}
+	return true
+}
+
+// queryAudit returns the newest entries in sink that match arg, up to its limit
+func queryAudit(arg *ManagerAuditArg, sink AuditSink) ([]*AuditEntry, error) {
+	limit := arg.Limit
+	if limit <= 0 {
+		limit = DefaultAuditQueryLimit
+	} else if limit > MaxAuditQueryLimit {
+		limit = MaxAuditQueryLimit
+	}
+	entries := []*AuditEntry{}
+	err := sink.Scan(arg.Since, arg.Until, func(entry *AuditEntry) bool {
+		if !arg.Since.IsZero() && entry.Time.Before(arg.Since) {
+			return false // everything after this is older still
+		}
+		if auditMatches(arg, entry) {
+			entries = append(entries, entry)
+		}
+		return len(entries) < limit
+	})
+	return entries, err
+}
+
+// ----------------------------------------------------------------------------------------------------------
+// Audit
+// ----------------------------------------------------------------------------------------------------------
+
+type AuditExecutor struct {
+	arg   ManagerAuditArg
+	reply *ManagerAuditReply
+}
+
+func (e *AuditExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *AuditExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *AuditExecutor) Description() string {
+	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] user: %s, action: %s, app: %s, env: %s", e.arg.AuditUser,
+		e.arg.Action, e.arg.App, e.arg.Env)
+}
+
+// Authorize lets superusers see the whole audit log, and everyone else the entries of the apps they may read
+func (e *AuditExecutor) Authorize() error {
+	if err := SimpleAuthorize(&e.arg.ManagerAuthArg); err != nil {
+		return err
+	}
+	if authorizeSuperUser(&e.arg.ManagerAuthArg) == nil {
+		return nil
+	}
+	if e.arg.App == "" {
+		return errorOf(ErrForbidden, "Please specify an app; only superusers can see the whole audit log")
+	}
+	return AuthorizeAction(&e.arg.ManagerAuthArg, "read", e.arg.App, e.arg.Env)
+}
+
+func (e *AuditExecutor) Execute(t *Task) (err error) {
+	if e.reply.Entries, err = queryAudit(&e.arg, AuditLog); err != nil {
+		e.reply.Status = StatusError
+		return err
+	}
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) Audit(arg ManagerAuditArg, reply *ManagerAuditReply) error {
+	return NewTask("Audit", &AuditExecutor{arg, reply}).Run()
+}
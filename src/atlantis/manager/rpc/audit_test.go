@@ -0,0 +1,101 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/manager/rpc/types"
+	. "github.com/adjust/gocheck"
+	"path"
+	"time"
+)
+
+type AuditSuite struct{}
+
+var _ = Suite(&AuditSuite{})
+
+func (s *AuditSuite) TestRedactRequest(c *C) {
+	arg := ManagerDeployArg{ManagerAuthArg: ManagerAuthArg{"alice", "hunter2", "s3cr3t", "10.0.0.1:5000"},
+		App: "app", Sha: "sha", Env: "prod"}
+	redacted := redactRequest(arg)
+	c.Assert(redacted, Not(Matches), ".*hunter2.*")
+	c.Assert(redacted, Not(Matches), ".*s3cr3t.*")
+	c.Assert(redacted, Matches, `.*"User":"alice".*`)
+	c.Assert(redacted, Matches, `.*"Source":"10.0.0.1:5000".*`)
+	c.Assert(redacted, Matches, `.*"Env":"prod".*`)
+	c.Assert(redactRequest(map[string]string{"Secret": ""}), Equals, `{"Secret":""}`)
+}
+
+func (s *AuditSuite) TestRequestAuthArg(c *C) {
+	arg := &ManagerTeardownArg{ManagerAuthArg: ManagerAuthArg{User: "alice"}, App: "app"}
+	auth := requestAuthArg(arg)
+	c.Assert(auth, Not(IsNil))
+	auth.Source = "10.0.0.1:5000"
+	c.Assert(arg.ManagerAuthArg.Source, Equals, "10.0.0.1:5000")
+	bare := &ManagerAuthArg{User: "bob"}
+	c.Assert(requestAuthArg(bare), Equals, bare)
+	c.Assert(requestAuthArg(&struct{ Name string }{}), IsNil)
+	c.Assert(requestAuthArg(ManagerAuthArg{}), IsNil)
+}
+
+func (s *AuditSuite) TestQueryAudit(c *C) {
+	sink, err := NewFileAuditSink(path.Join(c.MkDir(), "audit.log"))
+	c.Assert(err, IsNil)
+	start := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	entries := []*AuditEntry{
+		{Time: start, User: "alice", Action: "Deploy", App: "app", Env: "prod", Outcome: auditOk},
+		{Time: start.Add(time.Hour), User: "bob", Action: "Teardown", App: "app", Env: "prod", Outcome: auditDenied},
+		{Time: start.Add(2 * time.Hour), User: "alice", Action: "Teardown", App: "other", Env: "dev", Outcome: auditOk},
+	}
+	for _, entry := range entries {
+		c.Assert(sink.Append(entry), IsNil)
+	}
+	actions := func(arg ManagerAuditArg) []string {
+		found, err := queryAudit(&arg, sink)
+		c.Assert(err, IsNil)
+		users := []string{}
+		for _, entry := range found {
+			users = append(users, entry.User+":"+entry.Action)
+		}
+		return users
+	}
+	c.Assert(actions(ManagerAuditArg{}), DeepEquals, []string{"alice:Teardown", "bob:Teardown", "alice:Deploy"})
+	c.Assert(actions(ManagerAuditArg{AuditUser: "alice"}), DeepEquals, []string{"alice:Teardown", "alice:Deploy"})
+	c.Assert(actions(ManagerAuditArg{Action: "Teardown", App: "app"}), DeepEquals, []string{"bob:Teardown"})
+	c.Assert(actions(ManagerAuditArg{Env: "prod", Limit: 1}), DeepEquals, []string{"bob:Teardown"})
+	c.Assert(actions(ManagerAuditArg{Since: start.Add(time.Hour)}), DeepEquals,
+		[]string{"alice:Teardown", "bob:Teardown"})
+	c.Assert(actions(ManagerAuditArg{Until: start.Add(time.Hour)}), DeepEquals,
+		[]string{"bob:Teardown", "alice:Deploy"})
+}
+
+type rangeAuditSink struct {
+	since, until time.Time
+}
+
+func (s *rangeAuditSink) Append(entry *AuditEntry) error {
+	return nil
+}
+
+func (s *rangeAuditSink) Scan(since, until time.Time, fn func(entry *AuditEntry) bool) error {
+	s.since, s.until = since, until
+	return nil
+}
+
+func (s *AuditSuite) TestQueryAuditRange(c *C) {
+	sink := &rangeAuditSink{}
+	since := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	until := since.AddDate(0, 0, 2)
+	_, err := queryAudit(&ManagerAuditArg{Since: since, Until: until}, sink)
+	c.Assert(err, IsNil)
+	c.Assert(sink.since.Equal(since), Equals, true)
+	c.Assert(sink.until.Equal(until), Equals, true)
+}
@@ -13,9 +13,8 @@ package rpc
 
 import (
 	. "atlantis/common"
-	"atlantis/manager/ldap"
+	"atlantis/manager/auth"
 	. "atlantis/manager/rpc/types"
-	"errors"
 )
 
 type Authorizer struct {
@@ -24,16 +23,27 @@ type Authorizer struct {
 	Secret   string
 }
 
+// Authenticate logs in with the configured auth provider. Bearer tokens say who they are for, so User may change.
 func (a *Authorizer) Authenticate() (err error) {
-	a.Secret, err = ldap.Login(a.User, a.Password, a.Secret)
+	a.User, a.Secret, err = auth.Login(a.User, a.Password, a.Secret)
+	if auth.IsInvalidCredentials(err) {
+		return errorOf(ErrUnauthenticated, err.Error())
+	}
 	return err
 }
 
 func SimpleAuthorize(AuthArg *ManagerAuthArg) error {
 	user, password, secret := AuthArg.Credentials()
-	auther := Authorizer{user, password, secret}
-	if err := auther.Authenticate(); err != nil {
-		return err
+	if isToken(secret) {
+		if err := authenticateToken(AuthArg); err != nil {
+			return err
+		}
+	} else {
+		auther := Authorizer{user, password, secret}
+		if err := auther.Authenticate(); err != nil {
+			return err
+		}
+		AuthArg.User = auther.User
 	}
 	// if superuser only file exists, this should authorize superusers only.
 	if superUserOnly {
@@ -52,7 +62,7 @@ func AuthorizeTeamAdmin(AuthArg *ManagerAuthArg, team string) error {
 	if err != nil {
 		return err
 	} else if !res.IsAdmin {
-		return errors.New("Not a Team Admin")
+		return errorOf(ErrForbidden, "Not a Team Admin")
 	}
 	return nil
 }
@@ -61,13 +71,17 @@ func AuthorizeApp(AuthArg *ManagerAuthArg, app string) error {
 	if err := SimpleAuthorize(AuthArg); err != nil {
 		return err
 	}
+	// API tokens were only given apps their creator could deploy
+	if isToken(AuthArg.Secret) {
+		return authorizeTokenApp(AuthArg, app)
+	}
 	var reply ManagerIsAppAllowedReply
 	arg := ManagerIsAppAllowedArg{ManagerAuthArg: *AuthArg, App: app, User: AuthArg.User}
 	err := NewTask("Authorizer-IsAppAllowed", &IsAppAllowedExecutor{arg, &reply}).Run()
 	if err != nil {
 		return err
 	} else if !reply.IsAllowed {
-		return errors.New("Not Authorized to Deploy App")
+		return errorOf(ErrForbidden, "Not Authorized to Deploy App")
 	}
 	return nil
 }
@@ -86,7 +100,7 @@ func authorizeSuperUser(authArg *ManagerAuthArg) error {
 	if err != nil {
 		return err
 	} else if !reply.IsSuperUser {
-		return errors.New("Not a Super User")
+		return errorOf(ErrForbidden, "Not a Super User")
 	}
 	return nil
 }
@@ -0,0 +1,124 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	aldap "atlantis/manager/ldap"
+	. "atlantis/manager/rpc/types"
+	"crypto/tls"
+	"errors"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------------------------------------------------
+// Client Certificates
+// ----------------------------------------------------------------------------------------------------------
+
+// Machines such as supervisors, routers and CI log in with a client certificate instead of a password. The CN of
+// the certificate is matched against the configured patterns and the machine acts as a user named after the CN
+// who is in the team of the role it matched, so policies and team apps grant roles what they may do. A role that is
+// the superuser group makes the machine a superuser.
+
+type certRole struct {
+	Pattern string // glob on the CN
+	Role    string
+}
+
+var (
+	certRoles    = []certRole{}
+	certSessions = struct {
+		sync.Mutex
+		secrets map[string]string // CN -> secret of its session
+	}{secrets: map[string]string{}}
+)
+
+// InitCertRoles reads the CN to role mapping, e.g. "supervisor-*=supervisor,ci.example.com=ci". The first pattern
+// that matches wins.
+func InitCertRoles(spec string) error {
+	roles := []certRole{}
+	for _, mapping := range strings.Split(spec, ",") {
+		if mapping = strings.TrimSpace(mapping); mapping == "" {
+			continue
+		}
+		parts := strings.SplitN(mapping, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return errors.New("Invalid client cert role " + mapping + ", use <cn pattern>=<role>")
+		}
+		if _, err := path.Match(parts[0], ""); err != nil {
+			return errors.New("Invalid client cert pattern " + parts[0])
+		}
+		roles = append(roles, certRole{parts[0], parts[1]})
+	}
+	certSessions.Lock()
+	certRoles = roles
+	certSessions.secrets = map[string]string{}
+	certSessions.Unlock()
+	return nil
+}
+
+// certRoleFor returns the role of the client certificate with common name cn.
+func certRoleFor(cn string) (string, bool) {
+	for _, role := range certRoles {
+		if match, _ := path.Match(role.Pattern, cn); match {
+			return role.Role, true
+		}
+	}
+	return "", false
+}
+
+// CertLogin returns the user and secret a connection with a verified client certificate acts as. ok is false if
+// the connection has no verified certificate or its CN has no role.
+func CertLogin(state *tls.ConnectionState) (user, secret string, ok bool, err error) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", "", false, nil
+	}
+	user = state.VerifiedChains[0][0].Subject.CommonName
+	certSessions.Lock()
+	defer certSessions.Unlock()
+	role, ok := certRoleFor(user)
+	if !ok {
+		return "", "", false, nil
+	}
+	secret = certSessions.secrets[user]
+	if secret == "" {
+		random, err := randomHex(tokenSecretSize)
+		if err != nil {
+			return "", "", false, err
+		}
+		secret = "cert_" + random
+		certSessions.secrets[user] = secret
+	}
+	// the session is only there so everything that looks up teams by session works; it is started again
+	// whenever it has expired
+	if aldap.Sessions.Get(user, secret) == nil {
+		session := &aldap.Session{User: user, Team: []string{role}, Expires: time.Now().Add(aldap.SessionTTL)}
+		if err := aldap.Sessions.Put(secret, session); err != nil {
+			return "", "", false, err
+		}
+	}
+	return user, secret, true, nil
+}
+
+// certCredentials makes auth act as the client certificate of conn if the request came without credentials.
+func certCredentials(auth *ManagerAuthArg, state *tls.ConnectionState) error {
+	if auth.Password != "" || auth.Secret != "" {
+		return nil
+	}
+	user, secret, ok, err := CertLogin(state)
+	if ok {
+		auth.User, auth.Secret = user, secret
+	}
+	return err
+}
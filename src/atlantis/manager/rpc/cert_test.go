@@ -0,0 +1,87 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	aldap "atlantis/manager/ldap"
+	. "atlantis/manager/rpc/types"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	. "github.com/adjust/gocheck"
+)
+
+type CertSuite struct{}
+
+var _ = Suite(&CertSuite{})
+
+func verifiedState(cn string) *tls.ConnectionState {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+	return &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains: [][]*x509.Certificate{{cert}}}
+}
+
+func (s *CertSuite) TestInitCertRoles(c *C) {
+	defer InitCertRoles("")
+	c.Assert(InitCertRoles("supervisor-*=supervisor, ci.example.com=ci,*=other"), IsNil)
+	role, ok := certRoleFor("supervisor-1.example.com")
+	c.Assert(ok, Equals, true)
+	c.Assert(role, Equals, "supervisor")
+	role, _ = certRoleFor("ci.example.com")
+	c.Assert(role, Equals, "ci")
+	role, _ = certRoleFor("router-1")
+	c.Assert(role, Equals, "other")
+
+	c.Assert(InitCertRoles("ci.example.com=ci"), IsNil)
+	_, ok = certRoleFor("router-1")
+	c.Assert(ok, Equals, false)
+	c.Assert(InitCertRoles("ci.example.com"), Not(IsNil))
+	c.Assert(InitCertRoles("=ci"), Not(IsNil))
+	c.Assert(InitCertRoles("[=ci"), Not(IsNil))
+}
+
+func (s *CertSuite) TestCertLogin(c *C) {
+	defer func(store aldap.SessionStore) { aldap.Sessions = store }(aldap.Sessions)
+	aldap.Sessions = aldap.NewMemorySessionStore()
+	defer InitCertRoles("")
+	c.Assert(InitCertRoles("ci.example.com=ci"), IsNil)
+
+	_, _, ok, _ := CertLogin(nil)
+	c.Assert(ok, Equals, false)
+	_, _, ok, _ = CertLogin(&tls.ConnectionState{})
+	c.Assert(ok, Equals, false)
+	_, _, ok, _ = CertLogin(verifiedState("stranger.example.com"))
+	c.Assert(ok, Equals, false)
+
+	user, secret, ok, err := CertLogin(verifiedState("ci.example.com"))
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+	c.Assert(user, Equals, "ci.example.com")
+	c.Assert(aldap.LookupTeam(user, secret), DeepEquals, []string{"ci"})
+	_, again, _, _ := CertLogin(verifiedState("ci.example.com"))
+	c.Assert(again, Equals, secret)
+
+	// the session is started again once it has expired
+	c.Assert(aldap.Sessions.Delete(user, secret), IsNil)
+	_, again, _, _ = CertLogin(verifiedState("ci.example.com"))
+	c.Assert(again, Equals, secret)
+	c.Assert(aldap.LookupTeam(user, secret), DeepEquals, []string{"ci"})
+
+	// credentials that were sent win over the certificate
+	auth := &ManagerAuthArg{User: "alice", Secret: "session"}
+	c.Assert(certCredentials(auth, verifiedState("ci.example.com")), IsNil)
+	c.Assert(auth.User, Equals, "alice")
+	auth = &ManagerAuthArg{User: "alice"}
+	c.Assert(certCredentials(auth, verifiedState("ci.example.com")), IsNil)
+	c.Assert(auth.User, Equals, "ci.example.com")
+	c.Assert(auth.Secret, Equals, secret)
+}
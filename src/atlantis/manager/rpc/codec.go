@@ -0,0 +1,131 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/manager/rpc/types"
+	"bufio"
+	"crypto/tls"
+	"encoding/gob"
+	"io"
+	"log"
+	"net"
+	"net/rpc"
+	"reflect"
+	"strings"
+)
+
+// tokenCodec is net/rpc's gob codec, except that it knows which RPC a request is for and where it came from while
+// reading its arguments. This is the only place that does, so it is where API tokens are held to their operations,
+// where requests get their Source, where requests without credentials act as the client certificate and where
+// requests are rate limited.
+type tokenCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	method string
+	source string
+	tls    *tls.Conn
+	closed bool
+}
+
+func newTokenCodec(conn net.Conn) rpc.ServerCodec {
+	buf := bufio.NewWriter(conn)
+	tlsConn, _ := conn.(*tls.Conn)
+	return &tokenCodec{rwc: conn, dec: gob.NewDecoder(conn), enc: gob.NewEncoder(buf), encBuf: buf,
+		source: conn.RemoteAddr().String(), tls: tlsConn}
+}
+
+// requestAuthArg finds the credentials in the request that body points to. Requests either are or embed a
+// ManagerAuthArg. Returns nil if body has no credentials.
+func requestAuthArg(body interface{}) *ManagerAuthArg {
+	value := reflect.ValueOf(body)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return nil
+	}
+	if auth, ok := body.(*ManagerAuthArg); ok {
+		return auth
+	}
+	value = value.Elem()
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	if field := value.FieldByName("ManagerAuthArg"); field.IsValid() {
+		if auth, ok := field.Addr().Interface().(*ManagerAuthArg); ok {
+			return auth
+		}
+	}
+	return nil
+}
+
+func (c *tokenCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := c.dec.Decode(r); err != nil {
+		return err
+	}
+	c.method = r.ServiceMethod[strings.LastIndex(r.ServiceMethod, ".")+1:]
+	return nil
+}
+
+// An error here fails just this request; net/rpc goes on to read the next one.
+func (c *tokenCodec) ReadRequestBody(body interface{}) error {
+	if err := c.dec.Decode(body); err != nil || body == nil {
+		return err
+	}
+	auth := requestAuthArg(body)
+	if auth == nil {
+		return LimitRequest(rpcRateClass(c.method), &ManagerAuthArg{Source: c.source})
+	}
+	auth.Source = c.source // never trust the client with this
+	if c.tls != nil {
+		// the handshake is done by now, the request was read through it
+		state := c.tls.ConnectionState()
+		if err := certCredentials(auth, &state); err != nil {
+			return err
+		}
+	}
+	if err := LimitRequest(rpcRateClass(c.method), auth); err != nil {
+		return err
+	}
+	return AuthorizeTokenOperation(auth.Secret, c.method)
+}
+
+func (c *tokenCodec) WriteResponse(r *rpc.Response, body interface{}) (err error) {
+	if err = c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			// gob couldn't encode the header. Should not happen, so if it does, shut down the connection to
+			// signal that the connection is broken.
+			log.Println("[RPC] error encoding response:", err)
+			c.Close()
+		}
+		return
+	}
+	if err = c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			// Was a gob problem encoding the body but the header has been written. Shut down the connection to
+			// signal that the connection is broken.
+			log.Println("[RPC] error encoding body:", err)
+			c.Close()
+		}
+		return
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *tokenCodec) Close() error {
+	if c.closed {
+		// Only call c.rwc.Close once; otherwise the semantics are undefined.
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
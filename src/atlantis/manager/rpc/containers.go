@@ -17,9 +17,10 @@ import (
 	. "atlantis/manager/rpc/types"
 	"atlantis/manager/supervisor"
 	. "atlantis/supervisor/rpc/types"
-	"errors"
 	"fmt"
 	"sort"
+	"sync"
+	"time"
 )
 
 type GetContainerExecutor struct {
@@ -28,7 +29,7 @@ type GetContainerExecutor struct {
 }
 
 func (e *GetContainerExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *GetContainerExecutor) Result() interface{} {
@@ -41,13 +42,17 @@ func (e *GetContainerExecutor) Description() string {
 
 func (e *GetContainerExecutor) Execute(t *Task) (err error) {
 	if e.arg.ContainerID == "" {
-		return errors.New("Container ID is empty")
+		return errorOf(ErrInvalid, "Container ID is empty")
 	}
 	instance, err := datamodel.GetInstance(e.arg.ContainerID)
 	if err != nil {
 		e.reply.Status = StatusError
 		return err
 	}
+	if err = AuthorizeAction(&e.arg.ManagerAuthArg, "read", instance.App, instance.Env); err != nil {
+		e.reply.Status = StatusError
+		return err
+	}
 	var ihReply *SupervisorGetReply
 	ihReply, err = supervisor.Get(instance.Host, e.arg.ContainerID)
 	if err != nil {
@@ -62,10 +67,14 @@ func (e *GetContainerExecutor) Execute(t *Task) (err error) {
 }
 
 func (e *GetContainerExecutor) Authorize() error {
+	// execute checks the app and env of the container
 	return SimpleAuthorize(&e.arg.ManagerAuthArg)
 }
 
 func (m *ManagerRPC) GetContainer(arg ManagerGetContainerArg, reply *ManagerGetContainerReply) error {
+	if arg.Aggregate {
+		return m.aggregateGetContainer(arg, reply)
+	}
 	return NewTask("GetContainer", &GetContainerExecutor{arg, reply}).Run()
 }
 
@@ -75,7 +84,7 @@ type ListContainersExecutor struct {
 }
 
 func (e *ListContainersExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListContainersExecutor) Result() interface{} {
@@ -97,37 +106,38 @@ func (e *ListContainersExecutor) Execute(t *Task) error {
 		} else {
 			e.reply.Status = StatusOk
 		}
-		// filter by allowed app
-		if err := AuthorizeSuperUser(&e.arg.ManagerAuthArg); err == nil {
-			// if superuser, show everything
-			e.reply.ContainerIDs = allContainerIDs
-		} else {
-			// else only show what is allowed
-			allowedApps := GetAllowedApps(&e.arg.ManagerAuthArg, e.arg.ManagerAuthArg.User)
-			e.reply.ContainerIDs = []string{}
+		// only show what may be read
+		containerIDs := allContainerIDs
+		if readable := readFilter(&e.arg.ManagerAuthArg); readable != nil {
+			containerIDs = []string{}
 			for _, cid := range allContainerIDs {
-				if inst, err := datamodel.GetInstance(cid); err == nil && allowedApps[inst.App] {
-					e.reply.ContainerIDs = append(e.reply.ContainerIDs, cid)
+				if inst, err := datamodel.GetInstance(cid); err == nil && readable(inst.App, inst.Env) {
+					containerIDs = append(containerIDs, cid)
 				}
 			}
 		}
-		sort.Strings(e.reply.ContainerIDs)
-		return nil
+		e.reply.ContainerIDs, e.reply.NextCursor, err = pageList(containerIDs, e.arg.ListOptions, containerLabels)
+		if err != nil {
+			e.reply.Status = StatusError
+		}
+		return err
 	}
 	if e.arg.App == "" {
-		return errors.New("App is empty")
+		return errorOf(ErrInvalid, "App is empty")
 	}
 	if e.arg.Sha == "" {
-		return errors.New("Sha is empty")
+		return errorOf(ErrInvalid, "Sha is empty")
 	}
 	if e.arg.Env == "" {
-		return errors.New("Environment is empty")
+		return errorOf(ErrInvalid, "Environment is empty")
+	}
+	containerIDs, err := datamodel.ListInstances(e.arg.App, e.arg.Sha, e.arg.Env)
+	if err == nil {
+		e.reply.ContainerIDs, e.reply.NextCursor, err = pageList(containerIDs, e.arg.ListOptions, containerLabels)
 	}
-	e.reply.ContainerIDs, err = datamodel.ListInstances(e.arg.App, e.arg.Sha, e.arg.Env)
 	if err != nil {
 		e.reply.Status = StatusError
 	} else {
-		sort.Strings(e.reply.ContainerIDs)
 		e.reply.Status = StatusOk
 	}
 	return err
@@ -135,23 +145,250 @@ func (e *ListContainersExecutor) Execute(t *Task) error {
 
 func (e *ListContainersExecutor) Authorize() error {
 	if e.arg.App == "" && e.arg.Sha == "" && e.arg.Env == "" {
-		// execute will filter based on allowed apps
+		// execute will filter based on what may be read
 		return SimpleAuthorize(&e.arg.ManagerAuthArg)
 	}
-	return AuthorizeApp(&e.arg.ManagerAuthArg, e.arg.App)
+	return AuthorizeAction(&e.arg.ManagerAuthArg, "read", e.arg.App, e.arg.Env)
 }
 
 func (m *ManagerRPC) ListContainers(arg ManagerListContainersArg, reply *ManagerListContainersReply) error {
+	if arg.Aggregate {
+		return m.aggregateListContainers(arg, reply)
+	}
 	return NewTask("ListContainers", &ListContainersExecutor{arg, reply}).Run()
 }
 
+type QueryContainersExecutor struct {
+	arg   ManagerQueryContainersArg
+	reply *ManagerQueryContainersReply
+}
+
+func (e *QueryContainersExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *QueryContainersExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *QueryContainersExecutor) Description() string {
+	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] supervisor: %s, env: %s, team: %s", e.arg.Supervisor,
+		e.arg.Env, e.arg.Team)
+}
+
+func (e *QueryContainersExecutor) Execute(t *Task) error {
+	containerIDs, err := datamodel.QueryInstances(e.arg.Supervisor, e.arg.Env, e.arg.Team)
+	if err != nil {
+		e.reply.Status = StatusError
+		return err
+	}
+	e.reply.Status = StatusOk
+	readable := readFilter(&e.arg.ManagerAuthArg)
+	if readable == nil {
+		e.reply.ContainerIDs = containerIDs
+		return nil
+	}
+	// only show what may be read
+	e.reply.ContainerIDs = []string{}
+	for _, cid := range containerIDs {
+		if inst, err := datamodel.GetInstance(cid); err == nil && readable(inst.App, inst.Env) {
+			e.reply.ContainerIDs = append(e.reply.ContainerIDs, cid)
+		}
+	}
+	return nil
+}
+
+func (e *QueryContainersExecutor) Authorize() error {
+	// execute will filter based on what may be read
+	return SimpleAuthorize(&e.arg.ManagerAuthArg)
+}
+
+func (m *ManagerRPC) QueryContainers(arg ManagerQueryContainersArg, reply *ManagerQueryContainersReply) error {
+	return NewTask("QueryContainers", &QueryContainersExecutor{arg, reply}).Run()
+}
+
+// how many supervisors GetContainers asks at once when Live is set
+const liveSupervisorConcurrency = 16
+
+type GetContainersExecutor struct {
+	arg   ManagerGetContainersArg
+	reply *ManagerGetContainersReply
+}
+
+func (e *GetContainersExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *GetContainersExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *GetContainersExecutor) Description() string {
+	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] app: %s, sha: %s, env: %s, host: %s, zone: %s, live: %t",
+		e.arg.App, e.arg.Sha, e.arg.Env, e.arg.Host, e.arg.Zone, e.arg.Live)
+}
+
+func (e *GetContainersExecutor) Execute(t *Task) error {
+	// the supervisor and env indexes narrow things down before any instance is read
+	ids, err := datamodel.QueryInstances(e.arg.Host, e.arg.Env, "")
+	if err != nil {
+		e.reply.Status = StatusError
+		return err
+	}
+	// Authorize only checked the app and env asked for, and each instance may be in another env
+	readable := readFilter(&e.arg.ManagerAuthArg)
+	if readable == nil {
+		readable = func(app, env string) bool { return true }
+	}
+	instances := map[string]*datamodel.ZkInstance{}
+	matching := []string{}
+	for _, id := range ids {
+		inst, err := datamodel.GetInstance(id)
+		if err != nil || !instanceMatches(inst, &e.arg) || !readable(inst.App, inst.Env) {
+			continue
+		}
+		instances[id] = inst
+		matching = append(matching, id)
+	}
+	page, next, err := pageList(matching, e.arg.ListOptions, containerLabels)
+	if err != nil {
+		e.reply.Status = StatusError
+		return err
+	}
+	now := time.Now()
+	e.reply.Containers = make([]*ContainerDetails, len(page))
+	for i, id := range page {
+		e.reply.Containers[i] = containerDetails(instances[id], now)
+	}
+	if e.arg.Live {
+		addLiveDetails(e.reply.Containers, supervisor.List)
+	}
+	e.reply.NextCursor = next
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (e *GetContainersExecutor) Authorize() error {
+	if e.arg.App == "" {
+		// execute will filter based on what may be read
+		return SimpleAuthorize(&e.arg.ManagerAuthArg)
+	}
+	return AuthorizeAction(&e.arg.ManagerAuthArg, "read", e.arg.App, e.arg.Env)
+}
+
+func (m *ManagerRPC) GetContainers(arg ManagerGetContainersArg, reply *ManagerGetContainersReply) error {
+	return NewTask("GetContainers", &GetContainersExecutor{arg, reply}).Run()
+}
+
+// instanceMatches returns true if inst matches every non-empty filter of arg
+func instanceMatches(inst *datamodel.ZkInstance, arg *ManagerGetContainersArg) bool {
+	for _, filter := range [][2]string{{arg.App, inst.App}, {arg.Sha, inst.Sha}, {arg.Env, inst.Env},
+		{arg.Host, inst.Host}, {arg.Zone, inst.Zone}} {
+		if filter[0] != "" && filter[0] != filter[1] {
+			return false
+		}
+	}
+	return true
+}
+
+func containerDetails(inst *datamodel.ZkInstance, now time.Time) *ContainerDetails {
+	details := &ContainerDetails{
+		ID:          inst.ID,
+		App:         inst.App,
+		Sha:         inst.Sha,
+		Env:         inst.Env,
+		Host:        inst.Host,
+		Zone:        inst.Zone,
+		Port:        inst.Port,
+		Maintenance: inst.Maintenance,
+		Created:     inst.Created,
+	}
+	if inst.Manifest != nil {
+		details.CPUShares = inst.Manifest.CPUShares
+		details.MemoryLimit = inst.Manifest.MemoryLimit
+	}
+	if !inst.Created.IsZero() {
+		details.Age = now.Sub(inst.Created).Truncate(time.Second).String()
+	}
+	return details
+}
+
+// addLiveDetails asks the supervisors what they are running, with one call to list per supervisor and up to
+// liveSupervisorConcurrency calls at once, and adds the answer to each of details.
+func addLiveDetails(details []*ContainerDetails, list func(host string) (*SupervisorListReply, error)) {
+	byHost := map[string][]*ContainerDetails{}
+	for _, container := range details {
+		byHost[container.Host] = append(byHost[container.Host], container)
+	}
+	slots := make(chan bool, liveSupervisorConcurrency)
+	var wg sync.WaitGroup
+	for host, containers := range byHost {
+		wg.Add(1)
+		go func(host string, containers []*ContainerDetails) {
+			defer wg.Done()
+			slots <- true
+			reply, err := list(host)
+			<-slots
+			for _, container := range containers {
+				switch {
+				case err != nil:
+					container.LiveError = "Could not list containers on " + host + ": " + err.Error()
+				case reply.Containers[container.ID] == nil:
+					container.LiveError = "Not running on " + host
+				default:
+					container.Live = reply.Containers[container.ID]
+					container.Live.Host = host
+				}
+			}
+		}(host, containers)
+	}
+	wg.Wait()
+}
+
+type RebuildIndexesExecutor struct {
+	arg   ManagerRebuildIndexesArg
+	reply *ManagerRebuildIndexesReply
+}
+
+func (e *RebuildIndexesExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *RebuildIndexesExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *RebuildIndexesExecutor) Description() string {
+	return "[" + e.arg.ManagerAuthArg.User + "] RebuildIndexes"
+}
+
+func (e *RebuildIndexesExecutor) Execute(t *Task) (err error) {
+	t.LogStatus("Rebuilding Container Indexes")
+	e.reply.Indexed, err = datamodel.RebuildIndexes()
+	if err != nil {
+		e.reply.Status = StatusError
+		return err
+	}
+	t.Log("[RPC][RebuildIndexes] indexed %d containers", e.reply.Indexed)
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (e *RebuildIndexesExecutor) Authorize() error {
+	return AuthorizeSuperUser(&e.arg.ManagerAuthArg)
+}
+
+func (m *ManagerRPC) RebuildIndexes(arg ManagerRebuildIndexesArg, reply *ManagerRebuildIndexesReply) error {
+	return NewAuditedTask("RebuildIndexes", &RebuildIndexesExecutor{arg, reply}).Run()
+}
+
 type ListEnvsExecutor struct {
 	arg   ManagerListEnvsArg
 	reply *ManagerListEnvsReply
 }
 
 func (e *ListEnvsExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListEnvsExecutor) Result() interface{} {
@@ -185,10 +422,13 @@ func (e *ListEnvsExecutor) Authorize() error {
 	if e.arg.App == "" {
 		return SimpleAuthorize(&e.arg.ManagerAuthArg)
 	}
-	return AuthorizeApp(&e.arg.ManagerAuthArg, e.arg.App)
+	return AuthorizeAction(&e.arg.ManagerAuthArg, "read", e.arg.App, "")
 }
 
 func (m *ManagerRPC) ListEnvs(arg ManagerListEnvsArg, reply *ManagerListEnvsReply) error {
+	if arg.Aggregate {
+		return m.aggregateListEnvs(arg, reply)
+	}
 	return NewTask("ListEnvs", &ListEnvsExecutor{arg, reply}).Run()
 }
 
@@ -198,7 +438,7 @@ type ListShasExecutor struct {
 }
 
 func (e *ListShasExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListShasExecutor) Result() interface{} {
@@ -212,22 +452,27 @@ func (e *ListShasExecutor) Description() string {
 func (e *ListShasExecutor) Execute(t *Task) error {
 	var err error
 	if e.arg.App == "" {
-		return errors.New("App is empty")
+		return errorOf(ErrInvalid, "App is empty")
+	}
+	shas, err := datamodel.ListShas(e.arg.App)
+	if err == nil {
+		e.reply.Shas, e.reply.NextCursor, err = pageList(shas, e.arg.ListOptions, nil)
 	}
-	e.reply.Shas, err = datamodel.ListShas(e.arg.App)
 	if err != nil {
 		e.reply.Status = StatusError
 	} else {
-		sort.Strings(e.reply.Shas)
 		e.reply.Status = StatusOk
 	}
 	return err
 }
 func (e *ListShasExecutor) Authorize() error {
-	return AuthorizeApp(&e.arg.ManagerAuthArg, e.arg.App)
+	return AuthorizeAction(&e.arg.ManagerAuthArg, "read", e.arg.App, "")
 }
 
 func (m *ManagerRPC) ListShas(arg ManagerListShasArg, reply *ManagerListShasReply) error {
+	if arg.Aggregate {
+		return m.aggregateListShas(arg, reply)
+	}
 	return NewTask("ListShas", &ListShasExecutor{arg, reply}).Run()
 }
 
@@ -237,7 +482,7 @@ type ListAppsExecutor struct {
 }
 
 func (e *ListAppsExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListAppsExecutor) Result() interface{} {
@@ -251,25 +496,19 @@ func (e *ListAppsExecutor) Description() string {
 func (e *ListAppsExecutor) Execute(t *Task) error {
 	var err error
 	apps, err := datamodel.ListApps()
-	err = AuthorizeSuperUser(&e.arg.ManagerAuthArg)
-	if err == nil {
-		e.reply.Apps = apps
-	} else {
-		allowedApps := GetAllowedApps(&e.arg.ManagerAuthArg, e.arg.ManagerAuthArg.User)
-		appsCount := len(allowedApps)
-		totalAppsCount := len(apps)
-		e.reply.Apps = make([]string, 0, appsCount)
-		for i := 0; i < totalAppsCount; i++ {
-			if allowedApps[apps[i]] {
-				e.reply.Apps = append(e.reply.Apps, apps[i])
+	if readable := readFilter(&e.arg.ManagerAuthArg); readable != nil {
+		allowed := []string{}
+		for _, app := range apps {
+			if readable(app, "") {
+				allowed = append(allowed, app)
 			}
 		}
-		err = nil
+		apps = allowed
 	}
+	e.reply.Apps, e.reply.NextCursor, err = pageList(apps, e.arg.ListOptions, nil)
 	if err != nil {
 		e.reply.Status = StatusError
 	} else {
-		sort.Strings(e.reply.Apps)
 		e.reply.Status = StatusOk
 	}
 	return err
@@ -280,5 +519,8 @@ func (e *ListAppsExecutor) Authorize() error {
 }
 
 func (m *ManagerRPC) ListApps(arg ManagerListAppsArg, reply *ManagerListAppsReply) error {
+	if arg.Aggregate {
+		return m.aggregateListApps(arg, reply)
+	}
 	return NewTask("ListApps", &ListAppsExecutor{arg, reply}).Run()
 }
@@ -0,0 +1,67 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "github.com/adjust/gocheck"
+	"time"
+)
+
+type CronSuite struct{}
+
+var _ = Suite(&CronSuite{})
+
+func at(value string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func (s *CronSuite) TestParseCron(c *C) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		_, err := parseCron(spec)
+		c.Assert(err, Not(IsNil), Commentf("spec %q", spec))
+	}
+	cs, err := parseCron("0,30 9-17/4 * * 1-5")
+	c.Assert(err, IsNil)
+	c.Assert(cs.minute, Equals, uint64(1|1<<30))
+	c.Assert(cs.hour, Equals, uint64(1<<9|1<<13|1<<17))
+	c.Assert(cs.dow, Equals, uint64(1<<1|1<<2|1<<3|1<<4|1<<5))
+	cs, err = parseCron("@daily")
+	c.Assert(err, IsNil)
+	c.Assert(cs.minute, Equals, uint64(1))
+	c.Assert(cs.hour, Equals, uint64(1))
+}
+
+func (s *CronSuite) TestCronNext(c *C) {
+	for _, test := range []struct{ spec, after, next string }{
+		{"* * * * *", "2014-06-01 10:15", "2014-06-01 10:16"},
+		{"0 2 * * *", "2014-06-01 10:15", "2014-06-02 02:00"},
+		{"0 2 * * *", "2014-06-01 01:59", "2014-06-01 02:00"},
+		{"*/15 * * * *", "2014-06-01 10:50", "2014-06-01 11:00"},
+		{"30 4 1 * *", "2014-12-15 00:00", "2015-01-01 04:30"},
+		{"0 0 * * 0", "2014-06-02 00:00", "2014-06-08 00:00"}, // the 2nd is a Monday
+		{"0 0 * * 7", "2014-06-02 00:00", "2014-06-08 00:00"},
+		{"0 0 13 * 5", "2014-06-01 00:00", "2014-06-06 00:00"}, // a Friday comes before the 13th
+		{"0 0 29 2 *", "2014-03-01 00:00", "2016-02-29 00:00"},
+	} {
+		cs, err := parseCron(test.spec)
+		c.Assert(err, IsNil)
+		c.Assert(cs.Next(at(test.after)), Equals, at(test.next), Commentf("spec %q after %s", test.spec, test.after))
+	}
+	cs, err := parseCron("0 0 30 2 *")
+	c.Assert(err, IsNil)
+	c.Assert(cs.Next(at("2014-01-01 00:00")).IsZero(), Equals, true)
+}
@@ -30,7 +30,11 @@ type DeployExecutor struct {
 }
 
 func (e *DeployExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
+}
+
+func (e *DeployExecutor) authArg() *ManagerAuthArg {
+	return &e.arg.ManagerAuthArg
 }
 
 func (e *DeployExecutor) Result() interface{} {
@@ -45,19 +49,22 @@ func (e *DeployExecutor) Authorize() error {
 	if err := checkRole("deploys", "write"); err != nil {
 		return err
 	}
-	return SimpleAuthorize(&e.arg.ManagerAuthArg)
+	if e.arg.App == "" {
+		return SimpleAuthorize(&e.arg.ManagerAuthArg)
+	}
+	return AuthorizeAction(&e.arg.ManagerAuthArg, "deploy", e.arg.App, e.arg.Env)
 }
 
 func (e *DeployExecutor) Execute(t *Task) error {
 	// error checking
 	if e.arg.App == "" {
-		return errors.New("Please specify an app")
+		return errorOf(ErrInvalid, "Please specify an app")
 	}
 	if e.arg.Sha == "" {
-		return errors.New("Please specify a sha")
+		return errorOf(ErrInvalid, "Please specify a sha")
 	}
 	if e.arg.Env == "" {
-		return errors.New("Please specify an environment")
+		return errorOf(ErrInvalid, "Please specify an environment")
 	}
 	if e.arg.CPUShares < 0 ||
 		(e.arg.CPUShares > 0 && e.arg.CPUShares != 1 && e.arg.CPUShares%CPUSharesIncrement != 0) {
@@ -70,7 +77,7 @@ func (e *DeployExecutor) Execute(t *Task) error {
 	// fetch the repo and root
 	app, err := datamodel.GetApp(e.arg.App)
 	if err != nil {
-		return errors.New("App " + e.arg.App + " is not registered: " + err.Error())
+		return errorOf(ErrNotFound, "App "+e.arg.App+" is not registered: "+err.Error())
 	}
 	
 	var manifest *Manifest
@@ -96,7 +103,6 @@ func (e *DeployExecutor) Execute(t *Task) error {
 		if len(e.arg.Manifest) > 0 {
 			//read manifest from request if present
 			t.LogStatus("Using manifest from request parameter to deploy")
-			t.LogStatus("Manifest parameter value: %s", e.arg.Manifest)
 
 			var f map[string]interface{}
 			if err := json.Unmarshal([]byte(e.arg.Manifest), &f); err != nil {
@@ -184,13 +190,17 @@ func (e *DeployExecutor) Execute(t *Task) error {
 	} else if manifest.Instances == 0 {
 		manifest.Instances = uint(1) // default to 1 instance
 	}
+	lockWait, err := parseLockTimeout(e.arg.LockTimeout)
+	if err != nil {
+		return err
+	}
 	if e.arg.Dev {
 		t.LogStatus("Deploy only one instance, ie Dev=true")
-		e.reply.Containers, err = devDeploy(&e.arg.ManagerAuthArg, manifest, e.arg.Sha, e.arg.Env, t)
+		e.reply.Containers, err = devDeploy(&e.arg.ManagerAuthArg, manifest, e.arg.Sha, e.arg.Env, lockWait, t)
 	} else {
 		t.LogStatus("Deploy instances on multi AZ, ie. Dev=false")
 		
-		e.reply.Containers, err = deploy(&e.arg.ManagerAuthArg, manifest, e.arg.Sha, e.arg.Env, t)
+		e.reply.Containers, err = deploy(&e.arg.ManagerAuthArg, manifest, e.arg.Sha, e.arg.Env, lockWait, t)
 	}
 	return err
 }
@@ -208,7 +218,7 @@ func interfaceArrayToStringArray(data []interface{}) []string {
 }
 
 func (m *ManagerRPC) Deploy(arg ManagerDeployArg, reply *AsyncReply) error {
-	return NewTask("Deploy", &DeployExecutor{arg, &ManagerDeployReply{}}).RunAsync(reply)
+	return NewPersistentTask("Deploy", &DeployExecutor{arg, &ManagerDeployReply{}}).RunAsync(reply)
 }
 
 type DeployContainerExecutor struct {
@@ -217,7 +227,11 @@ type DeployContainerExecutor struct {
 }
 
 func (e *DeployContainerExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
+}
+
+func (e *DeployContainerExecutor) authArg() *ManagerAuthArg {
+	return &e.arg.ManagerAuthArg
 }
 
 func (e *DeployContainerExecutor) Result() interface{} {
@@ -232,16 +246,16 @@ func (e *DeployContainerExecutor) Authorize() error {
 	if err := checkRole("deploys", "write"); err != nil {
 		return err
 	}
-	// app is authorized in deploy()
-	return SimpleAuthorize(&e.arg.ManagerAuthArg)
+	_, app, env, _ := taskSubject(e)
+	return AuthorizeAction(&e.arg.ManagerAuthArg, "deploy", app, env)
 }
 
 func (e *DeployContainerExecutor) Execute(t *Task) error {
 	if e.arg.ContainerID == "" {
-		return errors.New("Container ID is empty")
+		return errorOf(ErrInvalid, "Container ID is empty")
 	}
 	if e.arg.Instances <= 0 {
-		return errors.New("Instances should be > 0")
+		return errorOf(ErrInvalid, "Instances should be > 0")
 	}
 	instance, err := datamodel.GetInstance(e.arg.ContainerID)
 	if err != nil {
@@ -256,7 +270,7 @@ func (e *DeployContainerExecutor) Execute(t *Task) error {
 }
 
 func (m *ManagerRPC) DeployContainer(arg ManagerDeployContainerArg, reply *AsyncReply) error {
-	return NewTask("DeployContainer", &DeployContainerExecutor{arg, &ManagerDeployReply{}}).RunAsync(reply)
+	return NewPersistentTask("DeployContainer", &DeployContainerExecutor{arg, &ManagerDeployReply{}}).RunAsync(reply)
 }
 
 type CopyContainerExecutor struct {
@@ -265,7 +279,11 @@ type CopyContainerExecutor struct {
 }
 
 func (e *CopyContainerExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
+}
+
+func (e *CopyContainerExecutor) authArg() *ManagerAuthArg {
+	return &e.arg.ManagerAuthArg
 }
 
 func (e *CopyContainerExecutor) Result() interface{} {
@@ -280,16 +298,16 @@ func (e *CopyContainerExecutor) Authorize() error {
 	if err := checkRole("deploys", "write"); err != nil {
 		return err
 	}
-	// app is authorized in deploy()
-	return SimpleAuthorize(&e.arg.ManagerAuthArg)
+	_, app, env, _ := taskSubject(e)
+	return AuthorizeAction(&e.arg.ManagerAuthArg, "deploy", app, env)
 }
 
 func (e *CopyContainerExecutor) Execute(t *Task) error {
 	if e.arg.ContainerID == "" {
-		return errors.New("Container ID is empty")
+		return errorOf(ErrInvalid, "Container ID is empty")
 	}
 	if e.arg.ToHost == "" {
-		return errors.New("To Host is empty")
+		return errorOf(ErrInvalid, "To Host is empty")
 	}
 	cont, err := copyContainer(&e.arg.ManagerAuthArg, e.arg.ContainerID, e.arg.ToHost, t)
 	if err != nil {
@@ -300,7 +318,7 @@ func (e *CopyContainerExecutor) Execute(t *Task) error {
 }
 
 func (m *ManagerRPC) CopyContainer(arg ManagerCopyContainerArg, reply *AsyncReply) error {
-	return NewTask("CopyContainer", &CopyContainerExecutor{arg, &ManagerDeployReply{}}).RunAsync(reply)
+	return NewPersistentTask("CopyContainer", &CopyContainerExecutor{arg, &ManagerDeployReply{}}).RunAsync(reply)
 }
 
 type ResolveDepsExecutor struct {
@@ -309,7 +327,7 @@ type ResolveDepsExecutor struct {
 }
 
 func (e *ResolveDepsExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ResolveDepsExecutor) Result() interface{} {
@@ -347,7 +365,11 @@ type TeardownExecutor struct {
 }
 
 func (e *TeardownExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
+}
+
+func (e *TeardownExecutor) authArg() *ManagerAuthArg {
+	return &e.arg.ManagerAuthArg
 }
 
 func (e *TeardownExecutor) Result() interface{} {
@@ -366,10 +388,20 @@ func (e *TeardownExecutor) Authorize() error {
 	if e.arg.All {
 		return AuthorizeSuperUser(&e.arg.ManagerAuthArg)
 	}
-	if e.arg.App == "" {
-		return SimpleAuthorize(&e.arg.ManagerAuthArg)
+	_, app, env, _ := taskSubject(e)
+	envs := []string{env}
+	if env == "" && app != "" {
+		// tearing down every env of the app takes permission in each of them
+		if deployed, _ := appEnvs(app, e.arg.Sha); len(deployed) > 0 {
+			envs = deployed
+		}
 	}
-	return AuthorizeApp(&e.arg.ManagerAuthArg, e.arg.App)
+	for _, env := range envs {
+		if err := AuthorizeAction(&e.arg.ManagerAuthArg, "teardown", app, env); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (e *TeardownExecutor) Execute(t *Task) error {
@@ -377,27 +409,22 @@ func (e *TeardownExecutor) Execute(t *Task) error {
 	if err != nil {
 		return err
 	}
+	lockWait, err := parseLockTimeout(e.arg.LockTimeout)
+	if err != nil {
+		return err
+	}
+	var tl *datamodel.TeardownLock
 	if e.arg.All {
-		tl := datamodel.NewTeardownLock(t.ID)
-		if err := tl.Lock(); err != nil {
-			return err
-		}
-		defer tl.Unlock()
+		tl = datamodel.NewTeardownLock(t.ID, e.arg.User)
 	} else if e.arg.Env != "" {
-		tl := datamodel.NewTeardownLock(t.ID, e.arg.App, e.arg.Sha, e.arg.Env)
-		if err := tl.Lock(); err != nil {
-			return err
-		}
-		defer tl.Unlock()
+		tl = datamodel.NewTeardownLock(t.ID, e.arg.User, e.arg.App, e.arg.Sha, e.arg.Env)
 	} else if e.arg.Sha != "" {
-		tl := datamodel.NewTeardownLock(t.ID, e.arg.App, e.arg.Sha)
-		if err := tl.Lock(); err != nil {
-			return err
-		}
-		defer tl.Unlock()
+		tl = datamodel.NewTeardownLock(t.ID, e.arg.User, e.arg.App, e.arg.Sha)
 	} else if e.arg.App != "" {
-		tl := datamodel.NewTeardownLock(t.ID, e.arg.App)
-		if err := tl.Lock(); err != nil {
+		tl = datamodel.NewTeardownLock(t.ID, e.arg.User, e.arg.App)
+	}
+	if tl != nil {
+		if err := lockWithTimeout(tl, lockWait, t); err != nil {
 			return err
 		}
 		defer tl.Unlock()
@@ -444,7 +471,7 @@ func (e *TeardownExecutor) Execute(t *Task) error {
 }
 
 func (m *ManagerRPC) Teardown(arg ManagerTeardownArg, reply *AsyncReply) error {
-	return NewTask("Teardown", &TeardownExecutor{arg, &ManagerTeardownReply{}}).RunAsync(reply)
+	return NewPersistentTask("Teardown", &TeardownExecutor{arg, &ManagerTeardownReply{}}).RunAsync(reply)
 }
 
 func (m *ManagerRPC) CopyContainerResult(id string, result *ManagerDeployReply) error {
@@ -453,17 +480,17 @@ func (m *ManagerRPC) CopyContainerResult(id string, result *ManagerDeployReply)
 
 func (m *ManagerRPC) DeployResult(id string, result *ManagerDeployReply) error {
 	if id == "" {
-		return errors.New("ID empty")
+		return errorOf(ErrInvalid, "ID empty")
 	}
 	status, err := Tracker.Status(id)
-	if status.Status == StatusUnknown {
-		return errors.New("Unknown ID.")
+	if status == nil || status.Status == StatusUnknown {
+		return persistedResult(id, result, "Deploy", "CopyContainer")
 	}
 	if status.Name != "Deploy" && status.Name != "CopyContainer" {
-		return errors.New("ID is not a Deploy.")
+		return errorOf(ErrInvalid, "ID is not a Deploy.")
 	}
 	if !status.Done {
-		return errors.New("Deploy isn't done.")
+		return errorOf(ErrConflict, "Deploy isn't done.")
 	}
 	if status.Status == StatusError || err != nil {
 		return err
@@ -481,17 +508,17 @@ func (m *ManagerRPC) DeployResult(id string, result *ManagerDeployReply) error {
 
 func (m *ManagerRPC) TeardownResult(id string, result *ManagerTeardownReply) error {
 	if id == "" {
-		return errors.New("ID empty")
+		return errorOf(ErrInvalid, "ID empty")
 	}
 	status, err := Tracker.Status(id)
-	if status.Status == StatusUnknown {
-		return errors.New("Unknown ID.")
+	if status == nil || status.Status == StatusUnknown {
+		return persistedResult(id, result, "Teardown")
 	}
 	if status.Name != "Teardown" {
-		return errors.New("ID is not a Teardown.")
+		return errorOf(ErrInvalid, "ID is not a Teardown.")
 	}
 	if !status.Done {
-		return errors.New("Teardown isn't done.")
+		return errorOf(ErrConflict, "Teardown isn't done.")
 	}
 	if status.Status == StatusError || err != nil {
 		return err
@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"time"
   
 )
 
@@ -36,7 +37,7 @@ import (
 func deployContainer(auth *ManagerAuthArg, cont *Container, instances uint, t *Task) ([]*Container, error) {
 	manifest := cont.Manifest
 	manifest.Instances = instances
-	return deploy(auth, manifest, cont.Sha, cont.Env, t)
+	return deploy(auth, manifest, cont.Sha, cont.Env, 0, t)
 }
 
 func MergeDependerEnvData(dst *DependerEnvData, src *DependerEnvData) *DependerEnvData {
@@ -142,7 +143,8 @@ func ResolveDepValues(app string, zkEnv *datamodel.ZkEnv, names []string, encryp
 	return deps, nil
 }
 
-func validateDeploy(auth *ManagerAuthArg, manifest *Manifest, sha, env string, t *Task) (deps map[string]DepsType, err error) {
+func validateDeploy(auth *ManagerAuthArg, manifest *Manifest, sha, env string, lockWait time.Duration,
+	t *Task) (deps map[string]DepsType, err error) {
 	t.LogStatus("Validate Deploy")
 
 	
@@ -150,9 +152,9 @@ func validateDeploy(auth *ManagerAuthArg, manifest *Manifest, sha, env string, t
 		return nil, err
 	}
 
-	// authorize that we're allowed to use the app
-	if err = AuthorizeApp(auth, manifest.Name); err != nil {
-		return nil, errors.New("Permission Denied: " + err.Error())
+	// authorize that we're allowed to deploy the app to env
+	if err = AuthorizeAction(auth, "deploy", manifest.Name, env); err != nil {
+		return nil, err
 	}
 	// fetch the environment
 	t.LogStatus("Fetching Environment")
@@ -161,8 +163,8 @@ func validateDeploy(auth *ManagerAuthArg, manifest *Manifest, sha, env string, t
 		return nil, errors.New("Environment Error: " + err.Error())
 	}
 	// lock the deploy
-	dl := datamodel.NewDeployLock(t.ID, manifest.Name, sha, env)
-	if err := dl.Lock(); err != nil {
+	dl := datamodel.NewDeployLock(t.ID, auth.User, manifest.Name, sha, env)
+	if err := lockWithTimeout(dl, lockWait, t); err != nil {
 		return nil, err
 	}
 	defer dl.Unlock()
@@ -228,7 +230,7 @@ type DeployHostResult struct {
 	Error     error
 }
 
-func deployToHost(respCh chan *DeployHostResult, manifest *Manifest, sha, env, host string) {
+func deployToHost(respCh chan *DeployHostResult, manifest *Manifest, sha, env, host, zone string) {
 	instance, err := datamodel.CreateInstance(manifest.Name, sha, env, host)
 	if err != nil {
 		respCh <- &DeployHostResult{Host: host, Container: nil, Error: err}
@@ -248,6 +250,7 @@ func deployToHost(respCh chan *DeployHostResult, manifest *Manifest, sha, env, h
 	ihReply.Container.Host = host
 	instance.SetPort(ihReply.Container.PrimaryPort)
 	instance.SetManifest(ihReply.Container.Manifest)
+	instance.SetZone(zone)
 	AddAppShaToEnv(manifest.Name, sha, env)
 	respCh <- &DeployHostResult{Host: host, Container: ihReply.Container, Error: nil}
 }
@@ -277,7 +280,7 @@ func deployToZone(respCh chan *DeployZoneResult, deps map[string]DepsType, rawMa
 				// duplicate manifest and get deps
 				manifest := rawManifest.Dup()
 				manifest.Deps = deps[ihReply.Zone]
-				go deployToHost(respCh, manifest, sha, env, host)
+				go deployToHost(respCh, manifest, sha, env, host, ihReply.Zone)
 			}
 			hostNum++
 			if hostNum >= len(hosts) {
@@ -398,8 +401,9 @@ func deployToHostsInZones(deps map[string]DepsType, manifest *Manifest, sha, env
 	return deployedContainers, nil
 }
 
-func deploy(auth *ManagerAuthArg, manifest *Manifest, sha, env string, t *Task) ([]*Container, error) {
-	deps, err := validateDeploy(auth, manifest, sha, env, t)
+func deploy(auth *ManagerAuthArg, manifest *Manifest, sha, env string, lockWait time.Duration,
+	t *Task) ([]*Container, error) {
+	deps, err := validateDeploy(auth, manifest, sha, env, lockWait, t)
 	if err != nil {
 		return nil, err
 	}
@@ -413,9 +417,10 @@ func deploy(auth *ManagerAuthArg, manifest *Manifest, sha, env string, t *Task)
 	return deployToHostsInZones(deps, manifest, sha, env, hosts, AvailableZones, t)
 }
 
-func devDeploy(auth *ManagerAuthArg, manifest *Manifest, sha, env string, t *Task) ([]*Container, error) {
+func devDeploy(auth *ManagerAuthArg, manifest *Manifest, sha, env string, lockWait time.Duration,
+	t *Task) ([]*Container, error) {
 	manifest.Instances = 1 // set to 1 instance regardless of what came in
-	deps, err := validateDeploy(auth, manifest, sha, env, t)
+	deps, err := validateDeploy(auth, manifest, sha, env, lockWait, t)
 	if err != nil {
 		return nil, err
 	}
@@ -456,7 +461,7 @@ func copyContainer(auth *ManagerAuthArg, cid, toHost string, t *Task) (*Containe
 	manifest.Instances = 1
 
 	// validate and get deps
-	deps, err := validateDeploy(auth, manifest, inst.Sha, inst.Env, t)
+	deps, err := validateDeploy(auth, manifest, inst.Sha, inst.Env, 0, t)
 	if err != nil {
 		return nil, err
 	}
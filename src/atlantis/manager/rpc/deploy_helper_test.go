@@ -18,11 +18,13 @@ import (
 	"atlantis/manager/datamodel"
 	"atlantis/manager/dns"
 	"atlantis/manager/helper"
+	aldap "atlantis/manager/ldap"
 	. "atlantis/manager/rpc/types"
 	scrypto "atlantis/supervisor/crypto"
 	"fmt"
 	zookeeper "github.com/ghao-ooyala/gozk-recipes"
 	. "github.com/adjust/gocheck"
+	"time"
 )
 
 type DeployHelperSuite struct{}
@@ -108,3 +110,175 @@ func (s *DeployHelperSuite) TestResolveDepValues(c *C) {
 	c.Assert(deps["dev1"]["hello-go"].DataMap, Not(IsNil))
 	c.Assert(deps["dev1"]["hello-go"].DataMap["address"], Equals, fmt.Sprintf("internal-router.1.%s.suffix.com:%d", Region, datamodel.MinRouterPort))
 }
+
+func (s *DeployHelperSuite) TestJobCredentials(c *C) {
+	datamodel.Zk.RecursiveDelete(helper.GetBaseTokenPath())
+	job := &ScheduledJob{ID: "nightly", User: "alice", Name: "Deploy"}
+	session := &ManagerAuthArg{User: "alice", Secret: "session-secret"}
+	auth, err := jobCredentials(job, session, "app1", "prod")
+	c.Assert(err, IsNil)
+	// the session is never kept, the job gets a token that may only do what it does
+	c.Assert(auth.User, Equals, "alice")
+	c.Assert(isToken(auth.Secret), Equals, true)
+	c.Assert(job.TokenID, Not(Equals), "")
+	zt, err := lookupToken(auth.Secret)
+	c.Assert(err, IsNil)
+	c.Assert(zt.ID, Equals, job.TokenID)
+	c.Assert(zt.User, Equals, "alice")
+	c.Assert(zt.ServiceAccount, Equals, false)
+	c.Assert(zt.Apps, DeepEquals, []string{"app1"})
+	c.Assert(zt.Envs, DeepEquals, []string{"prod"})
+	c.Assert(zt.Operations, DeepEquals, []string{"Deploy"})
+	c.Assert(deleteJobToken(job), IsNil)
+	_, err = lookupToken(auth.Secret)
+	c.Assert(err, Not(IsNil))
+
+	// jobs scheduled with a token run with it
+	token := &ManagerAuthArg{User: "alice", Secret: auth.Secret}
+	other := &ScheduledJob{ID: "once", User: "alice", Name: "Deploy"}
+	auth, err = jobCredentials(other, token, "app1", "prod")
+	c.Assert(err, IsNil)
+	c.Assert(auth.Secret, Equals, token.Secret)
+	c.Assert(other.TokenID, Equals, "")
+}
+
+func (s *DeployHelperSuite) TestReadFilterToken(c *C) {
+	datamodel.Zk.RecursiveDelete(helper.GetBaseTokenPath())
+	datamodel.Zk.RecursiveDelete(helper.GetBasePolicyPath())
+	token := APIToken{ID: "readtoken", Name: "ci", User: "ci", ServiceAccount: true, Owner: "root",
+		Apps: []string{"app1"}, Envs: []string{"staging"}}
+	_, secret, err := saveToken(token)
+	c.Assert(err, IsNil)
+	readable := readFilter(&ManagerAuthArg{User: "ci", Secret: secret})
+	c.Assert(readable, Not(IsNil))
+	// containers of other apps and envs are hidden from a token scoped to one app and env
+	c.Assert(readable("app1", "staging"), Equals, true)
+	c.Assert(readable("app1", "prod"), Equals, false)
+	c.Assert(readable("app2", "staging"), Equals, false)
+	// apps are listed across envs
+	c.Assert(readable("app1", ""), Equals, true)
+	c.Assert(readable("app2", ""), Equals, false)
+}
+
+func (s *DeployHelperSuite) TestPoliciesFailClosed(c *C) {
+	datamodel.Zk.RecursiveDelete(helper.GetBaseTokenPath())
+	datamodel.Zk.RecursiveDelete(helper.GetBasePolicyPath())
+	token := APIToken{ID: "policytoken", Name: "ci", User: "ci", ServiceAccount: true, Owner: "root",
+		Apps: []string{"app1"}, Envs: []string{"staging"}}
+	_, secret, err := saveToken(token)
+	c.Assert(err, IsNil)
+	auth := &ManagerAuthArg{User: "ci", Secret: secret}
+	allowed, _ := explainAction(auth, "deploy", "app1", "staging")
+	c.Assert(allowed, Equals, true)
+	// a policy that can't be read might be the one that restricts app1, so nothing is allowed
+	_, err = datamodel.Zk.TouchAndSet(helper.GetBasePolicyPath("broken"), "not json")
+	c.Assert(err, IsNil)
+	allowed, reason := explainAction(auth, "deploy", "app1", "staging")
+	c.Assert(allowed, Equals, false)
+	c.Assert(reason, Matches, "could not read policies.*")
+	c.Assert(readFilter(auth)("app1", "staging"), Equals, false)
+	datamodel.Zk.RecursiveDelete(helper.GetBasePolicyPath())
+}
+
+func (s *DeployHelperSuite) TestPeerCredentials(c *C) {
+	datamodel.Zk.RecursiveDelete(helper.GetBaseTokenPath())
+	arg := ManagerListAppsArg{ManagerAuthArg: ManagerAuthArg{User: "alice", Password: "hunter2",
+		Source: "10.0.0.1:5000"}}
+	peerArg, done, err := peerCredentials("ListApps", arg)
+	c.Assert(err, IsNil)
+	// other regions never see the password, just a token that acts as alice for this one call
+	auth := peerArg.(ManagerListAppsArg).ManagerAuthArg
+	c.Assert(auth.Password, Equals, "")
+	c.Assert(auth.User, Equals, "alice")
+	c.Assert(auth.Source, Equals, "10.0.0.1:5000")
+	c.Assert(arg.Password, Equals, "hunter2")
+	zt, err := lookupToken(auth.Secret)
+	c.Assert(err, IsNil)
+	c.Assert(zt.User, Equals, "alice")
+	c.Assert(zt.ServiceAccount, Equals, false)
+	c.Assert(zt.Operations, DeepEquals, []string{"ListApps"})
+	c.Assert(zt.Expires.After(time.Now()), Equals, true)
+	c.Assert(zt.Expires.Before(time.Now().Add(peerTokenTTL+time.Second)), Equals, true)
+	done()
+	_, err = lookupToken(auth.Secret)
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *DeployHelperSuite) TestTokenSuperUser(c *C) {
+	datamodel.Zk.RecursiveDelete(helper.GetBaseTokenPath())
+	superUserGroup := aldap.SuperUserGroup
+	aldap.SuperUserGroup = "superusers"
+	defer func() { aldap.SuperUserGroup = superUserGroup }()
+	_, admin, err := saveToken(APIToken{ID: "admintoken", Name: "ops", User: "ops-bot", ServiceAccount: true,
+		Role: "superusers", Owner: "root"})
+	c.Assert(err, IsNil)
+	_, ci, err := saveToken(APIToken{ID: "citoken", Name: "ci", User: "ci-bot", ServiceAccount: true,
+		Owner: "root"})
+	c.Assert(err, IsNil)
+	c.Assert(AuthorizeSuperUser(&ManagerAuthArg{User: "ops-bot", Secret: admin}), IsNil)
+	c.Assert(ErrorKindOf(AuthorizeSuperUser(&ManagerAuthArg{User: "ci-bot", Secret: ci})), Equals, ErrForbidden)
+}
+
+func (s *DeployHelperSuite) TestApproveOwnToken(c *C) {
+	datamodel.Zk.RecursiveDelete(helper.GetBaseTokenPath())
+	_, secret, err := saveToken(APIToken{ID: "approvetoken", Name: "ci", User: "ci-bot", ServiceAccount: true,
+		Owner: "alice"})
+	c.Assert(err, IsNil)
+	c.Assert(tokenOwner(secret), Equals, "alice")
+	c.Assert(tokenOwner("password"), Equals, "")
+	za := &datamodel.ZkApproval{TaskApproval{ID: "approvetask", Name: "Deploy", User: "ci-bot", Owner: "alice",
+		App: "app1", Env: "prod", State: "pending", Requested: time.Now(), Expires: time.Now().Add(time.Hour)}}
+	c.Assert(za.Save(), IsNil)
+	defer za.Delete()
+	// whoever made the service account's token is not a second person
+	e := &ApproveTaskExecutor{ManagerApproveTaskArg{ManagerAuthArg: ManagerAuthArg{User: "alice"},
+		ID: "approvetask"}, &ManagerApproveTaskReply{}}
+	err = e.Authorize()
+	c.Assert(ErrorKindOf(err), Equals, ErrForbidden)
+	c.Assert(err, ErrorMatches, "You cannot approve your own task")
+}
+
+func (s *DeployHelperSuite) TestTaskLog(c *C) {
+	datamodel.Zk.RecursiveDelete(helper.GetBaseTokenPath())
+	datamodel.Zk.RecursiveDelete(helper.GetBasePolicyPath())
+	token := APIToken{ID: "logtoken", Name: "ci", User: "ci", ServiceAccount: true, Owner: "root",
+		Apps: []string{"app1"}, Envs: []string{"staging"}}
+	_, secret, err := saveToken(token)
+	c.Assert(err, IsNil)
+	auth := ManagerAuthArg{User: "ci", Secret: secret}
+	zt := &datamodel.ZkTask{ID: "logtask", Name: "Deploy", Status: "Deploying", Log: []string{"one"}, App: "app1",
+		Env: "staging"}
+	c.Assert(zt.Save(), IsNil)
+	// only those who may read the app in the env see what the task logged
+	other := &datamodel.ZkTask{ID: "prodtask", Name: "Deploy", Log: []string{"manifest"}, App: "app1", Env: "prod"}
+	c.Assert(other.Save(), IsNil)
+	var denied ManagerTaskLogReply
+	err = new(ManagerRPC).TaskLog(ManagerTaskLogArg{ManagerAuthArg: auth, ID: "prodtask"}, &denied)
+	c.Assert(ErrorKindOf(err), Equals, ErrForbidden)
+	c.Assert(denied.Lines, IsNil)
+	err = new(ManagerRPC).TaskLog(ManagerTaskLogArg{ID: "prodtask"}, &denied)
+	c.Assert(err, Not(IsNil))
+	c.Assert(denied.Lines, IsNil)
+	c.Assert(other.Delete(), IsNil)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		zt.Log = append(zt.Log, "two", "three")
+		zt.Save()
+		time.Sleep(500 * time.Millisecond)
+		zt.Done = true
+		zt.Status = StatusDone
+		zt.Save()
+	}()
+	// waits for the lines after the first
+	var reply ManagerTaskLogReply
+	c.Assert(new(ManagerRPC).TaskLog(ManagerTaskLogArg{ManagerAuthArg: auth, ID: "logtask", Since: 1}, &reply), IsNil)
+	c.Assert(reply.Lines, DeepEquals, []string{"two", "three"})
+	c.Assert(reply.Next, Equals, 3)
+	c.Assert(reply.Done, Equals, false)
+	// and then for the task to be done
+	done, err := waitTaskLog("logtask", reply.Next, 5*time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(done.Done, Equals, true)
+	c.Assert(done.Log, HasLen, 3)
+	c.Assert(zt.Delete(), IsNil)
+}
@@ -107,7 +107,7 @@ type UpdateEnvExecutor struct {
 }
 
 func (e *UpdateEnvExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *UpdateEnvExecutor) Result() interface{} {
@@ -121,15 +121,18 @@ func (e *UpdateEnvExecutor) Description() string {
 func (e *UpdateEnvExecutor) Execute(t *Task) error {
 	// error checking
 	if e.arg.Name == "" {
-		return errors.New("Please specify a name")
+		return errorOf(ErrInvalid, "Please specify a name")
 	}
 	if !EnvRegexp.MatchString(e.arg.Name) {
-		return errors.New("Env name must be ^[A-Za-z0-9-]+$")
+		return errorOf(ErrInvalid, "Env name must be ^[A-Za-z0-9-]+$")
 	}
 	if IsEnvInUse(e.arg.Name) {
 		return errors.New(fmt.Sprintf("%s is in use and cannot be updated", e.arg.Name))
 	}
 	env := datamodel.Env(e.arg.Name)
+	if existing, err := datamodel.GetEnv(e.arg.Name); err == nil {
+		env = existing // keep whether the env is protected
+	}
 	if err := env.Save(); err != nil {
 		return err
 	}
@@ -147,7 +150,7 @@ type DeleteEnvExecutor struct {
 }
 
 func (e *DeleteEnvExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *DeleteEnvExecutor) Result() interface{} {
@@ -161,7 +164,7 @@ func (e *DeleteEnvExecutor) Description() string {
 func (e *DeleteEnvExecutor) Execute(t *Task) (err error) {
 	// error checking
 	if e.arg.Name == "" {
-		return errors.New("Please specify a name")
+		return errorOf(ErrInvalid, "Please specify a name")
 	}
 	if IsEnvInUse(e.arg.Name) {
 		return errors.New(fmt.Sprintf("%s is in use and cannot be deleted", e.arg.Name))
@@ -180,10 +183,52 @@ func (e *DeleteEnvExecutor) Authorize() error {
 	return SimpleAuthorize(&e.arg.ManagerAuthArg)
 }
 
+type ProtectEnvExecutor struct {
+	arg   ManagerProtectEnvArg
+	reply *ManagerProtectEnvReply
+}
+
+func (e *ProtectEnvExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *ProtectEnvExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *ProtectEnvExecutor) Description() string {
+	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] %s protected: %t", e.arg.Name, e.arg.Protected)
+}
+
+func (e *ProtectEnvExecutor) Execute(t *Task) error {
+	if e.arg.Name == "" {
+		return errorOf(ErrInvalid, "Please specify a name")
+	}
+	env, err := datamodel.GetEnv(e.arg.Name)
+	if err != nil {
+		return errorOf(ErrNotFound, "No such env "+e.arg.Name)
+	}
+	env.Protected = e.arg.Protected
+	if err := env.Save(); err != nil {
+		e.reply.Status = StatusError
+		return err
+	}
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (e *ProtectEnvExecutor) Authorize() error {
+	return AuthorizeSuperUser(&e.arg.ManagerAuthArg)
+}
+
 func (m *ManagerRPC) UpdateEnv(arg ManagerEnvArg, reply *ManagerEnvReply) error {
-	return NewTask("UpdateEnv", &UpdateEnvExecutor{arg, reply}).Run()
+	return NewAuditedTask("UpdateEnv", &UpdateEnvExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) DeleteEnv(arg ManagerEnvArg, reply *ManagerEnvReply) error {
-	return NewTask("DeleteEnv", &DeleteEnvExecutor{arg, reply}).Run()
+	return NewAuditedTask("DeleteEnv", &DeleteEnvExecutor{arg, reply}).Run()
+}
+
+func (m *ManagerRPC) ProtectEnv(arg ManagerProtectEnvArg, reply *ManagerProtectEnvReply) error {
+	return NewAuditedTask("ProtectEnv", &ProtectEnvExecutor{arg, reply}).Run()
 }
@@ -0,0 +1,47 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+// ErrorKind says what went wrong with an RPC, so that callers like the REST API need not read the message to
+// tell.
+type ErrorKind string
+
+const (
+	ErrUnauthenticated ErrorKind = "Unauthenticated" // the credentials are wrong or have expired
+	ErrForbidden       ErrorKind = "Forbidden"       // the caller may not do this
+	ErrNotFound        ErrorKind = "NotFound"        // something the arg names does not exist
+	ErrConflict        ErrorKind = "Conflict"        // it clashes with what is there, e.g. a task that isn't done
+	ErrInvalid         ErrorKind = "Invalid"         // the arg is missing something or makes no sense
+	ErrUnsupported     ErrorKind = "Unsupported"     // the RPC is no longer supported
+)
+
+// An Error is an RPC error of a known kind.
+type Error struct {
+	Kind    ErrorKind
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func errorOf(kind ErrorKind, message string) error {
+	return &Error{kind, message}
+}
+
+// ErrorKindOf returns the kind of err, or "" if it is not an Error.
+func ErrorKindOf(err error) ErrorKind {
+	if e, ok := err.(*Error); ok {
+		return e.Kind
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	"atlantis/manager/datamodel"
+	. "atlantis/manager/rpc/types"
+	. "atlantis/supervisor/rpc/types"
+	"errors"
+	. "github.com/adjust/gocheck"
+	"sync"
+	"time"
+)
+
+type GetContainersSuite struct{}
+
+var _ = Suite(&GetContainersSuite{})
+
+func (s *GetContainersSuite) TestInstanceMatches(c *C) {
+	inst := &datamodel.ZkInstance{ID: "app-sha-env-1", App: "app", Sha: "sha", Env: "env", Host: "host1",
+		Zone: "zone1"}
+	c.Assert(instanceMatches(inst, &ManagerGetContainersArg{}), Equals, true)
+	c.Assert(instanceMatches(inst, &ManagerGetContainersArg{App: "app", Env: "env", Zone: "zone1"}), Equals, true)
+	c.Assert(instanceMatches(inst, &ManagerGetContainersArg{App: "other"}), Equals, false)
+	c.Assert(instanceMatches(inst, &ManagerGetContainersArg{Host: "host1", Zone: "zone2"}), Equals, false)
+}
+
+func (s *GetContainersSuite) TestContainerDetails(c *C) {
+	now := time.Now()
+	inst := &datamodel.ZkInstance{ID: "app-sha-env-1", App: "app", Host: "host1", Port: 61000,
+		Maintenance: true, Created: now.Add(-90*time.Second - time.Millisecond),
+		Manifest: &Manifest{CPUShares: 2, MemoryLimit: 1024}}
+	details := containerDetails(inst, now)
+	c.Assert(details.ID, Equals, "app-sha-env-1")
+	c.Assert(details.Port, Equals, uint16(61000))
+	c.Assert(details.Maintenance, Equals, true)
+	c.Assert(details.CPUShares, Equals, uint(2))
+	c.Assert(details.MemoryLimit, Equals, uint(1024))
+	c.Assert(details.Age, Equals, "1m30s")
+
+	// instances created before Created was recorded have no age
+	details = containerDetails(&datamodel.ZkInstance{ID: "old"}, now)
+	c.Assert(details.Age, Equals, "")
+	c.Assert(details.CPUShares, Equals, uint(0))
+}
+
+func (s *GetContainersSuite) TestAddLiveDetails(c *C) {
+	details := []*ContainerDetails{
+		&ContainerDetails{ID: "a", Host: "host1"},
+		&ContainerDetails{ID: "b", Host: "host1"},
+		&ContainerDetails{ID: "c", Host: "host2"},
+		&ContainerDetails{ID: "d", Host: "host3"},
+	}
+	var lock sync.Mutex
+	calls := map[string]int{}
+	addLiveDetails(details, func(host string) (*SupervisorListReply, error) {
+		lock.Lock()
+		calls[host]++
+		lock.Unlock()
+		switch host {
+		case "host1":
+			return &SupervisorListReply{Containers: map[string]*Container{"a": &Container{ID: "a"}}}, nil
+		case "host2":
+			return &SupervisorListReply{Containers: map[string]*Container{"c": &Container{ID: "c"}}}, nil
+		}
+		return nil, errors.New("connection refused")
+	})
+	c.Assert(calls, DeepEquals, map[string]int{"host1": 1, "host2": 1, "host3": 1})
+	c.Assert(details[0].Live, NotNil)
+	c.Assert(details[0].Live.Host, Equals, "host1")
+	c.Assert(details[0].LiveError, Equals, "")
+	c.Assert(details[1].Live, IsNil)
+	c.Assert(details[1].LiveError, Equals, "Not running on host1")
+	c.Assert(details[2].Live.ID, Equals, "c")
+	c.Assert(details[3].Live, IsNil)
+	c.Assert(details[3].LiveError, Equals, "Could not list containers on host3: connection refused")
+}
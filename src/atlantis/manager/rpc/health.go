@@ -23,7 +23,7 @@ type HealthCheckExecutor struct {
 }
 
 func (e *HealthCheckExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *HealthCheckExecutor) Result() interface{} {
@@ -15,7 +15,6 @@ import (
 	. "atlantis/common"
 	"atlantis/manager/netsec"
 	. "atlantis/manager/rpc/types"
-	"errors"
 	"fmt"
 )
 
@@ -25,7 +24,7 @@ type UpdateIPGroupExecutor struct {
 }
 
 func (e *UpdateIPGroupExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *UpdateIPGroupExecutor) Result() interface{} {
@@ -42,10 +41,10 @@ func (e *UpdateIPGroupExecutor) Authorize() error {
 
 func (e *UpdateIPGroupExecutor) Execute(t *Task) error {
 	if e.arg.Name == "" {
-		return errors.New("Please specify a Name.")
+		return errorOf(ErrInvalid, "Please specify a Name.")
 	}
 	if e.arg.IPs == nil {
-		return errors.New("Please specify a list of IPs.")
+		return errorOf(ErrInvalid, "Please specify a list of IPs.")
 	}
 	if err := netsec.UpdateIPGroup(e.arg.Name, e.arg.IPs); err != nil {
 		e.reply.Status = StatusError
@@ -56,7 +55,7 @@ func (e *UpdateIPGroupExecutor) Execute(t *Task) error {
 }
 
 func (m *ManagerRPC) UpdateIPGroup(arg ManagerUpdateIPGroupArg, reply *ManagerUpdateIPGroupReply) error {
-	return NewTask("UpdateIPGroup", &UpdateIPGroupExecutor{arg, reply}).Run()
+	return NewAuditedTask("UpdateIPGroup", &UpdateIPGroupExecutor{arg, reply}).Run()
 }
 
 type DeleteIPGroupExecutor struct {
@@ -65,7 +64,7 @@ type DeleteIPGroupExecutor struct {
 }
 
 func (e *DeleteIPGroupExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *DeleteIPGroupExecutor) Result() interface{} {
@@ -82,7 +81,7 @@ func (e *DeleteIPGroupExecutor) Authorize() error {
 
 func (e *DeleteIPGroupExecutor) Execute(t *Task) error {
 	if e.arg.Name == "" {
-		return errors.New("Please specify a Name.")
+		return errorOf(ErrInvalid, "Please specify a Name.")
 	}
 	if err := netsec.DeleteIPGroup(e.arg.Name); err != nil {
 		e.reply.Status = StatusError
@@ -93,7 +92,7 @@ func (e *DeleteIPGroupExecutor) Execute(t *Task) error {
 }
 
 func (m *ManagerRPC) DeleteIPGroup(arg ManagerDeleteIPGroupArg, reply *ManagerDeleteIPGroupReply) error {
-	return NewTask("DeleteIPGroup", &DeleteIPGroupExecutor{arg, reply}).Run()
+	return NewAuditedTask("DeleteIPGroup", &DeleteIPGroupExecutor{arg, reply}).Run()
 }
 
 type GetIPGroupExecutor struct {
@@ -102,7 +101,7 @@ type GetIPGroupExecutor struct {
 }
 
 func (e *GetIPGroupExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *GetIPGroupExecutor) Result() interface{} {
@@ -119,7 +118,7 @@ func (e *GetIPGroupExecutor) Authorize() error {
 
 func (e *GetIPGroupExecutor) Execute(t *Task) (err error) {
 	if e.arg.Name == "" {
-		return errors.New("Please specify a Name.")
+		return errorOf(ErrInvalid, "Please specify a Name.")
 	}
 	e.reply.IPGroup, err = netsec.GetIPGroup(e.arg.Name)
 	if err != nil {
@@ -140,7 +139,7 @@ type ListIPGroupsExecutor struct {
 }
 
 func (e *ListIPGroupsExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListIPGroupsExecutor) Result() interface{} {
@@ -16,7 +16,6 @@ import (
 	"atlantis/manager/datamodel"
 	aldap "atlantis/manager/ldap"
 	. "atlantis/manager/rpc/types"
-	"errors"
 	"fmt"
 	"github.com/mavricknz/ldap"
 	"log"
@@ -37,7 +36,7 @@ type CreateTeamExecutor struct {
 }
 
 func (e *CreateTeamExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *CreateTeamExecutor) Result() interface{} {
@@ -49,7 +48,7 @@ func (e *CreateTeamExecutor) Description() string {
 }
 
 func (e *CreateTeamExecutor) Execute(t *Task) error {
-        return errors.New("Team creation is no longer supported")
+        return errorOf(ErrUnsupported, "Team creation is no longer supported")
 }
 
 func (e *CreateTeamExecutor) Authorize() error {
@@ -65,7 +64,7 @@ type DeleteTeamExecutor struct {
 }
 
 func (e *DeleteTeamExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *DeleteTeamExecutor) Result() interface{} {
@@ -77,7 +76,7 @@ func (e *DeleteTeamExecutor) Description() string {
 }
 
 func (e *DeleteTeamExecutor) Execute(t *Task) error {
-	return errors.New("Team Delete is no longer supported")
+	return errorOf(ErrUnsupported, "Team Delete is no longer supported")
 }
 
 func (e *DeleteTeamExecutor) Authorize() error {
@@ -88,11 +87,11 @@ func (e *DeleteTeamExecutor) Authorize() error {
 }
 
 func (m *ManagerRPC) CreateTeam(arg ManagerTeamArg, reply *ManagerTeamReply) error {
-	return NewTask("CreateTeam", &CreateTeamExecutor{arg, reply}).Run()
+	return NewAuditedTask("CreateTeam", &CreateTeamExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) DeleteTeam(arg ManagerTeamArg, reply *ManagerTeamReply) error {
-	return NewTask("DeleteTeam", &DeleteTeamExecutor{arg, reply}).Run()
+	return NewAuditedTask("DeleteTeam", &DeleteTeamExecutor{arg, reply}).Run()
 }
 
 type AddTeamEmailExecutor struct {
@@ -101,7 +100,7 @@ type AddTeamEmailExecutor struct {
 }
 
 func (e *AddTeamEmailExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *AddTeamEmailExecutor) Result() interface{} {
@@ -129,7 +128,7 @@ type RemoveTeamEmailExecutor struct {
 }
 
 func (e *RemoveTeamEmailExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *RemoveTeamEmailExecutor) Result() interface{} {
@@ -152,15 +151,15 @@ func (e *RemoveTeamEmailExecutor) Authorize() error {
 }
 
 func ModifyTeamEmail(action int, arg ManagerEmailArg, reply *ManagerEmailReply) error {
-	return errors.New("Team email modification is no longer supported")
+	return errorOf(ErrUnsupported, "Team email modification is no longer supported")
 }
 
 func (m *ManagerRPC) AddTeamEmail(arg ManagerEmailArg, reply *ManagerEmailReply) error {
-	return NewTask("AddTeamEmail", &AddTeamEmailExecutor{arg, reply}).Run()
+	return NewAuditedTask("AddTeamEmail", &AddTeamEmailExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) RemoveTeamEmail(arg ManagerEmailArg, reply *ManagerEmailReply) error {
-	return NewTask("RemoveTeamEmail", &RemoveTeamEmailExecutor{arg, reply}).Run()
+	return NewAuditedTask("RemoveTeamEmail", &RemoveTeamEmailExecutor{arg, reply}).Run()
 }
 
 type AddTeamAdminExecutor struct {
@@ -169,7 +168,7 @@ type AddTeamAdminExecutor struct {
 }
 
 func (e *AddTeamAdminExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *AddTeamAdminExecutor) Result() interface{} {
@@ -181,7 +180,7 @@ func (e *AddTeamAdminExecutor) Description() string {
 }
 
 func (e *AddTeamAdminExecutor) Execute(t *Task) error {
-	return errors.New("Modify Team admin is no longer supported")
+	return errorOf(ErrUnsupported, "Modify Team admin is no longer supported")
 }
 
 func (e *AddTeamAdminExecutor) Authorize() error {
@@ -192,7 +191,7 @@ func (e *AddTeamAdminExecutor) Authorize() error {
 	var res ManagerTeamAdminReply
 	err := NewTask("AddTeamAdmin-IsTeamAdmin", &IsTeamAdminExecutor{req, &res}).Run()
 	if err != nil || !res.IsAdmin {
-		return errors.New("Permission denied")
+		return errorOf(ErrForbidden, "Permission denied")
 	}
 	reql := ManagerListTeamAdminsArg{e.arg.ManagerAuthArg, e.arg.Team}
 	var resl ManagerListTeamAdminsReply
@@ -212,7 +211,7 @@ type RemoveTeamAdminExecutor struct {
 }
 
 func (e *RemoveTeamAdminExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *RemoveTeamAdminExecutor) Result() interface{} {
@@ -224,7 +223,7 @@ func (e *RemoveTeamAdminExecutor) Description() string {
 }
 
 func (e *RemoveTeamAdminExecutor) Execute(t *Task) error {
-	return errors.New("Modify Team admin is no longer supported")
+	return errorOf(ErrUnsupported, "Modify Team admin is no longer supported")
 }
 
 func (e *RemoveTeamAdminExecutor) Authorize() error {
@@ -235,21 +234,21 @@ func (e *RemoveTeamAdminExecutor) Authorize() error {
 	var res ManagerTeamAdminReply
 	err := NewTask("RemoveTeamAdmin-IsTeamAdmin", &IsTeamAdminExecutor{req, &res}).Run()
 	if err != nil || !res.IsAdmin {
-		return errors.New("Permission denied")
+		return errorOf(ErrForbidden, "Permission denied")
 	}
 	return nil
 }
 
 func ModifyTeamAdmin(action int, arg ManagerModifyTeamAdminArg, reply *ManagerModifyTeamAdminReply) error {
-	return errors.New("Modify Team admin is no longer supported")
+	return errorOf(ErrUnsupported, "Modify Team admin is no longer supported")
 }
 
 func (m *ManagerRPC) AddTeamAdmin(arg ManagerModifyTeamAdminArg, reply *ManagerModifyTeamAdminReply) error {
-	return NewTask("AddTeamAdmin", &AddTeamAdminExecutor{arg, reply}).Run()
+	return NewAuditedTask("AddTeamAdmin", &AddTeamAdminExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) RemoveTeamAdmin(arg ManagerModifyTeamAdminArg, reply *ManagerModifyTeamAdminReply) error {
-	return NewTask("RemoveTeamAdmin", &RemoveTeamAdminExecutor{arg, reply}).Run()
+	return NewAuditedTask("RemoveTeamAdmin", &RemoveTeamAdminExecutor{arg, reply}).Run()
 }
 
 type AddTeamMemberExecutor struct {
@@ -258,7 +257,7 @@ type AddTeamMemberExecutor struct {
 }
 
 func (e *AddTeamMemberExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *AddTeamMemberExecutor) Result() interface{} {
@@ -270,7 +269,7 @@ func (e *AddTeamMemberExecutor) Description() string {
 }
 
 func (e *AddTeamMemberExecutor) Execute(t *Task) error {
-	return errors.New("Modify Team memeber is no longer supported")
+	return errorOf(ErrUnsupported, "Modify Team memeber is no longer supported")
 }
 
 func (e *AddTeamMemberExecutor) Authorize() error {
@@ -286,7 +285,7 @@ type RemoveTeamMemberExecutor struct {
 }
 
 func (e *RemoveTeamMemberExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *RemoveTeamMemberExecutor) Result() interface{} {
@@ -298,7 +297,7 @@ func (e *RemoveTeamMemberExecutor) Description() string {
 }
 
 func (e *RemoveTeamMemberExecutor) Execute(t *Task) error {
-	return errors.New("Modify Team memeber is no longer supporte")
+	return errorOf(ErrUnsupported, "Modify Team memeber is no longer supporte")
 }
 
 func (e *RemoveTeamMemberExecutor) Authorize() error {
@@ -313,11 +312,11 @@ func ModifyTeamMember(action int, arg ManagerTeamMemberArg, reply *ManagerTeamMe
 }
 
 func (m *ManagerRPC) AddTeamMember(arg ManagerTeamMemberArg, reply *ManagerTeamMemberReply) error {
-	return NewTask("AddTeamMember", &AddTeamMemberExecutor{arg, reply}).Run()
+	return NewAuditedTask("AddTeamMember", &AddTeamMemberExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) RemoveTeamMember(arg ManagerTeamMemberArg, reply *ManagerTeamMemberReply) error {
-	return NewTask("RemoveTeamMember", &RemoveTeamMemberExecutor{arg, reply}).Run()
+	return NewAuditedTask("RemoveTeamMember", &RemoveTeamMemberExecutor{arg, reply}).Run()
 }
 
 // ----------------------------------------------------------------------------------------------------------
@@ -330,7 +329,7 @@ type ListTeamsExecutor struct {
 }
 
 func (e *ListTeamsExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListTeamsExecutor) Result() interface{} {
@@ -363,7 +362,7 @@ type ListTeamEmailsExecutor struct {
 }
 
 func (e *ListTeamEmailsExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListTeamEmailsExecutor) Result() interface{} {
@@ -396,7 +395,7 @@ type ListTeamAdminsExecutor struct {
 }
 
 func (e *ListTeamAdminsExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListTeamAdminsExecutor) Result() interface{} {
@@ -426,7 +425,7 @@ type ListTeamMembersExecutor struct {
 }
 
 func (e *ListTeamMembersExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListTeamMembersExecutor) Result() interface{} {
@@ -459,7 +458,7 @@ type ListTeamAppsExecutor struct {
 }
 
 func (e *ListTeamAppsExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListTeamAppsExecutor) Result() interface{} {
@@ -496,7 +495,7 @@ type AllowAppExecutor struct {
 }
 
 func (e *AllowAppExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *AllowAppExecutor) Result() interface{} {
@@ -515,20 +514,22 @@ func (e *AllowAppExecutor) Execute(t *Task) error {
 	}
 
 	if !TeamExists(e.arg.Team, &e.arg.ManagerAuthArg) && !suReply.IsSuperUser {
-		return errors.New("You do not have permission to allow apps for team " + e.arg.Team)
+		return errorOf(ErrForbidden, "You do not have permission to allow apps for team "+e.arg.Team)
+	}
+	if _, err := datamodel.GetApp(e.arg.App); err != nil {
+		return errorOf(ErrNotFound, "App "+e.arg.App+" does not exist")
 	}
 	teamApps := datamodel.GetTeamapps(e.arg.Team)
-	
-	//
-	//TODO: check app exist before add it to team
-	//
-
-        return teamApps.AddApp(e.arg.App)
+	if teamApps.HasApp(e.arg.App) {
+		// already allowed, nothing to sync
+		return nil
+	}
+	return teamApps.AddApp(e.arg.App)
 }
 
 func (e *AllowAppExecutor) Authorize() error {
-	//TODO need to check user in the team first
-	return nil
+	// team membership is checked in Execute
+	return SimpleAuthorize(&e.arg.ManagerAuthArg)
 }
 
 type DisallowAppExecutor struct {
@@ -537,7 +538,7 @@ type DisallowAppExecutor struct {
 }
 
 func (e *DisallowAppExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *DisallowAppExecutor) Result() interface{} {
@@ -556,25 +557,28 @@ func (e *DisallowAppExecutor) Execute(t *Task) error {
 	}
 
 	if !TeamExists(e.arg.Team, &e.arg.ManagerAuthArg) && !suReply.IsSuperUser {
-		return errors.New("You do not have permission to disallow apps for team " + e.arg.Team)
+		return errorOf(ErrForbidden, "You do not have permission to disallow apps for team "+e.arg.Team)
 	}
 
 	teamApps := datamodel.GetTeamapps(e.arg.Team)
+	if !teamApps.HasApp(e.arg.App) {
+		// already disallowed, nothing to sync
+		return nil
+	}
 	return teamApps.DeleteApp(e.arg.App)
 }
 
 func (e *DisallowAppExecutor) Authorize() error {
-	//return AuthorizeTeamAdmin(&e.arg.ManagerAuthArg, e.arg.Team)
-	//TODO: need check user is a member of the tea
-	return nil
+	// team membership is checked in Execute
+	return SimpleAuthorize(&e.arg.ManagerAuthArg)
 }
 
 func (m *ManagerRPC) AllowApp(arg ManagerAppArg, reply *ManagerAppReply) error {
-	return NewTask("AllowApp", &AllowAppExecutor{arg, reply}).Run()
+	return NewAuditedTask("AllowApp", &AllowAppExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) DisallowApp(arg ManagerAppArg, reply *ManagerAppReply) error {
-	return NewTask("DisallowApp", &DisallowAppExecutor{arg, reply}).Run()
+	return NewAuditedTask("DisallowApp", &DisallowAppExecutor{arg, reply}).Run()
 }
 
 type IsAppAllowedExecutor struct {
@@ -583,7 +587,7 @@ type IsAppAllowedExecutor struct {
 }
 
 func (e *IsAppAllowedExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *IsAppAllowedExecutor) Result() interface{} {
@@ -624,7 +628,7 @@ type ListAllowedAppsExecutor struct {
 }
 
 func (e *ListAllowedAppsExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListAllowedAppsExecutor) Result() interface{} {
@@ -668,21 +672,33 @@ func (e *ListAllowedAppsExecutor) Authorize() error {
 func GetAllowedApps(auth *ManagerAuthArg, user string) map[string]bool {
 	result := map[string]bool{}
 
-	teams, err := ListTeams(auth)
-	
-	if err != nil {
-		return result
+	var teams []string
+	if user == auth.User {
+		teams, _ = ListTeams(auth)
+	} else if aldap.LdapServer != "" {
+		// the session is not user's, so ask LDAP once for all of user's teams
+		var err error
+		if teams, err = aldap.TeamsOf(user); err != nil {
+			log.Println("Warning: unable to look up teams of", user, "; ", err)
+		}
 	}
 	for _, team := range teams {
-		teamApps := datamodel.GetTeamapps(team)
-		for _, app := range teamApps.Apps {
+		for _, app := range datamodel.GetTeamapps(team).Apps {
 			result[app] = true
 		}
 	}
+	// user may not be the one whose session we have, so also go by the synced team members
+	for _, app := range datamodel.ListTeamappsForMember(user) {
+		result[app] = true
+	}
 	return result
 }
 
 func IsAppAllowed(auth *ManagerAuthArg, user string, app string) bool {
+	// fast path: zookeeper knows which teams may deploy app and who is in them without asking LDAP
+	if datamodel.IsTeamappMember(user, app) {
+		return true
+	}
 	var suReply ManagerSuperUserReply
 	if err := NewTask("IsAppAllowed-IsSuperUser",
 		&IsSuperUserExecutor{ManagerSuperUserArg{*auth}, &suReply}).Run(); err != nil {
@@ -705,7 +721,7 @@ type IsTeamAdminExecutor struct {
 }
 
 func (e *IsTeamAdminExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *IsTeamAdminExecutor) Result() interface{} {
@@ -734,7 +750,7 @@ type IsSuperUserExecutor struct {
 }
 
 func (e *IsSuperUserExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *IsSuperUserExecutor) Result() interface{} {
@@ -819,7 +835,13 @@ func TeamExists(name string, auth *ManagerAuthArg) bool {
 }
 
 func ListTeams(auth *ManagerAuthArg) ([]string, error) {
-
+	if isToken(auth.Secret) {
+		zt, err := lookupToken(auth.Secret)
+		if err != nil {
+			return []string{}, err
+		}
+		return tokenTeams(zt)
+	}
 	if ret := aldap.LookupTeam(auth.User, auth.Secret); ret != nil {
 		return ret, nil
 	}
@@ -870,19 +892,17 @@ func ListTeamAdmins(team string, auth *ManagerAuthArg) ([]string, error) {
 
 func ListTeamMembers(team string, auth *ManagerAuthArg) ([]string, error) {
 
-	ldapConn, err := aldap.CreateLdapConn(aldap.LdapServer, aldap.LdapPort, aldap.TlsConfig)
+	ldapConn, err := aldap.Pool.Get()
 	if err != nil {
 		log.Println("Warning: Unalbe to connect to ldap to fetch team members; ", err)
 		return []string{}, nil
 	}
-	defer ldapConn.Close()
-
-	err = aldap.LoginBind(aldap.SearchUserDn, aldap.SearchUserPwd, ldapConn)
+	ret, err := ListTeamAttributes(team, aldap.UsernameAttr, ldapConn)
 	if err != nil {
-		log.Println("Warning: Unalbe to bind to LDAP to fetch team members; ", err)
-		return []string{}, nil
+		aldap.Pool.Discard(ldapConn)
+	} else {
+		aldap.Pool.Put(ldapConn)
 	}
-	ret, err := ListTeamAttributes(team, aldap.UsernameAttr, ldapConn)
 
 	return ret, err
 }
@@ -0,0 +1,180 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	"atlantis/manager/datamodel"
+	. "atlantis/manager/rpc/types"
+	routerzk "atlantis/router/zk"
+	"encoding/base64"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------------------------------------
+// Paged Lists
+// ----------------------------------------------------------------------------------------------------------
+
+// List calls take ListOptions. Items are filtered by Prefix and Labels, sorted by name and cut into pages of Limit
+// items. The cursor of the next page is the last item of this one, so paging through a list that changes in
+// between neither skips nor repeats the items that were there all along.
+
+const (
+	sortAsc  = "asc"
+	sortDesc = "desc"
+)
+
+// labelFunc returns the labels of an item of a list, for Labels filters.
+type labelFunc func(item string) (map[string]string, error)
+
+func encodeCursor(item string) string {
+	return base64.URLEncoding.EncodeToString([]byte(item))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	item, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", errorOf(ErrInvalid, "Invalid cursor "+cursor)
+	}
+	return string(item), nil
+}
+
+func parseLabels(labels []string) (map[string]string, error) {
+	parsed := map[string]string{}
+	for _, label := range labels {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errorOf(ErrInvalid, "Invalid label "+label+", expected key=value")
+		}
+		parsed[parts[0]] = parts[1]
+	}
+	return parsed, nil
+}
+
+// pageList returns the page of items that opts asks for and the cursor of the page after it, which is empty if
+// this is the last page. labels is only called for items that could be on the page; lists of things that have no
+// labels pass nil.
+func pageList(items []string, opts ListOptions, labels labelFunc) ([]string, string, error) {
+	if opts.Limit < 0 {
+		return nil, "", errorOf(ErrInvalid, "Limit must not be negative")
+	}
+	if opts.Sort != "" && opts.Sort != sortAsc && opts.Sort != sortDesc {
+		return nil, "", errorOf(ErrInvalid, "Invalid sort "+opts.Sort+", use asc or desc")
+	}
+	want, err := parseLabels(opts.Labels)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(want) > 0 && labels == nil {
+		return nil, "", errorOf(ErrInvalid, "This list can not be filtered by label")
+	}
+	matching := make([]string, 0, len(items))
+	for _, item := range items {
+		if strings.HasPrefix(item, opts.Prefix) {
+			matching = append(matching, item)
+		}
+	}
+	desc := opts.Sort == sortDesc
+	if desc {
+		sort.Sort(sort.Reverse(sort.StringSlice(matching)))
+	} else {
+		sort.Strings(matching)
+	}
+	start := 0
+	if opts.Cursor != "" {
+		after, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		start = sort.Search(len(matching), func(i int) bool {
+			if desc {
+				return matching[i] < after
+			}
+			return matching[i] > after
+		})
+	}
+	page := []string{}
+	for _, item := range matching[start:] {
+		if opts.Limit > 0 && len(page) == opts.Limit {
+			return page, encodeCursor(page[len(page)-1]), nil
+		}
+		if len(want) > 0 && !hasLabels(item, want, labels) {
+			continue
+		}
+		page = append(page, item)
+	}
+	return page, "", nil
+}
+
+// hasLabels returns true if item has every label in want. Items whose labels can't be looked up, because they
+// were removed since the list was read for instance, are left out.
+func hasLabels(item string, want map[string]string, labels labelFunc) bool {
+	have, err := labels(item)
+	if err != nil {
+		return false
+	}
+	for key, value := range want {
+		if have[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// pageOnly keeps the paging and sorting of opts and drops the filters, for lists that are already filtered.
+func pageOnly(opts ListOptions) ListOptions {
+	return ListOptions{Limit: opts.Limit, Cursor: opts.Cursor, Sort: opts.Sort}
+}
+
+// filterOnly keeps the filters of opts and drops the paging, for lists that are paged after they are merged.
+func filterOnly(opts ListOptions) ListOptions {
+	return ListOptions{Prefix: opts.Prefix, Labels: opts.Labels, Sort: opts.Sort}
+}
+
+func containerLabels(id string) (map[string]string, error) {
+	inst, err := datamodel.GetInstance(id)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"app": inst.App, "sha": inst.Sha, "env": inst.Env, "supervisor": inst.Host}, nil
+}
+
+func registeredAppLabels(name string) (map[string]string, error) {
+	app, err := datamodel.GetApp(name)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"team":        app.Team,
+		"email":       app.Email,
+		"internal":    strconv.FormatBool(app.Internal),
+		"nonatlantis": strconv.FormatBool(app.NonAtlantis),
+	}, nil
+}
+
+// poolLabels and ruleLabels read from the router root that was last set with helper.SetRouterRoot.
+func poolLabels(name string) (map[string]string, error) {
+	pool, err := routerzk.GetPool(datamodel.Zk.Conn, name)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"status": pool.Config.Status}, nil
+}
+
+func ruleLabels(name string) (map[string]string, error) {
+	rule, err := routerzk.GetRule(datamodel.Zk.Conn, name)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"type": rule.Type, "pool": rule.Pool, "next": rule.Next}, nil
+}
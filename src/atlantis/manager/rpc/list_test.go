@@ -0,0 +1,115 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/manager/rpc/types"
+	"errors"
+	. "github.com/adjust/gocheck"
+)
+
+type ListSuite struct{}
+
+var _ = Suite(&ListSuite{})
+
+var listItems = []string{"b-app", "a-app", "c-app", "a-tool", "b-tool"}
+
+// allPages pages through items like the client does and returns every page
+func allPages(c *C, items []string, opts ListOptions, labels labelFunc) [][]string {
+	pages := [][]string{}
+	for {
+		page, next, err := pageList(items, opts, labels)
+		c.Assert(err, IsNil)
+		pages = append(pages, page)
+		if next == "" {
+			return pages
+		}
+		opts.Cursor = next
+	}
+}
+
+func (s *ListSuite) TestWholeList(c *C) {
+	page, next, err := pageList(listItems, ListOptions{}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(next, Equals, "")
+	c.Assert(page, DeepEquals, []string{"a-app", "a-tool", "b-app", "b-tool", "c-app"})
+	// the list that was passed in is left alone
+	c.Assert(listItems[0], Equals, "b-app")
+
+	page, _, err = pageList(nil, ListOptions{Limit: 2}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(page, DeepEquals, []string{})
+}
+
+func (s *ListSuite) TestPages(c *C) {
+	c.Assert(allPages(c, listItems, ListOptions{Limit: 2}, nil), DeepEquals,
+		[][]string{{"a-app", "a-tool"}, {"b-app", "b-tool"}, {"c-app"}})
+	c.Assert(allPages(c, listItems, ListOptions{Limit: 5}, nil), DeepEquals,
+		[][]string{{"a-app", "a-tool", "b-app", "b-tool", "c-app"}})
+	c.Assert(allPages(c, listItems, ListOptions{Limit: 2, Sort: "desc"}, nil), DeepEquals,
+		[][]string{{"c-app", "b-tool"}, {"b-app", "a-tool"}, {"a-app"}})
+	c.Assert(allPages(c, listItems, ListOptions{Limit: 1, Prefix: "a-"}, nil), DeepEquals,
+		[][]string{{"a-app"}, {"a-tool"}})
+}
+
+func (s *ListSuite) TestCursorSurvivesChanges(c *C) {
+	page, next, err := pageList(listItems, ListOptions{Limit: 2}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(page, DeepEquals, []string{"a-app", "a-tool"})
+	// a-tool, the last item of the page, is removed and a-0 is added ahead of the cursor before the next page
+	changed := []string{"a-0", "a-app", "b-app", "b-tool", "c-app"}
+	page, _, err = pageList(changed, ListOptions{Limit: 2, Cursor: next}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(page, DeepEquals, []string{"b-app", "b-tool"})
+}
+
+func (s *ListSuite) TestLabels(c *C) {
+	teams := map[string]string{"a-app": "web", "a-tool": "ops", "b-app": "web", "c-app": "web"}
+	labels := func(item string) (map[string]string, error) {
+		team, ok := teams[item]
+		if !ok {
+			return nil, errors.New("gone")
+		}
+		return map[string]string{"team": team, "env": "prod"}, nil
+	}
+	page, _, err := pageList(listItems, ListOptions{Labels: []string{"team=web", "env=prod"}}, labels)
+	c.Assert(err, IsNil)
+	c.Assert(page, DeepEquals, []string{"a-app", "b-app", "c-app"})
+	page, _, err = pageList(listItems, ListOptions{Labels: []string{"team=web", "env=dev"}}, labels)
+	c.Assert(err, IsNil)
+	c.Assert(page, DeepEquals, []string{})
+	c.Assert(allPages(c, listItems, ListOptions{Limit: 2, Labels: []string{"team=web"}}, labels), DeepEquals,
+		[][]string{{"a-app", "b-app"}, {"c-app"}})
+
+	// labels are only looked up for items that could be on the page
+	looked := 0
+	counting := func(item string) (map[string]string, error) {
+		looked++
+		return labels(item)
+	}
+	_, _, err = pageList(listItems, ListOptions{Limit: 1, Labels: []string{"team=web"}}, counting)
+	c.Assert(err, IsNil)
+	c.Assert(looked, Equals, 1)
+}
+
+func (s *ListSuite) TestInvalidOptions(c *C) {
+	_, _, err := pageList(listItems, ListOptions{Limit: -1}, nil)
+	c.Assert(err, ErrorMatches, "Limit must not be negative")
+	_, _, err = pageList(listItems, ListOptions{Sort: "name"}, nil)
+	c.Assert(err, ErrorMatches, "Invalid sort name.*")
+	_, _, err = pageList(listItems, ListOptions{Cursor: "not base64!"}, nil)
+	c.Assert(err, ErrorMatches, "Invalid cursor.*")
+	_, _, err = pageList(listItems, ListOptions{Labels: []string{"team"}}, containerLabels)
+	c.Assert(err, ErrorMatches, "Invalid label team.*")
+	_, _, err = pageList(listItems, ListOptions{Labels: []string{"team=web"}}, nil)
+	c.Assert(err, ErrorMatches, "This list can not be filtered by label")
+}
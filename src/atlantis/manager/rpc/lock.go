@@ -0,0 +1,122 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/common"
+	"atlantis/manager/datamodel"
+	. "atlantis/manager/rpc/types"
+	"time"
+)
+
+type waitLocker interface {
+	Lock() error
+	LockWait(timeout time.Duration) error
+}
+
+func parseLockTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, errorOf(ErrInvalid, "Invalid Lock Timeout: "+err.Error())
+	}
+	return wait, nil
+}
+
+// fails right away on a conflict unless wait is positive
+func lockWithTimeout(l waitLocker, wait time.Duration, t *Task) error {
+	if wait <= 0 {
+		return l.Lock()
+	}
+	t.LogStatus("Waiting up to %s for Lock", wait.String())
+	return l.LockWait(wait)
+}
+
+type ListLocksExecutor struct {
+	arg   ManagerListLocksArg
+	reply *ManagerListLocksReply
+}
+
+func (e *ListLocksExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *ListLocksExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *ListLocksExecutor) Description() string {
+	return "[" + e.arg.ManagerAuthArg.User + "] ListLocks"
+}
+
+func (e *ListLocksExecutor) Execute(t *Task) (err error) {
+	e.reply.Locks, err = datamodel.ListLocks()
+	if err != nil {
+		e.reply.Status = StatusError
+		return err
+	}
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (e *ListLocksExecutor) Authorize() error {
+	return SimpleAuthorize(&e.arg.ManagerAuthArg)
+}
+
+func (m *ManagerRPC) ListLocks(arg ManagerListLocksArg, reply *ManagerListLocksReply) error {
+	return NewTask("ListLocks", &ListLocksExecutor{arg, reply}).Run()
+}
+
+type ForceUnlockExecutor struct {
+	arg   ManagerForceUnlockArg
+	reply *ManagerForceUnlockReply
+}
+
+func (e *ForceUnlockExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *ForceUnlockExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *ForceUnlockExecutor) Description() string {
+	return "[" + e.arg.ManagerAuthArg.User + "] " + e.arg.Path
+}
+
+func (e *ForceUnlockExecutor) Execute(t *Task) (err error) {
+	if e.arg.Path == "" {
+		return errorOf(ErrInvalid, "Please specify a lock path")
+	}
+	e.reply.Lock, err = datamodel.ForceUnlock(e.arg.Path)
+	if err != nil {
+		e.reply.Status = StatusError
+		return err
+	}
+	t.Log("[RPC][ForceUnlock] released %s held by %s (%s on %s)", e.arg.Path, e.reply.Lock.TaskID,
+		e.reply.Lock.User, e.reply.Lock.Owner)
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (e *ForceUnlockExecutor) Authorize() error {
+	if err := checkRole("deploys", "write"); err != nil {
+		return err
+	}
+	return AuthorizeSuperUser(&e.arg.ManagerAuthArg)
+}
+
+func (m *ManagerRPC) ForceUnlock(arg ManagerForceUnlockArg, reply *ManagerForceUnlockReply) error {
+	return NewAuditedTask("ForceUnlock", &ForceUnlockExecutor{arg, reply}).Run()
+}
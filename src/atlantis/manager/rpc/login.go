@@ -13,6 +13,7 @@ package rpc
 
 import (
 	. "atlantis/common"
+	"atlantis/manager/auth"
 	. "atlantis/manager/rpc/types"
 )
 
@@ -22,7 +23,7 @@ type LoginExecutor struct {
 }
 
 func (e *LoginExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *LoginExecutor) Result() interface{} {
@@ -56,3 +57,39 @@ func (e *LoginExecutor) Authorize() error {
 func (m *ManagerRPC) Login(arg ManagerLoginArg, reply *ManagerLoginReply) error {
 	return NewTask("Login", &LoginExecutor{arg, reply}).Run()
 }
+
+type LogoutExecutor struct {
+	arg   ManagerLogoutArg
+	reply *ManagerLogoutReply
+}
+
+func (e *LogoutExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *LogoutExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *LogoutExecutor) Description() string {
+	return "[" + e.arg.User + "] Logout"
+}
+
+func (e *LogoutExecutor) Execute(t *Task) error {
+	if err := auth.Logout(e.arg.User, e.arg.Secret); err != nil {
+		return err
+	}
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (e *LogoutExecutor) Authorize() error {
+	if isToken(e.arg.Secret) {
+		return errorOf(ErrForbidden, "API Tokens do not log in; revoke them instead")
+	}
+	return SimpleAuthorize(&e.arg.ManagerAuthArg)
+}
+
+func (m *ManagerRPC) Logout(arg ManagerLogoutArg, reply *ManagerLogoutReply) error {
+	return NewTask("Logout", &LogoutExecutor{arg, reply}).Run()
+}
@@ -16,7 +16,6 @@ import (
 	"atlantis/manager/datamodel"
 	. "atlantis/manager/rpc/types"
 	"atlantis/manager/supervisor"
-	"errors"
 	"fmt"
 )
 
@@ -29,7 +28,7 @@ type ContainerMaintenanceExecutor struct {
 }
 
 func (e *ContainerMaintenanceExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ContainerMaintenanceExecutor) Result() interface{} {
@@ -42,7 +41,7 @@ func (e *ContainerMaintenanceExecutor) Description() string {
 
 func (e *ContainerMaintenanceExecutor) Execute(t *Task) error {
 	if e.arg.ContainerID == "" {
-		return errors.New("Please specify a container id.")
+		return errorOf(ErrInvalid, "Please specify a container id.")
 	}
 	instance, err := datamodel.GetInstance(e.arg.ContainerID)
 	if err != nil {
@@ -50,6 +49,12 @@ func (e *ContainerMaintenanceExecutor) Execute(t *Task) error {
 	}
 	ihReply, err := supervisor.ContainerMaintenance(instance.Host, e.arg.ContainerID, e.arg.Maintenance)
 	e.reply.Status = ihReply.Status
+	if err == nil && ihReply.Status == StatusOk {
+		// remembered so that GetContainers can say which containers are in maintenance without asking supervisors
+		if err := instance.SetMaintenance(e.arg.Maintenance); err != nil {
+			t.Log("-> could not save maintenance state of %s: %s", e.arg.ContainerID, err)
+		}
+	}
 	return err
 }
 
@@ -58,12 +63,12 @@ func (e *ContainerMaintenanceExecutor) Authorize() error {
 	if err != nil {
 		return err
 	}
-	return AuthorizeApp(&e.arg.ManagerAuthArg, instance.App)
+	return AuthorizeAction(&e.arg.ManagerAuthArg, "maintenance", instance.App, instance.Env)
 }
 
 func (m *ManagerRPC) ContainerMaintenance(arg ManagerContainerMaintenanceArg,
 	reply *ManagerContainerMaintenanceReply) error {
-	return NewTask("ContainerMaintenance", &ContainerMaintenanceExecutor{arg, reply}).Run()
+	return NewAuditedTask("ContainerMaintenance", &ContainerMaintenanceExecutor{arg, reply}).Run()
 }
 
 // Manager Idle Check
@@ -74,7 +79,7 @@ type IdleExecutor struct {
 }
 
 func (e *IdleExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *IdleExecutor) Result() interface{} {
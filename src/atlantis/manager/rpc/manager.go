@@ -15,7 +15,6 @@ import (
 	. "atlantis/common"
 	"atlantis/manager/datamodel"
 	. "atlantis/manager/rpc/types"
-	"errors"
 	"fmt"
 )
 
@@ -25,7 +24,7 @@ type AddRoleExecutor struct {
 }
 
 func (e *AddRoleExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *AddRoleExecutor) Result() interface{} {
@@ -43,13 +42,13 @@ func (e *AddRoleExecutor) Authorize() error {
 
 func (e *AddRoleExecutor) Execute(t *Task) error {
 	if e.arg.Host == "" {
-		return errors.New("Please specify a host")
+		return errorOf(ErrInvalid, "Please specify a host")
 	}
 	if e.arg.Region == "" {
-		return errors.New("Please specify a region")
+		return errorOf(ErrInvalid, "Please specify a region")
 	}
 	if e.arg.Role == "" {
-		return errors.New("Please specify a role")
+		return errorOf(ErrInvalid, "Please specify a role")
 	}
 	zkManager, err := datamodel.GetManager(e.arg.Region, e.arg.Host)
 	if err != nil {
@@ -68,7 +67,7 @@ func (e *AddRoleExecutor) Execute(t *Task) error {
 }
 
 func (m *ManagerRPC) AddRole(arg ManagerRoleArg, reply *ManagerRoleReply) error {
-	return NewTask("AddRole", &AddRoleExecutor{arg, reply}).Run()
+	return NewAuditedTask("AddRole", &AddRoleExecutor{arg, reply}).Run()
 }
 
 type RemoveRoleExecutor struct {
@@ -77,7 +76,7 @@ type RemoveRoleExecutor struct {
 }
 
 func (e *RemoveRoleExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *RemoveRoleExecutor) Result() interface{} {
@@ -95,13 +94,13 @@ func (e *RemoveRoleExecutor) Authorize() error {
 
 func (e *RemoveRoleExecutor) Execute(t *Task) error {
 	if e.arg.Host == "" {
-		return errors.New("Please specify a host")
+		return errorOf(ErrInvalid, "Please specify a host")
 	}
 	if e.arg.Region == "" {
-		return errors.New("Please specify a region")
+		return errorOf(ErrInvalid, "Please specify a region")
 	}
 	if e.arg.Role == "" {
-		return errors.New("Please specify a role")
+		return errorOf(ErrInvalid, "Please specify a role")
 	}
 	zkManager, err := datamodel.GetManager(e.arg.Region, e.arg.Host)
 	if err != nil {
@@ -120,7 +119,7 @@ func (e *RemoveRoleExecutor) Execute(t *Task) error {
 }
 
 func (m *ManagerRPC) RemoveRole(arg ManagerRoleArg, reply *ManagerRoleReply) error {
-	return NewTask("RemoveRole", &RemoveRoleExecutor{arg, reply}).Run()
+	return NewAuditedTask("RemoveRole", &RemoveRoleExecutor{arg, reply}).Run()
 }
 
 type HasRoleExecutor struct {
@@ -129,7 +128,7 @@ type HasRoleExecutor struct {
 }
 
 func (e *HasRoleExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *HasRoleExecutor) Result() interface{} {
@@ -147,16 +146,16 @@ func (e *HasRoleExecutor) Authorize() error {
 
 func (e *HasRoleExecutor) Execute(t *Task) error {
 	if e.arg.Host == "" {
-		return errors.New("Please specify a host")
+		return errorOf(ErrInvalid, "Please specify a host")
 	}
 	if e.arg.Region == "" {
-		return errors.New("Please specify a region")
+		return errorOf(ErrInvalid, "Please specify a region")
 	}
 	if e.arg.Role == "" {
-		return errors.New("Please specify a role")
+		return errorOf(ErrInvalid, "Please specify a role")
 	}
 	if e.arg.Type == "" {
-		return errors.New("Please specify a type")
+		return errorOf(ErrInvalid, "Please specify a type")
 	}
 	has, err := datamodel.ManagerHasRole(e.arg.Region, e.arg.Host, e.arg.Role, e.arg.Type)
 	if err != nil {
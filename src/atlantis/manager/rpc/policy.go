@@ -0,0 +1,419 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/common"
+	"atlantis/manager/datamodel"
+	. "atlantis/manager/rpc/types"
+	"fmt"
+	gozk "github.com/scalingdata/gozk"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const policyIDSize = 8
+
+var policyActions = []string{"deploy", "teardown", "ssh", "maintenance", "read", "hotfix"}
+
+// policyCovers returns true if rule says something about action on app in env.
+func policyCovers(rule *PolicyRule, action, app, env string) bool {
+	appMatch, _ := path.Match(rule.App, app)
+	envMatch, _ := path.Match(rule.Env, env)
+	return appMatch && envMatch && contains(rule.Actions, action)
+}
+
+func policyGrants(rule *PolicyRule, user string, teams []string) bool {
+	return (rule.User != "" && rule.User == user) || (rule.Team != "" && contains(teams, rule.Team))
+}
+
+// evaluatePolicy returns the rules that cover action on app in env and the first of them that grants user or one
+// of teams, if any.
+func evaluatePolicy(rules []*PolicyRule, user string, teams []string, action, app, env string) (covering []*PolicyRule,
+	granted *PolicyRule) {
+	for _, rule := range rules {
+		if !policyCovers(rule, action, app, env) {
+			continue
+		}
+		covering = append(covering, rule)
+		if granted == nil && policyGrants(rule, user, teams) {
+			granted = rule
+		}
+	}
+	return
+}
+
+func describePolicy(rule *PolicyRule) string {
+	who := "user " + rule.User
+	if rule.Team != "" {
+		who = "team " + rule.Team
+	}
+	return fmt.Sprintf("policy %s (%s may %s %s in %s)", rule.ID, who, strings.Join(rule.Actions, "/"), rule.App,
+		rule.Env)
+}
+
+// listPolicies returns every policy. Policies restrict access, so if they can't all be read the caller has to deny
+// rather than go on as if there were none.
+func listPolicies() ([]*PolicyRule, error) {
+	ids, err := datamodel.ListPolicies()
+	if err != nil && !gozk.IsError(err, gozk.ZNONODE) {
+		return nil, err
+	}
+	sort.Strings(ids)
+	rules := []*PolicyRule{}
+	for _, id := range ids {
+		zp, err := datamodel.GetPolicy(id)
+		if gozk.IsError(err, gozk.ZNONODE) {
+			continue // removed since we listed them
+		} else if err != nil {
+			return nil, err
+		}
+		rules = append(rules, &zp.PolicyRule)
+	}
+	return rules, nil
+}
+
+// policyMayCover returns true if any of rules says something about action on app in some env.
+func policyMayCover(rules []*PolicyRule, action, app string) bool {
+	for _, rule := range rules {
+		if appMatch, _ := path.Match(rule.App, app); appMatch && contains(rule.Actions, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// decidePolicy decides whether rules let user (in teams) do action on app in every one of envs. decided is false if
+// no rule covers any of them, in which case it comes down to the app.
+func decidePolicy(rules []*PolicyRule, user string, teams []string, action, app string, envs []string) (decided,
+	allowed bool, reason string) {
+	var grants []*PolicyRule
+	for _, env := range envs {
+		covering, granted := evaluatePolicy(rules, user, teams, action, app, env)
+		if granted != nil {
+			grants = append(grants, granted)
+			continue
+		}
+		if len(covering) > 0 {
+			ids := make([]string, len(covering))
+			for i, rule := range covering {
+				ids[i] = rule.ID
+			}
+			return true, false, fmt.Sprintf("%s of %s in %s is limited by policies %s and none of them grant %s",
+				action, app, env, strings.Join(ids, ", "), user)
+		}
+	}
+	if len(grants) > 0 && len(grants) == len(envs) {
+		return true, true, "allowed by " + describePolicy(grants[0])
+	}
+	return false, false, ""
+}
+
+// explainAction decides whether auth may do action on app in env and says why. auth must already be
+// authenticated.
+func explainAction(auth *ManagerAuthArg, action, app, env string) (bool, string) {
+	rules, err := listPolicies()
+	if err != nil {
+		return false, "could not read policies: " + err.Error()
+	}
+	return explainActionWith(rules, auth, action, app, env)
+}
+
+// explainActionWith is explainAction with the policies already read. A read of app across envs (env "") reads
+// every env the app is in, so if a policy covers reads of app it has to allow each of them.
+func explainActionWith(rules []*PolicyRule, auth *ManagerAuthArg, action, app, env string) (bool, string) {
+	var zt *datamodel.ZkToken
+	if isToken(auth.Secret) {
+		var err error
+		if zt, err = lookupToken(auth.Secret); err != nil {
+			return false, err.Error()
+		}
+		if !zt.AllowsApp(app) {
+			return false, "API Token " + zt.ID + " is not allowed app " + app
+		}
+		// reads across envs are fine, changes have to name one of the token's envs
+		if !zt.AllowsEnv(env) && !(action == "read" && env == "") {
+			if env == "" {
+				return false, "API Token " + zt.ID + " must name an env"
+			}
+			return false, "API Token " + zt.ID + " is not allowed env " + env
+		}
+	} else if authorizeSuperUser(auth) == nil {
+		return true, auth.User + " is a superuser"
+	}
+	envs := []string{env}
+	if action == "read" && env == "" && policyMayCover(rules, action, app) {
+		deployed, err := appEnvs(app, "")
+		if err != nil {
+			return false, "could not read the envs of " + app + ": " + err.Error()
+		}
+		if len(deployed) > 0 {
+			envs = deployed
+		}
+	}
+	teams, _ := ListTeams(auth)
+	if decided, allowed, reason := decidePolicy(rules, auth.User, teams, action, app, envs); decided {
+		return allowed, reason
+	}
+	// hotfixes jump the task queue, so only superusers and those a policy grants may
+	if action == "hotfix" {
+		return false, "no policy grants hotfix of " + app + " in " + env + " to " + auth.User
+	}
+	// no policy says anything, so it comes down to the app
+	if zt != nil {
+		if !tokenUserAllowsApp(zt, app) {
+			return false, "API Token " + zt.ID + " is for " + zt.User + ", who is no longer allowed app " + app
+		}
+		return true, "no policy covers " + action + " of " + app + " in " + env + " and the API token allows " + app
+	}
+	if IsAppAllowed(auth, auth.User, app) {
+		return true, "no policy covers " + action + " of " + app + " in " + env + " and " + auth.User +
+			" is allowed " + app
+	}
+	return false, "Not Authorized to Deploy App"
+}
+
+// AuthorizeAction checks that auth may do action (deploy, teardown, ssh, maintenance, read or hotfix) on app in
+// env.
+func AuthorizeAction(auth *ManagerAuthArg, action, app, env string) error {
+	if err := SimpleAuthorize(auth); err != nil {
+		return err
+	}
+	if allowed, reason := explainAction(auth, action, app, env); !allowed {
+		return errorOf(ErrForbidden, "Permission Denied: "+reason)
+	}
+	return nil
+}
+
+// readFilter returns a func that tells whether auth may read app in env, which asks explainAction once for each
+// app and env, or nil if auth may read everything. If the policies can't be read it lets nothing through. auth must
+// already be authenticated.
+func readFilter(auth *ManagerAuthArg) func(app, env string) bool {
+	if !isToken(auth.Secret) && authorizeSuperUser(auth) == nil {
+		return nil
+	}
+	rules, err := listPolicies()
+	if err != nil {
+		log.Printf("[RPC][Policy] could not read policies, hiding everything from %s: %s", auth.User, err)
+		return func(app, env string) bool { return false }
+	}
+	decided := map[[2]string]bool{}
+	return func(app, env string) bool {
+		key := [2]string{app, env}
+		allowed, ok := decided[key]
+		if !ok {
+			allowed, _ = explainActionWith(rules, auth, "read", app, env)
+			decided[key] = allowed
+		}
+		return allowed
+	}
+}
+
+// appEnvs returns the envs app (at sha, if given) is deployed in.
+func appEnvs(app, sha string) ([]string, error) {
+	shas := []string{sha}
+	if sha == "" {
+		var err error
+		if shas, err = datamodel.ListShas(app); err != nil && !gozk.IsError(err, gozk.ZNONODE) {
+			return nil, err
+		}
+	}
+	seen := map[string]bool{}
+	envs := []string{}
+	for _, sha := range shas {
+		shaEnvs, err := datamodel.ListAppEnvs(app, sha)
+		if err != nil && !gozk.IsError(err, gozk.ZNONODE) {
+			return nil, err
+		}
+		for _, env := range shaEnvs {
+			if !seen[env] {
+				seen[env] = true
+				envs = append(envs, env)
+			}
+		}
+	}
+	return envs, nil
+}
+
+type AddPolicyExecutor struct {
+	arg   ManagerAddPolicyArg
+	reply *ManagerAddPolicyReply
+}
+
+func (e *AddPolicyExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *AddPolicyExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *AddPolicyExecutor) Description() string {
+	return fmt.Sprintf("[%s] Add Policy %s in %s", e.arg.ManagerAuthArg.User, e.arg.App, e.arg.Env)
+}
+
+func (e *AddPolicyExecutor) Authorize() error {
+	return AuthorizeSuperUser(&e.arg.ManagerAuthArg)
+}
+
+func (e *AddPolicyExecutor) Execute(t *Task) error {
+	if (e.arg.Team == "") == (e.arg.User == "") {
+		return errorOf(ErrInvalid, "Please specify either a team or a user")
+	}
+	if len(e.arg.Actions) == 0 {
+		return errorOf(ErrInvalid, "Please specify at least one action")
+	}
+	for _, action := range e.arg.Actions {
+		if !contains(policyActions, action) {
+			return errorOf(ErrInvalid, "Unknown action "+action+", use "+strings.Join(policyActions, ", "))
+		}
+	}
+	rule := PolicyRule{
+		ID:        CreateRandomID(policyIDSize),
+		Team:      e.arg.Team,
+		User:      e.arg.User,
+		App:       e.arg.App,
+		Env:       e.arg.Env,
+		Actions:   e.arg.Actions,
+		Created:   time.Now(),
+		CreatedBy: e.arg.ManagerAuthArg.User,
+	}
+	for _, pattern := range []*string{&rule.App, &rule.Env} {
+		if *pattern == "" {
+			*pattern = "*"
+		}
+		if _, err := path.Match(*pattern, ""); err != nil {
+			return errorOf(ErrInvalid, "Bad pattern "+*pattern+": "+err.Error())
+		}
+	}
+	zp := &datamodel.ZkPolicy{rule}
+	if err := zp.Save(); err != nil {
+		return err
+	}
+	t.Log("-> added %s", describePolicy(&zp.PolicyRule))
+	e.reply.Policy = &zp.PolicyRule
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) AddPolicy(arg ManagerAddPolicyArg, reply *ManagerAddPolicyReply) error {
+	return NewAuditedTask("AddPolicy", &AddPolicyExecutor{arg, reply}).Run()
+}
+
+type RemovePolicyExecutor struct {
+	arg   ManagerRemovePolicyArg
+	reply *ManagerRemovePolicyReply
+}
+
+func (e *RemovePolicyExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *RemovePolicyExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *RemovePolicyExecutor) Description() string {
+	return "[" + e.arg.ManagerAuthArg.User + "] Remove Policy " + e.arg.ID
+}
+
+func (e *RemovePolicyExecutor) Authorize() error {
+	return AuthorizeSuperUser(&e.arg.ManagerAuthArg)
+}
+
+func (e *RemovePolicyExecutor) Execute(t *Task) error {
+	zp, err := datamodel.GetPolicy(e.arg.ID)
+	if err != nil {
+		return errorOf(ErrNotFound, "No such policy "+e.arg.ID)
+	}
+	if err := zp.Delete(); err != nil {
+		return err
+	}
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) RemovePolicy(arg ManagerRemovePolicyArg, reply *ManagerRemovePolicyReply) error {
+	return NewAuditedTask("RemovePolicy", &RemovePolicyExecutor{arg, reply}).Run()
+}
+
+type ListPoliciesExecutor struct {
+	arg   ManagerListPoliciesArg
+	reply *ManagerListPoliciesReply
+}
+
+func (e *ListPoliciesExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *ListPoliciesExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *ListPoliciesExecutor) Description() string {
+	return "[" + e.arg.ManagerAuthArg.User + "] List Policies"
+}
+
+func (e *ListPoliciesExecutor) Authorize() error {
+	return SimpleAuthorize(&e.arg.ManagerAuthArg)
+}
+
+func (e *ListPoliciesExecutor) Execute(t *Task) error {
+	policies, err := listPolicies()
+	if err != nil {
+		return err
+	}
+	e.reply.Policies = policies
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) ListPolicies(arg ManagerListPoliciesArg, reply *ManagerListPoliciesReply) error {
+	return NewTask("ListPolicies", &ListPoliciesExecutor{arg, reply}).Run()
+}
+
+type CanIExecutor struct {
+	arg   ManagerCanIArg
+	reply *ManagerCanIReply
+}
+
+func (e *CanIExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *CanIExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *CanIExecutor) Description() string {
+	return fmt.Sprintf("[%s] Can I %s %s in %s", e.arg.ManagerAuthArg.User, e.arg.Action, e.arg.App, e.arg.Env)
+}
+
+func (e *CanIExecutor) Authorize() error {
+	return SimpleAuthorize(&e.arg.ManagerAuthArg)
+}
+
+func (e *CanIExecutor) Execute(t *Task) error {
+	if !contains(policyActions, e.arg.Action) {
+		return errorOf(ErrInvalid, "Unknown action "+e.arg.Action+", use "+strings.Join(policyActions, ", "))
+	}
+	e.reply.Allowed, e.reply.Reason = explainAction(&e.arg.ManagerAuthArg, e.arg.Action, e.arg.App, e.arg.Env)
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) CanI(arg ManagerCanIArg, reply *ManagerCanIReply) error {
+	return NewTask("CanI", &CanIExecutor{arg, reply}).Run()
+}
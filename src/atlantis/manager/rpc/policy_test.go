@@ -0,0 +1,118 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	"atlantis/manager/datamodel"
+	aldap "atlantis/manager/ldap"
+	. "atlantis/manager/rpc/types"
+	. "github.com/adjust/gocheck"
+)
+
+type PolicySuite struct{}
+
+var _ = Suite(&PolicySuite{})
+
+func (s *PolicySuite) TestEvaluatePolicy(c *C) {
+	prod := &PolicyRule{ID: "prod", Team: "sre", App: "*", Env: "prod*", Actions: []string{"deploy", "teardown"}}
+	oncall := &PolicyRule{ID: "oncall", User: "alice", App: "web", Env: "prod", Actions: []string{"deploy", "ssh"}}
+	rules := []*PolicyRule{prod, oncall}
+
+	// nothing covers dev, so app permissions decide
+	covering, granted := evaluatePolicy(rules, "bob", nil, "deploy", "web", "dev")
+	c.Assert(covering, HasLen, 0)
+	c.Assert(granted, IsNil)
+
+	covering, granted = evaluatePolicy(rules, "bob", []string{"web-team"}, "deploy", "web", "prod")
+	c.Assert(covering, DeepEquals, []*PolicyRule{prod, oncall})
+	c.Assert(granted, IsNil)
+	_, granted = evaluatePolicy(rules, "bob", []string{"sre"}, "deploy", "web", "prod-east")
+	c.Assert(granted, Equals, prod)
+	_, granted = evaluatePolicy(rules, "alice", nil, "deploy", "web", "prod")
+	c.Assert(granted, Equals, oncall)
+	_, granted = evaluatePolicy(rules, "alice", nil, "deploy", "api", "prod")
+	c.Assert(granted, IsNil)
+
+	covering, granted = evaluatePolicy(rules, "alice", nil, "ssh", "web", "prod")
+	c.Assert(covering, DeepEquals, []*PolicyRule{oncall})
+	c.Assert(granted, Equals, oncall)
+	covering, _ = evaluatePolicy(rules, "bob", nil, "read", "web", "prod")
+	c.Assert(covering, HasLen, 0)
+}
+
+func (s *PolicySuite) TestDecidePolicy(c *C) {
+	prodRead := &PolicyRule{ID: "prod-read", Team: "sre", App: "web", Env: "prod", Actions: []string{"read"}}
+	stagingRead := &PolicyRule{ID: "staging-read", Team: "web-team", App: "web", Env: "staging",
+		Actions: []string{"read"}}
+	rules := []*PolicyRule{prodRead, stagingRead}
+	c.Assert(policyMayCover(rules, "read", "web"), Equals, true)
+	c.Assert(policyMayCover(rules, "deploy", "web"), Equals, false)
+	c.Assert(policyMayCover(rules, "read", "api"), Equals, false)
+
+	// nothing covers dev, so it comes down to the app
+	decided, _, _ := decidePolicy(rules, "bob", []string{"web-team"}, "read", "web", []string{"dev"})
+	c.Assert(decided, Equals, false)
+	decided, allowed, reason := decidePolicy(rules, "bob", []string{"web-team"}, "read", "web",
+		[]string{"staging"})
+	c.Assert(decided && allowed, Equals, true)
+	c.Assert(reason, Matches, "allowed by policy staging-read.*")
+	// reading across envs takes every env the app is in
+	decided, allowed, reason = decidePolicy(rules, "bob", []string{"web-team"}, "read", "web",
+		[]string{"staging", "prod"})
+	c.Assert(decided, Equals, true)
+	c.Assert(allowed, Equals, false)
+	c.Assert(reason, Matches, "read of web in prod is limited by policies prod-read.*")
+	decided, allowed, _ = decidePolicy(rules, "bob", []string{"web-team", "sre"}, "read", "web",
+		[]string{"staging", "prod"})
+	c.Assert(decided && allowed, Equals, true)
+	// one env no policy covers leaves it to the app
+	decided, _, _ = decidePolicy(rules, "bob", []string{"web-team"}, "read", "web", []string{"staging", "dev"})
+	c.Assert(decided, Equals, false)
+}
+
+func (s *PolicySuite) TestDescribePolicy(c *C) {
+	c.Assert(describePolicy(&PolicyRule{ID: "p", Team: "sre", App: "*", Env: "prod*",
+		Actions: []string{"deploy", "teardown"}}), Equals, "policy p (team sre may deploy/teardown * in prod*)")
+	c.Assert(describePolicy(&PolicyRule{ID: "p", User: "alice", App: "web", Env: "prod", Actions: []string{"ssh"}}),
+		Equals, "policy p (user alice may ssh web in prod)")
+}
+
+func (s *PolicySuite) TestTokenUserAllowsApp(c *C) {
+	ldapServer, superUserGroup := aldap.LdapServer, aldap.SuperUserGroup
+	aldap.LdapServer, aldap.SuperUserGroup = "ldap.example.com", "superusers"
+	defer func() { aldap.LdapServer, aldap.SuperUserGroup = ldapServer, superUserGroup }()
+	aldap.UserTeams.Set("root", []string{"superusers"})
+
+	service := &datamodel.ZkToken{APIToken: APIToken{ID: "ci", User: "ci-bot", ServiceAccount: true}}
+	c.Assert(tokenUserAllowsApp(service, "web"), Equals, true)
+	root := &datamodel.ZkToken{APIToken: APIToken{ID: "root", User: "root"}}
+	c.Assert(tokenUserAllowsApp(root, "web"), Equals, true)
+}
+
+func (s *PolicySuite) TestTokenTeams(c *C) {
+	ldapServer := aldap.LdapServer
+	aldap.LdapServer = "ldap.example.com"
+	defer func() { aldap.LdapServer = ldapServer }()
+	aldap.UserTeams.Set("alice", []string{"web-team"})
+
+	// personal tokens are in their user's teams, service accounts in the team of their role
+	teams, err := tokenTeams(&datamodel.ZkToken{APIToken: APIToken{ID: "mine", User: "alice"}})
+	c.Assert(err, IsNil)
+	c.Assert(teams, DeepEquals, []string{"web-team"})
+	teams, err = tokenTeams(&datamodel.ZkToken{APIToken: APIToken{ID: "ci", User: "ci-bot", ServiceAccount: true,
+		Role: "superusers", Owner: "alice"}})
+	c.Assert(err, IsNil)
+	c.Assert(teams, DeepEquals, []string{"superusers"})
+	teams, err = tokenTeams(&datamodel.ZkToken{APIToken: APIToken{ID: "ci", User: "ci-bot", ServiceAccount: true}})
+	c.Assert(err, IsNil)
+	c.Assert(teams, HasLen, 0)
+}
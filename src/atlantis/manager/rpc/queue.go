@@ -0,0 +1,284 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/common"
+	"atlantis/manager/datamodel"
+	. "atlantis/manager/rpc/types"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// taskQueue holds async tasks until there is room for them to run. At most limits[name] tasks with the same name
+// and appLimit tasks for the same app run at once (0 means no limit). Priority tasks wait ahead of the others; a
+// task that is blocked only by its own app does not hold up tasks for other apps.
+type taskQueue struct {
+	sync.Mutex
+	limits      map[string]int
+	appLimit    int
+	running     map[string]*queuedTask
+	runningName map[string]int
+	runningApp  map[string]int
+	waiting     []*queuedTask
+}
+
+type queuedTask struct {
+	QueuedTask
+	task  *Task
+	ready chan bool
+}
+
+var runQueue = newTaskQueue(map[string]int{}, 0)
+
+func newTaskQueue(limits map[string]int, appLimit int) *taskQueue {
+	return &taskQueue{
+		limits:      limits,
+		appLimit:    appLimit,
+		running:     map[string]*queuedTask{},
+		runningName: map[string]int{},
+		runningApp:  map[string]int{},
+		waiting:     []*queuedTask{},
+	}
+}
+
+// InitTaskQueue sets the concurrency limits of the task queue. limits looks like "Deploy:10,Teardown:10".
+func InitTaskQueue(limits string, appLimit uint) error {
+	parsed, err := ParseTaskLimits(limits)
+	if err != nil {
+		return err
+	}
+	runQueue.Lock()
+	runQueue.limits = parsed
+	runQueue.appLimit = int(appLimit)
+	runQueue.Unlock()
+	// the new limits may have made room
+	runQueue.dispatch()
+	return nil
+}
+
+func ParseTaskLimits(limits string) (map[string]int, error) {
+	parsed := map[string]int{}
+	for _, limit := range strings.Split(limits, ",") {
+		limit = strings.TrimSpace(limit)
+		if limit == "" {
+			continue
+		}
+		parts := strings.Split(limit, ":")
+		if len(parts) != 2 {
+			return nil, errors.New("Invalid task limit " + limit + ", expected name:count")
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || count < 0 {
+			return nil, errors.New("Invalid task limit " + limit + ", expected name:count")
+		}
+		parsed[strings.TrimSpace(parts[0])] = count
+	}
+	return parsed, nil
+}
+
+// enter blocks until there is room for t to run and returns the function to call when it is done.
+func (q *taskQueue) enter(t *Task, entry QueuedTask) func() {
+	qt := &queuedTask{QueuedTask: entry, task: t, ready: make(chan bool)}
+	qt.ID = t.ID
+	qt.Queued = time.Now()
+	q.Lock()
+	// priority tasks go behind the other priority tasks, everything else goes to the back
+	i := len(q.waiting)
+	if qt.Priority {
+		for i = 0; i < len(q.waiting) && q.waiting[i].Priority; i++ {
+		}
+	}
+	q.waiting = append(q.waiting, nil)
+	copy(q.waiting[i+1:], q.waiting[i:])
+	q.waiting[i] = qt
+	q.Unlock()
+	q.dispatch()
+	<-qt.ready
+	return func() { q.leave(qt) }
+}
+
+func (q *taskQueue) leave(qt *queuedTask) {
+	q.Lock()
+	delete(q.running, qt.ID)
+	q.runningName[qt.Name]--
+	if qt.App != "" {
+		q.runningApp[qt.App]--
+	}
+	q.Unlock()
+	q.dispatch()
+}
+
+func (q *taskQueue) fits(qt *queuedTask) bool {
+	if limit := q.limits[qt.Name]; limit > 0 && q.runningName[qt.Name] >= limit {
+		return false
+	}
+	if qt.App != "" && q.appLimit > 0 && q.runningApp[qt.App] >= q.appLimit {
+		return false
+	}
+	return true
+}
+
+// dispatch starts every waiting task there is room for, in order, and tells the rest where they are in line.
+func (q *taskQueue) dispatch() {
+	q.Lock()
+	defer q.Unlock()
+	waiting := []*queuedTask{}
+	for _, qt := range q.waiting {
+		if !q.fits(qt) {
+			waiting = append(waiting, qt)
+			continue
+		}
+		q.running[qt.ID] = qt
+		q.runningName[qt.Name]++
+		if qt.App != "" {
+			q.runningApp[qt.App]++
+		}
+		qt.Position = 0
+		close(qt.ready)
+	}
+	q.waiting = waiting
+	for i, qt := range q.waiting {
+		if qt.Position != i+1 {
+			qt.Position = i + 1
+			qt.task.LogStatus("Queued at position %d", qt.Position)
+		}
+	}
+}
+
+// Snapshot returns copies of the running and waiting entries, in order.
+func (q *taskQueue) Snapshot() (running []*QueuedTask, waiting []*QueuedTask) {
+	q.Lock()
+	defer q.Unlock()
+	running = []*QueuedTask{}
+	for _, qt := range q.running {
+		entry := qt.QueuedTask
+		running = append(running, &entry)
+	}
+	sort.Sort(queuedByTime(running))
+	waiting = []*QueuedTask{}
+	for _, qt := range q.waiting {
+		entry := qt.QueuedTask
+		waiting = append(waiting, &entry)
+	}
+	return
+}
+
+type queuedByTime []*QueuedTask
+
+func (q queuedByTime) Len() int           { return len(q) }
+func (q queuedByTime) Less(i, j int) bool { return q[i].Queued.Before(q[j].Queued) }
+func (q queuedByTime) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+// authExecutor is an executor that hands out the credentials of its request as they were passed. Request masks
+// the secret, so checks made on behalf of the task outside of Authorize have to use these.
+type authExecutor interface {
+	authArg() *ManagerAuthArg
+}
+
+// taskSubject returns who asked for the task e runs, which app and env it is for and whether it is a hotfix.
+func taskSubject(e TaskExecutor) (auth ManagerAuthArg, app, env string, hotfix bool) {
+	auth, app, env, hotfix = requestSubject(e.Request())
+	if a, ok := e.(authExecutor); ok {
+		auth = *a.authArg()
+	}
+	return
+}
+
+func requestSubject(request interface{}) (auth ManagerAuthArg, app, env string, hotfix bool) {
+	switch arg := request.(type) {
+	case ManagerDeployArg:
+		return arg.ManagerAuthArg, arg.App, arg.Env, arg.Hotfix
+	case ManagerTeardownArg:
+		app, env = arg.App, arg.Env
+		if app == "" && arg.ContainerID != "" {
+			if inst, err := datamodel.GetInstance(arg.ContainerID); err == nil {
+				app, env = inst.App, inst.Env
+			}
+		}
+		return arg.ManagerAuthArg, app, env, arg.Hotfix
+	case ManagerDeployContainerArg:
+		if inst, err := datamodel.GetInstance(arg.ContainerID); err == nil {
+			app, env = inst.App, inst.Env
+		}
+		return arg.ManagerAuthArg, app, env, false
+	case ManagerCopyContainerArg:
+		if inst, err := datamodel.GetInstance(arg.ContainerID); err == nil {
+			app, env = inst.App, inst.Env
+		}
+		return arg.ManagerAuthArg, app, env, false
+	case ManagerRegisterRouterArg:
+		auth = arg.ManagerAuthArg
+	case ManagerRegisterSupervisorArg:
+		auth = arg.ManagerAuthArg
+	case ManagerRegisterManagerArg:
+		auth = arg.ManagerAuthArg
+	}
+	return
+}
+
+// queueEntry describes a task for the task queue. Superuser tasks go ahead of the others, and so do hotfixes by
+// those a policy grants hotfix of app in env.
+func queueEntry(name string, auth ManagerAuthArg, app, env string, hotfix bool) QueuedTask {
+	priority := auth.User != "" && authorizeSuperUser(&auth) == nil
+	if !priority && hotfix {
+		priority, _ = explainAction(&auth, "hotfix", app, env)
+	}
+	return QueuedTask{
+		Name:     name,
+		App:      app,
+		User:     auth.User,
+		Priority: priority,
+	}
+}
+
+type TaskQueueExecutor struct {
+	arg   ManagerTaskQueueArg
+	reply *ManagerTaskQueueReply
+}
+
+func (e *TaskQueueExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *TaskQueueExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *TaskQueueExecutor) Description() string {
+	return "[" + e.arg.ManagerAuthArg.User + "] task queue"
+}
+
+func (e *TaskQueueExecutor) Authorize() error {
+	return SimpleAuthorize(&e.arg.ManagerAuthArg)
+}
+
+func (e *TaskQueueExecutor) Execute(t *Task) error {
+	e.reply.Running, e.reply.Waiting = runQueue.Snapshot()
+	runQueue.Lock()
+	e.reply.Limits = map[string]int{}
+	for name, limit := range runQueue.limits {
+		e.reply.Limits[name] = limit
+	}
+	e.reply.AppLimit = runQueue.appLimit
+	runQueue.Unlock()
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) TaskQueue(arg ManagerTaskQueueArg, reply *ManagerTaskQueueReply) error {
+	return NewTask("TaskQueue", &TaskQueueExecutor{arg, reply}).Run()
+}
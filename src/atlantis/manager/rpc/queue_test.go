@@ -0,0 +1,116 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/common"
+	aldap "atlantis/manager/ldap"
+	. "atlantis/manager/rpc/types"
+	. "github.com/adjust/gocheck"
+	"time"
+)
+
+type QueueSuite struct{}
+
+var _ = Suite(&QueueSuite{})
+
+func (s *QueueSuite) TestParseTaskLimits(c *C) {
+	limits, err := ParseTaskLimits("Deploy:2, Teardown:0,")
+	c.Assert(err, IsNil)
+	c.Assert(limits, DeepEquals, map[string]int{"Deploy": 2, "Teardown": 0})
+	_, err = ParseTaskLimits("Deploy")
+	c.Assert(err, Not(IsNil))
+	_, err = ParseTaskLimits("Deploy:lots")
+	c.Assert(err, Not(IsNil))
+}
+
+// enters q in the background and returns the channel that gets the leave function once the task may run
+func enterAsync(q *taskQueue, id string, entry QueuedTask) chan func() {
+	entered := make(chan func(), 1)
+	go func() {
+		entered <- q.enter(&Task{ID: id}, entry)
+	}()
+	return entered
+}
+
+func waitForWaiting(c *C, q *taskQueue, count int) []*QueuedTask {
+	for i := 0; i < 100; i++ {
+		if _, waiting := q.Snapshot(); len(waiting) == count {
+			return waiting
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Fatalf("queue never had %d waiting tasks", count)
+	return nil
+}
+
+func (s *QueueSuite) TestTaskQueue(c *C) {
+	q := newTaskQueue(map[string]int{"Deploy": 1}, 0)
+	leave1 := q.enter(&Task{ID: "1"}, QueuedTask{Name: "Deploy", App: "app"})
+	// other task types are not limited
+	q.enter(&Task{ID: "other"}, QueuedTask{Name: "RegisterRouter"})()
+
+	second := enterAsync(q, "2", QueuedTask{Name: "Deploy", App: "app"})
+	waitForWaiting(c, q, 1)
+	third := enterAsync(q, "3", QueuedTask{Name: "Deploy", App: "app", Priority: true})
+	waiting := waitForWaiting(c, q, 2)
+	c.Assert(waiting[0].ID, Equals, "3")
+	c.Assert(waiting[0].Position, Equals, 1)
+	c.Assert(waiting[1].ID, Equals, "2")
+	c.Assert(waiting[1].Position, Equals, 2)
+
+	leave1()
+	leave3 := <-third
+	waiting = waitForWaiting(c, q, 1)
+	c.Assert(waiting[0].ID, Equals, "2")
+	c.Assert(waiting[0].Position, Equals, 1)
+	running, _ := q.Snapshot()
+	c.Assert(running, HasLen, 1)
+	c.Assert(running[0].ID, Equals, "3")
+
+	leave3()
+	(<-second)()
+	running, waiting = q.Snapshot()
+	c.Assert(running, HasLen, 0)
+	c.Assert(waiting, HasLen, 0)
+}
+
+func (s *QueueSuite) TestTaskQueueAppLimit(c *C) {
+	q := newTaskQueue(map[string]int{}, 1)
+	leave := q.enter(&Task{ID: "1"}, QueuedTask{Name: "Deploy", App: "app"})
+	blocked := enterAsync(q, "2", QueuedTask{Name: "Deploy", App: "app"})
+	waitForWaiting(c, q, 1)
+	// a different app does not wait behind the blocked one
+	q.enter(&Task{ID: "3"}, QueuedTask{Name: "Deploy", App: "other"})()
+	leave()
+	(<-blocked)()
+}
+
+func (s *QueueSuite) TestSuperUserHasPriority(c *C) {
+	superUserGroup := aldap.SuperUserGroup
+	aldap.SuperUserGroup = "superusers"
+	defer func() { aldap.SuperUserGroup = superUserGroup }()
+	expires := time.Now().Add(time.Hour)
+	aldap.Sessions.Put("root-secret", &aldap.Session{User: "root", Team: []string{"superusers"}, Expires: expires})
+	defer aldap.Sessions.Delete("root", "root-secret")
+	aldap.Sessions.Put("alice-secret", &aldap.Session{User: "alice", Team: []string{"devs"}, Expires: expires})
+	defer aldap.Sessions.Delete("alice", "alice-secret")
+
+	deploy := func(user, secret string) QueuedTask {
+		e := &DeployExecutor{ManagerDeployArg{ManagerAuthArg: ManagerAuthArg{User: user, Secret: secret},
+			App: "app", Env: "prod"}, &ManagerDeployReply{}}
+		auth, app, env, hotfix := taskSubject(e)
+		return queueEntry("Deploy", auth, app, env, hotfix)
+	}
+	c.Assert(deploy("root", "root-secret").Priority, Equals, true)
+	c.Assert(deploy("alice", "alice-secret").Priority, Equals, false)
+}
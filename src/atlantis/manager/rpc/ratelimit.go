@@ -0,0 +1,309 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/common"
+	aldap "atlantis/manager/ldap"
+	. "atlantis/manager/rpc/types"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------------------------------------------------
+// Rate Limits
+// ----------------------------------------------------------------------------------------------------------
+
+// Every RPC and REST request takes a token from the bucket of its source IP and, if it is known who sent it, from
+// the bucket of its user. Buckets are per endpoint class: read, mutate or login. A request is rejected if either
+// bucket is empty. Limits are per manager.
+
+const (
+	RateClassRead   = "read"
+	RateClassMutate = "mutate"
+	RateClassLogin  = "login"
+)
+
+type RateLimit struct {
+	Rate  float64 // tokens added per second; 0 means no limit
+	Burst float64 // most tokens a bucket holds
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	stats  RateLimitStats
+}
+
+type rateLimiter struct {
+	sync.Mutex
+	limits  map[string]RateLimit
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+var (
+	limiter   = newRateLimiter(map[string]RateLimit{})
+	pruneOnce sync.Once
+)
+
+func newRateLimiter(limits map[string]RateLimit) *rateLimiter {
+	return &rateLimiter{limits: limits, buckets: map[string]*tokenBucket{}, now: time.Now}
+}
+
+// A RateLimitError says which bucket ran out and when to try again.
+type RateLimitError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("Rate limit exceeded for %s, retry in %s", e.Key, e.RetryAfter)
+}
+
+// InitRateLimits sets the limits of each class. limits looks like "read:20/40,mutate:2/10,login:0.2/5", which is
+// rate per second / burst. Classes that are not mentioned are not limited.
+func InitRateLimits(limits string) error {
+	parsed, err := ParseRateLimits(limits)
+	if err != nil {
+		return err
+	}
+	limiter.Lock()
+	limiter.limits = parsed
+	limiter.buckets = map[string]*tokenBucket{}
+	limiter.Unlock()
+	pruneOnce.Do(func() { go limiter.pruneRoutine() })
+	return nil
+}
+
+func ParseRateLimits(limits string) (map[string]RateLimit, error) {
+	parsed := map[string]RateLimit{}
+	for _, limit := range strings.Split(limits, ",") {
+		limit = strings.TrimSpace(limit)
+		if limit == "" {
+			continue
+		}
+		invalid := errors.New("Invalid rate limit " + limit + ", expected class:rate/burst")
+		parts := strings.Split(limit, ":")
+		if len(parts) != 2 {
+			return nil, invalid
+		}
+		class := strings.TrimSpace(parts[0])
+		if class != RateClassRead && class != RateClassMutate && class != RateClassLogin {
+			return nil, errors.New("Invalid rate limit class " + class + ", use read, mutate or login")
+		}
+		values := strings.Split(parts[1], "/")
+		if len(values) != 2 {
+			return nil, invalid
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(values[0]), 64)
+		if err != nil || rate < 0 {
+			return nil, invalid
+		}
+		burst, err := strconv.ParseFloat(strings.TrimSpace(values[1]), 64)
+		if err != nil || burst < 1 {
+			return nil, invalid
+		}
+		parsed[class] = RateLimit{rate, burst}
+	}
+	return parsed, nil
+}
+
+// take takes a token from each bucket in keys, or from none of them if one is empty.
+func (l *rateLimiter) take(class string, keys ...string) error {
+	l.Lock()
+	defer l.Unlock()
+	limit, ok := l.limits[class]
+	if !ok || limit.Rate == 0 {
+		return nil
+	}
+	now := l.now()
+	buckets := make([]*tokenBucket, len(keys))
+	for i, key := range keys {
+		bucket := l.buckets[key]
+		if bucket == nil {
+			bucket = &tokenBucket{tokens: limit.Burst, last: now, stats: RateLimitStats{Key: key, Class: class}}
+			l.buckets[key] = bucket
+		}
+		bucket.tokens = math.Min(limit.Burst, bucket.tokens+now.Sub(bucket.last).Seconds()*limit.Rate)
+		bucket.last = now
+		buckets[i] = bucket
+	}
+	for _, bucket := range buckets {
+		if bucket.tokens < 1 {
+			if now.Sub(bucket.stats.LastThrottled) > time.Minute {
+				log.Printf("[RateLimit] throttling %s", bucket.stats.Key)
+			}
+			bucket.stats.Throttled++
+			bucket.stats.LastThrottled = now
+			wait := time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second))
+			return &RateLimitError{bucket.stats.Key, wait.Round(time.Millisecond)}
+		}
+	}
+	for _, bucket := range buckets {
+		bucket.tokens--
+		bucket.stats.Allowed++
+	}
+	return nil
+}
+
+// how long the stats of a throttled bucket are kept for RateLimits once it is full again
+var throttledRetention = time.Hour
+
+// prune drops the buckets that are full again and were not throttled within throttledRetention; they are the same
+// as no bucket at all. Buckets are made for whatever user a request claims to be, so they must not pile up.
+func (l *rateLimiter) prune() {
+	l.Lock()
+	defer l.Unlock()
+	now := l.now()
+	for key, bucket := range l.buckets {
+		limit := l.limits[bucket.stats.Class]
+		full := limit.Rate == 0 || bucket.tokens+now.Sub(bucket.last).Seconds()*limit.Rate >= limit.Burst
+		if full && (bucket.stats.Throttled == 0 || now.Sub(bucket.stats.LastThrottled) > throttledRetention) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *rateLimiter) pruneRoutine() {
+	for _ = range time.Tick(time.Minute) {
+		l.prune()
+	}
+}
+
+// throttled returns the stats of every bucket that was throttled, most throttled first.
+func (l *rateLimiter) throttled() []*RateLimitStats {
+	l.Lock()
+	defer l.Unlock()
+	stats := []*RateLimitStats{}
+	for _, bucket := range l.buckets {
+		if bucket.stats.Throttled > 0 {
+			copied := bucket.stats
+			stats = append(stats, &copied)
+		}
+	}
+	sort.Sort(throttledStats(stats))
+	return stats
+}
+
+type throttledStats []*RateLimitStats
+
+func (s throttledStats) Len() int      { return len(s) }
+func (s throttledStats) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s throttledStats) Less(i, j int) bool {
+	if s[i].Throttled != s[j].Throttled {
+		return s[i].Throttled > s[j].Throttled
+	}
+	return s[i].Key < s[j].Key
+}
+
+// rpcRateClass returns the class of the RPC method. Anything that is not known to only read is a mutation.
+func rpcRateClass(method string) string {
+	switch {
+	case method == "Login":
+		return RateClassLogin
+	case strings.HasPrefix(method, "Get"), strings.HasPrefix(method, "List"), strings.HasPrefix(method, "Is"),
+		strings.HasPrefix(method, "Has"), strings.HasPrefix(method, "Query"), strings.HasSuffix(method, "Result"):
+		return RateClassRead
+	}
+	switch method {
+	case "Audit", "CacheStats", "CanI", "HealthCheck", "Idle", "RateLimits", "ResolveDeps", "Status", "TaskLog",
+		"TaskQueue", "Usage", "Version":
+		return RateClassRead
+	}
+	return RateClassMutate
+}
+
+// rateLimitUser returns who sent a request if that is certain without logging them in. Anyone can claim to be
+// anyone, so only users with a session or API token have a bucket of their own. Logins are limited by the user they
+// are for from each address, on top of the bucket of the address, so that nobody can lock a user out by failing to
+// log in as them.
+func rateLimitUser(class string, auth *ManagerAuthArg) string {
+	switch {
+	case auth.User == "" && auth.Secret == "":
+		return ""
+	case class == RateClassLogin:
+		return auth.User + "@" + sourceHost(auth)
+	case isToken(auth.Secret):
+		if zt, err := lookupToken(auth.Secret); err == nil {
+			return zt.User
+		}
+	case auth.Secret != "" && aldap.Sessions.Get(auth.User, auth.Secret) != nil:
+		return auth.User
+	}
+	return ""
+}
+
+// sourceHost returns the address a request came from without its port
+func sourceHost(auth *ManagerAuthArg) string {
+	if host, _, err := net.SplitHostPort(auth.Source); err == nil {
+		return host
+	}
+	return auth.Source
+}
+
+// LimitRequest takes a token for a request of class from the buckets of its source and user.
+func LimitRequest(class string, auth *ManagerAuthArg) error {
+	keys := []string{}
+	if host := sourceHost(auth); host != "" {
+		keys = append(keys, class+":ip:"+host)
+	}
+	if user := rateLimitUser(class, auth); user != "" {
+		keys = append(keys, class+":user:"+user)
+	}
+	return limiter.take(class, keys...)
+}
+
+type RateLimitsExecutor struct {
+	arg   ManagerRateLimitsArg
+	reply *ManagerRateLimitsReply
+}
+
+func (e *RateLimitsExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *RateLimitsExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *RateLimitsExecutor) Description() string {
+	return "[" + e.arg.ManagerAuthArg.User + "] RateLimits"
+}
+
+func (e *RateLimitsExecutor) Execute(t *Task) error {
+	limiter.Lock()
+	e.reply.Limits = map[string]string{}
+	for class, limit := range limiter.limits {
+		e.reply.Limits[class] = fmt.Sprintf("%g/%g", limit.Rate, limit.Burst)
+	}
+	limiter.Unlock()
+	e.reply.Throttled = limiter.throttled()
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (e *RateLimitsExecutor) Authorize() error {
+	return AuthorizeSuperUser(&e.arg.ManagerAuthArg)
+}
+
+func (m *ManagerRPC) RateLimits(arg ManagerRateLimitsArg, reply *ManagerRateLimitsReply) error {
+	return NewTask("RateLimits", &RateLimitsExecutor{arg, reply}).Run()
+}
@@ -0,0 +1,117 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/manager/rpc/types"
+	. "github.com/adjust/gocheck"
+	"time"
+)
+
+type RateLimitSuite struct{}
+
+var _ = Suite(&RateLimitSuite{})
+
+func (s *RateLimitSuite) TestParseRateLimits(c *C) {
+	limits, err := ParseRateLimits("read:50/200, mutate:0.5/5,")
+	c.Assert(err, IsNil)
+	c.Assert(limits, DeepEquals, map[string]RateLimit{"read": {50, 200}, "mutate": {0.5, 5}})
+	limits, err = ParseRateLimits("")
+	c.Assert(err, IsNil)
+	c.Assert(limits, HasLen, 0)
+	for _, invalid := range []string{"read", "read:50", "read:x/10", "read:-1/10", "read:1/0", "write:1/10"} {
+		_, err = ParseRateLimits(invalid)
+		c.Assert(err, Not(IsNil), Commentf(invalid))
+	}
+}
+
+func (s *RateLimitSuite) TestTake(c *C) {
+	now := time.Now()
+	l := newRateLimiter(map[string]RateLimit{"mutate": {1, 2}, "read": {0, 1}})
+	l.now = func() time.Time { return now }
+
+	c.Assert(l.take("mutate", "ip", "user"), IsNil)
+	c.Assert(l.take("mutate", "ip", "user"), IsNil)
+	err := l.take("mutate", "ip", "user")
+	c.Assert(err, FitsTypeOf, &RateLimitError{})
+	c.Assert(err.(*RateLimitError).Key, Equals, "ip")
+	c.Assert(err.(*RateLimitError).RetryAfter, Equals, time.Second)
+	// a request that is rejected takes nothing from the other bucket
+	c.Assert(l.take("mutate", "other-ip", "user"), Not(IsNil))
+	c.Assert(l.buckets["other-ip"].tokens, Equals, float64(2))
+
+	now = now.Add(1500 * time.Millisecond)
+	c.Assert(l.take("mutate", "ip", "user"), IsNil)
+	c.Assert(l.take("mutate", "ip", "user"), Not(IsNil))
+	now = now.Add(time.Hour)
+	c.Assert(l.buckets["ip"].tokens < 1, Equals, true)
+	c.Assert(l.take("mutate", "ip"), IsNil)
+	c.Assert(l.buckets["ip"].tokens, Equals, float64(1))
+
+	// classes without a limit are not limited
+	for i := 0; i < 10; i++ {
+		c.Assert(l.take("read", "ip"), IsNil)
+		c.Assert(l.take("login", "ip"), IsNil)
+	}
+
+	throttled := l.throttled()
+	c.Assert(throttled, HasLen, 2)
+	c.Assert(throttled[0].Key, Equals, "ip")
+	c.Assert(throttled[0].Throttled, Equals, uint64(2))
+	c.Assert(throttled[0].Allowed, Equals, uint64(4))
+	c.Assert(throttled[1].Key, Equals, "user")
+	c.Assert(throttled[1].Throttled, Equals, uint64(1))
+
+	// full buckets are dropped, throttled ones once they have not been throttled for throttledRetention
+	l.take("mutate", "quiet")
+	for i := 0; i < 3; i++ {
+		l.take("mutate", "noisy")
+	}
+	now = now.Add(time.Minute)
+	l.prune()
+	_, ok := l.buckets["quiet"]
+	c.Assert(ok, Equals, false)
+	_, ok = l.buckets["ip"]
+	c.Assert(ok, Equals, false)
+	_, ok = l.buckets["noisy"]
+	c.Assert(ok, Equals, true)
+	now = now.Add(throttledRetention)
+	l.prune()
+	c.Assert(l.buckets, HasLen, 0)
+}
+
+func (s *RateLimitSuite) TestLimitRequest(c *C) {
+	defer func(l *rateLimiter) { limiter = l }(limiter)
+	limiter = newRateLimiter(map[string]RateLimit{"login": {1, 1}, "mutate": {1, 1}})
+	// claiming to be someone counts against their logins from that address, but not from others
+	c.Assert(LimitRequest("login", &ManagerAuthArg{User: "alice", Source: "10.0.0.1:5000"}), IsNil)
+	c.Assert(LimitRequest("login", &ManagerAuthArg{User: "alice", Source: "10.0.0.2:5000"}), IsNil)
+	c.Assert(limiter.buckets["login:user:alice@10.0.0.1"], Not(IsNil))
+	c.Assert(LimitRequest("login", &ManagerAuthArg{User: "alice", Source: "10.0.0.1:6000"}), ErrorMatches,
+		"Rate limit exceeded for login:ip:10.0.0.1.*")
+	// nor their other requests
+	c.Assert(LimitRequest("mutate", &ManagerAuthArg{User: "bob", Secret: "forged", Source: "10.0.0.1:5000"}), IsNil)
+	c.Assert(LimitRequest("mutate", &ManagerAuthArg{User: "bob", Secret: "forged", Source: "10.0.0.2:5000"}), IsNil)
+	c.Assert(LimitRequest("mutate", &ManagerAuthArg{Source: "10.0.0.2:6000"}), ErrorMatches,
+		"Rate limit exceeded for mutate:ip:10.0.0.2.*")
+}
+
+func (s *RateLimitSuite) TestRPCRateClass(c *C) {
+	c.Assert(rpcRateClass("Login"), Equals, RateClassLogin)
+	for _, method := range []string{"ListApps", "GetContainer", "IsSuperUser", "QueryTasks", "DeployResult",
+		"TaskLog", "Version"} {
+		c.Assert(rpcRateClass(method), Equals, RateClassRead, Commentf(method))
+	}
+	for _, method := range []string{"Deploy", "Teardown", "RegisterApp", "Logout", "CreateToken"} {
+		c.Assert(rpcRateClass(method), Equals, RateClassMutate, Commentf(method))
+	}
+}
@@ -0,0 +1,143 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	"reflect"
+	"strings"
+)
+
+// what the value of a sensitive field is replaced with
+const redactedMask = "[redacted]"
+
+// fields with these in their names are sensitive even if they are not tagged, which covers types from other
+// packages such as the DataMap of supervisor app deps
+var redactedFields = []string{"secret", "password", "credential", "privatekey", "datamap"}
+
+// Redact returns a copy of v with the values of sensitive fields masked, for use wherever a request or result is
+// logged, audited or kept in a task record. A struct field is sensitive if it is tagged `redact:"true"` or its
+// name looks like one of redactedFields, and so is the value of a map key that does. Strings become [redacted],
+// maps keep their keys but have their values masked and anything else is zeroed; empty values are left alone so
+// that it is still clear what was not given. The copy has the same type as v, so it can be used in its place, and
+// v itself is not changed.
+func Redact(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return redactCopy(reflect.ValueOf(v), false).Interface()
+}
+
+func redactCopy(v reflect.Value, sensitive bool) reflect.Value {
+	if sensitive {
+		return mask(v)
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(redactCopy(v.Elem(), false))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(redactCopy(v.Elem(), false))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Type().Field(i); field.PkgPath == "" {
+				c.Field(i).Set(redactCopy(v.Field(i), isSensitiveField(field)))
+			}
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(redactCopy(v.Index(i), false))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(redactCopy(v.Index(i), false))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMap(v.Type())
+		for _, key := range v.MapKeys() {
+			sensitive := key.Kind() == reflect.String && isRedactedField(key.String())
+			c.SetMapIndex(key, redactCopy(v.MapIndex(key), sensitive))
+		}
+		return c
+	}
+	return v
+}
+
+// mask hides the value of a sensitive field
+func mask(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.String:
+		if v.Len() == 0 {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.SetString(redactedMask)
+		return c
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		if v.Kind() == reflect.Ptr {
+			c.Set(reflect.New(v.Type().Elem()))
+			c.Elem().Set(mask(v.Elem()))
+		} else {
+			c.Set(mask(v.Elem()))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMap(v.Type())
+		for _, key := range v.MapKeys() {
+			c.SetMapIndex(key, mask(v.MapIndex(key)))
+		}
+		return c
+	}
+	return reflect.Zero(v.Type())
+}
+
+func isSensitiveField(field reflect.StructField) bool {
+	return field.Tag.Get("redact") == "true" || isRedactedField(field.Name)
+}
+
+func isRedactedField(name string) bool {
+	name = strings.ToLower(name)
+	for _, redacted := range redactedFields {
+		if strings.Contains(name, redacted) {
+			return true
+		}
+	}
+	return false
+}
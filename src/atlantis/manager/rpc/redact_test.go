@@ -0,0 +1,169 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/common"
+	"atlantis/manager/datamodel"
+	. "atlantis/manager/rpc/types"
+	. "atlantis/supervisor/rpc/types"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	. "github.com/adjust/gocheck"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"strings"
+)
+
+type RedactSuite struct{}
+
+var _ = Suite(&RedactSuite{})
+
+var redactAuth = ManagerAuthArg{"alice", "hunter2", "s3cr3t", "10.0.0.1:5000"}
+
+func (s *RedactSuite) TestRedactTaggedFields(c *C) {
+	arg := ManagerLoginArg{User: "alice", Pass: "hunter2", Secret: "s3cr3t"}
+	c.Assert(Redact(arg), DeepEquals, ManagerLoginArg{User: "alice", Pass: redactedMask, Secret: redactedMask})
+	reply := &ManagerCreateTokenReply{Token: &APIToken{ID: "id"}, Secret: "tok_s3cr3t", Status: StatusOk}
+	redacted := Redact(reply).(*ManagerCreateTokenReply)
+	c.Assert(redacted.Secret, Equals, redactedMask)
+	c.Assert(redacted.Token.ID, Equals, "id")
+	c.Assert(redacted.Status, Equals, StatusOk)
+}
+
+func (s *RedactSuite) TestRedactLeavesOriginal(c *C) {
+	data := &DependerEnvData{Name: "prod", EncryptedData: "ciphertext",
+		DataMap: map[string]interface{}{"password": "hunter2", "port": 5432.0, "nested": map[string]interface{}{"a": "b"}}}
+	redacted := Redact(data).(*DependerEnvData)
+	c.Assert(redacted, Not(Equals), data)
+	c.Assert(redacted.Name, Equals, "prod")
+	c.Assert(redacted.EncryptedData, Equals, redactedMask)
+	c.Assert(redacted.DataMap, DeepEquals, map[string]interface{}{"password": redactedMask, "port": 0.0,
+		"nested": map[string]interface{}{"a": redactedMask}})
+	c.Assert(data.EncryptedData, Equals, "ciphertext")
+	c.Assert(data.DataMap["password"], Equals, "hunter2")
+}
+
+func (s *RedactSuite) TestRedactNamedFields(c *C) {
+	// supervisor types can't be tagged, they are caught by name
+	reply := ManagerDeployReply{Containers: []*Container{&Container{ID: "c1", Manifest: &Manifest{
+		Deps: DepsType{"db": &AppDep{DataMap: map[string]interface{}{"address": "db:5432"}}}}}}}
+	redacted := Redact(reply).(ManagerDeployReply)
+	c.Assert(redacted.Containers[0].ID, Equals, "c1")
+	c.Assert(redacted.Containers[0].Manifest.Deps["db"].DataMap["address"], Equals, redactedMask)
+	c.Assert(reply.Containers[0].Manifest.Deps["db"].DataMap["address"], Equals, "db:5432")
+	c.Assert(Redact(map[string]string{"Secret": "s3cr3t", "User": "alice"}), DeepEquals,
+		map[string]string{"Secret": redactedMask, "User": "alice"})
+	c.Assert(Redact(ManagerAuthArg{User: "alice"}), DeepEquals, ManagerAuthArg{User: "alice"})
+	c.Assert(Redact(nil), IsNil)
+	c.Assert(Redact("s3cr3t"), Equals, "s3cr3t")
+}
+
+func (s *RedactSuite) TestExecutorRequests(c *C) {
+	executors := []TaskExecutor{
+		&LoginExecutor{ManagerLoginArg{User: "alice", Pass: "hunter2", Secret: "s3cr3t"}, &ManagerLoginReply{}},
+		&DeployExecutor{arg: ManagerDeployArg{ManagerAuthArg: redactAuth, App: "app", Env: "prod"}},
+		&AddDependerEnvDataExecutor{arg: ManagerAddDependerEnvDataArg{ManagerAuthArg: redactAuth, App: "app",
+			DependerEnvData: &DependerEnvData{Name: "prod", DataMap: map[string]interface{}{"key": "hunter2"}}}},
+		&AddDependerAppDataExecutor{arg: ManagerAddDependerAppDataArg{ManagerAuthArg: redactAuth, App: "app",
+			DependerAppData: &DependerAppData{Name: "dep", DependerEnvData: map[string]*DependerEnvData{
+				"prod": &DependerEnvData{Name: "prod", EncryptedData: "s3cr3t"}}}}},
+		&CreateTokenExecutor{arg: ManagerCreateTokenArg{ManagerAuthArg: redactAuth, Name: "ci"}},
+	}
+	for _, e := range executors {
+		data, err := json.Marshal(e.Request())
+		c.Assert(err, IsNil)
+		comment := Commentf("%T", e)
+		for _, secret := range []string{"hunter2", "s3cr3t"} {
+			c.Assert(string(data), Not(Matches), ".*"+secret+".*", comment)
+			c.Assert(e.Description(), Not(Matches), ".*"+secret+".*", comment)
+		}
+		if _, ok := e.(*LoginExecutor); !ok {
+			// the audit log and task records still know who asked
+			c.Assert(executorAuth(e).User, Equals, "alice", comment)
+		}
+	}
+	// the executor still runs with the real credentials
+	c.Assert(executors[1].(*DeployExecutor).arg.Password, Equals, "hunter2")
+}
+
+func (s *RedactSuite) TestSSHKeyNotDescribed(c *C) {
+	blob := []byte("ssh-ed25519 key blob")
+	key := "ssh-ed25519 " + base64.StdEncoding.EncodeToString(blob) + " alice@laptop"
+	e := &AuthorizeSSHExecutor{arg: ManagerAuthorizeSSHArg{ManagerAuthArg: redactAuth, ContainerID: "c1",
+		PublicKey: key}}
+	sum := sha256.Sum256(blob)
+	// the description is logged with the task, so it names the key by its fingerprint
+	c.Assert(e.Description(), Not(Matches), ".*"+base64.StdEncoding.EncodeToString(blob)+".*")
+	c.Assert(strings.HasSuffix(e.Description(), "SHA256:"+base64.RawStdEncoding.EncodeToString(sum[:])), Equals,
+		true)
+	c.Assert(sshKeyFingerprint("not a key"), Equals, "invalid key")
+	c.Assert(sshKeyFingerprint("ssh-rsa !!!"), Equals, "invalid key")
+}
+
+// what a task logs is kept with it and streamed, so a deploy must not log the manifest it was given
+func (s *DeployHelperSuite) TestDeployManifestNotLogged(c *C) {
+	_, err := datamodel.CreateOrUpdateApp(false, true, "manifest-app", "ssh://github.com/ooyala/hello-go", "/",
+		"jigish@ooyala.com")
+	c.Assert(err, IsNil)
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	// the manifest is not valid json, so the deploy stops right after reading it
+	e := &DeployExecutor{arg: ManagerDeployArg{ManagerAuthArg: redactAuth, App: "manifest-app", Sha: "sha",
+		Env: "prod", SkipBuild: true, Manifest: `{"password": "hunter2"`}, reply: &ManagerDeployReply{}}
+	t := NewTask("Deploy", e)
+	c.Assert(e.Execute(t), Not(IsNil))
+	c.Assert(logged.String(), Not(Matches), "(?s).*hunter2.*")
+	c.Assert(t.Status, Not(Matches), ".*hunter2.*")
+	c.Assert(e.Description(), Not(Matches), ".*hunter2.*")
+}
+
+// every executor has to hand out a redacted request, since that is what gets logged, audited and queued
+func (s *RedactSuite) TestAllExecutorsRedactRequest(c *C) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	c.Assert(err, IsNil)
+	count := 0
+	for _, file := range pkgs["rpc"].Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Name.Name != "Request" {
+				continue
+			}
+			star, ok := fn.Recv.List[0].Type.(*ast.StarExpr)
+			if !ok {
+				continue
+			}
+			name := star.X.(*ast.Ident).Name
+			if !strings.HasSuffix(name, "Executor") {
+				continue
+			}
+			count++
+			comment := Commentf("%s.Request does not return Redact(e.arg)", name)
+			c.Assert(fn.Body.List, HasLen, 1, comment)
+			ret, ok := fn.Body.List[0].(*ast.ReturnStmt)
+			c.Assert(ok, Equals, true, comment)
+			call, ok := ret.Results[0].(*ast.CallExpr)
+			c.Assert(ok && fmt.Sprint(call.Fun) == "Redact", Equals, true, comment)
+		}
+	}
+	c.Assert(count, Not(Equals), 0)
+}
@@ -35,7 +35,11 @@ type RegisterRouterExecutor struct {
 }
 
 func (e *RegisterRouterExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
+}
+
+func (e *RegisterRouterExecutor) authArg() *ManagerAuthArg {
+	return &e.arg.ManagerAuthArg
 }
 
 func (e *RegisterRouterExecutor) Result() interface{} {
@@ -53,13 +57,13 @@ func (e *RegisterRouterExecutor) Authorize() error {
 
 func (e *RegisterRouterExecutor) Execute(t *Task) error {
 	if e.arg.Host == "" {
-		return errors.New("Please specify a host to register")
+		return errorOf(ErrInvalid, "Please specify a host to register")
 	}
 	if e.arg.Zone == "" {
-		return errors.New("Please specify a zone")
+		return errorOf(ErrInvalid, "Please specify a zone")
 	}
 	if e.arg.IP == "" {
-		return errors.New("Please specify an ip")
+		return errorOf(ErrInvalid, "Please specify an ip")
 	}
 	routerObj, err := router.Register(e.arg.Internal, e.arg.Zone, e.arg.Host, e.arg.IP)
 	if err != nil {
@@ -81,17 +85,17 @@ func (e *RegisterRouterExecutor) Execute(t *Task) error {
 
 func (m *ManagerRPC) RegisterRouterResult(id string, result *ManagerRegisterRouterReply) error {
 	if id == "" {
-		return errors.New("ID empty")
+		return errorOf(ErrInvalid, "ID empty")
 	}
 	status, err := Tracker.Status(id)
-	if status.Status == StatusUnknown {
-		return errors.New("Unknown ID.")
+	if status == nil || status.Status == StatusUnknown {
+		return persistedResult(id, result, "RegisterRouter")
 	}
 	if status.Name != "RegisterRouter" {
-		return errors.New("ID is not a RegisterRouter.")
+		return errorOf(ErrInvalid, "ID is not a RegisterRouter.")
 	}
 	if !status.Done {
-		return errors.New("RegisterRouter isn't done.")
+		return errorOf(ErrConflict, "RegisterRouter isn't done.")
 	}
 	if status.Status == StatusError || err != nil {
 		return err
@@ -113,7 +117,7 @@ type UnregisterRouterExecutor struct {
 }
 
 func (e *UnregisterRouterExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *UnregisterRouterExecutor) Result() interface{} {
@@ -131,10 +135,10 @@ func (e *UnregisterRouterExecutor) Authorize() error {
 
 func (e *UnregisterRouterExecutor) Execute(t *Task) error {
 	if e.arg.Host == "" {
-		return errors.New("Please specify a host to uregister")
+		return errorOf(ErrInvalid, "Please specify a host to uregister")
 	}
 	if e.arg.Zone == "" {
-		return errors.New("Please specify a zone")
+		return errorOf(ErrInvalid, "Please specify a zone")
 	}
 	err := router.Unregister(e.arg.Internal, e.arg.Zone, e.arg.Host)
 	if err != nil {
@@ -154,17 +158,17 @@ func (e *UnregisterRouterExecutor) Execute(t *Task) error {
 
 func (m *ManagerRPC) UnregisterRouterResult(id string, result *ManagerRegisterRouterReply) error {
 	if id == "" {
-		return errors.New("ID empty")
+		return errorOf(ErrInvalid, "ID empty")
 	}
 	status, err := Tracker.Status(id)
-	if status.Status == StatusUnknown {
-		return errors.New("Unknown ID.")
+	if status == nil || status.Status == StatusUnknown {
+		return persistedResult(id, result, "UnregisterRouter")
 	}
 	if status.Name != "UnregisterRouter" {
-		return errors.New("ID is not a UnregisterRouter.")
+		return errorOf(ErrInvalid, "ID is not a UnregisterRouter.")
 	}
 	if !status.Done {
-		return errors.New("UnregisterRouter isn't done.")
+		return errorOf(ErrConflict, "UnregisterRouter isn't done.")
 	}
 	if status.Status == StatusError || err != nil {
 		return err
@@ -186,7 +190,7 @@ type ListRoutersExecutor struct {
 }
 
 func (e *ListRoutersExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListRoutersExecutor) Result() interface{} {
@@ -220,7 +224,7 @@ type GetRouterExecutor struct {
 }
 
 func (e *GetRouterExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *GetRouterExecutor) Result() interface{} {
@@ -256,7 +260,7 @@ type RegisterAppExecutor struct {
 }
 
 func (e *RegisterAppExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *RegisterAppExecutor) Result() interface{} {
@@ -264,40 +268,62 @@ func (e *RegisterAppExecutor) Result() interface{} {
 }
 
 func (e *RegisterAppExecutor) Description() string {
-	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] %s -> %s:%s, non-atlantis: %t, internal: %t",
-		e.arg.Name, e.arg.Repo, e.arg.Root, e.arg.NonAtlantis, e.arg.Internal)
+	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] %s -> %s:%s, non-atlantis: %t, internal: %t, team: %s",
+		e.arg.Name, e.arg.Repo, e.arg.Root, e.arg.NonAtlantis, e.arg.Internal, e.arg.Team)
 }
 
 func (e *RegisterAppExecutor) Authorize() error {
-	return AuthorizeApp(&e.arg.ManagerAuthArg, e.arg.Name)
+	if e.arg.Team == "" {
+		return AuthorizeApp(&e.arg.ManagerAuthArg, e.arg.Name)
+	}
+	// the app is not allowed for anyone yet, so being able to speak for the owning team is enough
+	if err := SimpleAuthorize(&e.arg.ManagerAuthArg); err != nil {
+		return err
+	}
+	if TeamExists(e.arg.Team, &e.arg.ManagerAuthArg) {
+		return nil
+	}
+	return authorizeSuperUser(&e.arg.ManagerAuthArg)
 }
 
 func (e *RegisterAppExecutor) Execute(t *Task) error {
 	if e.arg.Name == "" {
-		return errors.New("Please specify an app name to register")
+		return errorOf(ErrInvalid, "Please specify an app name to register")
 	}
 	if !AppRegexp.MatchString(e.arg.Name) {
-		return errors.New("App name must be ^[A-Za-z0-9-]+$")
+		return errorOf(ErrInvalid, "App name must be ^[A-Za-z0-9-]+$")
 	}
 	if !e.arg.NonAtlantis && e.arg.Repo == "" {
-		return errors.New("Please specify a repo")
+		return errorOf(ErrInvalid, "Please specify a repo")
 	}
 	if !e.arg.NonAtlantis && e.arg.Root == "" {
-		return errors.New("Please specify the repo's root")
+		return errorOf(ErrInvalid, "Please specify the repo's root")
 	}
 	if e.arg.Email == "" {
-		return errors.New("Please specify the email of the app owner")
+		return errorOf(ErrInvalid, "Please specify the email of the app owner")
 	}
 	if _, err := datamodel.GetApp(e.arg.Name); err == nil {
-		return errors.New("Already Registered.")
+		return errorOf(ErrConflict, "Already Registered.")
 	}
-	_, err := datamodel.CreateOrUpdateApp(e.arg.NonAtlantis, e.arg.Internal, e.arg.Name, e.arg.Repo, e.arg.Root,
+	za, err := datamodel.CreateOrUpdateApp(e.arg.NonAtlantis, e.arg.Internal, e.arg.Name, e.arg.Repo, e.arg.Root,
 		e.arg.Email)
 	if err != nil {
 		e.reply.Status = StatusError
+		return err
+	}
+	if e.arg.Team != "" {
+		za.Team = e.arg.Team
+		if err := za.Save(); err != nil {
+			e.reply.Status = StatusError
+			return err
+		}
+		if err := datamodel.GetTeamapps(e.arg.Team).AddApp(e.arg.Name); err != nil {
+			e.reply.Status = StatusError
+			return err
+		}
 	}
 	e.reply.Status = StatusOk
-	return err
+	return nil
 }
 
 type UpdateAppExecutor struct {
@@ -306,7 +332,7 @@ type UpdateAppExecutor struct {
 }
 
 func (e *UpdateAppExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *UpdateAppExecutor) Result() interface{} {
@@ -324,19 +350,19 @@ func (e *UpdateAppExecutor) Authorize() error {
 
 func (e *UpdateAppExecutor) Execute(t *Task) error {
 	if e.arg.Name == "" {
-		return errors.New("Please specify an app name to update")
+		return errorOf(ErrInvalid, "Please specify an app name to update")
 	}
 	if !AppRegexp.MatchString(e.arg.Name) {
-		return errors.New("App name must be [A-Za-z0-9-]+")
+		return errorOf(ErrInvalid, "App name must be [A-Za-z0-9-]+")
 	}
 	if !e.arg.NonAtlantis && e.arg.Repo == "" {
-		return errors.New("Please specify a repo")
+		return errorOf(ErrInvalid, "Please specify a repo")
 	}
 	if !e.arg.NonAtlantis && e.arg.Root == "" {
-		return errors.New("Please specify the repo's root")
+		return errorOf(ErrInvalid, "Please specify the repo's root")
 	}
 	if e.arg.Email == "" {
-		return errors.New("Please specify the email of the app owner")
+		return errorOf(ErrInvalid, "Please specify the email of the app owner")
 	}
 	_, err := datamodel.CreateOrUpdateApp(e.arg.NonAtlantis, e.arg.Internal, e.arg.Name, e.arg.Repo, e.arg.Root,
 		e.arg.Email)
@@ -353,7 +379,7 @@ type UnregisterAppExecutor struct {
 }
 
 func (e *UnregisterAppExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *UnregisterAppExecutor) Result() interface{} {
@@ -370,17 +396,20 @@ func (e *UnregisterAppExecutor) Authorize() error {
 
 func (e *UnregisterAppExecutor) Execute(t *Task) error {
 	if e.arg.Name == "" {
-		return errors.New("Please specify an app name to unregister")
+		return errorOf(ErrInvalid, "Please specify an app name to unregister")
 	}
 	app, err := datamodel.GetApp(e.arg.Name)
 	if err != nil || app == nil {
 		e.reply.Status = StatusError
-		return errors.New("App " + e.arg.Name + " does not exist")
+		return errorOf(ErrNotFound, "App "+e.arg.Name+" does not exist")
 	}
 	if err = app.Delete(); err != nil {
 		e.reply.Status = StatusError
 		return err
 	}
+	if err = datamodel.DeleteAppFromTeams(e.arg.Name); err != nil {
+		t.Log("[RPC][UnregisterApp] could not remove %s from its teams: %s", e.arg.Name, err)
+	}
 	e.reply.Status = StatusOk
 	return nil
 }
@@ -391,7 +420,7 @@ type GetAppExecutor struct {
 }
 
 func (e *GetAppExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *GetAppExecutor) Result() interface{} {
@@ -408,12 +437,12 @@ func (e *GetAppExecutor) Authorize() error {
 
 func (e *GetAppExecutor) Execute(t *Task) error {
 	if e.arg.Name == "" {
-		return errors.New("Please specify an app name to get")
+		return errorOf(ErrInvalid, "Please specify an app name to get")
 	}
 	app, err := datamodel.GetApp(e.arg.Name)
 	if err != nil || app == nil {
 		e.reply.Status = StatusError
-		return errors.New("App " + e.arg.Name + " does not exist")
+		return errorOf(ErrNotFound, "App "+e.arg.Name+" does not exist")
 	}
 	e.reply.Status = StatusOk
 	castedApp := App(*app)
@@ -427,7 +456,7 @@ type ListRegisteredAppsExecutor struct {
 }
 
 func (e *ListRegisteredAppsExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListRegisteredAppsExecutor) Result() interface{} {
@@ -443,11 +472,13 @@ func (e *ListRegisteredAppsExecutor) Authorize() error {
 }
 
 func (e *ListRegisteredAppsExecutor) Execute(t *Task) (err error) {
-	e.reply.Apps, err = datamodel.ListRegisteredApps()
+	apps, err := datamodel.ListRegisteredApps()
+	if err == nil {
+		e.reply.Apps, e.reply.NextCursor, err = pageList(apps, e.arg.ListOptions, registeredAppLabels)
+	}
 	if err != nil {
 		e.reply.Status = StatusError
 	} else {
-		sort.Strings(e.reply.Apps)
 		e.reply.Status = StatusOk
 	}
 	return err
@@ -459,7 +490,7 @@ type ListAuthorizedRegisteredAppsExecutor struct {
 }
 
 func (e *ListAuthorizedRegisteredAppsExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListAuthorizedRegisteredAppsExecutor) Result() interface{} {
@@ -478,18 +509,43 @@ func (e *ListAuthorizedRegisteredAppsExecutor) Execute(t *Task) (err error) {
 	apps, err := datamodel.ListRegisteredApps()
 	if err != nil {
 		e.reply.Status = StatusError
-	} else {
-		e.reply.Status = StatusOk
+		return err
+	}
+	allowed, err := authorizedApps(&e.arg.ManagerAuthArg)
+	if err != nil {
+		e.reply.Status = StatusError
+		return err
 	}
 	authdApps := []string{}
 	for _, app := range apps {
-		if err := AuthorizeApp(&e.arg.ManagerAuthArg, app); err == nil {
+		if allowed(app) {
 			authdApps = append(authdApps, app)
 		}
 	}
-	sort.Strings(authdApps)
-	e.reply.Apps = authdApps
-	return err
+	e.reply.Apps, e.reply.NextCursor, err = pageList(authdApps, e.arg.ListOptions, registeredAppLabels)
+	if err != nil {
+		e.reply.Status = StatusError
+		return err
+	}
+	e.reply.Status = StatusOk
+	return nil
+}
+
+// authorizedApps answers AuthorizeApp for every app at once: the teams of the user are looked up a single time
+// instead of once per app.
+func authorizedApps(auth *ManagerAuthArg) (func(app string) bool, error) {
+	if isToken(auth.Secret) {
+		zt, err := lookupToken(auth.Secret)
+		if err != nil {
+			return nil, err
+		}
+		return zt.AllowsApp, nil
+	}
+	if authorizeSuperUser(auth) == nil {
+		return func(string) bool { return true }, nil
+	}
+	allowed := GetAllowedApps(auth, auth.User)
+	return func(app string) bool { return allowed[app] }, nil
 }
 
 // ----------------------------------------------------------------------------------------------------------
@@ -502,7 +558,11 @@ type RegisterSupervisorExecutor struct {
 }
 
 func (e *RegisterSupervisorExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
+}
+
+func (e *RegisterSupervisorExecutor) authArg() *ManagerAuthArg {
+	return &e.arg.ManagerAuthArg
 }
 
 func (e *RegisterSupervisorExecutor) Result() interface{} {
@@ -515,7 +575,7 @@ func (e *RegisterSupervisorExecutor) Description() string {
 
 func (e *RegisterSupervisorExecutor) Execute(t *Task) error {
 	if e.arg.Host == "" {
-		return errors.New("Please specify a host to register")
+		return errorOf(ErrInvalid, "Please specify a host to register")
 	}
 	// check health of to be registered supervisor
 	health, err := supervisor.HealthCheck(e.arg.Host)
@@ -554,17 +614,17 @@ func (e *RegisterSupervisorExecutor) Authorize() error {
 
 func (m *ManagerRPC) RegisterSupervisorResult(id string, result *ManagerRegisterSupervisorReply) error {
 	if id == "" {
-		return errors.New("ID empty")
+		return errorOf(ErrInvalid, "ID empty")
 	}
 	status, err := Tracker.Status(id)
-	if status.Status == StatusUnknown {
-		return errors.New("Unknown ID.")
+	if status == nil || status.Status == StatusUnknown {
+		return persistedResult(id, result, "RegisterSupervisor")
 	}
 	if status.Name != "RegisterSupervisor" {
-		return errors.New("ID is not a RegisterSupervisor.")
+		return errorOf(ErrInvalid, "ID is not a RegisterSupervisor.")
 	}
 	if !status.Done {
-		return errors.New("RegisterSupervisor isn't done.")
+		return errorOf(ErrConflict, "RegisterSupervisor isn't done.")
 	}
 	if status.Status == StatusError || err != nil {
 		return err
@@ -586,7 +646,7 @@ type UnregisterSupervisorExecutor struct {
 }
 
 func (e *UnregisterSupervisorExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *UnregisterSupervisorExecutor) Result() interface{} {
@@ -599,7 +659,7 @@ func (e *UnregisterSupervisorExecutor) Description() string {
 
 func (e *UnregisterSupervisorExecutor) Execute(t *Task) error {
 	if e.arg.Host == "" {
-		return errors.New("Please specify a host to unregister")
+		return errorOf(ErrInvalid, "Please specify a host to unregister")
 	}
 	// TODO(edanaher): With appropriate confirmation (--force?), tear down containers on the host and delete
 	// metadata.  Until then, cowardly refuse to tear down supervisors with containers.
@@ -630,17 +690,17 @@ func (e *UnregisterSupervisorExecutor) Authorize() error {
 
 func (m *ManagerRPC) UnregisterSupervisorResult(id string, result *ManagerRegisterSupervisorReply) error {
 	if id == "" {
-		return errors.New("ID empty")
+		return errorOf(ErrInvalid, "ID empty")
 	}
 	status, err := Tracker.Status(id)
-	if status.Status == StatusUnknown {
-		return errors.New("Unknown ID.")
+	if status == nil || status.Status == StatusUnknown {
+		return persistedResult(id, result, "UnregisterSupervisor")
 	}
 	if status.Name != "UnregisterSupervisor" {
-		return errors.New("ID is not a UnregisterSupervisor.")
+		return errorOf(ErrInvalid, "ID is not a UnregisterSupervisor.")
 	}
 	if !status.Done {
-		return errors.New("UnregisterSupervisor isn't done.")
+		return errorOf(ErrConflict, "UnregisterSupervisor isn't done.")
 	}
 	if status.Status == StatusError || err != nil {
 		return err
@@ -662,7 +722,7 @@ type ListSupervisorsExecutor struct {
 }
 
 func (e *ListSupervisorsExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListSupervisorsExecutor) Result() interface{} {
@@ -698,7 +758,11 @@ type RegisterManagerExecutor struct {
 }
 
 func (e *RegisterManagerExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
+}
+
+func (e *RegisterManagerExecutor) authArg() *ManagerAuthArg {
+	return &e.arg.ManagerAuthArg
 }
 
 func (e *RegisterManagerExecutor) Result() interface{} {
@@ -711,10 +775,10 @@ func (e *RegisterManagerExecutor) Description() string {
 
 func (e *RegisterManagerExecutor) Execute(t *Task) error {
 	if e.arg.Host == "" {
-		return errors.New("Please specify an Host to register")
+		return errorOf(ErrInvalid, "Please specify an Host to register")
 	}
 	if e.arg.Region == "" {
-		return errors.New("Please specify a Region to register")
+		return errorOf(ErrInvalid, "Please specify a Region to register")
 	}
 	mgr, err := manager.Register(e.arg.Region, e.arg.Host, e.arg.RegistryCName, e.arg.ManagerCName)
 	castedManager := Manager(*mgr)
@@ -733,17 +797,17 @@ func (e *RegisterManagerExecutor) Authorize() error {
 
 func (m *ManagerRPC) RegisterManagerResult(id string, result *ManagerRegisterManagerReply) error {
 	if id == "" {
-		return errors.New("ID empty")
+		return errorOf(ErrInvalid, "ID empty")
 	}
 	status, err := Tracker.Status(id)
-	if status.Status == StatusUnknown {
-		return errors.New("Unknown ID.")
+	if status == nil || status.Status == StatusUnknown {
+		return persistedResult(id, result, "RegisterManager")
 	}
 	if status.Name != "RegisterManager" {
-		return errors.New("ID is not a RegisterManager.")
+		return errorOf(ErrInvalid, "ID is not a RegisterManager.")
 	}
 	if !status.Done {
-		return errors.New("RegisterManager isn't done.")
+		return errorOf(ErrConflict, "RegisterManager isn't done.")
 	}
 	if status.Status == StatusError || err != nil {
 		return err
@@ -765,7 +829,7 @@ type UnregisterManagerExecutor struct {
 }
 
 func (e *UnregisterManagerExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *UnregisterManagerExecutor) Result() interface{} {
@@ -778,10 +842,10 @@ func (e *UnregisterManagerExecutor) Description() string {
 
 func (e *UnregisterManagerExecutor) Execute(t *Task) error {
 	if e.arg.Host == "" {
-		return errors.New("Please specify an host to unregister")
+		return errorOf(ErrInvalid, "Please specify an host to unregister")
 	}
 	if e.arg.Region == "" {
-		return errors.New("Please specify a region to unregister")
+		return errorOf(ErrInvalid, "Please specify a region to unregister")
 	}
 	err := manager.Unregister(e.arg.Region, e.arg.Host)
 	if err != nil {
@@ -798,17 +862,17 @@ func (e *UnregisterManagerExecutor) Authorize() error {
 
 func (m *ManagerRPC) UnregisterManagerResult(id string, result *ManagerRegisterManagerReply) error {
 	if id == "" {
-		return errors.New("ID empty")
+		return errorOf(ErrInvalid, "ID empty")
 	}
 	status, err := Tracker.Status(id)
-	if status.Status == StatusUnknown {
-		return errors.New("Unknown ID.")
+	if status == nil || status.Status == StatusUnknown {
+		return persistedResult(id, result, "UnregisterManager")
 	}
 	if status.Name != "UnregisterManager" {
-		return errors.New("ID is not a UnregisterManager.")
+		return errorOf(ErrInvalid, "ID is not a UnregisterManager.")
 	}
 	if !status.Done {
-		return errors.New("UnregisterManager isn't done.")
+		return errorOf(ErrConflict, "UnregisterManager isn't done.")
 	}
 	if status.Status == StatusError || err != nil {
 		return err
@@ -830,7 +894,7 @@ type ListManagersExecutor struct {
 }
 
 func (e *ListManagersExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListManagersExecutor) Result() interface{} {
@@ -864,7 +928,7 @@ type GetManagerExecutor struct {
 }
 
 func (e *GetManagerExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *GetManagerExecutor) Result() interface{} {
@@ -896,7 +960,7 @@ type GetSelfExecutor struct {
 }
 
 func (e *GetSelfExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *GetSelfExecutor) Result() interface{} {
@@ -923,11 +987,11 @@ func (e *GetSelfExecutor) Execute(t *Task) error {
 }
 
 func (m *ManagerRPC) RegisterRouter(arg ManagerRegisterRouterArg, reply *AsyncReply) error {
-	return NewTask("RegisterRouter", &RegisterRouterExecutor{arg, &ManagerRegisterRouterReply{}}).RunAsync(reply)
+	return NewPersistentTask("RegisterRouter", &RegisterRouterExecutor{arg, &ManagerRegisterRouterReply{}}).RunAsync(reply)
 }
 
 func (m *ManagerRPC) UnregisterRouter(arg ManagerRegisterRouterArg, reply *AsyncReply) error {
-	return NewTask("UnregisterRouter", &UnregisterRouterExecutor{arg, &ManagerRegisterRouterReply{}}).RunAsync(reply)
+	return NewPersistentTask("UnregisterRouter", &UnregisterRouterExecutor{arg, &ManagerRegisterRouterReply{}}).RunAsync(reply)
 }
 
 func (m *ManagerRPC) GetRouter(arg ManagerGetRouterArg, reply *ManagerGetRouterReply) error {
@@ -939,15 +1003,15 @@ func (m *ManagerRPC) ListRouters(arg ManagerListRoutersArg, reply *ManagerListRo
 }
 
 func (m *ManagerRPC) RegisterApp(arg ManagerRegisterAppArg, reply *ManagerRegisterAppReply) error {
-	return NewTask("RegisterApp", &RegisterAppExecutor{arg, reply}).Run()
+	return NewAuditedTask("RegisterApp", &RegisterAppExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) UpdateApp(arg ManagerRegisterAppArg, reply *ManagerRegisterAppReply) error {
-	return NewTask("UpdateApp", &UpdateAppExecutor{arg, reply}).Run()
+	return NewAuditedTask("UpdateApp", &UpdateAppExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) UnregisterApp(arg ManagerRegisterAppArg, reply *ManagerRegisterAppReply) error {
-	return NewTask("UnregisterApp", &UnregisterAppExecutor{arg, reply}).Run()
+	return NewAuditedTask("UnregisterApp", &UnregisterAppExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) GetApp(arg ManagerGetAppArg, reply *ManagerGetAppReply) error {
@@ -959,15 +1023,15 @@ func (m *ManagerRPC) ListRegisteredApps(arg ManagerListRegisteredAppsArg, reply
 }
 
 func (m *ManagerRPC) ListAuthorizedRegisteredApps(arg ManagerListRegisteredAppsArg, reply *ManagerListRegisteredAppsReply) error {
-	return NewTask("ListAuthorizedRegisteredApps", &ListRegisteredAppsExecutor{arg, reply}).Run()
+	return NewTask("ListAuthorizedRegisteredApps", &ListAuthorizedRegisteredAppsExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) RegisterSupervisor(arg ManagerRegisterSupervisorArg, reply *AsyncReply) error {
-	return NewTask("RegisterSupervisor", &RegisterSupervisorExecutor{arg, &ManagerRegisterSupervisorReply{}}).RunAsync(reply)
+	return NewPersistentTask("RegisterSupervisor", &RegisterSupervisorExecutor{arg, &ManagerRegisterSupervisorReply{}}).RunAsync(reply)
 }
 
 func (m *ManagerRPC) UnregisterSupervisor(arg ManagerRegisterSupervisorArg, reply *AsyncReply) error {
-	return NewTask("UnregisterSupervisor", &UnregisterSupervisorExecutor{arg, &ManagerRegisterSupervisorReply{}}).RunAsync(reply)
+	return NewPersistentTask("UnregisterSupervisor", &UnregisterSupervisorExecutor{arg, &ManagerRegisterSupervisorReply{}}).RunAsync(reply)
 }
 
 func (m *ManagerRPC) ListSupervisors(arg ManagerListSupervisorsArg, reply *ManagerListSupervisorsReply) error {
@@ -975,11 +1039,11 @@ func (m *ManagerRPC) ListSupervisors(arg ManagerListSupervisorsArg, reply *Manag
 }
 
 func (m *ManagerRPC) RegisterManager(arg ManagerRegisterManagerArg, reply *AsyncReply) error {
-	return NewTask("RegisterManager", &RegisterManagerExecutor{arg, &ManagerRegisterManagerReply{}}).RunAsync(reply)
+	return NewPersistentTask("RegisterManager", &RegisterManagerExecutor{arg, &ManagerRegisterManagerReply{}}).RunAsync(reply)
 }
 
 func (m *ManagerRPC) UnregisterManager(arg ManagerRegisterManagerArg, reply *AsyncReply) error {
-	return NewTask("UnregisterManager", &UnregisterManagerExecutor{arg, &ManagerRegisterManagerReply{}}).RunAsync(reply)
+	return NewPersistentTask("UnregisterManager", &UnregisterManagerExecutor{arg, &ManagerRegisterManagerReply{}}).RunAsync(reply)
 }
 
 func (m *ManagerRPC) ListManagers(arg ManagerListManagersArg, reply *ManagerListManagersReply) error {
@@ -20,7 +20,6 @@ import (
 	. "atlantis/manager/rpc/types"
 	routercfg "atlantis/router/config"
 	routerzk "atlantis/router/zk"
-	"errors"
 	"fmt"
 	"sort"
 	"strconv"
@@ -37,7 +36,7 @@ type GetAppEnvPortExecutor struct {
 }
 
 func (e *GetAppEnvPortExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *GetAppEnvPortExecutor) Result() interface{} {
@@ -50,9 +49,9 @@ func (e *GetAppEnvPortExecutor) Description() string {
 
 func (e *GetAppEnvPortExecutor) Execute(t *Task) (err error) {
 	if e.arg.App == "" {
-		return errors.New("Please specify an app")
+		return errorOf(ErrInvalid, "Please specify an app")
 	} else if e.arg.Env == "" {
-		return errors.New("Please specify an environment")
+		return errorOf(ErrInvalid, "Please specify an environment")
 	}
 	zkApp, err := datamodel.GetApp(e.arg.App)
 	if err != nil {
@@ -93,7 +92,7 @@ type ListAppEnvsWithPortExecutor struct {
 }
 
 func (e *ListAppEnvsWithPortExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListAppEnvsWithPortExecutor) Result() interface{} {
@@ -124,7 +123,7 @@ type UpdatePortExecutor struct {
 }
 
 func (e *UpdatePortExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *UpdatePortExecutor) Result() interface{} {
@@ -137,10 +136,10 @@ func (e *UpdatePortExecutor) Description() string {
 
 func (e *UpdatePortExecutor) Execute(t *Task) (err error) {
 	if e.arg.Port.Trie == "" {
-		return errors.New("Please specify a trie")
+		return errorOf(ErrInvalid, "Please specify a trie")
 	}
 	if e.arg.Port.Port == uint16(0) {
-		return errors.New("Please specify a port")
+		return errorOf(ErrInvalid, "Please specify a port")
 	}
 	helper.SetRouterRoot(e.arg.Port.Internal)
 	return routerzk.SetPort(datamodel.Zk.Conn, e.arg.Port)
@@ -156,7 +155,7 @@ type DeletePortExecutor struct {
 }
 
 func (e *DeletePortExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *DeletePortExecutor) Result() interface{} {
@@ -169,7 +168,7 @@ func (e *DeletePortExecutor) Description() string {
 
 func (e *DeletePortExecutor) Execute(t *Task) (err error) {
 	if e.arg.Port == 0 {
-		return errors.New("Please specify a port")
+		return errorOf(ErrInvalid, "Please specify a port")
 	}
 	helper.SetRouterRoot(e.arg.Internal)
 	err = routerzk.DelPort(datamodel.Zk.Conn, e.arg.Port)
@@ -186,7 +185,7 @@ type GetPortExecutor struct {
 }
 
 func (e *GetPortExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *GetPortExecutor) Result() interface{} {
@@ -199,7 +198,7 @@ func (e *GetPortExecutor) Description() string {
 
 func (e *GetPortExecutor) Execute(t *Task) (err error) {
 	if e.arg.Port == 0 {
-		return errors.New("Please specify a port")
+		return errorOf(ErrInvalid, "Please specify a port")
 	}
 	helper.SetRouterRoot(e.arg.Internal)
 	e.reply.Port, err = routerzk.GetPort(datamodel.Zk.Conn, e.arg.Port)
@@ -216,7 +215,7 @@ type ListPortsExecutor struct {
 }
 
 func (e *ListPortsExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListPortsExecutor) Result() interface{} {
@@ -261,11 +260,11 @@ func (m *ManagerRPC) ListAppEnvsWithPort(arg ManagerListAppEnvsWithPortArg, repl
 }
 
 func (m *ManagerRPC) UpdatePort(arg ManagerUpdatePortArg, reply *ManagerUpdatePortReply) error {
-	return NewTask("UpdatePort", &UpdatePortExecutor{arg, reply}).Run()
+	return NewAuditedTask("UpdatePort", &UpdatePortExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) DeletePort(arg ManagerDeletePortArg, reply *ManagerDeletePortReply) error {
-	return NewTask("DeletePort", &DeletePortExecutor{arg, reply}).Run()
+	return NewAuditedTask("DeletePort", &DeletePortExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) GetPort(arg ManagerGetPortArg, reply *ManagerGetPortReply) error {
@@ -286,7 +285,7 @@ type UpdatePoolExecutor struct {
 }
 
 func (e *UpdatePoolExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *UpdatePoolExecutor) Result() interface{} {
@@ -299,13 +298,13 @@ func (e *UpdatePoolExecutor) Description() string {
 
 func (e *UpdatePoolExecutor) Execute(t *Task) error {
 	if e.arg.Pool.Name == "" {
-		return errors.New("Please specify a name")
+		return errorOf(ErrInvalid, "Please specify a name")
 	} else if e.arg.Pool.Config.HealthzEvery == "" {
-		return errors.New("Please specify a healthz check frequency")
+		return errorOf(ErrInvalid, "Please specify a healthz check frequency")
 	} else if e.arg.Pool.Config.HealthzTimeout == "" {
-		return errors.New("Please specify a healthz timeout")
+		return errorOf(ErrInvalid, "Please specify a healthz timeout")
 	} else if e.arg.Pool.Config.RequestTimeout == "" {
-		return errors.New("Please specify a request timeout")
+		return errorOf(ErrInvalid, "Please specify a request timeout")
 	} // no need to check hosts. an empty pool is still a valid pool
 	helper.SetRouterRoot(e.arg.Pool.Internal)
 	err := routerzk.SetPool(datamodel.Zk.Conn, e.arg.Pool)
@@ -355,7 +354,7 @@ type DeletePoolExecutor struct {
 }
 
 func (e *DeletePoolExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *DeletePoolExecutor) Result() interface{} {
@@ -368,7 +367,7 @@ func (e *DeletePoolExecutor) Description() string {
 
 func (e *DeletePoolExecutor) Execute(t *Task) (err error) {
 	if e.arg.Name == "" {
-		return errors.New("Please specify a name")
+		return errorOf(ErrInvalid, "Please specify a name")
 	}
 	helper.SetRouterRoot(e.arg.Internal)
 	err = routerzk.DelPool(datamodel.Zk.Conn, e.arg.Name)
@@ -390,7 +389,7 @@ type GetPoolExecutor struct {
 }
 
 func (e *GetPoolExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *GetPoolExecutor) Result() interface{} {
@@ -403,7 +402,7 @@ func (e *GetPoolExecutor) Description() string {
 
 func (e *GetPoolExecutor) Execute(t *Task) (err error) {
 	if e.arg.Name == "" {
-		return errors.New("Please specify a name")
+		return errorOf(ErrInvalid, "Please specify a name")
 	}
 	helper.SetRouterRoot(e.arg.Internal)
 	e.reply.Pool, err = routerzk.GetPool(datamodel.Zk.Conn, e.arg.Name)
@@ -425,7 +424,7 @@ type ListPoolsExecutor struct {
 }
 
 func (e *ListPoolsExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListPoolsExecutor) Result() interface{} {
@@ -438,11 +437,13 @@ func (e *ListPoolsExecutor) Description() string {
 
 func (e *ListPoolsExecutor) Execute(t *Task) (err error) {
 	helper.SetRouterRoot(e.arg.Internal)
-	e.reply.Pools, err = routerzk.ListPools(datamodel.Zk.Conn)
+	pools, err := routerzk.ListPools(datamodel.Zk.Conn)
+	if err == nil {
+		e.reply.Pools, e.reply.NextCursor, err = pageList(pools, e.arg.ListOptions, poolLabels)
+	}
 	if err != nil {
 		e.reply.Status = StatusError
 	} else {
-		sort.Strings(e.reply.Pools)
 		e.reply.Status = StatusOk
 	}
 	return err
@@ -453,11 +454,11 @@ func (e *ListPoolsExecutor) Authorize() error {
 }
 
 func (m *ManagerRPC) UpdatePool(arg ManagerUpdatePoolArg, reply *ManagerUpdatePoolReply) error {
-	return NewTask("UpdatePool", &UpdatePoolExecutor{arg, reply}).Run()
+	return NewAuditedTask("UpdatePool", &UpdatePoolExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) DeletePool(arg ManagerDeletePoolArg, reply *ManagerDeletePoolReply) error {
-	return NewTask("DeletePool", &DeletePoolExecutor{arg, reply}).Run()
+	return NewAuditedTask("DeletePool", &DeletePoolExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) GetPool(arg ManagerGetPoolArg, reply *ManagerGetPoolReply) error {
@@ -478,7 +479,7 @@ type UpdateRuleExecutor struct {
 }
 
 func (e *UpdateRuleExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *UpdateRuleExecutor) Result() interface{} {
@@ -491,13 +492,13 @@ func (e *UpdateRuleExecutor) Description() string {
 
 func (e *UpdateRuleExecutor) Execute(t *Task) (err error) {
 	if e.arg.Rule.Name == "" {
-		return errors.New("Please specify a name")
+		return errorOf(ErrInvalid, "Please specify a name")
 	} else if e.arg.Rule.Type == "" {
-		return errors.New("Please specify a type")
+		return errorOf(ErrInvalid, "Please specify a type")
 	} else if e.arg.Rule.Value == "" {
-		return errors.New("Please specify a value")
+		return errorOf(ErrInvalid, "Please specify a value")
 	} else if e.arg.Rule.Next == "" && e.arg.Rule.Pool == "" {
-		return errors.New("Please specify either a next trie or a pool")
+		return errorOf(ErrInvalid, "Please specify either a next trie or a pool")
 	}
 	// fill in current cname suffixes in multi-host rules
 	if e.arg.Rule.Type == "multi-host" && dns.Provider != nil {
@@ -529,7 +530,7 @@ type DeleteRuleExecutor struct {
 }
 
 func (e *DeleteRuleExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *DeleteRuleExecutor) Result() interface{} {
@@ -542,7 +543,7 @@ func (e *DeleteRuleExecutor) Description() string {
 
 func (e *DeleteRuleExecutor) Execute(t *Task) (err error) {
 	if e.arg.Name == "" {
-		return errors.New("Please specify a name")
+		return errorOf(ErrInvalid, "Please specify a name")
 	}
 	helper.SetRouterRoot(e.arg.Internal)
 	err = routerzk.DelRule(datamodel.Zk.Conn, e.arg.Name)
@@ -564,7 +565,7 @@ type GetRuleExecutor struct {
 }
 
 func (e *GetRuleExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *GetRuleExecutor) Result() interface{} {
@@ -577,7 +578,7 @@ func (e *GetRuleExecutor) Description() string {
 
 func (e *GetRuleExecutor) Execute(t *Task) (err error) {
 	if e.arg.Name == "" {
-		return errors.New("Please specify a name")
+		return errorOf(ErrInvalid, "Please specify a name")
 	}
 	helper.SetRouterRoot(e.arg.Internal)
 	e.reply.Rule, err = routerzk.GetRule(datamodel.Zk.Conn, e.arg.Name)
@@ -599,7 +600,7 @@ type ListRulesExecutor struct {
 }
 
 func (e *ListRulesExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListRulesExecutor) Result() interface{} {
@@ -612,11 +613,13 @@ func (e *ListRulesExecutor) Description() string {
 
 func (e *ListRulesExecutor) Execute(t *Task) (err error) {
 	helper.SetRouterRoot(e.arg.Internal)
-	e.reply.Rules, err = routerzk.ListRules(datamodel.Zk.Conn)
+	rules, err := routerzk.ListRules(datamodel.Zk.Conn)
+	if err == nil {
+		e.reply.Rules, e.reply.NextCursor, err = pageList(rules, e.arg.ListOptions, ruleLabels)
+	}
 	if err != nil {
 		e.reply.Status = StatusError
 	} else {
-		sort.Strings(e.reply.Rules)
 		e.reply.Status = StatusOk
 	}
 	return err
@@ -627,11 +630,11 @@ func (e *ListRulesExecutor) Authorize() error {
 }
 
 func (m *ManagerRPC) UpdateRule(arg ManagerUpdateRuleArg, reply *ManagerUpdateRuleReply) error {
-	return NewTask("UpdateRule", &UpdateRuleExecutor{arg, reply}).Run()
+	return NewAuditedTask("UpdateRule", &UpdateRuleExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) DeleteRule(arg ManagerDeleteRuleArg, reply *ManagerDeleteRuleReply) error {
-	return NewTask("DeleteRule", &DeleteRuleExecutor{arg, reply}).Run()
+	return NewAuditedTask("DeleteRule", &DeleteRuleExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) GetRule(arg ManagerGetRuleArg, reply *ManagerGetRuleReply) error {
@@ -652,7 +655,7 @@ type UpdateTrieExecutor struct {
 }
 
 func (e *UpdateTrieExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *UpdateTrieExecutor) Result() interface{} {
@@ -665,7 +668,7 @@ func (e *UpdateTrieExecutor) Description() string {
 
 func (e *UpdateTrieExecutor) Execute(t *Task) (err error) {
 	if e.arg.Trie.Name == "" {
-		return errors.New("Please specify a name")
+		return errorOf(ErrInvalid, "Please specify a name")
 	}
 	helper.SetRouterRoot(e.arg.Trie.Internal)
 	err = routerzk.SetTrie(datamodel.Zk.Conn, e.arg.Trie)
@@ -687,7 +690,7 @@ type DeleteTrieExecutor struct {
 }
 
 func (e *DeleteTrieExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *DeleteTrieExecutor) Result() interface{} {
@@ -700,7 +703,7 @@ func (e *DeleteTrieExecutor) Description() string {
 
 func (e *DeleteTrieExecutor) Execute(t *Task) (err error) {
 	if e.arg.Name == "" {
-		return errors.New("Please specify a name")
+		return errorOf(ErrInvalid, "Please specify a name")
 	}
 	helper.SetRouterRoot(e.arg.Internal)
 	err = routerzk.DelTrie(datamodel.Zk.Conn, e.arg.Name)
@@ -722,7 +725,7 @@ type GetTrieExecutor struct {
 }
 
 func (e *GetTrieExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *GetTrieExecutor) Result() interface{} {
@@ -735,7 +738,7 @@ func (e *GetTrieExecutor) Description() string {
 
 func (e *GetTrieExecutor) Execute(t *Task) (err error) {
 	if e.arg.Name == "" {
-		return errors.New("Please specify a name")
+		return errorOf(ErrInvalid, "Please specify a name")
 	}
 	helper.SetRouterRoot(e.arg.Internal)
 	e.reply.Trie, err = routerzk.GetTrie(datamodel.Zk.Conn, e.arg.Name)
@@ -757,7 +760,7 @@ type ListTriesExecutor struct {
 }
 
 func (e *ListTriesExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *ListTriesExecutor) Result() interface{} {
@@ -785,11 +788,11 @@ func (e *ListTriesExecutor) Authorize() error {
 }
 
 func (m *ManagerRPC) UpdateTrie(arg ManagerUpdateTrieArg, reply *ManagerUpdateTrieReply) error {
-	return NewTask("UpdateTrie", &UpdateTrieExecutor{arg, reply}).Run()
+	return NewAuditedTask("UpdateTrie", &UpdateTrieExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) DeleteTrie(arg ManagerDeleteTrieArg, reply *ManagerDeleteTrieReply) error {
-	return NewTask("DeleteTrie", &DeleteTrieExecutor{arg, reply}).Run()
+	return NewAuditedTask("DeleteTrie", &DeleteTrieExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) GetTrie(arg ManagerGetTrieArg, reply *ManagerGetTrieReply) error {
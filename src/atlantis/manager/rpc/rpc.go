@@ -80,11 +80,7 @@ func Init(listenAddr string, supervisorPort uint16, cpuIncr, memIncr uint, resDu
 	manager := new(ManagerRPC)
 	server = rpc.NewServer()
 	server.Register(manager)
-	config := &tls.Config{}
-	config.InsecureSkipVerify = true
-	config.Certificates = make([]tls.Certificate, 1)
-	config.Certificates[0], err = tls.X509KeyPair(crypto.SERVER_CERT, crypto.SERVER_KEY)
-
+	config = crypto.TLS.Config()
 	l, err = tls.Listen("tcp", lAddr, config)
 	return err
 }
@@ -95,7 +91,14 @@ func Listen() {
 		panic("Not Initialized.")
 	}
 	log.Println("[RPC] Listening on", lAddr)
-	server.Accept(l)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Println("[RPC] accept:", err)
+			return
+		}
+		go server.ServeCodec(newTokenCodec(conn))
+	}
 }
 
 func selfRegister() {
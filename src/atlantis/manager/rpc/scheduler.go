@@ -0,0 +1,454 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/common"
+	. "atlantis/manager/constant"
+	"atlantis/manager/crypto"
+	"atlantis/manager/datamodel"
+	. "atlantis/manager/rpc/types"
+	"log"
+	"sort"
+	"time"
+)
+
+const jobIDSize = 12
+
+// jobTask returns the executor of the task job runs, with auth as its credentials.
+func jobTask(job *ScheduledJob, auth ManagerAuthArg) (TaskExecutor, error) {
+	switch job.Name {
+	case "Deploy":
+		if job.Deploy == nil {
+			return nil, errorOf(ErrInvalid, "Deploy job has no Deploy")
+		}
+		arg := *job.Deploy
+		arg.ManagerAuthArg = auth
+		return &DeployExecutor{arg, &ManagerDeployReply{}}, nil
+	case "Teardown":
+		if job.Teardown == nil {
+			return nil, errorOf(ErrInvalid, "Teardown job has no Teardown")
+		}
+		arg := *job.Teardown
+		arg.ManagerAuthArg = auth
+		return &TeardownExecutor{arg, &ManagerTeardownReply{}}, nil
+	case "DeployContainer":
+		if job.DeployContainer == nil {
+			return nil, errorOf(ErrInvalid, "DeployContainer job has no DeployContainer")
+		}
+		arg := *job.DeployContainer
+		arg.ManagerAuthArg = auth
+		return &DeployContainerExecutor{arg, &ManagerDeployReply{}}, nil
+	default:
+		return nil, errorOf(ErrInvalid, "Can not schedule "+job.Name+", only Deploy, Teardown and DeployContainer")
+	}
+}
+
+// stripCredentials blanks the credentials in the requests of job; they are kept encrypted on their own.
+func stripCredentials(job *ScheduledJob) {
+	if job.Deploy != nil {
+		arg := *job.Deploy
+		arg.ManagerAuthArg = ManagerAuthArg{}
+		job.Deploy = &arg
+	}
+	if job.Teardown != nil {
+		arg := *job.Teardown
+		arg.ManagerAuthArg = ManagerAuthArg{}
+		job.Teardown = &arg
+	}
+	if job.DeployContainer != nil {
+		arg := *job.DeployContainer
+		arg.ManagerAuthArg = ManagerAuthArg{}
+		job.DeployContainer = &arg
+	}
+}
+
+// nextRun returns when job should run next after after, or the zero time if it should not run again.
+func nextRun(job *ScheduledJob, after time.Time) (time.Time, error) {
+	if job.Cron == "" {
+		if job.At.After(after) {
+			return job.At, nil
+		}
+		return time.Time{}, nil
+	}
+	schedule, err := parseCron(job.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Next(after), nil
+}
+
+// authorizeJob checks that auth may manage job: it has to be the user that scheduled it or a superuser.
+func authorizeJob(auth *ManagerAuthArg, id string) (*datamodel.ZkJob, error) {
+	if err := SimpleAuthorize(auth); err != nil {
+		return nil, err
+	}
+	zj, err := datamodel.GetJob(id)
+	if err != nil {
+		return nil, errorOf(ErrNotFound, "No such job "+id)
+	}
+	if zj.User != auth.User {
+		if err := authorizeSuperUser(auth); err != nil {
+			return nil, errorOf(ErrForbidden, "Job "+id+" belongs to "+zj.User)
+		}
+	}
+	return zj, nil
+}
+
+// jobCredentials returns the credentials job runs with. Sessions end long before most jobs are due, so a job never
+// keeps the session it was scheduled with: it gets an API token of its own that may only run its RPC on its app and
+// env, and that acts as the user who scheduled it, so what they may do is checked again on every run. Jobs that were
+// scheduled with an API token run with that token.
+func jobCredentials(job *ScheduledJob, auth *ManagerAuthArg, app, env string) (*ManagerAuthArg, error) {
+	if isToken(auth.Secret) {
+		return &ManagerAuthArg{User: auth.User, Secret: auth.Secret}, nil
+	}
+	token := APIToken{
+		ID:         CreateRandomID(tokenIDSize),
+		Name:       "job " + job.ID,
+		User:       auth.User,
+		Owner:      auth.User,
+		Operations: []string{job.Name},
+		Created:    time.Now(),
+	}
+	if app != "" {
+		token.Apps = []string{app}
+	}
+	if env != "" {
+		token.Envs = []string{env}
+	}
+	zt, secret, err := saveToken(token)
+	if err != nil {
+		return nil, err
+	}
+	job.TokenID = zt.ID
+	return &ManagerAuthArg{User: auth.User, Secret: secret}, nil
+}
+
+// deleteJobToken deletes the API token that was made for job, if any.
+func deleteJobToken(job *ScheduledJob) error {
+	if job.TokenID == "" {
+		return nil
+	}
+	zt, err := datamodel.GetToken(job.TokenID)
+	if err != nil {
+		return nil // already gone
+	}
+	return zt.Delete()
+}
+
+// runJob starts the task of job with the API token it was scheduled with and moves it on to its next run.
+func runJob(zj *datamodel.ZkJob, now time.Time) {
+	zj.LastRun = now
+	zj.LastError = ""
+	zj.LastTaskID = ""
+	auth, err := crypto.DecryptCredentials(zj.Credentials)
+	if err == nil && !isToken(auth.Secret) {
+		// scheduled before jobs had tokens; the session it kept has long ended, so don't keep it any longer
+		zj.Credentials = ""
+		err = errorOf(ErrUnauthenticated, "Job "+zj.ID+" was scheduled with a session rather than an API token, "+
+			"please schedule it again")
+	}
+	var executor TaskExecutor
+	if err == nil {
+		auth.Source = "job " + zj.ID // audit the run as coming from the job, not from where it was scheduled
+		executor, err = jobTask(&zj.ScheduledJob, *auth)
+	}
+	if err == nil {
+		var reply AsyncReply
+		if err = NewPersistentTask(zj.Name, executor).RunAsync(&reply); err == nil {
+			zj.LastTaskID = reply.ID
+		}
+	}
+	if err != nil {
+		zj.LastError = err.Error()
+		log.Printf("[Scheduler] job %s failed to start: %s", zj.ID, err)
+	} else {
+		log.Printf("[Scheduler] job %s started task %s", zj.ID, zj.LastTaskID)
+	}
+	if zj.NextRun, err = nextRun(&zj.ScheduledJob, now); err != nil {
+		zj.NextRun = time.Time{}
+	}
+	if err := zj.Save(); err != nil {
+		log.Printf("[Scheduler] could not save job %s: %s", zj.ID, err)
+	}
+}
+
+// RunDueJobs starts every job that is not paused and due by now.
+func RunDueJobs(now time.Time) {
+	ids, err := datamodel.ListJobs()
+	if err != nil {
+		return
+	}
+	for _, id := range ids {
+		zj, err := datamodel.GetJob(id)
+		if err != nil || zj.Paused || zj.NextRun.IsZero() || zj.NextRun.After(now) {
+			continue
+		}
+		runJob(zj, now)
+	}
+}
+
+// Scheduler runs due jobs every interval on the one manager in the region that holds the scheduler lead.
+func Scheduler(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		for {
+			if datamodel.TakeSchedulerLead(Host) && !Tracker.UnderMaintenance() {
+				RunDueJobs(time.Now())
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+type ScheduleJobExecutor struct {
+	arg   ManagerScheduleJobArg
+	reply *ManagerScheduleJobReply
+}
+
+func (e *ScheduleJobExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *ScheduleJobExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *ScheduleJobExecutor) Description() string {
+	return "[" + e.arg.ManagerAuthArg.User + "] schedule " + e.arg.Name + " at " + e.arg.At + e.arg.Cron
+}
+
+func (e *ScheduleJobExecutor) job() *ScheduledJob {
+	return &ScheduledJob{
+		User:            e.arg.ManagerAuthArg.User,
+		Name:            e.arg.Name,
+		Deploy:          e.arg.Deploy,
+		Teardown:        e.arg.Teardown,
+		DeployContainer: e.arg.DeployContainer,
+		Cron:            e.arg.Cron,
+	}
+}
+
+// The job has to be something the user could do right now.
+func (e *ScheduleJobExecutor) Authorize() error {
+	if err := SimpleAuthorize(&e.arg.ManagerAuthArg); err != nil {
+		return err
+	}
+	executor, err := jobTask(e.job(), e.arg.ManagerAuthArg)
+	if err != nil {
+		return err
+	}
+	if err := executor.Authorize(); err != nil {
+		return err
+	}
+	if e.arg.Name == "Teardown" && e.arg.Teardown.All {
+		return errorOf(ErrInvalid, "Teardowns of every app can not be scheduled")
+	}
+	// deploys only authorize the app once they have the manifest, so check it now
+	switch e.arg.Name {
+	case "Deploy":
+		return AuthorizeApp(&e.arg.ManagerAuthArg, e.arg.Deploy.App)
+	case "DeployContainer":
+		inst, err := datamodel.GetInstance(e.arg.DeployContainer.ContainerID)
+		if err != nil {
+			return errorOf(ErrNotFound, "No such container "+e.arg.DeployContainer.ContainerID)
+		}
+		return AuthorizeApp(&e.arg.ManagerAuthArg, inst.App)
+	}
+	return nil
+}
+
+func (e *ScheduleJobExecutor) Execute(t *Task) error {
+	job := e.job()
+	now := time.Now()
+	if (e.arg.At == "") == (e.arg.Cron == "") {
+		return errorOf(ErrInvalid, "Please specify either At or Cron")
+	}
+	if e.arg.At != "" {
+		at, err := time.Parse(time.RFC3339, e.arg.At)
+		if err != nil {
+			return errorOf(ErrInvalid, "Invalid At "+e.arg.At+", expected a time like 2014-06-01T02:00:00-07:00")
+		}
+		job.At = at
+	}
+	next, err := nextRun(job, now)
+	if err != nil {
+		return err
+	}
+	if next.IsZero() {
+		return errorOf(ErrInvalid, "The job would never run")
+	}
+	executor, err := jobTask(job, e.arg.ManagerAuthArg)
+	if err != nil {
+		return err
+	}
+	_, app, env, _ := taskSubject(executor)
+	stripCredentials(job)
+	job.ID = CreateRandomID(jobIDSize)
+	job.Created = now
+	job.NextRun = next
+	auth, err := jobCredentials(job, &e.arg.ManagerAuthArg, app, env)
+	if err != nil {
+		return err
+	}
+	credentials, err := crypto.EncryptCredentials(auth)
+	if err == nil {
+		zj := &datamodel.ZkJob{ScheduledJob: *job, Credentials: credentials}
+		err = zj.Save()
+	}
+	if err != nil {
+		deleteJobToken(job)
+		return err
+	}
+	t.Log("[RPC][ScheduleJob] %s will run %s next at %s", job.ID, job.Name, job.NextRun)
+	e.reply.Job = job
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) ScheduleJob(arg ManagerScheduleJobArg, reply *ManagerScheduleJobReply) error {
+	return NewAuditedTask("ScheduleJob", &ScheduleJobExecutor{arg, reply}).Run()
+}
+
+type ListJobsExecutor struct {
+	arg   ManagerListJobsArg
+	reply *ManagerListJobsReply
+}
+
+func (e *ListJobsExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *ListJobsExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *ListJobsExecutor) Description() string {
+	return "[" + e.arg.ManagerAuthArg.User + "] list jobs"
+}
+
+func (e *ListJobsExecutor) Authorize() error {
+	return SimpleAuthorize(&e.arg.ManagerAuthArg)
+}
+
+// Superusers see every job, everyone else sees their own.
+func (e *ListJobsExecutor) Execute(t *Task) error {
+	ids, err := datamodel.ListJobs()
+	if err != nil {
+		return err
+	}
+	sort.Strings(ids)
+	superUser := authorizeSuperUser(&e.arg.ManagerAuthArg) == nil
+	e.reply.Jobs = []*ScheduledJob{}
+	for _, id := range ids {
+		zj, err := datamodel.GetJob(id)
+		if err != nil || (!superUser && zj.User != e.arg.ManagerAuthArg.User) {
+			continue
+		}
+		e.reply.Jobs = append(e.reply.Jobs, &zj.ScheduledJob)
+	}
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) ListJobs(arg ManagerListJobsArg, reply *ManagerListJobsReply) error {
+	return NewTask("ListJobs", &ListJobsExecutor{arg, reply}).Run()
+}
+
+type PauseJobExecutor struct {
+	arg   ManagerPauseJobArg
+	reply *ManagerPauseJobReply
+	job   *datamodel.ZkJob
+}
+
+func (e *PauseJobExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *PauseJobExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *PauseJobExecutor) Description() string {
+	if e.arg.Paused {
+		return "[" + e.arg.ManagerAuthArg.User + "] pause job " + e.arg.ID
+	}
+	return "[" + e.arg.ManagerAuthArg.User + "] resume job " + e.arg.ID
+}
+
+func (e *PauseJobExecutor) Authorize() (err error) {
+	e.job, err = authorizeJob(&e.arg.ManagerAuthArg, e.arg.ID)
+	return
+}
+
+func (e *PauseJobExecutor) Execute(t *Task) error {
+	e.job.Paused = e.arg.Paused
+	if !e.job.Paused && e.job.Cron != "" {
+		// don't make up for the runs we skipped while paused
+		next, err := nextRun(&e.job.ScheduledJob, time.Now())
+		if err != nil {
+			return err
+		}
+		e.job.NextRun = next
+	}
+	if err := e.job.Save(); err != nil {
+		return err
+	}
+	e.reply.Job = &e.job.ScheduledJob
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) PauseJob(arg ManagerPauseJobArg, reply *ManagerPauseJobReply) error {
+	return NewAuditedTask("PauseJob", &PauseJobExecutor{arg, reply, nil}).Run()
+}
+
+type DeleteJobExecutor struct {
+	arg   ManagerDeleteJobArg
+	reply *ManagerDeleteJobReply
+	job   *datamodel.ZkJob
+}
+
+func (e *DeleteJobExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *DeleteJobExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *DeleteJobExecutor) Description() string {
+	return "[" + e.arg.ManagerAuthArg.User + "] delete job " + e.arg.ID
+}
+
+func (e *DeleteJobExecutor) Authorize() (err error) {
+	e.job, err = authorizeJob(&e.arg.ManagerAuthArg, e.arg.ID)
+	return
+}
+
+func (e *DeleteJobExecutor) Execute(t *Task) error {
+	if err := e.job.Delete(); err != nil {
+		return err
+	}
+	if err := deleteJobToken(&e.job.ScheduledJob); err != nil {
+		t.AddWarning("Could not delete API token " + e.job.TokenID + " of the job: " + err.Error())
+	}
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) DeleteJob(arg ManagerDeleteJobArg, reply *ManagerDeleteJobReply) error {
+	return NewAuditedTask("DeleteJob", &DeleteJobExecutor{arg, reply, nil}).Run()
+}
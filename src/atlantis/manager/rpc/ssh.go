@@ -16,8 +16,10 @@ import (
 	"atlantis/manager/datamodel"
 	. "atlantis/manager/rpc/types"
 	"atlantis/manager/supervisor"
-	"errors"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
+	"strings"
 )
 
 // NOTE[jigish]: these are simple pass-throughs to supervisor.
@@ -28,7 +30,7 @@ type AuthorizeSSHExecutor struct {
 }
 
 func (e *AuthorizeSSHExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *AuthorizeSSHExecutor) Result() interface{} {
@@ -36,19 +38,34 @@ func (e *AuthorizeSSHExecutor) Result() interface{} {
 }
 
 func (e *AuthorizeSSHExecutor) Description() string {
-	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] %s @ %s : \n%s", e.arg.User, e.arg.ContainerID,
-		e.arg.PublicKey)
+	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] %s @ %s : %s", e.arg.User, e.arg.ContainerID,
+		sshKeyFingerprint(e.arg.PublicKey))
+}
+
+// sshKeyFingerprint returns the SHA256 fingerprint of an authorized_keys line, as ssh-keygen -l prints it, so that
+// the key itself need not be logged.
+func sshKeyFingerprint(key string) string {
+	fields := strings.Fields(key)
+	if len(fields) < 2 {
+		return "invalid key"
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return "invalid key"
+	}
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
 }
 
 func (e *AuthorizeSSHExecutor) Execute(t *Task) error {
 	if e.arg.PublicKey == "" {
-		return errors.New("Please specify an SSH public key.")
+		return errorOf(ErrInvalid, "Please specify an SSH public key.")
 	}
 	if e.arg.ContainerID == "" {
-		return errors.New("Please specify a container id.")
+		return errorOf(ErrInvalid, "Please specify a container id.")
 	}
 	if e.arg.User == "" {
-		return errors.New("Please specify a user.")
+		return errorOf(ErrInvalid, "Please specify a user.")
 	}
 	instance, err := datamodel.GetInstance(e.arg.ContainerID)
 	if err != nil {
@@ -71,7 +88,7 @@ func (e *AuthorizeSSHExecutor) Authorize() error {
 	if err != nil {
 		return err
 	}
-	return AuthorizeApp(&e.arg.ManagerAuthArg, instance.App)
+	return AuthorizeAction(&e.arg.ManagerAuthArg, "ssh", instance.App, instance.Env)
 }
 
 type DeauthorizeSSHExecutor struct {
@@ -80,7 +97,7 @@ type DeauthorizeSSHExecutor struct {
 }
 
 func (e *DeauthorizeSSHExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *DeauthorizeSSHExecutor) Result() interface{} {
@@ -93,10 +110,10 @@ func (e *DeauthorizeSSHExecutor) Description() string {
 
 func (e *DeauthorizeSSHExecutor) Execute(t *Task) error {
 	if e.arg.ContainerID == "" {
-		return errors.New("Please specify a container id.")
+		return errorOf(ErrInvalid, "Please specify a container id.")
 	}
 	if e.arg.User == "" {
-		return errors.New("Please specify a user.")
+		return errorOf(ErrInvalid, "Please specify a user.")
 	}
 	instance, err := datamodel.GetInstance(e.arg.ContainerID)
 	if err != nil {
@@ -113,13 +130,13 @@ func (e *DeauthorizeSSHExecutor) Authorize() error {
 	if err != nil {
 		return err
 	}
-	return AuthorizeApp(&e.arg.ManagerAuthArg, instance.App)
+	return AuthorizeAction(&e.arg.ManagerAuthArg, "ssh", instance.App, instance.Env)
 }
 
 func (m *ManagerRPC) AuthorizeSSH(arg ManagerAuthorizeSSHArg, reply *ManagerAuthorizeSSHReply) error {
-	return NewTask("AuthorizeSSH", &AuthorizeSSHExecutor{arg, reply}).Run()
+	return NewAuditedTask("AuthorizeSSH", &AuthorizeSSHExecutor{arg, reply}).Run()
 }
 
 func (m *ManagerRPC) DeauthorizeSSH(arg ManagerAuthorizeSSHArg, reply *ManagerAuthorizeSSHReply) error {
-	return NewTask("DeauthorizeSSH", &DeauthorizeSSHExecutor{arg, reply}).Run()
+	return NewAuditedTask("DeauthorizeSSH", &DeauthorizeSSHExecutor{arg, reply}).Run()
 }
@@ -13,6 +13,7 @@ package rpc
 
 import (
 	. "atlantis/common"
+	"atlantis/manager/datamodel"
 	. "atlantis/manager/rpc/types"
 	"atlantis/manager/status"
 )
@@ -23,7 +24,7 @@ type UsageExecutor struct {
 }
 
 func (e *UsageExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *UsageExecutor) Result() interface{} {
@@ -37,7 +38,12 @@ func (e *UsageExecutor) Description() string {
 func (e *UsageExecutor) Execute(t *Task) (err error) {
 	e.reply.Usage, err = status.GetUsage()
 	t.Log("[RPC][Usage] -> %+v", e.reply.Usage)
-	return
+	if err != nil {
+		e.reply.Status = StatusError
+		return err
+	}
+	e.reply.Status = StatusOk
+	return nil
 }
 
 func (e *UsageExecutor) Authorize() error {
@@ -45,5 +51,40 @@ func (e *UsageExecutor) Authorize() error {
 }
 
 func (m *ManagerRPC) Usage(arg ManagerUsageArg, reply *ManagerUsageReply) error {
+	if arg.Aggregate {
+		return m.aggregateUsage(arg, reply)
+	}
 	return NewTask("Usage", &UsageExecutor{arg, reply}).Run()
 }
+
+type CacheStatsExecutor struct {
+	arg   ManagerCacheStatsArg
+	reply *ManagerCacheStatsReply
+}
+
+func (e *CacheStatsExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *CacheStatsExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *CacheStatsExecutor) Description() string {
+	return "CacheStats"
+}
+
+func (e *CacheStatsExecutor) Execute(t *Task) error {
+	e.reply.Stats = datamodel.GetCacheStats()
+	e.reply.Status = StatusOk
+	t.Log("[RPC][CacheStats] -> %+v", e.reply.Stats)
+	return nil
+}
+
+func (e *CacheStatsExecutor) Authorize() error {
+	return SimpleAuthorize(&e.arg.ManagerAuthArg)
+}
+
+func (m *ManagerRPC) CacheStats(arg ManagerCacheStatsArg, reply *ManagerCacheStatsReply) error {
+	return NewTask("CacheStats", &CacheStatsExecutor{arg, reply}).Run()
+}
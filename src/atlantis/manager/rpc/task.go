@@ -13,16 +13,362 @@ package rpc
 
 import (
 	. "atlantis/common"
+	. "atlantis/manager/constant"
+	"atlantis/manager/datamodel"
 	. "atlantis/manager/rpc/types"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
+// how often the status of a running task is copied to zookeeper
+var taskPersistInterval = time.Second
+
+// the most lines the record of a task keeps, which has to fit in one zookeeper node
+var maxTaskLogLines = 1000
+
+// taskLogWriter tees the standard logger so that the lines of the tasks it follows are kept in their records as they
+// are logged. Task.Log writes to the standard logger with the ID of the task in brackets in front of the line.
+type taskLogWriter struct {
+	sync.RWMutex
+	out     io.Writer
+	follows map[string]func(line string)
+}
+
+var taskLogs = &taskLogWriter{out: os.Stderr, follows: map[string]func(string){}}
+
+func (w *taskLogWriter) Write(p []byte) (int, error) {
+	w.RLock()
+	if len(w.follows) > 0 {
+		line := strings.TrimSuffix(string(p), "\n")
+		if start := strings.Index(line, "["); start >= 0 {
+			if end := strings.Index(line[start:], "] "); end > 0 {
+				if add := w.follows[line[start+1:start+end]]; add != nil {
+					add(line[start+end+2:])
+				}
+			}
+		}
+	}
+	w.RUnlock()
+	return w.out.Write(p)
+}
+
+// follow calls add with each line task id logs until the returned func is called.
+func (w *taskLogWriter) follow(id string, add func(line string)) func() {
+	w.Lock()
+	w.follows[id] = add
+	w.Unlock()
+	return func() {
+		w.Lock()
+		delete(w.follows, id)
+		w.Unlock()
+	}
+}
+
+// taskRecord is the zookeeper copy of a task that is running. Its lines come in from the logger while the task
+// changes its status and approval, so zt is only touched with the record locked.
+type taskRecord struct {
+	sync.Mutex
+	zt      *datamodel.ZkTask
+	changed chan bool
+	saving  sync.Mutex // keeps saves in the order their copies were taken
+}
+
+func newTaskRecord(zt *datamodel.ZkTask) *taskRecord {
+	return &taskRecord{zt: zt, changed: make(chan bool, 1)}
+}
+
+// addLine appends a line the task logged and has the record saved.
+func (r *taskRecord) addLine(line string) {
+	r.Lock()
+	if n := len(r.zt.Log); n < maxTaskLogLines {
+		r.zt.Log = append(r.zt.Log, line)
+	} else if n == maxTaskLogLines {
+		r.zt.Log = append(r.zt.Log, fmt.Sprintf("... only the first %d lines are kept", maxTaskLogLines))
+	}
+	r.Unlock()
+	select {
+	case r.changed <- true:
+	default: // a save is on its way already
+	}
+}
+
+// update copies the current status and warnings of t into the record. Returns true if anything changed.
+func (r *taskRecord) update(t *Task) bool {
+	t.RLock()
+	status := t.Status
+	warnings := append([]string{}, t.Warnings...)
+	t.RUnlock()
+	r.Lock()
+	defer r.Unlock()
+	changed := len(warnings) != len(r.zt.Warnings)
+	r.zt.Warnings = warnings
+	if status != "" && status != r.zt.Status {
+		r.zt.Status = status
+		changed = true
+	}
+	return changed
+}
+
+func (r *taskRecord) save() {
+	r.saving.Lock()
+	defer r.saving.Unlock()
+	r.Lock()
+	zt := *r.zt
+	zt.Log = append([]string{}, r.zt.Log...)
+	r.Unlock()
+	persistTask(&zt)
+}
+
+// persistentExecutor keeps the task it runs in zookeeper so that any manager can answer Status, ListTaskIDs and
+// *Result for it, even after the manager that ran it is gone. It also waits for approval if it changes a protected
+// env, and then waits its turn in the task queue.
+type persistentExecutor struct {
+	TaskExecutor
+	name string
+}
+
+// NewPersistentTask creates a task that is kept in zookeeper while it runs and for a while after. Use it for async
+// tasks. Async tasks all change something, so they are written to the audit log too.
+func NewPersistentTask(name string, e TaskExecutor) *Task {
+	return NewAuditedTask(name, &persistentExecutor{e, name})
+}
+
+func (e *persistentExecutor) Execute(t *Task) error {
+	auth, app, env, hotfix := taskSubject(e.TaskExecutor)
+	record := newTaskRecord(&datamodel.ZkTask{
+		ID:          t.ID,
+		Name:        e.name,
+		Description: e.Description(),
+		User:        auth.User,
+		App:         app,
+		Env:         env,
+		Manager:     Host,
+		Status:      StatusInit,
+		Log:         []string{},
+		Warnings:    []string{},
+		StartTime:   time.Now(),
+	})
+	record.save()
+	if err := record.zt.AddToIndex(); err != nil {
+		log.Printf("[RPC][Task] could not index task %s: %s", t.ID, err)
+	}
+	unfollow := taskLogs.follow(t.ID, record.addLine)
+	stop := make(chan bool)
+	stopped := make(chan bool)
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(taskPersistInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-record.changed:
+				record.update(t)
+				record.save()
+			case <-ticker.C:
+				if record.update(t) {
+					record.save()
+				}
+			}
+		}
+	}()
+	err := awaitApproval(t, record, &auth)
+	if err == nil {
+		entry := queueEntry(e.name, auth, app, env, hotfix)
+		if hotfix && !entry.Priority {
+			t.AddWarning("Not allowed to hotfix " + app + " in " + env + ", waiting in line")
+		}
+		leave := runQueue.enter(t, entry)
+		err = e.TaskExecutor.Execute(t)
+		leave()
+	}
+	unfollow()
+	close(stop)
+	<-stopped
+
+	record.update(t)
+	zt := record.zt
+	zt.Done = true
+	zt.EndTime = time.Now()
+	if err != nil {
+		zt.Status = StatusError
+		zt.Error = err.Error()
+	} else {
+		zt.Status = StatusDone
+	}
+	result := e.Result()
+	zt.ResultType = fmt.Sprintf("%T", result)
+	if zt.Result, err = json.Marshal(Redact(result)); err != nil {
+		log.Printf("[RPC][Task] could not save result of task %s: %s", zt.ID, err)
+	}
+	record.save()
+	if zt.Error != "" {
+		return errors.New(zt.Error)
+	}
+	return nil
+}
+
+func persistTask(zt *datamodel.ZkTask) {
+	if err := zt.Save(); err != nil {
+		log.Printf("[RPC][Task] could not save task %s: %s", zt.ID, err)
+	}
+}
+
+func taskStatus(zt *datamodel.ZkTask) *TaskStatus {
+	return &TaskStatus{
+		Name:        zt.Name,
+		Status:      zt.Status,
+		Warnings:    zt.Warnings,
+		Description: zt.Description,
+		Done:        zt.Done,
+	}
+}
+
+// persistedResult fills result from the zookeeper copy of task id, which has to be one of names. It is the fallback
+// for tasks this manager did not run (or no longer remembers).
+func persistedResult(id string, result interface{}, names ...string) error {
+	zt, err := datamodel.GetTask(id)
+	if err != nil {
+		return errorOf(ErrNotFound, "Unknown ID.")
+	}
+	known := false
+	for _, name := range names {
+		known = known || zt.Name == name
+	}
+	if !known {
+		return errorOf(ErrInvalid, "ID is not a "+names[0]+".")
+	}
+	if !zt.Done {
+		return errorOf(ErrConflict, names[0]+" isn't done.")
+	}
+	if zt.Error != "" {
+		return errors.New(zt.Error)
+	}
+	if zt.ResultType != fmt.Sprintf("%T", result) {
+		// this should never happen
+		return errors.New("Invalid Result Type.")
+	}
+	return json.Unmarshal(zt.Result, result)
+}
+
+// InitTasks fails the tasks this manager was running when it went down, makes sure every task is indexed and prunes
+// finished tasks older than retention every hour. From then on what persistent tasks log is kept in their records.
+func InitTasks(retention time.Duration) {
+	log.SetOutput(taskLogs)
+	failed, err := datamodel.FailOrphanedTasks(Host)
+	if err != nil {
+		log.Printf("[RPC][Task] could not fail orphaned tasks: %s", err)
+	} else if len(failed) > 0 {
+		log.Printf("[RPC][Task] failed orphaned tasks %v", failed)
+	}
+	if _, err := datamodel.IndexTasks(); err != nil {
+		log.Printf("[RPC][Task] could not index tasks: %s", err)
+	}
+	go func() {
+		for {
+			if count, err := datamodel.PruneTasks(retention); err != nil {
+				log.Printf("[RPC][Task] could not prune tasks: %s", err)
+			} else if count > 0 {
+				log.Printf("[RPC][Task] pruned %d tasks", count)
+			}
+			time.Sleep(time.Hour)
+		}
+	}()
+}
+
+// how long TaskLog waits for a task to log something before returning nothing
+var taskLogTimeout = 20 * time.Second
+
+// waitTaskLog waits until task id has logged more than since lines or is done, at most for timeout
+func waitTaskLog(id string, since int, timeout time.Duration) (*datamodel.ZkTask, error) {
+	deadline := time.After(timeout)
+	for {
+		zt, watch, err := datamodel.WatchTask(id)
+		if err != nil {
+			// a task we just started may not have saved itself yet
+			if status, _ := Tracker.Status(id); status == nil || status.Status == StatusUnknown {
+				return nil, errorOf(ErrNotFound, "Unknown ID.")
+			}
+			select {
+			case <-time.After(taskPersistInterval):
+				continue
+			case <-deadline:
+				return nil, errors.New("Timed out waiting for task " + id)
+			}
+		}
+		if len(zt.Log) > since || zt.Done {
+			return zt, nil
+		}
+		select {
+		case <-watch:
+		case <-deadline:
+			return zt, nil
+		}
+	}
+}
+
+// authorizeTaskRead checks that auth may read what task zt logged: those who may read its app in its env, or
+// superusers if it has no app. auth must already be authenticated.
+func authorizeTaskRead(auth *ManagerAuthArg, zt *datamodel.ZkTask) error {
+	if zt.App == "" {
+		return authorizeSuperUser(auth)
+	}
+	if allowed, reason := explainAction(auth, "read", zt.App, zt.Env); !allowed {
+		return errorOf(ErrForbidden, "Permission Denied: "+reason)
+	}
+	return nil
+}
+
+// TaskLog returns the lines task arg.ID logged after the first arg.Since. If there are none yet it waits a
+// while for one, so calling it in a loop follows the task as it runs.
+func (m *ManagerRPC) TaskLog(arg ManagerTaskLogArg, reply *ManagerTaskLogReply) error {
+	if arg.ID == "" {
+		return errorOf(ErrInvalid, "ID empty")
+	}
+	if err := SimpleAuthorize(&arg.ManagerAuthArg); err != nil {
+		return err
+	}
+	zt, err := waitTaskLog(arg.ID, arg.Since, taskLogTimeout)
+	if err != nil {
+		return err
+	}
+	if err := authorizeTaskRead(&arg.ManagerAuthArg, zt); err != nil {
+		return err
+	}
+	since := arg.Since
+	if since < 0 || since > len(zt.Log) {
+		since = len(zt.Log)
+	}
+	reply.Lines = zt.Log[since:]
+	reply.Next = len(zt.Log)
+	reply.Status = zt.Status
+	reply.Warnings = zt.Warnings
+	reply.Error = zt.Error
+	reply.Done = zt.Done
+	return nil
+}
+
 func (m *ManagerRPC) Status(id string, status *TaskStatus) error {
 	if id == "" {
-		return errors.New("ID empty")
+		return errorOf(ErrInvalid, "ID empty")
 	}
 	getStatus, getError := Tracker.Status(id)
+	if getStatus == nil || getStatus.Status == StatusUnknown {
+		// not one of ours, maybe another manager ran it
+		if zt, err := datamodel.GetTask(id); err == nil {
+			*status = *taskStatus(zt)
+			return nil
+		}
+	}
 	if getStatus == nil {
 		*status = *TaskStatusUnknown
 	} else {
@@ -31,12 +377,10 @@ func (m *ManagerRPC) Status(id string, status *TaskStatus) error {
 	return getError
 }
 
-func (m *ManagerRPC) ListTaskIDs(arg ManagerAuthArg, ids *[]string) error {
-	if err := SimpleAuthorize(&arg); err != nil {
-		return err
-	}
+// visibleTaskTypes returns the names of the tasks auth may look at. Superusers see them all.
+func visibleTaskTypes(auth *ManagerAuthArg) []string {
 	types := []string{"Deploy", "Teardown"}
-	if AuthorizeSuperUser(&arg) == nil {
+	if AuthorizeSuperUser(auth) == nil {
 		// superuser, return all types
 		types = append(types, []string{
 			"RegisterRouter",
@@ -47,7 +391,224 @@ func (m *ManagerRPC) ListTaskIDs(arg ManagerAuthArg, ids *[]string) error {
 			"UnregisterSupervisor",
 		}...)
 	}
+	return types
+}
+
+func (m *ManagerRPC) ListTaskIDs(arg ManagerAuthArg, ids *[]string) error {
+	if err := SimpleAuthorize(&arg); err != nil {
+		return err
+	}
+	types := visibleTaskTypes(&arg)
 	*ids = Tracker.ListIDs(types)
+	seen := map[string]bool{}
+	for _, id := range *ids {
+		seen[id] = true
+	}
+	// the index knows the name of every task, so none of them have to be read
+	entries, _ := datamodel.ListTaskIndex(time.Time{}, time.Time{})
+	for _, entry := range entries {
+		if !seen[entry.ID] && contains(types, entry.Name) {
+			seen[entry.ID] = true
+			*ids = append(*ids, entry.ID)
+		}
+	}
 	sort.Strings(*ids)
 	return nil
 }
+
+// indexMatches returns true if the task of entry may match arg. It checks everything the index knows.
+func indexMatches(arg *ManagerQueryTasksArg, entry *datamodel.TaskIndexEntry) bool {
+	if arg.TaskUser != "" && entry.User != arg.TaskUser {
+		return false
+	}
+	if len(arg.Names) > 0 && !contains(arg.Names, entry.Name) {
+		return false
+	}
+	if (arg.App != "" && entry.App != arg.App) || (arg.Env != "" && entry.Env != arg.Env) {
+		return false
+	}
+	return (arg.StartedAfter.IsZero() || !entry.StartTime.Before(arg.StartedAfter)) &&
+		(arg.StartedBefore.IsZero() || entry.StartTime.Before(arg.StartedBefore))
+}
+
+// needsRecords returns true if arg asks about something only the task records know
+func needsRecords(arg *ManagerQueryTasksArg) bool {
+	return arg.Status != "" || !arg.FinishedAfter.IsZero() || !arg.FinishedBefore.IsZero()
+}
+
+func taskMatches(arg *ManagerQueryTasksArg, zt *datamodel.ZkTask) bool {
+	if !indexMatches(arg, zt.IndexEntry()) {
+		return false
+	}
+	switch strings.ToLower(arg.Status) {
+	case "":
+	case "running":
+		if zt.Done {
+			return false
+		}
+	case "done":
+		if !zt.Done || zt.Error != "" {
+			return false
+		}
+	case "error":
+		if !zt.Done || zt.Error == "" {
+			return false
+		}
+	default:
+		if zt.Status != arg.Status {
+			return false
+		}
+	}
+	if !arg.FinishedAfter.IsZero() || !arg.FinishedBefore.IsZero() {
+		// only finished tasks finished in a time range
+		if !zt.Done || (!arg.FinishedAfter.IsZero() && zt.EndTime.Before(arg.FinishedAfter)) ||
+			(!arg.FinishedBefore.IsZero() && !zt.EndTime.Before(arg.FinishedBefore)) {
+			return false
+		}
+	}
+	return true
+}
+
+type tasksByStartTime []*datamodel.ZkTask
+
+func (t tasksByStartTime) Len() int      { return len(t) }
+func (t tasksByStartTime) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+func (t tasksByStartTime) Less(i, j int) bool {
+	if t[i].StartTime.Equal(t[j].StartTime) {
+		return t[i].ID < t[j].ID
+	}
+	return t[i].StartTime.After(t[j].StartTime)
+}
+
+type entriesByStartTime []*datamodel.TaskIndexEntry
+
+func (t entriesByStartTime) Len() int      { return len(t) }
+func (t entriesByStartTime) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+func (t entriesByStartTime) Less(i, j int) bool {
+	if t[i].StartTime.Equal(t[j].StartTime) {
+		return t[i].ID < t[j].ID
+	}
+	return t[i].StartTime.After(t[j].StartTime)
+}
+
+func taskQueryLimit(arg *ManagerQueryTasksArg) int {
+	if arg.Limit <= 0 {
+		return DefaultTaskQueryLimit
+	} else if arg.Limit > MaxTaskQueryLimit {
+		return MaxTaskQueryLimit
+	}
+	return arg.Limit
+}
+
+// taskCandidates returns the ids of the tasks in index of the kinds given that may match arg, newest first. If the
+// index knows everything arg asks about, only the page arg asks for is returned along with the number of tasks that
+// match in all; otherwise that number is -1 and the records of the tasks have to be checked.
+func taskCandidates(arg *ManagerQueryTasksArg, index []*datamodel.TaskIndexEntry, kinds []string) ([]string, int) {
+	matching := []*datamodel.TaskIndexEntry{}
+	for _, entry := range index {
+		if contains(kinds, entry.Name) && indexMatches(arg, entry) {
+			matching = append(matching, entry)
+		}
+	}
+	sort.Sort(entriesByStartTime(matching))
+	total := -1
+	if !needsRecords(arg) {
+		total = len(matching)
+		start, end := arg.Offset, arg.Offset+taskQueryLimit(arg)
+		if start < 0 || start > len(matching) {
+			start = len(matching)
+		}
+		if end > len(matching) {
+			end = len(matching)
+		}
+		matching = matching[start:end]
+	}
+	ids := make([]string, len(matching))
+	for i, entry := range matching {
+		ids[i] = entry.ID
+	}
+	return ids, total
+}
+
+// queryTasks returns the page of tasks that match arg, newest first, and the number of tasks that match in all.
+func queryTasks(arg *ManagerQueryTasksArg, tasks []*datamodel.ZkTask) ([]*TaskSummary, int) {
+	matching := []*datamodel.ZkTask{}
+	for _, zt := range tasks {
+		if taskMatches(arg, zt) {
+			matching = append(matching, zt)
+		}
+	}
+	sort.Sort(tasksByStartTime(matching))
+	limit := taskQueryLimit(arg)
+	page := []*TaskSummary{}
+	for i := arg.Offset; i >= 0 && i < len(matching) && len(page) < limit; i++ {
+		zt := matching[i]
+		page = append(page, &TaskSummary{
+			ID:          zt.ID,
+			Name:        zt.Name,
+			Description: zt.Description,
+			User:        zt.User,
+			App:         zt.App,
+			Env:         zt.Env,
+			Manager:     zt.Manager,
+			Status:      zt.Status,
+			Error:       zt.Error,
+			Done:        zt.Done,
+			StartTime:   zt.StartTime,
+			EndTime:     zt.EndTime,
+		})
+	}
+	return page, len(matching)
+}
+
+type QueryTasksExecutor struct {
+	arg   ManagerQueryTasksArg
+	reply *ManagerQueryTasksReply
+}
+
+func (e *QueryTasksExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *QueryTasksExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *QueryTasksExecutor) Description() string {
+	return fmt.Sprintf("["+e.arg.ManagerAuthArg.User+"] user: %s, names: %v, app: %s, env: %s, status: %s",
+		e.arg.TaskUser, e.arg.Names, e.arg.App, e.arg.Env, e.arg.Status)
+}
+
+func (e *QueryTasksExecutor) Authorize() error {
+	return SimpleAuthorize(&e.arg.ManagerAuthArg)
+}
+
+// The index narrows the tasks down by day, kind, user, app and env, so only the tasks that may match are read. When
+// the index has all the answers only the tasks on the page are read.
+func (e *QueryTasksExecutor) Execute(t *Task) error {
+	entries, err := datamodel.ListTaskIndex(e.arg.StartedAfter, e.arg.StartedBefore)
+	if err != nil {
+		return err
+	}
+	ids, total := taskCandidates(&e.arg, entries, visibleTaskTypes(&e.arg.ManagerAuthArg))
+	tasks := []*datamodel.ZkTask{}
+	for _, id := range ids {
+		if zt, err := datamodel.GetTask(id); err == nil {
+			tasks = append(tasks, zt)
+		}
+	}
+	arg := e.arg
+	if total >= 0 {
+		arg.Offset = 0 // tasks is the page already
+	}
+	e.reply.Tasks, e.reply.Total = queryTasks(&arg, tasks)
+	if total >= 0 {
+		e.reply.Total = total
+	}
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) QueryTasks(arg ManagerQueryTasksArg, reply *ManagerQueryTasksReply) error {
+	return NewTask("QueryTasks", &QueryTasksExecutor{arg, reply}).Run()
+}
@@ -0,0 +1,144 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	"atlantis/manager/datamodel"
+	. "atlantis/manager/rpc/types"
+	"bytes"
+	. "github.com/adjust/gocheck"
+	"log"
+	"time"
+)
+
+type TaskQuerySuite struct{}
+
+var _ = Suite(&TaskQuerySuite{})
+
+func queriedIDs(arg ManagerQueryTasksArg, tasks []*datamodel.ZkTask) ([]string, int) {
+	page, total := queryTasks(&arg, tasks)
+	ids := []string{}
+	for _, summary := range page {
+		ids = append(ids, summary.ID)
+	}
+	return ids, total
+}
+
+func (s *TaskQuerySuite) TestQueryTasks(c *C) {
+	start := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	tasks := []*datamodel.ZkTask{
+		{ID: "deploy1", Name: "Deploy", User: "alice", App: "app", Env: "prod", Status: "DONE", Done: true,
+			StartTime: start, EndTime: start.Add(time.Minute)},
+		{ID: "deploy2", Name: "Deploy", User: "bob", App: "app", Env: "staging", Status: "Deploying",
+			StartTime: start.Add(time.Hour)},
+		{ID: "teardown", Name: "Teardown", User: "alice", App: "other", Env: "prod", Status: "ERROR",
+			Error: "boom", Done: true, StartTime: start.Add(2 * time.Hour), EndTime: start.Add(3 * time.Hour)},
+	}
+
+	ids, total := queriedIDs(ManagerQueryTasksArg{}, tasks)
+	c.Assert(ids, DeepEquals, []string{"teardown", "deploy2", "deploy1"})
+	c.Assert(total, Equals, 3)
+
+	ids, _ = queriedIDs(ManagerQueryTasksArg{TaskUser: "alice"}, tasks)
+	c.Assert(ids, DeepEquals, []string{"teardown", "deploy1"})
+	ids, _ = queriedIDs(ManagerQueryTasksArg{Names: []string{"Deploy"}, Env: "prod"}, tasks)
+	c.Assert(ids, DeepEquals, []string{"deploy1"})
+	ids, _ = queriedIDs(ManagerQueryTasksArg{App: "app"}, tasks)
+	c.Assert(ids, DeepEquals, []string{"deploy2", "deploy1"})
+
+	ids, _ = queriedIDs(ManagerQueryTasksArg{Status: "running"}, tasks)
+	c.Assert(ids, DeepEquals, []string{"deploy2"})
+	ids, _ = queriedIDs(ManagerQueryTasksArg{Status: "done"}, tasks)
+	c.Assert(ids, DeepEquals, []string{"deploy1"})
+	ids, _ = queriedIDs(ManagerQueryTasksArg{Status: "error"}, tasks)
+	c.Assert(ids, DeepEquals, []string{"teardown"})
+	ids, _ = queriedIDs(ManagerQueryTasksArg{Status: "Deploying"}, tasks)
+	c.Assert(ids, DeepEquals, []string{"deploy2"})
+
+	ids, _ = queriedIDs(ManagerQueryTasksArg{StartedAfter: start.Add(time.Hour)}, tasks)
+	c.Assert(ids, DeepEquals, []string{"teardown", "deploy2"})
+	ids, _ = queriedIDs(ManagerQueryTasksArg{StartedBefore: start.Add(time.Hour)}, tasks)
+	c.Assert(ids, DeepEquals, []string{"deploy1"})
+	ids, _ = queriedIDs(ManagerQueryTasksArg{FinishedBefore: start.Add(2 * time.Hour)}, tasks)
+	c.Assert(ids, DeepEquals, []string{"deploy1"})
+	ids, _ = queriedIDs(ManagerQueryTasksArg{FinishedAfter: start.Add(2 * time.Hour)}, tasks)
+	c.Assert(ids, DeepEquals, []string{"teardown"})
+
+	ids, total = queriedIDs(ManagerQueryTasksArg{Offset: 1, Limit: 1}, tasks)
+	c.Assert(ids, DeepEquals, []string{"deploy2"})
+	c.Assert(total, Equals, 3)
+	ids, total = queriedIDs(ManagerQueryTasksArg{Offset: 5}, tasks)
+	c.Assert(ids, DeepEquals, []string{})
+	c.Assert(total, Equals, 3)
+}
+
+func (s *TaskQuerySuite) TestTaskLogWriter(c *C) {
+	out := &bytes.Buffer{}
+	writer := &taskLogWriter{out: out, follows: map[string]func(string){}}
+	logger := log.New(writer, "", log.LstdFlags)
+	lines := []string{}
+	unfollow := writer.follow("abc", func(line string) { lines = append(lines, line) })
+	logger.Printf("[abc] [RPC][Deploy] Deploying app1")
+	logger.Printf("[def] [RPC][Deploy] Deploying app2")
+	logger.Printf("[RPC][Task] pruned 3 tasks")
+	logger.Printf("[abc] Done")
+	unfollow()
+	logger.Printf("[abc] after the task")
+	c.Assert(lines, DeepEquals, []string{"[RPC][Deploy] Deploying app1", "Done"})
+	// everything still goes to the log
+	c.Assert(bytes.Count(out.Bytes(), []byte("\n")), Equals, 5)
+}
+
+func (s *TaskQuerySuite) TestTaskRecordLines(c *C) {
+	defer func(max int) { maxTaskLogLines = max }(maxTaskLogLines)
+	maxTaskLogLines = 2
+	record := newTaskRecord(&datamodel.ZkTask{ID: "abc", Log: []string{}})
+	for _, line := range []string{"one", "two", "three", "four"} {
+		record.addLine(line)
+	}
+	c.Assert(record.zt.Log, DeepEquals, []string{"one", "two", "... only the first 2 lines are kept"})
+	select {
+	case <-record.changed:
+	default:
+		c.Fatal("adding a line did not ask for a save")
+	}
+}
+
+func (s *TaskQuerySuite) TestTaskCandidates(c *C) {
+	start := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	index := []*datamodel.TaskIndexEntry{
+		{ID: "deploy1", Name: "Deploy", User: "alice", App: "app", Env: "prod", StartTime: start},
+		{ID: "deploy2", Name: "Deploy", User: "bob", App: "app", Env: "staging", StartTime: start.Add(time.Hour)},
+		{ID: "teardown", Name: "Teardown", User: "alice", App: "app", Env: "prod", StartTime: start.Add(2 * time.Hour)},
+		{ID: "router", Name: "RegisterRouter", User: "root", StartTime: start.Add(3 * time.Hour)},
+	}
+	kinds := []string{"Deploy", "Teardown"}
+	// the index has all the answers, so only the page is read
+	ids, total := taskCandidates(&ManagerQueryTasksArg{}, index, kinds)
+	c.Assert(ids, DeepEquals, []string{"teardown", "deploy2", "deploy1"})
+	c.Assert(total, Equals, 3)
+	ids, total = taskCandidates(&ManagerQueryTasksArg{Limit: 1, Offset: 1}, index, kinds)
+	c.Assert(ids, DeepEquals, []string{"deploy2"})
+	c.Assert(total, Equals, 3)
+	ids, total = taskCandidates(&ManagerQueryTasksArg{Offset: 5}, index, kinds)
+	c.Assert(ids, DeepEquals, []string{})
+	c.Assert(total, Equals, 3)
+	ids, total = taskCandidates(&ManagerQueryTasksArg{TaskUser: "alice", Env: "prod", Limit: 1}, index, kinds)
+	c.Assert(ids, DeepEquals, []string{"teardown"})
+	c.Assert(total, Equals, 2)
+	ids, _ = taskCandidates(&ManagerQueryTasksArg{StartedAfter: start.Add(time.Minute)}, index, kinds)
+	c.Assert(ids, DeepEquals, []string{"teardown", "deploy2"})
+	// the status is only in the records, so every candidate has to be read
+	ids, total = taskCandidates(&ManagerQueryTasksArg{Status: "done", Limit: 1, App: "app"}, index, kinds)
+	c.Assert(ids, DeepEquals, []string{"teardown", "deploy2", "deploy1"})
+	c.Assert(total, Equals, -1)
+}
@@ -0,0 +1,203 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/common"
+	"atlantis/manager/datamodel"
+	aldap "atlantis/manager/ldap"
+	. "atlantis/manager/rpc/types"
+	"github.com/mavricknz/ldap"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------------------------------------------------------------------
+// Team Apps Sync
+// ----------------------------------------------------------------------------------------------------------
+
+// Zookeeper is the source of truth for which teams may deploy which apps (AllowApp/DisallowApp only write there),
+// but teams and their members live in LDAP. The sync copies each team's members and any apps still granted through
+// the LDAP allowed app attribute into zookeeper, and drops teams that no longer exist in LDAP.
+
+type ldapTeam struct {
+	Apps    []string
+	Members []string
+}
+
+// returns the value of the first component of a dn ("uid=jdoe,ou=people" -> "jdoe"). Plain values are returned as
+// is.
+func dnValue(dn string) string {
+	first := strings.SplitN(dn, ",", 2)[0]
+	if parts := strings.SplitN(first, "=", 2); len(parts) == 2 {
+		return parts[1]
+	}
+	return first
+}
+
+func ldapTeamsFromSearch(sr *ldap.SearchResult) map[string]*ldapTeam {
+	teams := map[string]*ldapTeam{}
+	if sr == nil {
+		return teams
+	}
+	for _, entry := range sr.Entries {
+		name := entry.GetAttributeValue(aldap.TeamCommonName)
+		if name == "" {
+			name = dnValue(entry.DN)
+		}
+		if name == "" || contains(aldap.TeamBlackList, name) {
+			continue
+		}
+		team := &ldapTeam{Apps: []string{}, Members: []string{}}
+		for _, app := range entry.GetAttributeValues(aldap.AllowedAppAttr) {
+			team.Apps = append(team.Apps, dnValue(app))
+		}
+		for _, member := range entry.GetAttributeValues(aldap.UsernameAttr) {
+			team.Members = append(team.Members, dnValue(member))
+		}
+		sort.Strings(team.Members)
+		teams[name] = team
+	}
+	return teams
+}
+
+func searchLdapTeams() (map[string]*ldapTeam, error) {
+	filterStr := "(objectClass=" + aldap.TeamClass + ")"
+	searchReq := ldap.NewSimpleSearchRequest(aldap.BaseDomain, 2, filterStr,
+		[]string{aldap.TeamCommonName, aldap.AllowedAppAttr, aldap.UsernameAttr})
+	sr, err := aldap.Search(searchReq)
+	if err != nil {
+		return nil, err
+	}
+	return ldapTeamsFromSearch(sr), nil
+}
+
+// SyncTeamApps reconciles the team apps in zookeeper with LDAP. It returns the team:app grants it added and the
+// teams and team:app grants it removed.
+func SyncTeamApps() (added, removed []string, err error) {
+	added, removed = []string{}, []string{}
+	if aldap.SkipAuthorization || aldap.LdapServer == "" {
+		return
+	}
+	ldapTeams, err := searchLdapTeams()
+	if err != nil {
+		return
+	}
+	registered, err := datamodel.ListRegisteredApps()
+	if err != nil {
+		return
+	}
+	for name, team := range ldapTeams {
+		teamApps := datamodel.GetTeamapps(name)
+		for _, app := range team.Apps {
+			if !contains(registered, app) || teamApps.HasApp(app) {
+				continue
+			}
+			if err = teamApps.AddApp(app); err != nil {
+				return
+			}
+			added = append(added, name+":"+app)
+		}
+		// only write if we'd be changing something
+		if len(teamApps.Apps) > 0 && strings.Join(teamApps.Members, ",") != strings.Join(team.Members, ",") {
+			if err = teamApps.SetMembers(team.Members); err != nil {
+				return
+			}
+		}
+	}
+	zkTeams, err := datamodel.ListTeamapps()
+	if err != nil {
+		return
+	}
+	for _, name := range zkTeams {
+		teamApps := datamodel.GetTeamapps(name)
+		if _, ok := ldapTeams[name]; !ok && len(ldapTeams) > 0 {
+			// an empty search is much more likely to be an LDAP problem than every team being deleted
+			if err = teamApps.Delete(); err != nil {
+				return
+			}
+			removed = append(removed, name)
+			continue
+		}
+		for _, app := range append([]string{}, teamApps.Apps...) {
+			if contains(registered, app) {
+				continue
+			}
+			if err = teamApps.DeleteApp(app); err != nil {
+				return
+			}
+			removed = append(removed, name+":"+app)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return
+}
+
+// TeamAppsSyncer runs SyncTeamApps every interval. Every manager runs it; the sync only writes what differs so
+// managers racing each other converge on the same state.
+func TeamAppsSyncer(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		for {
+			added, removed, err := SyncTeamApps()
+			if err != nil {
+				log.Printf("[TeamAppsSync] error: %s", err)
+			} else if len(added) > 0 || len(removed) > 0 {
+				log.Printf("[TeamAppsSync] added %v, removed %v", added, removed)
+				recordAudit(&AuditEntry{Time: time.Now(), Source: "team apps sync", Action: "SyncTeamApps",
+					Request: redactRequest(map[string][]string{"Added": added, "Removed": removed}), Outcome: auditOk})
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+type SyncTeamAppsExecutor struct {
+	arg   ManagerSyncTeamAppsArg
+	reply *ManagerSyncTeamAppsReply
+}
+
+func (e *SyncTeamAppsExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *SyncTeamAppsExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *SyncTeamAppsExecutor) Description() string {
+	return "[" + e.arg.ManagerAuthArg.User + "] SyncTeamApps"
+}
+
+func (e *SyncTeamAppsExecutor) Execute(t *Task) (err error) {
+	e.reply.Added, e.reply.Removed, err = SyncTeamApps()
+	if err != nil {
+		e.reply.Status = StatusError
+		return err
+	}
+	t.Log("[RPC][SyncTeamApps] added %v, removed %v", e.reply.Added, e.reply.Removed)
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (e *SyncTeamAppsExecutor) Authorize() error {
+	return AuthorizeSuperUser(&e.arg.ManagerAuthArg)
+}
+
+func (m *ManagerRPC) SyncTeamApps(arg ManagerSyncTeamAppsArg, reply *ManagerSyncTeamAppsReply) error {
+	return NewAuditedTask("SyncTeamApps", &SyncTeamAppsExecutor{arg, reply}).Run()
+}
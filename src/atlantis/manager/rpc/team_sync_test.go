@@ -0,0 +1,47 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	aldap "atlantis/manager/ldap"
+	. "github.com/adjust/gocheck"
+	"github.com/mavricknz/ldap"
+)
+
+type TeamSyncSuite struct{}
+
+var _ = Suite(&TeamSyncSuite{})
+
+func (s *TeamSyncSuite) TestDNValue(c *C) {
+	c.Assert(dnValue("uid=jdoe,ou=people,dc=example"), Equals, "jdoe")
+	c.Assert(dnValue("cn=my-app"), Equals, "my-app")
+	c.Assert(dnValue("my-app"), Equals, "my-app")
+}
+
+func (s *TeamSyncSuite) TestLdapTeamsFromSearch(c *C) {
+	aldap.TeamCommonName = "cn"
+	aldap.AllowedAppAttr = "allowedApp"
+	aldap.UsernameAttr = "member"
+	aldap.TeamBlackList = []string{"blacklisted"}
+	name := ldap.EntryAttribute{"cn", []string{"team1"}}
+	apps := ldap.EntryAttribute{"allowedApp", []string{"cn=app1,ou=apps", "app2"}}
+	members := ldap.EntryAttribute{"member", []string{"uid=user2,ou=people", "uid=user1,ou=people"}}
+	team := ldap.Entry{"cn=team1,ou=teams", []*ldap.EntryAttribute{&name, &apps, &members}}
+	blacklisted := ldap.Entry{"cn=blacklisted,ou=teams", []*ldap.EntryAttribute{}}
+	sr := ldap.SearchResult{[]*ldap.Entry{&team, &blacklisted}, []string{}, []ldap.Control{}}
+
+	teams := ldapTeamsFromSearch(&sr)
+	c.Assert(len(teams), Equals, 1)
+	c.Assert(teams["team1"].Apps, DeepEquals, []string{"app1", "app2"})
+	c.Assert(teams["team1"].Members, DeepEquals, []string{"user1", "user2"})
+	c.Assert(ldapTeamsFromSearch(nil), DeepEquals, map[string]*ldapTeam{})
+}
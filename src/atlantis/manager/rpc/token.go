@@ -0,0 +1,332 @@
+/* Copyright 2014 Ooyala, Inc. All rights reserved.
+ *
+ * This file is licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is
+ * distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and limitations under the License.
+ */
+
+package rpc
+
+import (
+	. "atlantis/common"
+	"atlantis/manager/datamodel"
+	aldap "atlantis/manager/ldap"
+	. "atlantis/manager/rpc/types"
+	"crypto/rand"
+	"encoding/hex"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	tokenPrefix     = "atl_"
+	tokenIDSize     = 12
+	tokenSecretSize = 24
+)
+
+// isToken returns true if secret is an API token rather than a login session.
+func isToken(secret string) bool {
+	return strings.HasPrefix(secret, tokenPrefix)
+}
+
+// lookupToken returns the token secret belongs to if it is still valid. Tokens look like atl_<id>_<random hex>.
+func lookupToken(secret string) (*datamodel.ZkToken, error) {
+	if sep := strings.LastIndex(secret, "_"); sep > len(tokenPrefix) {
+		zt, err := datamodel.GetToken(secret[len(tokenPrefix):sep])
+		if err == nil && zt.Valid(secret, time.Now()) {
+			return zt, nil
+		}
+	}
+	return nil, errorOf(ErrUnauthenticated, "Invalid or Revoked API Token")
+}
+
+// authenticateToken checks the token in auth and makes auth act as the token's user.
+func authenticateToken(auth *ManagerAuthArg) error {
+	zt, err := lookupToken(auth.Secret)
+	if err != nil {
+		return err
+	}
+	auth.User = zt.User
+	return nil
+}
+
+// AuthorizeTokenOperation returns an error if secret is an API token that may not call the RPC operation. The
+// operation is "" where it is not known (e.g. the REST API), which only tokens that are not limited to some
+// operations may use.
+func AuthorizeTokenOperation(secret, operation string) error {
+	if !isToken(secret) {
+		return nil
+	}
+	zt, err := lookupToken(secret)
+	if err != nil {
+		return err
+	}
+	if operation == "" && len(zt.Operations) > 0 {
+		return errorOf(ErrForbidden, "API Token "+zt.ID+" is limited to RPCs "+strings.Join(zt.Operations, ", "))
+	}
+	if !zt.AllowsOperation(operation) {
+		return errorOf(ErrForbidden, "API Token "+zt.ID+" may not call "+operation)
+	}
+	return nil
+}
+
+// authorizeTokenApp returns an error if auth uses an API token that is not allowed app.
+func authorizeTokenApp(auth *ManagerAuthArg, app string) error {
+	zt, err := lookupToken(auth.Secret)
+	if err != nil {
+		return err
+	}
+	if !zt.AllowsApp(app) {
+		return errorOf(ErrForbidden, "API Token "+zt.ID+" is not allowed app "+app)
+	}
+	if !tokenUserAllowsApp(zt, app) {
+		return errorOf(ErrForbidden, "API Token "+zt.ID+" is for "+zt.User+", who is no longer allowed app "+app)
+	}
+	return nil
+}
+
+// tokenUserAllowsApp returns true if the user of zt may still deploy app. Tokens only get apps their owner could
+// deploy when they were made, but the owner may have lost the app or left since. Service accounts may do whatever
+// their tokens were given.
+func tokenUserAllowsApp(zt *datamodel.ZkToken, app string) bool {
+	if zt.ServiceAccount {
+		return true
+	}
+	if aldap.LdapServer != "" {
+		if teams, err := aldap.TeamsOf(zt.User); err == nil && contains(teams, aldap.SuperUserGroup) {
+			return true
+		}
+	}
+	if datamodel.IsTeamappMember(zt.User, app) {
+		return true
+	}
+	// the session is not zt.User's, so GetAllowedApps asks LDAP for their teams
+	return GetAllowedApps(&ManagerAuthArg{}, zt.User)[app]
+}
+
+// tokenTeams returns the teams of the user zt acts as. Tokens have no session to keep teams in, so they are looked
+// up for the owner of a personal token, and a service account is in the team of its role if it has one.
+func tokenTeams(zt *datamodel.ZkToken) ([]string, error) {
+	if zt.ServiceAccount {
+		if zt.Role == "" {
+			return []string{}, nil
+		}
+		return []string{zt.Role}, nil
+	}
+	if aldap.LdapServer == "" {
+		return []string{}, nil
+	}
+	return aldap.TeamsOf(zt.User)
+}
+
+func randomHex(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// saveToken makes up a secret for token and saves it. Returns the saved token and its secret.
+func saveToken(token APIToken) (*datamodel.ZkToken, string, error) {
+	random, err := randomHex(tokenSecretSize)
+	if err != nil {
+		return nil, "", err
+	}
+	secret := tokenPrefix + token.ID + "_" + random
+	zt := &datamodel.ZkToken{APIToken: token, Hash: datamodel.HashTokenSecret(secret)}
+	if err := zt.Save(); err != nil {
+		return nil, "", err
+	}
+	return zt, secret, nil
+}
+
+// authorizeToken returns the token id if auth owns it or is a superuser.
+func authorizeToken(auth *ManagerAuthArg, id string) (*datamodel.ZkToken, error) {
+	if err := SimpleAuthorize(auth); err != nil {
+		return nil, err
+	}
+	zt, err := datamodel.GetToken(id)
+	if err != nil {
+		return nil, errorOf(ErrNotFound, "No such token "+id)
+	}
+	if zt.Owner != auth.User && zt.User != auth.User {
+		if err := authorizeSuperUser(auth); err != nil {
+			return nil, errorOf(ErrForbidden, "Token "+id+" belongs to "+zt.Owner)
+		}
+	}
+	return zt, nil
+}
+
+type CreateTokenExecutor struct {
+	arg   ManagerCreateTokenArg
+	reply *ManagerCreateTokenReply
+}
+
+func (e *CreateTokenExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *CreateTokenExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *CreateTokenExecutor) Description() string {
+	return "[" + e.arg.ManagerAuthArg.User + "] Create Token " + e.arg.Name
+}
+
+// Only what the creator could do themselves can go into a token: a token for a service account or for every app
+// takes a superuser, and tokens can not make more tokens.
+func (e *CreateTokenExecutor) Authorize() error {
+	if isToken(e.arg.ManagerAuthArg.Secret) {
+		return errorOf(ErrForbidden, "API Tokens can not create API Tokens")
+	}
+	if e.arg.ServiceAccount != "" || len(e.arg.Apps) == 0 {
+		return AuthorizeSuperUser(&e.arg.ManagerAuthArg)
+	}
+	for _, app := range e.arg.Apps {
+		if err := AuthorizeApp(&e.arg.ManagerAuthArg, app); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *CreateTokenExecutor) Execute(t *Task) error {
+	if e.arg.Name == "" {
+		return errorOf(ErrInvalid, "Please specify a name")
+	}
+	rpcType := reflect.TypeOf(new(ManagerRPC))
+	for _, op := range e.arg.Operations {
+		if _, ok := rpcType.MethodByName(op); !ok {
+			return errorOf(ErrInvalid, "No such RPC "+op)
+		}
+	}
+	now := time.Now()
+	token := APIToken{
+		ID:         CreateRandomID(tokenIDSize),
+		Name:       e.arg.Name,
+		User:       e.arg.ManagerAuthArg.User,
+		Owner:      e.arg.ManagerAuthArg.User,
+		Apps:       e.arg.Apps,
+		Envs:       e.arg.Envs,
+		Operations: e.arg.Operations,
+		Created:    now,
+	}
+	if e.arg.ServiceAccount != "" {
+		token.User = e.arg.ServiceAccount
+		token.ServiceAccount = true
+		token.Role = e.arg.Role
+	} else if e.arg.Role != "" {
+		return errorOf(ErrInvalid, "Only service accounts have a role; your own tokens are in your teams")
+	}
+	if e.arg.TTL != "" {
+		ttl, err := time.ParseDuration(e.arg.TTL)
+		if err != nil {
+			return errorOf(ErrInvalid, "Invalid TTL: "+err.Error())
+		}
+		token.Expires = now.Add(ttl)
+	}
+	zt, secret, err := saveToken(token)
+	if err != nil {
+		return err
+	}
+	t.Log("-> created token %s for %s", token.ID, token.User)
+	e.reply.Token = &zt.APIToken
+	e.reply.Secret = secret
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) CreateToken(arg ManagerCreateTokenArg, reply *ManagerCreateTokenReply) error {
+	return NewAuditedTask("CreateToken", &CreateTokenExecutor{arg, reply}).Run()
+}
+
+type ListTokensExecutor struct {
+	arg   ManagerListTokensArg
+	reply *ManagerListTokensReply
+}
+
+func (e *ListTokensExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *ListTokensExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *ListTokensExecutor) Description() string {
+	return "[" + e.arg.ManagerAuthArg.User + "] List Tokens"
+}
+
+func (e *ListTokensExecutor) Authorize() error {
+	return SimpleAuthorize(&e.arg.ManagerAuthArg)
+}
+
+// superusers see every token, everyone else the tokens they made or act as them
+func (e *ListTokensExecutor) Execute(t *Task) error {
+	ids, err := datamodel.ListTokens()
+	if err != nil {
+		return err
+	}
+	sort.Strings(ids)
+	user := e.arg.ManagerAuthArg.User
+	superUser := authorizeSuperUser(&e.arg.ManagerAuthArg) == nil
+	e.reply.Tokens = []*APIToken{}
+	for _, id := range ids {
+		zt, err := datamodel.GetToken(id)
+		if err != nil || (!superUser && zt.Owner != user && zt.User != user) {
+			continue
+		}
+		e.reply.Tokens = append(e.reply.Tokens, &zt.APIToken)
+	}
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) ListTokens(arg ManagerListTokensArg, reply *ManagerListTokensReply) error {
+	return NewTask("ListTokens", &ListTokensExecutor{arg, reply}).Run()
+}
+
+type RevokeTokenExecutor struct {
+	arg   ManagerRevokeTokenArg
+	reply *ManagerRevokeTokenReply
+	token *datamodel.ZkToken
+}
+
+func (e *RevokeTokenExecutor) Request() interface{} {
+	return Redact(e.arg)
+}
+
+func (e *RevokeTokenExecutor) Result() interface{} {
+	return e.reply
+}
+
+func (e *RevokeTokenExecutor) Description() string {
+	return "[" + e.arg.ManagerAuthArg.User + "] Revoke Token " + e.arg.ID
+}
+
+func (e *RevokeTokenExecutor) Authorize() (err error) {
+	e.token, err = authorizeToken(&e.arg.ManagerAuthArg, e.arg.ID)
+	return
+}
+
+// revoked tokens are kept so that it is still clear who a token was
+func (e *RevokeTokenExecutor) Execute(t *Task) error {
+	e.token.Revoked = true
+	if err := e.token.Save(); err != nil {
+		return err
+	}
+	e.reply.Status = StatusOk
+	return nil
+}
+
+func (m *ManagerRPC) RevokeToken(arg ManagerRevokeTokenArg, reply *ManagerRevokeTokenReply) error {
+	return NewAuditedTask("RevokeToken", &RevokeTokenExecutor{arg, reply, nil}).Run()
+}
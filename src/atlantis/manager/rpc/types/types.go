@@ -14,6 +14,8 @@ package types
 import (
 	"atlantis/router/config"
 	. "atlantis/supervisor/rpc/types"
+	"encoding/json"
+	"time"
 )
 
 type IPGroup struct {
@@ -26,6 +28,7 @@ type App struct {
 	Internal        bool // atlantis apps only
 	Name            string
 	Email           string
+	Team            string `json:",omitempty"` // the team that registered the app
 	Repo            string // atlantis apps only
 	Root            string // atlantis apps only
 	DependerEnvData map[string]*DependerEnvData
@@ -35,8 +38,8 @@ type App struct {
 type DependerEnvData struct {
 	Name          string
 	SecurityGroup map[string][]uint16
-	EncryptedData string
-	DataMap       map[string]interface{} `json:",omitempty"`
+	EncryptedData string                 `redact:"true"`
+	DataMap       map[string]interface{} `json:",omitempty" redact:"true"` // decrypted EncryptedData
 }
 
 type DependerAppData struct {
@@ -120,10 +123,53 @@ type ManagerHealthCheckReply struct {
 // Used to check the usage stats of Manager
 type ManagerUsageArg struct {
 	ManagerAuthArg
+	Aggregate bool
 }
 
 type ManagerUsageReply struct {
-	Usage map[string]*SupervisorUsage
+	Usage        map[string]*SupervisorUsage
+	Regions      map[string]*ManagerUsageReply `json:",omitempty"`
+	RegionErrors map[string]string             `json:",omitempty"`
+	Status       string
+}
+
+// ------------ Cache Stats ------------
+// Used to check how well the datamodel cache is doing
+type CacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Invalidations uint64
+	Entries       int
+	HitRate       float64
+}
+
+type ManagerCacheStatsArg struct {
+	ManagerAuthArg
+}
+
+type ManagerCacheStatsReply struct {
+	Stats  map[string]*CacheStats
+	Status string
+}
+
+// ------------ Rate Limits ------------
+// Used to see the rate limits and who was throttled within the last hour
+type RateLimitStats struct {
+	Key           string // <class>:ip:<address> or <class>:user:<name>
+	Class         string
+	Allowed       uint64
+	Throttled     uint64
+	LastThrottled time.Time
+}
+
+type ManagerRateLimitsArg struct {
+	ManagerAuthArg
+}
+
+type ManagerRateLimitsReply struct {
+	Limits    map[string]string // class -> rate per second/burst
+	Throttled []*RateLimitStats
+	Status    string
 }
 
 // ------------ Register Supervisor ------------
@@ -197,6 +243,7 @@ type ManagerRegisterAppArg struct {
 	Repo        string
 	Root        string
 	Email       string
+	Team        string // owning team, recorded on register only
 }
 
 type ManagerRegisterAppReply struct {
@@ -348,15 +395,27 @@ type ManagerGetDependerEnvDataForDependerAppReply struct {
 	DependerEnvData *DependerEnvData
 }
 
+// ------------ List Options ------------
+// Paging, filtering and sorting of list calls. The zero value asks for the whole list in ascending order.
+type ListOptions struct {
+	Limit  int      // most items to return, 0 for all of them
+	Cursor string   // the NextCursor of the page before, to get the page after it
+	Prefix string   // only items that start with Prefix
+	Labels []string // only items with all of these labels, as key=value
+	Sort   string   // asc (the default) or desc
+}
+
 // ------------ ListRegisteredApps ------------
 // List all apps
 type ManagerListRegisteredAppsArg struct {
 	ManagerAuthArg
+	ListOptions
 }
 
 type ManagerListRegisteredAppsReply struct {
-	Apps   []string
-	Status string
+	Apps       []string
+	NextCursor string `json:",omitempty"` // empty on the last page
+	Status     string
 }
 
 // ------------ Register Router ------------
@@ -460,6 +519,8 @@ type ManagerDeployArg struct {
 	Dev         bool // if true, only install 1 instance in 1 zone
 	SkipBuild   bool
 	Manifest    string
+	LockTimeout string // how long to wait for a conflicting deploy/teardown to finish, e.g. "5m"; fail right away if empty
+	Hotfix      bool   // jump ahead of non-hotfix tasks in the task queue, if a policy allows it
 }
 
 type ManagerDeployReply struct {
@@ -510,6 +571,8 @@ type ManagerTeardownArg struct {
 	Env         string
 	ContainerID string
 	All         bool
+	LockTimeout string // how long to wait for a conflicting deploy/teardown to finish, e.g. "5m"; fail right away if empty
+	Hotfix      bool   // jump ahead of non-hotfix tasks in the task queue, if a policy allows it
 }
 
 type ManagerTeardownReply struct {
@@ -517,66 +580,423 @@ type ManagerTeardownReply struct {
 	Status       string
 }
 
+// ------------ Tasks ------------
+// An async task as kept in zookeeper so that any manager can answer for it, even after a restart
+type TaskRecord struct {
+	ID          string
+	Name        string
+	Description string
+	User        string `json:",omitempty"`
+	App         string `json:",omitempty"`
+	Env         string `json:",omitempty"`
+	Manager     string // host of the manager running the task
+	Status      string
+	Log         []string // every line the task logged
+	Warnings    []string
+	Error       string
+	Done        bool
+	StartTime   time.Time
+	EndTime     time.Time
+	ResultType  string
+	Result      json.RawMessage `json:",omitempty"`
+	Approval    *TaskApproval   `json:",omitempty"` // set if the task changed a protected env
+}
+
+// ------------ Audit ------------
+// Used to find out who changed what, from where, and when
+type AuditEntry struct {
+	ID      string
+	Time    time.Time
+	User    string
+	Source  string // where the request came from
+	Action  string // the RPC, e.g. Teardown
+	App     string `json:",omitempty"`
+	Env     string `json:",omitempty"`
+	TaskID  string `json:",omitempty"`
+	Request string // the request as json, with secrets redacted
+	Outcome string // ok, denied or error
+	Error   string `json:",omitempty"`
+}
+
+type ManagerAuditArg struct {
+	ManagerAuthArg
+	AuditUser string // only entries by this user
+	Action    string
+	App       string
+	Env       string
+	Since     time.Time
+	Until     time.Time
+	Limit     int // 0 for the default
+}
+
+type ManagerAuditReply struct {
+	Entries []*AuditEntry // newest first
+	Status  string
+}
+
+// ------------ Approvals ------------
+// Used to have a second person approve deploys and teardowns of protected envs
+type TaskApproval struct {
+	ID          string // the task waiting for approval
+	Name        string
+	Description string
+	User        string // who asked for the task
+	Owner       string `json:",omitempty"` // who created the API token the task was asked for with, if any
+	App         string `json:",omitempty"`
+	Env         string // the protected env
+	State       string // pending, approved, rejected or expired
+	Approver    string `json:",omitempty"`
+	Requested   time.Time
+	Expires     time.Time
+	Decided     time.Time
+}
+
+type ManagerListApprovalsArg struct {
+	ManagerAuthArg
+}
+
+type ManagerListApprovalsReply struct {
+	Approvals []*TaskApproval
+	Status    string
+}
+
+type ManagerApproveTaskArg struct {
+	ManagerAuthArg
+	ID     string
+	Reject bool
+}
+
+type ManagerApproveTaskReply struct {
+	Approval *TaskApproval
+	Status   string
+}
+
+type ManagerProtectEnvArg struct {
+	ManagerAuthArg
+	Name      string
+	Protected bool
+}
+
+type ManagerProtectEnvReply struct {
+	Status string
+}
+
+// ------------ QueryTasks ------------
+// Used to search the async tasks kept in zookeeper
+type TaskSummary struct {
+	ID          string
+	Name        string
+	Description string
+	User        string
+	App         string
+	Env         string
+	Manager     string
+	Status      string
+	Error       string
+	Done        bool
+	StartTime   time.Time
+	EndTime     time.Time
+}
+
+type ManagerQueryTasksArg struct {
+	ManagerAuthArg
+	TaskUser       string   // only tasks started by this user
+	Names          []string // only tasks of these types, e.g. Deploy
+	App            string
+	Env            string
+	Status         string // running, done, error or an exact status
+	StartedAfter   time.Time
+	StartedBefore  time.Time
+	FinishedAfter  time.Time
+	FinishedBefore time.Time
+	Offset         int
+	Limit          int // 0 for the default page size
+}
+
+type ManagerQueryTasksReply struct {
+	Tasks  []*TaskSummary // newest first
+	Total  int            // number of matching tasks, across all pages
+	Status string
+}
+
+// ------------ TaskLog ------------
+// Used to follow what an async task logs as it happens
+type ManagerTaskLogArg struct {
+	ManagerAuthArg
+	ID    string
+	Since int // number of lines already seen
+}
+
+type ManagerTaskLogReply struct {
+	Lines    []string
+	Next     int // pass back as Since to get the lines after these
+	Status   string
+	Warnings []string
+	Error    string
+	Done     bool
+}
+
+// ------------ TaskQueue ------------
+// Used to inspect the async tasks that are running or waiting for room to run
+type QueuedTask struct {
+	ID       string
+	Name     string
+	App      string `json:",omitempty"`
+	User     string `json:",omitempty"`
+	Priority bool   // superuser and hotfix tasks go ahead of the others
+	Position int    // 1-based place in line; 0 once running
+	Queued   time.Time
+}
+
+type ManagerTaskQueueArg struct {
+	ManagerAuthArg
+}
+
+type ManagerTaskQueueReply struct {
+	Running  []*QueuedTask
+	Waiting  []*QueuedTask
+	Limits   map[string]int // max running tasks per task name
+	AppLimit int            // max running tasks per app
+	Status   string
+}
+
+// ------------ Scheduled Jobs ------------
+// Used to run a deploy or teardown later, either once or on a cron schedule
+type ScheduledJob struct {
+	ID              string
+	User            string                     // the job acts as this user
+	TokenID         string                     // the API token the job runs with; revoking it stops the job
+	Name            string                     // the RPC to run: Deploy, Teardown or DeployContainer
+	Deploy          *ManagerDeployArg          `json:",omitempty"`
+	Teardown        *ManagerTeardownArg        `json:",omitempty"`
+	DeployContainer *ManagerDeployContainerArg `json:",omitempty"`
+	At              time.Time                  // when a one off job runs
+	Cron            string                     // minute hour day-of-month month day-of-week, in the manager's time zone
+	Paused          bool
+	Created         time.Time
+	NextRun         time.Time // zero once a one off job has run
+	LastRun         time.Time
+	LastTaskID      string
+	LastError       string
+}
+
+type ManagerScheduleJobArg struct {
+	ManagerAuthArg
+	Name            string
+	Deploy          *ManagerDeployArg
+	Teardown        *ManagerTeardownArg
+	DeployContainer *ManagerDeployContainerArg
+	At              string // RFC3339, e.g. 2014-06-01T02:00:00-07:00
+	Cron            string // e.g. "0 2 * * *"; set either this or At
+}
+
+type ManagerScheduleJobReply struct {
+	Job    *ScheduledJob
+	Status string
+}
+
+type ManagerListJobsArg struct {
+	ManagerAuthArg
+}
+
+type ManagerListJobsReply struct {
+	Jobs   []*ScheduledJob
+	Status string
+}
+
+type ManagerPauseJobArg struct {
+	ManagerAuthArg
+	ID     string
+	Paused bool // false resumes the job
+}
+
+type ManagerPauseJobReply struct {
+	Job    *ScheduledJob
+	Status string
+}
+
+type ManagerDeleteJobArg struct {
+	ManagerAuthArg
+	ID string
+}
+
+type ManagerDeleteJobReply struct {
+	Status string
+}
+
+// ------------ Locks ------------
+// Used to inspect and release deploy and teardown locks
+type LockInfo struct {
+	Path     string // /app/sha/env, /app/sha, /app or / for everything
+	TaskID   string
+	Owner    string // host of the manager running the task
+	User     string
+	Acquired time.Time
+	Expired  bool // the owning manager is gone
+}
+
+type ManagerListLocksArg struct {
+	ManagerAuthArg
+}
+
+type ManagerListLocksReply struct {
+	Locks  []*LockInfo
+	Status string
+}
+
+type ManagerForceUnlockArg struct {
+	ManagerAuthArg
+	Path string
+}
+
+type ManagerForceUnlockReply struct {
+	Lock   *LockInfo
+	Status string
+}
+
 // ------------ GetContainer ------------
 // Used to get a container
 type ManagerGetContainerArg struct {
 	ManagerAuthArg
 	ContainerID string
+	Aggregate   bool
 }
 
 type ManagerGetContainerReply struct {
-	Container *Container
-	Status    string
+	Container    *Container
+	Status       string
+	Regions      map[string]*ManagerGetContainerReply `json:",omitempty"`
+	RegionErrors map[string]string                    `json:",omitempty"`
 }
 
 // ------------ ListContainers ------------
 // List all containers that are part of the app+sha+env combo
 type ManagerListContainersArg struct {
 	ManagerAuthArg
-	App string
-	Sha string
-	Env string
+	App       string
+	Sha       string
+	Env       string
+	Aggregate bool
+	ListOptions
 }
 
 type ManagerListContainersReply struct {
 	ContainerIDs []string
+	NextCursor   string `json:",omitempty"` // empty on the last page
 	Status       string
+	Regions      map[string]*ManagerListContainersReply `json:",omitempty"`
+	RegionErrors map[string]string                      `json:",omitempty"`
+}
+
+// ------------ QueryContainers ------------
+// List containers using the supervisor, env and team indexes. Empty filters match everything.
+type ManagerQueryContainersArg struct {
+	ManagerAuthArg
+	Supervisor string
+	Env        string
+	Team       string
+}
+
+type ManagerQueryContainersReply struct {
+	ContainerIDs []string
+	Status       string
+}
+
+// ------------ GetContainers ------------
+// Get the details of every container that matches all of the non-empty filters in one call. Details come from
+// zookeeper; Live also asks the supervisors, one call per supervisor.
+type ManagerGetContainersArg struct {
+	ManagerAuthArg
+	App  string
+	Sha  string
+	Env  string
+	Host string
+	Zone string
+	Live bool
+	ListOptions
+}
+
+type ContainerDetails struct {
+	ID          string
+	App         string
+	Sha         string
+	Env         string
+	Host        string
+	Zone        string `json:",omitempty"` // empty for containers deployed before zones were recorded
+	Port        uint16
+	CPUShares   uint
+	MemoryLimit uint // MBytes
+	Maintenance bool
+	Created     time.Time
+	Age         string     `json:",omitempty"` // e.g. 72h3m0s, empty if Created is not known
+	Live        *Container `json:",omitempty"` // what the supervisor says, if Live was asked for
+	LiveError   string     `json:",omitempty"` // why Live is missing
+}
+
+type ManagerGetContainersReply struct {
+	Containers []*ContainerDetails
+	NextCursor string `json:",omitempty"` // empty on the last page
+	Status     string
+}
+
+// ------------ RebuildIndexes ------------
+// Recreate the container indexes from instance data
+type ManagerRebuildIndexesArg struct {
+	ManagerAuthArg
+}
+
+type ManagerRebuildIndexesReply struct {
+	Indexed int
+	Status  string
 }
 
 // ------------ ListEnvs ------------
 // List all envs that are part of the app+sha combo
 type ManagerListEnvsArg struct {
 	ManagerAuthArg
-	App string
-	Sha string
+	App       string
+	Sha       string
+	Aggregate bool
 }
 
 type ManagerListEnvsReply struct {
-	Envs   []string
-	Status string
+	Envs         []string
+	Status       string
+	Regions      map[string]*ManagerListEnvsReply `json:",omitempty"`
+	RegionErrors map[string]string                `json:",omitempty"`
 }
 
 // ------------ ListShas ------------
 // List all shas that are part of the app
 type ManagerListShasArg struct {
 	ManagerAuthArg
-	App string
+	App       string
+	Aggregate bool
+	ListOptions
 }
 
 type ManagerListShasReply struct {
-	Shas   []string
-	Status string
+	Shas         []string
+	NextCursor   string `json:",omitempty"` // empty on the last page
+	Status       string
+	Regions      map[string]*ManagerListShasReply `json:",omitempty"`
+	RegionErrors map[string]string                `json:",omitempty"`
 }
 
 // ------------ ListApps ------------
 // List all apps
 type ManagerListAppsArg struct {
 	ManagerAuthArg
+	Aggregate bool
+	ListOptions
 }
 
 type ManagerListAppsReply struct {
-	Apps   []string
-	Status string
+	Apps         []string
+	NextCursor   string `json:",omitempty"` // empty on the last page
+	Status       string
+	Regions      map[string]*ManagerListAppsReply `json:",omitempty"`
+	RegionErrors map[string]string                `json:",omitempty"`
 }
 
 // ------------ UpdatePool ------------
@@ -620,11 +1040,13 @@ type ManagerGetPoolReply struct {
 type ManagerListPoolsArg struct {
 	ManagerAuthArg
 	Internal bool
+	ListOptions
 }
 
 type ManagerListPoolsReply struct {
-	Pools  []string
-	Status string
+	Pools      []string
+	NextCursor string `json:",omitempty"` // empty on the last page
+	Status     string
 }
 
 // ------------ UpdateRule ------------
@@ -668,11 +1090,13 @@ type ManagerGetRuleReply struct {
 type ManagerListRulesArg struct {
 	ManagerAuthArg
 	Internal bool
+	ListOptions
 }
 
 type ManagerListRulesReply struct {
-	Rules  []string
-	Status string
+	Rules      []string
+	NextCursor string `json:",omitempty"` // empty on the last page
+	Status     string
 }
 
 // ------------ UpdateTrie ------------
@@ -800,8 +1224,8 @@ type ManagerListPortsReply struct {
 // used for LDAP Logins
 type ManagerLoginArg struct {
 	User   string
-	Pass   string
-	Secret string
+	Pass   string `redact:"true"`
+	Secret string `redact:"true"`
 }
 
 func (a *ManagerLoginArg) SetCredentials(user, secret string) {
@@ -813,7 +1237,17 @@ func (a *ManagerLoginArg) SetCredentials(user, secret string) {
 
 type ManagerLoginReply struct {
 	LoggedIn bool
-	Secret   string
+	Secret   string `redact:"true"`
+}
+
+// ------------ Logout -----------
+// used to end a session on every manager in the region
+type ManagerLogoutArg struct {
+	ManagerAuthArg
+}
+
+type ManagerLogoutReply struct {
+	Status string
 }
 
 // ------------ Group ----------
@@ -887,6 +1321,18 @@ type ManagerListTeamAppsReply struct {
 	TeamApps []string
 }
 
+// ------------ SyncTeamApps ----------
+// used to reconcile the team apps in zookeeper with LDAP right away
+type ManagerSyncTeamAppsArg struct {
+	ManagerAuthArg
+}
+
+type ManagerSyncTeamAppsReply struct {
+	Added   []string // team:app grants copied from LDAP
+	Removed []string // teams gone from LDAP and team:app grants for unregistered apps
+	Status  string
+}
+
 // ------------ Team Member----------
 // used for add/removing team members
 type ManagerTeamMemberArg struct {
@@ -1062,12 +1508,126 @@ type ManagerIdleReply struct {
 	Status string
 }
 
+// ------------ Policies ------------
+// Used to limit who may do what to which envs. Once any policy covers an action on an app in an env, only the
+// users and teams those policies grant may do it there; elsewhere app permissions decide as before.
+type PolicyRule struct {
+	ID        string
+	Team      string   `json:",omitempty"` // grants members of this team...
+	User      string   `json:",omitempty"` // ...or this user
+	App       string   // glob, e.g. * or web-*
+	Env       string   // glob, e.g. prod*
+	Actions   []string // deploy, teardown, ssh, maintenance, read and/or hotfix
+	Created   time.Time
+	CreatedBy string
+}
+
+type ManagerAddPolicyArg struct {
+	ManagerAuthArg
+	Team    string
+	User    string
+	App     string
+	Env     string
+	Actions []string
+}
+
+type ManagerAddPolicyReply struct {
+	Policy *PolicyRule
+	Status string
+}
+
+type ManagerRemovePolicyArg struct {
+	ManagerAuthArg
+	ID string
+}
+
+type ManagerRemovePolicyReply struct {
+	Status string
+}
+
+type ManagerListPoliciesArg struct {
+	ManagerAuthArg
+}
+
+type ManagerListPoliciesReply struct {
+	Policies []*PolicyRule
+	Status   string
+}
+
+// Used to ask whether you may do an action and why
+type ManagerCanIArg struct {
+	ManagerAuthArg
+	Action string
+	App    string
+	Env    string
+}
+
+type ManagerCanIReply struct {
+	Allowed bool
+	Reason  string
+	Status  string
+}
+
+// ------------ API Tokens ------------
+// Used to give CI and other automation long-lived credentials. A token is passed as the Secret and acts as User,
+// limited to its Apps, Envs and Operations (RPC names). An empty scope is not limited.
+type APIToken struct {
+	ID             string
+	Name           string
+	User           string // who the token acts as: its owner, or a service account
+	ServiceAccount bool
+	Role           string // the team a service account is in, like the role of a client cert
+	Owner          string // who created the token
+	Apps           []string
+	Envs           []string
+	Operations     []string
+	Created        time.Time
+	Expires        time.Time // zero if the token does not expire
+	Revoked        bool
+}
+
+type ManagerCreateTokenArg struct {
+	ManagerAuthArg
+	Name           string
+	ServiceAccount string // create the token for this service account instead of yourself (superuser only)
+	Role           string // the team the service account is in, e.g. the superuser group
+	Apps           []string
+	Envs           []string
+	Operations     []string
+	TTL            string // e.g. 2160h; the token does not expire if empty
+}
+
+type ManagerCreateTokenReply struct {
+	Token  *APIToken
+	Secret string `redact:"true"` // only ever returned here; the manager keeps a hash
+	Status string
+}
+
+type ManagerListTokensArg struct {
+	ManagerAuthArg
+}
+
+type ManagerListTokensReply struct {
+	Tokens []*APIToken
+	Status string
+}
+
+type ManagerRevokeTokenArg struct {
+	ManagerAuthArg
+	ID string
+}
+
+type ManagerRevokeTokenReply struct {
+	Status string
+}
+
 // ------- Authentication --------
 // Used for authenticating and accessing current user's sessions
 type ManagerAuthArg struct {
 	User     string
-	Password string
-	Secret   string
+	Password string `redact:"true"`
+	Secret   string `redact:"true"`
+	Source   string // where the request came from, set by the manager
 }
 
 func (a *ManagerAuthArg) SetCredentials(user, secret string) {
@@ -22,7 +22,7 @@ type VersionExecutor struct {
 }
 
 func (e *VersionExecutor) Request() interface{} {
-	return e.arg
+	return Redact(e.arg)
 }
 
 func (e *VersionExecutor) Result() interface{} {
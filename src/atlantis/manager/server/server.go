@@ -15,7 +15,9 @@ import (
 	. "atlantis/common"
 	"atlantis/crypto"
 	"atlantis/manager/api"
+	"atlantis/manager/auth"
 	"atlantis/manager/builder"
+	mcrypto "atlantis/manager/crypto"
 	. "atlantis/manager/constant"
 	"atlantis/manager/datamodel"
 	"atlantis/manager/dns"
@@ -78,6 +80,33 @@ type ServerConfig struct {
 	SMTPAddr                   string `toml:"smtp_addr"`
 	SMTPFrom                   string `toml:"smtp_from"`
 	SMTPCC                     string `toml:"smtp_cc"`
+	DisableZkCache             bool   `toml:"disable_zk_cache"`
+	TeamAppsSyncInterval       string `toml:"team_apps_sync_interval"`
+	TaskRetention              string `toml:"task_retention"`
+	TaskLimits                 string `toml:"task_limits"`
+	AppTaskLimit               uint   `toml:"app_task_limit"`
+	SchedulerInterval          string `toml:"scheduler_interval"`
+	SessionStore               string `toml:"session_store"`
+	ApprovalTimeout            string `toml:"approval_timeout"`
+	AuditSink                  string `toml:"audit_sink"`
+	AuditFile                  string `toml:"audit_file"`
+	AuditRetention             string `toml:"audit_retention"`
+	AuthProvider               string `toml:"auth_provider"`
+	AuthFile                   string `toml:"auth_file"`
+	JWTJWKS                    string `toml:"jwt_jwks"`
+	JWTIssuer                  string `toml:"jwt_issuer"`
+	JWTAudience                string `toml:"jwt_audience"`
+	JWTUserClaim               string `toml:"jwt_user_claim"`
+	JWTTeamsClaim              string `toml:"jwt_teams_claim"`
+	JWTSuperUserClaim          string `toml:"jwt_superuser_claim"`
+	LdapPoolSize               uint   `toml:"ldap_pool_size"`
+	LdapCacheTTL               string `toml:"ldap_cache_ttl"`
+	TLSCert                    string `toml:"tls_cert"`
+	TLSKey                     string `toml:"tls_key"`
+	TLSCA                      string `toml:"tls_ca"`
+	TLSClientAuth              string `toml:"tls_client_auth"`
+	TLSClientRoles             string `toml:"tls_client_roles"`
+	RateLimits                 string `toml:"rate_limits"`
 }
 
 type ServerOpts struct {
@@ -107,6 +136,33 @@ type ServerOpts struct {
 	SMTPAddr                   string `long:"smtp-addr"`
 	SMTPFrom                   string `long:"smtp-from"`
 	SMTPCC                     string `long:"smtp-cc"`
+	DisableZkCache             bool   `long:"disable-zk-cache" description:"always read instances, apps, envs and supervisors from zookeeper"`
+	TeamAppsSyncInterval       string `long:"team-apps-sync-interval" description:"the interval to sync team apps with LDAP (0 to disable)"`
+	TaskRetention              string `long:"task-retention" description:"how long to keep finished async tasks in zookeeper"`
+	TaskLimits                 string `long:"task-limits" description:"max async tasks of each type to run at once, e.g. Deploy:10,Teardown:10"`
+	AppTaskLimit               uint   `long:"app-task-limit" description:"max async tasks to run at once for one app (0 for no limit)"`
+	SchedulerInterval          string `long:"scheduler-interval" description:"the interval to check for due scheduled jobs (0 to disable)"`
+	SessionStore               string `long:"session-store" description:"where to keep login sessions: zookeeper (shared by the region) or memory"`
+	ApprovalTimeout            string `long:"approval-timeout" description:"how long a deploy or teardown of a protected env waits to be approved"`
+	AuditSink                  string `long:"audit-sink" description:"where to keep the audit log: zookeeper (shared by the region) or file"`
+	AuditFile                  string `long:"audit-file" description:"the file to append the audit log to if the audit sink is file"`
+	AuditRetention             string `long:"audit-retention" description:"how long to keep the days of the audit log in zookeeper, 0 to keep them forever"`
+	AuthProvider               string `long:"auth-provider" description:"who checks logins: ldap, file (htpasswd) or jwt"`
+	AuthFile                   string `long:"auth-file" description:"the htpasswd file to use if the auth provider is file"`
+	JWTJWKS                    string `long:"jwt-jwks" description:"the JWKS file or URL to verify tokens with if the auth provider is jwt"`
+	JWTIssuer                  string `long:"jwt-issuer" description:"the issuer tokens must have (empty to accept any)"`
+	JWTAudience                string `long:"jwt-audience" description:"the audience tokens must have (empty to accept any)"`
+	JWTUserClaim               string `long:"jwt-user-claim" description:"the token claim with the user name"`
+	JWTTeamsClaim              string `long:"jwt-teams-claim" description:"the token claim with the teams of the user"`
+	JWTSuperUserClaim          string `long:"jwt-superuser-claim" description:"the token claim that is true for superusers (empty to disable)"`
+	LdapPoolSize               uint   `long:"ldap-pool-size" description:"max connections to keep open to LDAP"`
+	LdapCacheTTL               string `long:"ldap-cache-ttl" description:"how long to cache the teams of users (0 to disable)"`
+	TLSCert                    string `long:"tls-cert" description:"the certificate to serve RPC and API with (reloaded on SIGHUP)"`
+	TLSKey                     string `long:"tls-key" description:"the key of the TLS certificate"`
+	TLSCA                      string `long:"tls-ca" description:"the CA bundle to verify client certificates with"`
+	TLSClientAuth              string `long:"tls-client-auth" description:"whether clients present a certificate: none, request or require"`
+	TLSClientRoles             string `long:"tls-client-roles" description:"client certificate CN patterns and their roles, e.g. supervisor-*=supervisor,ci.example.com=ci"`
+	RateLimits                 string `long:"rate-limits" description:"requests per second/burst per user and per IP for each class, e.g. read:50/200,mutate:10/50,login:1/10"`
 }
 
 type ManagerServer struct {
@@ -144,6 +200,23 @@ func New() *ManagerServer {
 			SMTPAddr:                   "",
 			SMTPFrom:                   "",
 			SMTPCC:                     "",
+			TeamAppsSyncInterval:       DefaultTeamAppsSyncInterval,
+			TaskRetention:              DefaultTaskRetention,
+			TaskLimits:                 DefaultTaskLimits,
+			AppTaskLimit:               DefaultAppTaskLimit,
+			SchedulerInterval:          DefaultSchedulerInterval,
+			SessionStore:               DefaultSessionStore,
+			ApprovalTimeout:            DefaultApprovalTimeout,
+			AuditSink:                  DefaultAuditSink,
+			AuditFile:                  DefaultAuditFile,
+			AuditRetention:             DefaultAuditRetention,
+			AuthProvider:               DefaultAuthProvider,
+			JWTUserClaim:               DefaultJWTUserClaim,
+			JWTTeamsClaim:              DefaultJWTTeamsClaim,
+			LdapPoolSize:               DefaultLDAPPoolSize,
+			LdapCacheTTL:               DefaultLDAPCacheTTL,
+			TLSClientAuth:              DefaultTLSClientAuth,
+			RateLimits:                 DefaultRateLimits,
 		},
 	}
 	manager.parser.Parse()
@@ -187,17 +260,50 @@ func (m *ManagerServer) Run(bldr builder.Builder) {
 	datamodel.Init(m.Config.ZookeeperUri)
 	datamodel.MinRouterPort = m.Config.MinRouterPort
 	datamodel.MaxRouterPort = m.Config.MaxRouterPort
+	handleError(ldap.InitSessions(m.Config.SessionStore))
+	auditRetention, err := time.ParseDuration(m.Config.AuditRetention)
+	if err != nil {
+		panic(fmt.Sprintf("Could not parse Audit Retention: %s", err.Error()))
+	}
+	handleError(rpc.InitAudit(m.Config.AuditSink, m.Config.AuditFile, auditRetention))
+	if m.Config.DisableZkCache {
+		datamodel.SetCacheEnabled(false)
+	} else {
+		go datamodel.WarmCache()
+	}
 	resultDuration, err := time.ParseDuration(m.Config.ResultDuration)
 	if err != nil {
 		panic(fmt.Sprintf("Could not parse Result Duration: %s", err.Error()))
 	}
+	taskRetention, err := time.ParseDuration(m.Config.TaskRetention)
+	if err != nil {
+		panic(fmt.Sprintf("Could not parse Task Retention: %s", err.Error()))
+	}
+	approvalTimeout, err := time.ParseDuration(m.Config.ApprovalTimeout)
+	if err != nil {
+		panic(fmt.Sprintf("Could not parse Approval Timeout: %s", err.Error()))
+	}
+	handleError(mcrypto.InitTLS(m.Config.TLSCert, m.Config.TLSKey, m.Config.TLSCA, m.Config.TLSClientAuth))
+	handleError(rpc.InitCertRoles(m.Config.TLSClientRoles))
 	handleError(rpc.Init(m.Config.RpcAddr, m.Config.SupervisorPort, m.Config.CPUSharesIncrement,
 		m.Config.MemoryLimitIncrement, resultDuration))
+	rpc.InitTasks(taskRetention)
+	rpc.InitApprovals(approvalTimeout)
+	handleError(rpc.InitTaskQueue(m.Config.TaskLimits, m.Config.AppTaskLimit))
+	handleError(rpc.InitRateLimits(m.Config.RateLimits))
 	handleError(api.Init(m.Config.ApiAddr))
 	err = m.LDAPInit()
 	if err != nil {
 		log.Fatalln(err)
 	}
+	if err = m.AuthInit(); err != nil {
+		log.Fatalln(err)
+	}
+	ldapCacheTTL, err := time.ParseDuration(m.Config.LdapCacheTTL)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	ldap.InitPool(int(m.Config.LdapPoolSize), ldapCacheTTL)
 	maintenanceCheckInterval, err := time.ParseDuration(m.Config.MaintenanceCheckInterval)
 	if err != nil {
 		log.Fatalln(err)
@@ -206,9 +312,20 @@ func (m *ManagerServer) Run(bldr builder.Builder) {
 	if err != nil {
 		log.Fatalln(err)
 	}
+	teamAppsSyncInterval, err := time.ParseDuration(m.Config.TeamAppsSyncInterval)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	schedulerInterval, err := time.ParseDuration(m.Config.SchedulerInterval)
+	if err != nil {
+		log.Fatalln(err)
+	}
 	MaintenanceChecker(m.Config.MaintenanceFile, maintenanceCheckInterval)
 	rpc.SuperUserOnlyChecker(m.Config.SuperUserOnlyFile, superUserCheckInterval)
+	rpc.TeamAppsSyncer(teamAppsSyncInterval)
+	rpc.Scheduler(schedulerInterval)
 	go signalListener()
+	go reloadListener()
 	go rpc.Listen()
 	api.Listen()
 }
@@ -299,10 +416,91 @@ func (m *ManagerServer) overlayConfig() {
 	if m.Opts.SMTPCC != "" {
 		m.Config.SMTPCC = m.Opts.SMTPCC
 	}
+	if m.Opts.DisableZkCache {
+		m.Config.DisableZkCache = true
+	}
+	if m.Opts.TeamAppsSyncInterval != "" {
+		m.Config.TeamAppsSyncInterval = m.Opts.TeamAppsSyncInterval
+	}
+	if m.Opts.TaskRetention != "" {
+		m.Config.TaskRetention = m.Opts.TaskRetention
+	}
+	if m.Opts.TaskLimits != "" {
+		m.Config.TaskLimits = m.Opts.TaskLimits
+	}
+	if m.Opts.AppTaskLimit != 0 {
+		m.Config.AppTaskLimit = m.Opts.AppTaskLimit
+	}
+	if m.Opts.SchedulerInterval != "" {
+		m.Config.SchedulerInterval = m.Opts.SchedulerInterval
+	}
+	if m.Opts.SessionStore != "" {
+		m.Config.SessionStore = m.Opts.SessionStore
+	}
+	if m.Opts.ApprovalTimeout != "" {
+		m.Config.ApprovalTimeout = m.Opts.ApprovalTimeout
+	}
+	if m.Opts.AuditSink != "" {
+		m.Config.AuditSink = m.Opts.AuditSink
+	}
+	if m.Opts.AuditFile != "" {
+		m.Config.AuditFile = m.Opts.AuditFile
+	}
+	if m.Opts.AuditRetention != "" {
+		m.Config.AuditRetention = m.Opts.AuditRetention
+	}
+	if m.Opts.AuthProvider != "" {
+		m.Config.AuthProvider = m.Opts.AuthProvider
+	}
+	if m.Opts.AuthFile != "" {
+		m.Config.AuthFile = m.Opts.AuthFile
+	}
+	if m.Opts.JWTJWKS != "" {
+		m.Config.JWTJWKS = m.Opts.JWTJWKS
+	}
+	if m.Opts.JWTIssuer != "" {
+		m.Config.JWTIssuer = m.Opts.JWTIssuer
+	}
+	if m.Opts.JWTAudience != "" {
+		m.Config.JWTAudience = m.Opts.JWTAudience
+	}
+	if m.Opts.JWTUserClaim != "" {
+		m.Config.JWTUserClaim = m.Opts.JWTUserClaim
+	}
+	if m.Opts.JWTTeamsClaim != "" {
+		m.Config.JWTTeamsClaim = m.Opts.JWTTeamsClaim
+	}
+	if m.Opts.JWTSuperUserClaim != "" {
+		m.Config.JWTSuperUserClaim = m.Opts.JWTSuperUserClaim
+	}
+	if m.Opts.LdapPoolSize != 0 {
+		m.Config.LdapPoolSize = m.Opts.LdapPoolSize
+	}
+	if m.Opts.LdapCacheTTL != "" {
+		m.Config.LdapCacheTTL = m.Opts.LdapCacheTTL
+	}
+	if m.Opts.TLSCert != "" {
+		m.Config.TLSCert = m.Opts.TLSCert
+	}
+	if m.Opts.TLSKey != "" {
+		m.Config.TLSKey = m.Opts.TLSKey
+	}
+	if m.Opts.TLSCA != "" {
+		m.Config.TLSCA = m.Opts.TLSCA
+	}
+	if m.Opts.TLSClientAuth != "" {
+		m.Config.TLSClientAuth = m.Opts.TLSClientAuth
+	}
+	if m.Opts.TLSClientRoles != "" {
+		m.Config.TLSClientRoles = m.Opts.TLSClientRoles
+	}
+	if m.Opts.RateLimits != "" {
+		m.Config.RateLimits = m.Opts.RateLimits
+	}
 }
 
 func (m *ManagerServer) LDAPInit() error {
-	if m.Config.SkipAuthorization == false {
+	if m.Config.SkipAuthorization == false && m.Config.AuthProvider == "ldap" {
 		if m.Config.LdapUserCommonNamePrefix == "" {
 			return errors.New("Missing in server.toml: ldap_user_common_name_prefix")
 		}
@@ -335,6 +533,38 @@ func (m *ManagerServer) LDAPInit() error {
 	return nil
 }
 
+// AuthInit picks the provider that checks logins. LDAP is still asked about teams and apps whichever it is.
+func (m *ManagerServer) AuthInit() (err error) {
+	switch m.Config.AuthProvider {
+	case "ldap":
+		auth.Provider = auth.LDAPProvider{}
+	case "file":
+		auth.Provider, err = auth.NewFileProvider(m.Config.AuthFile)
+	case "jwt":
+		auth.Provider, err = auth.NewJWTProvider(m.Config.JWTJWKS, m.Config.JWTIssuer, m.Config.JWTAudience,
+			m.Config.JWTUserClaim, m.Config.JWTTeamsClaim, m.Config.JWTSuperUserClaim)
+	default:
+		err = errors.New("Invalid auth provider: " + m.Config.AuthProvider)
+	}
+	if err == nil {
+		log.Printf("Logins are checked by %s", m.Config.AuthProvider)
+	}
+	return err
+}
+
+// reloadListener reads the TLS certificates again on SIGHUP. Open connections are not touched.
+func reloadListener() {
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	for range hupChan {
+		if err := mcrypto.TLS.Reload(); err != nil {
+			log.Println("[SIGHUP] could not reload TLS certificates, keeping the old ones:", err)
+		} else {
+			log.Println("[SIGHUP] reloaded TLS certificates")
+		}
+	}
+}
+
 func signalListener() {
 	// wait for SIGTERM
 	termChan := make(chan os.Signal)